@@ -2,4 +2,17 @@ module github.com/Pablo997/pulsardb
 
 go 1.21
 
-require github.com/gorilla/mux v1.8.1
+require (
+	github.com/golang/snappy v0.0.4
+	github.com/gorilla/mux v1.8.1
+	github.com/gorilla/websocket v1.5.3
+	google.golang.org/grpc v1.65.0
+)
+
+require (
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
+	google.golang.org/protobuf v1.34.1 // indirect
+)
@@ -7,14 +7,22 @@ import (
 
 // Config holds all configuration for PulsarDB
 type Config struct {
-	HTTP    HTTPConfig    `json:"http"`
-	Storage StorageConfig `json:"storage"`
+	HTTP      HTTPConfig      `json:"http"`
+	Storage   StorageConfig   `json:"storage"`
+	Graphite  GraphiteConfig  `json:"graphite"`
+	StatsD    StatsDConfig    `json:"statsd"`
+	OpenTSDB  OpenTSDBConfig  `json:"opentsdb"`
+	MQTT      MQTTConfig      `json:"mqtt"`
+	GRPC      GRPCConfig      `json:"grpc"`
+	Subscribe SubscribeConfig `json:"subscribe"`
+	Query     QueryConfig     `json:"query"`
 }
 
 // HTTPConfig holds HTTP server configuration
 type HTTPConfig struct {
-	Address string `json:"address"`
-	Port    int    `json:"port"`
+	Address       string `json:"address"`
+	Port          int    `json:"port"`
+	MaxBodySizeMB int    `json:"max_body_size_mb"` // decoded binary request bodies
 }
 
 // StorageConfig holds storage engine configuration
@@ -26,6 +34,83 @@ type StorageConfig struct {
 	CompressionOn  bool   `json:"compression_enabled"`
 	WALEnabled     bool   `json:"wal_enabled"`
 	WALPath        string `json:"wal_path"`
+
+	// Series limits; writes that would create a series beyond them are
+	// rejected. 0 disables a limit.
+	MaxSeries          int `json:"max_series"`
+	MaxSeriesPerMetric int `json:"max_series_per_metric"`
+}
+
+// GraphiteConfig holds Graphite plaintext listener configuration
+type GraphiteConfig struct {
+	Enabled      bool     `json:"enabled"`
+	TCPAddress   string   `json:"tcp_address"` // empty disables TCP
+	UDPAddress   string   `json:"udp_address"` // empty disables UDP
+	Separator    string   `json:"separator"`   // joins metric name segments
+	Templates    []string `json:"templates"`
+	BatchSize    int      `json:"batch_size"`
+	BatchTimeout int      `json:"batch_timeout_ms"`
+}
+
+// StatsDConfig holds StatsD UDP listener configuration
+type StatsDConfig struct {
+	Enabled       bool      `json:"enabled"`
+	Address       string    `json:"address"`
+	FlushInterval int       `json:"flush_interval_seconds"`
+	Percentiles   []float64 `json:"percentiles"` // timer percentiles, e.g. 95 -> <name>.p95
+}
+
+// OpenTSDBConfig holds OpenTSDB telnet listener configuration.
+// The HTTP /api/put endpoint is always available.
+type OpenTSDBConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Address   string `json:"address"`
+	BatchSize int    `json:"batch_size"`
+}
+
+// MQTTConfig holds MQTT subscriber configuration
+type MQTTConfig struct {
+	Enabled        bool               `json:"enabled"`
+	Broker         string             `json:"broker"` // host:port, "tcp://" prefix optional
+	ClientID       string             `json:"client_id"`
+	Username       string             `json:"username"`
+	Password       string             `json:"password"`
+	CleanSession   bool               `json:"clean_session"`
+	KeepAlive      int                `json:"keep_alive_seconds"`
+	ReconnectDelay int                `json:"reconnect_delay_seconds"`
+	Subscriptions  []MQTTSubscription `json:"subscriptions"`
+}
+
+// MQTTSubscription maps a topic filter to a payload format
+type MQTTSubscription struct {
+	Topic    string `json:"topic"`    // filter, may use + and #
+	QoS      int    `json:"qos"`      // 0 or 1
+	Format   string `json:"format"`   // "json" or "line"
+	Template string `json:"template"` // topic levels to tags, e.g. "_/site/device/measurement"
+}
+
+// GRPCConfig holds gRPC API server configuration
+type GRPCConfig struct {
+	Enabled          bool   `json:"enabled"`
+	Address          string `json:"address"`
+	MaxMessageSizeMB int    `json:"max_message_size_mb"`
+}
+
+// SubscribeConfig holds live subscription (GET /subscribe) settings
+type SubscribeConfig struct {
+	BufferSize       int    `json:"buffer_size"`   // points queued per subscriber
+	SlowConsumer     string `json:"slow_consumer"` // "drop" or "disconnect"
+	MaxSubscribers   int    `json:"max_subscribers"`
+	HeartbeatSeconds int    `json:"heartbeat_seconds"`
+}
+
+// QueryConfig holds query limits. Zero disables a limit.
+type QueryConfig struct {
+	TimeoutSeconds int   `json:"timeout_seconds"` // includes time spent queued
+	MaxPoints      int64 `json:"max_points"`      // per query
+	MaxBytes       int64 `json:"max_bytes"`       // approximate, per query
+	MaxConcurrent  int   `json:"max_concurrent"`
+	MaxQueued      int   `json:"max_queued"` // queries waiting for a slot
 }
 
 // Load loads configuration from file or returns defaults
@@ -51,8 +136,9 @@ func Load(path string) (*Config, error) {
 func defaultConfig() *Config {
 	return &Config{
 		HTTP: HTTPConfig{
-			Address: "0.0.0.0",
-			Port:    8080,
+			Address:       "0.0.0.0",
+			Port:          8080,
+			MaxBodySizeMB: 32,
 		},
 		Storage: StorageConfig{
 			DataDir:        "./data",
@@ -62,6 +148,54 @@ func defaultConfig() *Config {
 			CompressionOn:  true,
 			WALEnabled:     true,
 			WALPath:        "./data/wal.log",
+
+			MaxSeries:          1000000,
+			MaxSeriesPerMetric: 100000,
+		},
+		Graphite: GraphiteConfig{
+			Enabled:      false,
+			TCPAddress:   ":2003",
+			UDPAddress:   "",
+			Separator:    ".",
+			BatchSize:    1000,
+			BatchTimeout: 1000,
+		},
+		StatsD: StatsDConfig{
+			Enabled:       false,
+			Address:       ":8125",
+			FlushInterval: 10,
+			Percentiles:   []float64{90, 95, 99},
+		},
+		OpenTSDB: OpenTSDBConfig{
+			Enabled:   false,
+			Address:   ":4242",
+			BatchSize: 1000,
+		},
+		MQTT: MQTTConfig{
+			Enabled:        false,
+			Broker:         "localhost:1883",
+			ClientID:       "pulsardb",
+			CleanSession:   false,
+			KeepAlive:      60,
+			ReconnectDelay: 5,
+		},
+		GRPC: GRPCConfig{
+			Enabled:          false,
+			Address:          ":9090",
+			MaxMessageSizeMB: 16,
+		},
+		Subscribe: SubscribeConfig{
+			BufferSize:       1000,
+			SlowConsumer:     "drop",
+			MaxSubscribers:   100,
+			HeartbeatSeconds: 15,
+		},
+		Query: QueryConfig{
+			TimeoutSeconds: 30,
+			MaxPoints:      5000000,
+			MaxBytes:       512 << 20,
+			MaxConcurrent:  16,
+			MaxQueued:      64,
 		},
 	}
 }
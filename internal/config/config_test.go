@@ -20,6 +20,10 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.HTTP.Port != 8080 {
 		t.Errorf("expected port=8080, got %d", cfg.HTTP.Port)
 	}
+
+	if cfg.HTTP.MaxBodySizeMB != 32 {
+		t.Errorf("expected max_body_size_mb=32, got %d", cfg.HTTP.MaxBodySizeMB)
+	}
 	
 	// Check Storage config
 	if cfg.Storage.DataDir != "./data" {
@@ -49,6 +53,14 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.Storage.WALPath != "./data/wal.log" {
 		t.Errorf("expected wal_path=./data/wal.log, got %s", cfg.Storage.WALPath)
 	}
+
+	if cfg.Storage.MaxSeries != 1000000 {
+		t.Errorf("expected max_series=1000000, got %d", cfg.Storage.MaxSeries)
+	}
+
+	if cfg.Storage.MaxSeriesPerMetric != 100000 {
+		t.Errorf("expected max_series_per_metric=100000, got %d", cfg.Storage.MaxSeriesPerMetric)
+	}
 }
 
 func TestLoadNoFile(t *testing.T) {
@@ -189,3 +201,123 @@ func TestLoadPartialConfig(t *testing.T) {
 	}
 }
 
+
+func TestDefaultGraphiteConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.Graphite.Enabled {
+		t.Error("expected graphite listener disabled by default")
+	}
+
+	if cfg.Graphite.TCPAddress != ":2003" {
+		t.Errorf("expected tcp_address=:2003, got %s", cfg.Graphite.TCPAddress)
+	}
+
+	if cfg.Graphite.BatchSize != 1000 {
+		t.Errorf("expected batch_size=1000, got %d", cfg.Graphite.BatchSize)
+	}
+}
+
+func TestDefaultStatsDConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.StatsD.Enabled {
+		t.Error("expected statsd listener disabled by default")
+	}
+
+	if cfg.StatsD.Address != ":8125" {
+		t.Errorf("expected address=:8125, got %s", cfg.StatsD.Address)
+	}
+
+	if cfg.StatsD.FlushInterval != 10 {
+		t.Errorf("expected flush_interval_seconds=10, got %d", cfg.StatsD.FlushInterval)
+	}
+}
+
+func TestDefaultOpenTSDBConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.OpenTSDB.Enabled {
+		t.Error("expected opentsdb telnet listener disabled by default")
+	}
+
+	if cfg.OpenTSDB.Address != ":4242" {
+		t.Errorf("expected address=:4242, got %s", cfg.OpenTSDB.Address)
+	}
+}
+
+func TestDefaultMQTTConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.MQTT.Enabled {
+		t.Error("expected mqtt subscriber disabled by default")
+	}
+
+	if cfg.MQTT.Broker != "localhost:1883" {
+		t.Errorf("expected broker=localhost:1883, got %s", cfg.MQTT.Broker)
+	}
+
+	if cfg.MQTT.CleanSession {
+		t.Error("expected persistent session by default so QoS 1 messages survive reconnects")
+	}
+}
+
+func TestDefaultGRPCConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.GRPC.Enabled {
+		t.Error("expected grpc server disabled by default")
+	}
+
+	if cfg.GRPC.Address != ":9090" {
+		t.Errorf("expected address=:9090, got %s", cfg.GRPC.Address)
+	}
+
+	if cfg.GRPC.MaxMessageSizeMB != 16 {
+		t.Errorf("expected max_message_size_mb=16, got %d", cfg.GRPC.MaxMessageSizeMB)
+	}
+}
+
+func TestDefaultSubscribeConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.Subscribe.BufferSize != 1000 {
+		t.Errorf("expected buffer_size=1000, got %d", cfg.Subscribe.BufferSize)
+	}
+
+	if cfg.Subscribe.SlowConsumer != "drop" {
+		t.Errorf("expected slow_consumer=drop, got %s", cfg.Subscribe.SlowConsumer)
+	}
+
+	if cfg.Subscribe.MaxSubscribers != 100 {
+		t.Errorf("expected max_subscribers=100, got %d", cfg.Subscribe.MaxSubscribers)
+	}
+
+	if cfg.Subscribe.HeartbeatSeconds != 15 {
+		t.Errorf("expected heartbeat_seconds=15, got %d", cfg.Subscribe.HeartbeatSeconds)
+	}
+}
+
+func TestDefaultQueryConfig(t *testing.T) {
+	cfg := defaultConfig()
+
+	if cfg.Query.TimeoutSeconds != 30 {
+		t.Errorf("expected timeout_seconds=30, got %d", cfg.Query.TimeoutSeconds)
+	}
+
+	if cfg.Query.MaxPoints != 5000000 {
+		t.Errorf("expected max_points=5000000, got %d", cfg.Query.MaxPoints)
+	}
+
+	if cfg.Query.MaxBytes != 512<<20 {
+		t.Errorf("expected max_bytes=%d, got %d", 512<<20, cfg.Query.MaxBytes)
+	}
+
+	if cfg.Query.MaxConcurrent != 16 {
+		t.Errorf("expected max_concurrent=16, got %d", cfg.Query.MaxConcurrent)
+	}
+
+	if cfg.Query.MaxQueued != 64 {
+		t.Errorf("expected max_queued=64, got %d", cfg.Query.MaxQueued)
+	}
+}
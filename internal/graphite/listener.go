@@ -0,0 +1,299 @@
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// maxUDPPacket is the largest datagram accepted by the UDP listener
+const maxUDPPacket = 64 * 1024
+
+// BatchWriter persists a batch of data points
+type BatchWriter interface {
+	WriteBatch(points []*storage.DataPoint) error
+}
+
+// Stats holds listener counters
+type Stats struct {
+	PointsReceived int64 // parsed successfully
+	PointsWritten  int64 // persisted by the writer
+	PointsRejected int64 // refused by the series limits
+	ParseErrors    int64
+	WriteErrors    int64 // failed batches
+}
+
+// Listener accepts Graphite plaintext lines over TCP and/or UDP and writes
+// them to storage in batches
+type Listener struct {
+	cfg    *config.GraphiteConfig
+	parser *Parser
+	writer BatchWriter
+
+	tcp net.Listener
+	udp net.PacketConn
+
+	points  chan *storage.DataPoint
+	done    chan struct{}
+	wg      sync.WaitGroup // readers
+	flusher sync.WaitGroup
+
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+
+	stats Stats // accessed via atomic
+}
+
+// NewListener creates a listener. Sockets are opened by Start.
+func NewListener(cfg *config.GraphiteConfig, writer BatchWriter) (*Listener, error) {
+	parser, err := NewParser(cfg.Templates, cfg.Separator)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.TCPAddress == "" && cfg.UDPAddress == "" {
+		return nil, errors.New("graphite: no TCP or UDP address configured")
+	}
+
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	return &Listener{
+		cfg:    cfg,
+		parser: parser,
+		writer: writer,
+		points: make(chan *storage.DataPoint, batchSize),
+		done:   make(chan struct{}),
+		conns:  make(map[net.Conn]struct{}),
+	}, nil
+}
+
+// Start opens the configured sockets and begins accepting data
+func (l *Listener) Start() error {
+	if l.cfg.TCPAddress != "" {
+		ln, err := net.Listen("tcp", l.cfg.TCPAddress)
+		if err != nil {
+			return err
+		}
+		l.tcp = ln
+	}
+
+	if l.cfg.UDPAddress != "" {
+		pc, err := net.ListenPacket("udp", l.cfg.UDPAddress)
+		if err != nil {
+			if l.tcp != nil {
+				l.tcp.Close()
+			}
+			return err
+		}
+		l.udp = pc
+	}
+
+	l.flusher.Add(1)
+	go l.batchLoop()
+
+	if l.tcp != nil {
+		l.wg.Add(1)
+		go l.acceptLoop()
+	}
+	if l.udp != nil {
+		l.wg.Add(1)
+		go l.udpLoop()
+	}
+
+	return nil
+}
+
+// TCPAddr returns the bound TCP address, or nil if TCP is disabled
+func (l *Listener) TCPAddr() net.Addr {
+	if l.tcp == nil {
+		return nil
+	}
+	return l.tcp.Addr()
+}
+
+// UDPAddr returns the bound UDP address, or nil if UDP is disabled
+func (l *Listener) UDPAddr() net.Addr {
+	if l.udp == nil {
+		return nil
+	}
+	return l.udp.LocalAddr()
+}
+
+// Stats returns a snapshot of the listener counters
+func (l *Listener) Stats() Stats {
+	return Stats{
+		PointsReceived: atomic.LoadInt64(&l.stats.PointsReceived),
+		PointsWritten:  atomic.LoadInt64(&l.stats.PointsWritten),
+		PointsRejected: atomic.LoadInt64(&l.stats.PointsRejected),
+		ParseErrors:    atomic.LoadInt64(&l.stats.ParseErrors),
+		WriteErrors:    atomic.LoadInt64(&l.stats.WriteErrors),
+	}
+}
+
+// Close stops accepting data and flushes pending points
+func (l *Listener) Close() error {
+	close(l.done)
+
+	if l.tcp != nil {
+		l.tcp.Close()
+	}
+	if l.udp != nil {
+		l.udp.Close()
+	}
+
+	l.connsMu.Lock()
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.connsMu.Unlock()
+
+	// Readers must stop before the channel is closed
+	l.wg.Wait()
+	close(l.points)
+	l.flusher.Wait()
+
+	return nil
+}
+
+func (l *Listener) acceptLoop() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.tcp.Accept()
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return
+		}
+
+		l.connsMu.Lock()
+		select {
+		case <-l.done:
+			// Close already ran over the connection set
+			l.connsMu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		l.conns[conn] = struct{}{}
+		l.connsMu.Unlock()
+
+		l.wg.Add(1)
+		go l.handleConn(conn)
+	}
+}
+
+func (l *Listener) handleConn(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.connsMu.Lock()
+		delete(l.conns, conn)
+		l.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		l.handleLine(scanner.Text())
+	}
+}
+
+func (l *Listener) udpLoop() {
+	defer l.wg.Done()
+
+	buf := make([]byte, maxUDPPacket)
+	for {
+		n, _, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			l.handleLine(line)
+		}
+	}
+}
+
+func (l *Listener) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+
+	point, err := l.parser.Parse(line)
+	if err != nil {
+		atomic.AddInt64(&l.stats.ParseErrors, 1)
+		return
+	}
+
+	atomic.AddInt64(&l.stats.PointsReceived, 1)
+	l.points <- point
+}
+
+// batchLoop groups points and writes a batch when it is full or when the
+// batch timeout expires
+func (l *Listener) batchLoop() {
+	defer l.flusher.Done()
+
+	batchSize := cap(l.points)
+	timeout := time.Duration(l.cfg.BatchTimeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	ticker := time.NewTicker(timeout)
+	defer ticker.Stop()
+
+	batch := make([]*storage.DataPoint, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		rejected, err := storage.SplitWriteError(l.writer.WriteBatch(batch))
+		if err != nil {
+			atomic.AddInt64(&l.stats.WriteErrors, 1)
+		} else {
+			atomic.AddInt64(&l.stats.PointsWritten, int64(len(batch)-rejected))
+			atomic.AddInt64(&l.stats.PointsRejected, int64(rejected))
+		}
+		batch = make([]*storage.DataPoint, 0, batchSize)
+	}
+
+	for {
+		select {
+		case point, ok := <-l.points:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, point)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package graphite
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// memWriter records written batches
+type memWriter struct {
+	mu      sync.Mutex
+	batches [][]*storage.DataPoint
+}
+
+func (w *memWriter) WriteBatch(points []*storage.DataPoint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches = append(w.batches, points)
+	return nil
+}
+
+func (w *memWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, b := range w.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestListenerTCP(t *testing.T) {
+	writer := &memWriter{}
+	l, err := NewListener(&config.GraphiteConfig{
+		TCPAddress:   "127.0.0.1:0",
+		Templates:    []string{"servers.* .host.measurement*"},
+		BatchSize:    2,
+		BatchTimeout: 20,
+	}, writer)
+	if err != nil {
+		t.Fatalf("NewListener failed: %v", err)
+	}
+	if err := l.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	fmt.Fprintf(conn, "servers.host1.cpu 1 1699267200\n")
+	fmt.Fprintf(conn, "servers.host1.cpu 2 1699267260\n")
+	fmt.Fprintf(conn, "not a valid line\n")
+	fmt.Fprintf(conn, "servers.host2.cpu 3 1699267200\n")
+	conn.Close()
+
+	// Third point is flushed by the batch timeout
+	waitFor(t, func() bool { return writer.count() == 3 })
+
+	stats := l.Stats()
+	if stats.PointsReceived != 3 || stats.ParseErrors != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	first := writer.batches[0][0]
+	if first.Metric != "cpu" || first.Tags["host"] != "host1" {
+		t.Errorf("template not applied: %+v", first)
+	}
+}
+
+func TestListenerUDP(t *testing.T) {
+	writer := &memWriter{}
+	l, err := NewListener(&config.GraphiteConfig{
+		UDPAddress:   "127.0.0.1:0",
+		BatchSize:    100,
+		BatchTimeout: 20,
+	}, writer)
+	if err != nil {
+		t.Fatalf("NewListener failed: %v", err)
+	}
+	if err := l.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("udp", l.UDPAddr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("pump.pressure 3.2 1699267200\npump.flow 10 1699267200\n"))
+
+	waitFor(t, func() bool { return writer.count() == 2 })
+}
+
+func TestListenerCloseFlushes(t *testing.T) {
+	writer := &memWriter{}
+	l, _ := NewListener(&config.GraphiteConfig{
+		TCPAddress:   "127.0.0.1:0",
+		BatchSize:    1000,
+		BatchTimeout: 60000,
+	}, writer)
+	if err := l.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	conn, _ := net.Dial("tcp", l.TCPAddr().String())
+	fmt.Fprintf(conn, "cpu 1 1699267200\n")
+
+	waitFor(t, func() bool { return l.Stats().PointsReceived == 1 })
+
+	// The open connection must not block Close
+	l.Close()
+	conn.Close()
+
+	if writer.count() != 1 {
+		t.Errorf("expected pending point to be flushed on close, got %d", writer.count())
+	}
+}
+
+func TestNewListenerNoAddress(t *testing.T) {
+	if _, err := NewListener(&config.GraphiteConfig{}, &memWriter{}); err == nil {
+		t.Error("expected error without TCP or UDP address")
+	}
+}
@@ -0,0 +1,253 @@
+// Package graphite implements a Graphite plaintext protocol listener.
+//
+// Lines have the form "path.to.metric value timestamp". Templates split the
+// dotted path into a metric name and tags, following the InfluxDB template
+// syntax:
+//
+//	[filter] template [default_tags]
+//
+// e.g. "sensors.* .site.measurement* type=sensor" turns
+// "sensors.plant1.pump.pressure" into metric "pump.pressure" with tags
+// site=plant1 and type=sensor. Template segments are "measurement",
+// "measurement*" (the rest of the path), a tag name, or empty to skip the
+// segment. When several filters match, the one with the most segments wins.
+package graphite
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Template maps the segments of a dotted path to a metric name and tags
+type Template struct {
+	filter      []string
+	parts       []string
+	defaultTags map[string]string
+}
+
+// Parser converts Graphite lines into data points
+type Parser struct {
+	templates []*Template
+	separator string
+	now       func() time.Time
+}
+
+// NewParser creates a parser from template definitions. Separator joins
+// the segments that make up the metric name.
+func NewParser(templates []string, separator string) (*Parser, error) {
+	if separator == "" {
+		separator = "."
+	}
+
+	p := &Parser{separator: separator, now: time.Now}
+	for _, def := range templates {
+		tpl, err := parseTemplate(def)
+		if err != nil {
+			return nil, err
+		}
+		p.templates = append(p.templates, tpl)
+	}
+
+	return p, nil
+}
+
+func parseTemplate(def string) (*Template, error) {
+	fields := strings.Fields(def)
+	tpl := &Template{defaultTags: make(map[string]string)}
+
+	switch len(fields) {
+	case 1:
+		tpl.parts = strings.Split(fields[0], ".")
+	case 2:
+		// Either "filter template" or "template tags"
+		if strings.Contains(fields[1], "=") {
+			tpl.parts = strings.Split(fields[0], ".")
+			if err := parseDefaultTags(fields[1], tpl.defaultTags); err != nil {
+				return nil, err
+			}
+		} else {
+			tpl.filter = strings.Split(fields[0], ".")
+			tpl.parts = strings.Split(fields[1], ".")
+		}
+	case 3:
+		tpl.filter = strings.Split(fields[0], ".")
+		tpl.parts = strings.Split(fields[1], ".")
+		if err := parseDefaultTags(fields[2], tpl.defaultTags); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("invalid template %q", def)
+	}
+
+	hasMeasurement := false
+	for i, part := range tpl.parts {
+		if part == "measurement*" && i != len(tpl.parts)-1 {
+			return nil, fmt.Errorf("invalid template %q: measurement* must be the last segment", def)
+		}
+		if part == "measurement" || part == "measurement*" {
+			hasMeasurement = true
+		}
+	}
+	if !hasMeasurement {
+		return nil, fmt.Errorf("invalid template %q: no measurement segment", def)
+	}
+
+	for _, f := range tpl.filter {
+		if _, err := path.Match(f, ""); err != nil {
+			return nil, fmt.Errorf("invalid template filter %q: %w", def, err)
+		}
+	}
+
+	return tpl, nil
+}
+
+func parseDefaultTags(s string, tags map[string]string) error {
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid default tag %q", kv)
+		}
+		tags[k] = v
+	}
+	return nil
+}
+
+// matches reports whether the template filter matches the path segments
+func (t *Template) matches(segments []string) bool {
+	if len(t.filter) > len(segments) {
+		return false
+	}
+	for i, f := range t.filter {
+		if ok, _ := path.Match(f, segments[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// apply extracts the metric name and tags from the path segments
+func (t *Template) apply(segments []string, separator string) (string, map[string]string) {
+	var measurement []string
+	tags := make(map[string]string)
+
+	for i, part := range t.parts {
+		if i >= len(segments) {
+			break
+		}
+
+		switch part {
+		case "":
+			// Skipped segment
+		case "measurement":
+			measurement = append(measurement, segments[i])
+		case "measurement*":
+			measurement = append(measurement, segments[i:]...)
+		default:
+			if existing, ok := tags[part]; ok {
+				tags[part] = existing + separator + segments[i]
+			} else {
+				tags[part] = segments[i]
+			}
+		}
+	}
+
+	for k, v := range t.defaultTags {
+		if _, ok := tags[k]; !ok {
+			tags[k] = v
+		}
+	}
+
+	if len(measurement) == 0 {
+		return strings.Join(segments, separator), tags
+	}
+	return strings.Join(measurement, separator), tags
+}
+
+// ApplyTemplate returns the metric name and tags for a dotted path using
+// the most specific matching template. Without a match the whole path is
+// the metric name.
+func (p *Parser) ApplyTemplate(metricPath string) (string, map[string]string) {
+	segments := strings.Split(metricPath, ".")
+
+	var best *Template
+	for _, tpl := range p.templates {
+		if !tpl.matches(segments) {
+			continue
+		}
+		if best == nil || len(tpl.filter) > len(best.filter) {
+			best = tpl
+		}
+	}
+
+	if best == nil {
+		return metricPath, map[string]string{}
+	}
+	return best.apply(segments, p.separator)
+}
+
+// Parse converts one line into a data point. Graphite 1.1 tags
+// ("path;tag=value value ts") are merged with template tags. A missing or
+// negative timestamp means "now".
+func (p *Parser) Parse(line string) (*storage.DataPoint, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil, fmt.Errorf("expected \"path value [timestamp]\", got %q", line)
+	}
+
+	metricPath, lineTags, err := splitTaggedPath(fields[0])
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, fmt.Errorf("invalid value %q", fields[1])
+	}
+
+	ts := p.now().UnixMilli()
+	if len(fields) == 3 {
+		secs, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", fields[2])
+		}
+		if secs >= 0 {
+			ts = int64(secs * 1000)
+		}
+	}
+
+	metric, tags := p.ApplyTemplate(metricPath)
+	for k, v := range lineTags {
+		tags[k] = v
+	}
+
+	return &storage.DataPoint{
+		Metric:    metric,
+		Timestamp: ts,
+		Value:     value,
+		Tags:      tags,
+	}, nil
+}
+
+func splitTaggedPath(s string) (string, map[string]string, error) {
+	parts := strings.Split(s, ";")
+	if parts[0] == "" {
+		return "", nil, fmt.Errorf("empty metric path")
+	}
+
+	tags := make(map[string]string, len(parts)-1)
+	for _, kv := range parts[1:] {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" || v == "" {
+			return "", nil, fmt.Errorf("invalid tag %q", kv)
+		}
+		tags[k] = v
+	}
+
+	return parts[0], tags, nil
+}
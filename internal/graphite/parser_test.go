@@ -0,0 +1,126 @@
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNoTemplates(t *testing.T) {
+	p, err := NewParser(nil, "")
+	if err != nil {
+		t.Fatalf("NewParser failed: %v", err)
+	}
+
+	point, err := p.Parse("servers.host1.cpu 42.5 1699267200")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if point.Metric != "servers.host1.cpu" {
+		t.Errorf("expected full path as metric, got %s", point.Metric)
+	}
+	if point.Value != 42.5 {
+		t.Errorf("expected value=42.5, got %f", point.Value)
+	}
+	if point.Timestamp != 1699267200000 {
+		t.Errorf("expected timestamp in ms, got %d", point.Timestamp)
+	}
+}
+
+func TestParseMissingTimestamp(t *testing.T) {
+	p, _ := NewParser(nil, "")
+	p.now = func() time.Time { return time.UnixMilli(5000) }
+
+	for _, line := range []string{"cpu 1", "cpu 1 -1"} {
+		point, err := p.Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", line, err)
+		}
+		if point.Timestamp != 5000 {
+			t.Errorf("Parse(%q): expected now, got %d", line, point.Timestamp)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	p, _ := NewParser(nil, "")
+
+	for _, line := range []string{
+		"cpu",
+		"cpu abc 1699267200",
+		"cpu 1 abc",
+		"cpu 1 2 3",
+		"cpu;host 1 1699267200",
+	} {
+		if _, err := p.Parse(line); err == nil {
+			t.Errorf("Parse(%q) should fail", line)
+		}
+	}
+}
+
+func TestParseTaggedPath(t *testing.T) {
+	p, _ := NewParser(nil, "")
+
+	point, err := p.Parse("cpu;host=a;dc=eu 1 1699267200")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if point.Metric != "cpu" || point.Tags["host"] != "a" || point.Tags["dc"] != "eu" {
+		t.Errorf("unexpected point: %+v", point)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	p, err := NewParser([]string{
+		"measurement*",
+		"servers.* .host.measurement*",
+		"sensors.*.*.temperature .site.sensor.measurement unit=celsius",
+		"sensors.* .site..measurement*",
+	}, "_")
+	if err != nil {
+		t.Fatalf("NewParser failed: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		metric string
+		tags   map[string]string
+	}{
+		{"servers.host1.cpu.idle", "cpu_idle", map[string]string{"host": "host1"}},
+		{"sensors.plant1.s7.temperature", "temperature", map[string]string{"site": "plant1", "sensor": "s7", "unit": "celsius"}},
+		{"sensors.plant1.pump.pressure", "pressure", map[string]string{"site": "plant1"}},
+		{"other.metric", "other_metric", map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			metric, tags := p.ApplyTemplate(tt.path)
+			if metric != tt.metric {
+				t.Errorf("metric = %q, want %q", metric, tt.metric)
+			}
+			if len(tags) != len(tt.tags) {
+				t.Errorf("tags = %v, want %v", tags, tt.tags)
+			}
+			for k, v := range tt.tags {
+				if tags[k] != v {
+					t.Errorf("tag %s = %q, want %q", k, tags[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidTemplates(t *testing.T) {
+	for _, tpl := range []string{
+		"host.cpu",
+		"measurement*.host",
+		"servers.* .host.measurement badtag",
+		"a b c d",
+		"[ .measurement",
+	} {
+		if _, err := NewParser([]string{tpl}, ""); err == nil {
+			t.Errorf("NewParser(%q) should fail", tpl)
+		}
+	}
+}
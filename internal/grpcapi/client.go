@@ -0,0 +1,111 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Client calls the gRPC API
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// Dial creates a client for addr. Without options the connection is
+// unencrypted.
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(codec{})),
+	}, opts...)
+
+	conn, err := grpc.NewClient(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Write sends one batch of points
+func (c *Client) Write(ctx context.Context, req *WriteRequest) (*WriteResponse, error) {
+	resp := new(WriteResponse)
+	if err := c.conn.Invoke(ctx, "/"+ServiceName+"/Write", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Query runs a query and returns all points in one response
+func (c *Client) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
+	resp := new(QueryResponse)
+	if err := c.conn.Invoke(ctx, "/"+ServiceName+"/Query", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WriteStream opens a client stream of write batches
+func (c *Client) WriteStream(ctx context.Context) (*WriteStreamClient, error) {
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/WriteStream")
+	if err != nil {
+		return nil, err
+	}
+	return &WriteStreamClient{stream: stream}, nil
+}
+
+// QueryStream runs a query whose results arrive in batches
+func (c *Client) QueryStream(ctx context.Context, req *QueryRequest) (*QueryStreamClient, error) {
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[1], "/"+ServiceName+"/QueryStream")
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &QueryStreamClient{stream: stream}, nil
+}
+
+// WriteStreamClient sends write batches
+type WriteStreamClient struct {
+	stream grpc.ClientStream
+}
+
+// Send sends one batch
+func (s *WriteStreamClient) Send(req *WriteRequest) error {
+	return s.stream.SendMsg(req)
+}
+
+// CloseAndRecv ends the stream and returns the totals
+func (s *WriteStreamClient) CloseAndRecv() (*WriteResponse, error) {
+	if err := s.stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	resp := new(WriteResponse)
+	if err := s.stream.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// QueryStreamClient receives query result batches
+type QueryStreamClient struct {
+	stream grpc.ClientStream
+}
+
+// Recv returns the next batch, or io.EOF after the last one
+func (s *QueryStreamClient) Recv() (*QueryResponse, error) {
+	resp := new(QueryResponse)
+	if err := s.stream.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
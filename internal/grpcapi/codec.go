@@ -0,0 +1,34 @@
+package grpcapi
+
+import "fmt"
+
+// message is implemented by every request and response type
+type message interface {
+	Marshal() []byte
+	Unmarshal(data []byte) error
+}
+
+// codec encodes messages with their hand-written protobuf marshalers.
+// It is registered under the "proto" name so that clients generated
+// from pulsardb.proto interoperate without extra configuration.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("grpcapi: cannot marshal %T", v)
+	}
+	return m.Marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("grpcapi: cannot unmarshal into %T", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func (codec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,331 @@
+package grpcapi
+
+import (
+	"io"
+	"sort"
+
+	"github.com/Pablo997/pulsardb/internal/pbwire"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Point is a data point on the wire. See pulsardb.proto.
+type Point struct {
+	Metric    string
+	Timestamp int64 // milliseconds
+	Value     float64
+	Tags      map[string]string
+}
+
+// WriteRequest carries a batch of points
+type WriteRequest struct {
+	Points []Point
+}
+
+// WriteResponse reports how many points were written. Errors holds one
+// message per rejected point.
+type WriteResponse struct {
+	Written uint64
+	Errors  []string
+}
+
+// QueryRequest selects points of one metric in [Start, End]. Tags, if
+// set, must all match. BatchSize sets the points per QueryStream message.
+type QueryRequest struct {
+	Metric    string
+	Start     int64
+	End       int64
+	Tags      map[string]string
+	BatchSize uint32
+}
+
+// QueryResponse carries query results
+type QueryResponse struct {
+	Points []Point
+}
+
+// FromStorage converts a storage data point
+func FromStorage(dp *storage.DataPoint) Point {
+	return Point{
+		Metric:    dp.Metric,
+		Timestamp: dp.Timestamp,
+		Value:     dp.Value,
+		Tags:      dp.Tags,
+	}
+}
+
+// ToStorage converts the point for the storage engine
+func (m *Point) ToStorage() *storage.DataPoint {
+	tags := m.Tags
+	if tags == nil {
+		tags = make(map[string]string)
+	}
+	return &storage.DataPoint{
+		Metric:    m.Metric,
+		Timestamp: m.Timestamp,
+		Value:     m.Value,
+		Tags:      tags,
+	}
+}
+
+// Marshal encodes the point
+func (m *Point) Marshal() []byte {
+	var b []byte
+	if m.Metric != "" {
+		b = pbwire.AppendString(b, 1, m.Metric)
+	}
+	if m.Timestamp != 0 {
+		b = pbwire.AppendInt64(b, 2, m.Timestamp)
+	}
+	if m.Value != 0 {
+		b = pbwire.AppendDouble(b, 3, m.Value)
+	}
+	return appendMap(b, 4, m.Tags)
+}
+
+// Unmarshal decodes the point
+func (m *Point) Unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			if m.Metric, err = r.String(); err != nil {
+				return err
+			}
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			if err != nil {
+				return err
+			}
+			m.Timestamp = int64(v)
+		case num == 3 && typ == pbwire.Fixed64:
+			if m.Value, err = r.Double(); err != nil {
+				return err
+			}
+		case num == 4 && typ == pbwire.Bytes:
+			if m.Tags, err = readMapEntry(r, m.Tags); err != nil {
+				return err
+			}
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Marshal encodes the request
+func (m *WriteRequest) Marshal() []byte {
+	var b []byte
+	for i := range m.Points {
+		b = pbwire.AppendBytes(b, 1, m.Points[i].Marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes the request
+func (m *WriteRequest) Unmarshal(data []byte) error {
+	m.Points = m.Points[:0]
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if num == 1 && typ == pbwire.Bytes {
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var p Point
+			if err := p.Unmarshal(buf); err != nil {
+				return err
+			}
+			m.Points = append(m.Points, p)
+			continue
+		}
+		if err := r.Skip(typ); err != nil {
+			return err
+		}
+	}
+}
+
+// Marshal encodes the response
+func (m *WriteResponse) Marshal() []byte {
+	var b []byte
+	if m.Written != 0 {
+		b = pbwire.AppendUint64(b, 1, m.Written)
+	}
+	for _, e := range m.Errors {
+		b = pbwire.AppendString(b, 2, e)
+	}
+	return b
+}
+
+// Unmarshal decodes the response
+func (m *WriteResponse) Unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Varint:
+			if m.Written, err = r.Varint(); err != nil {
+				return err
+			}
+		case num == 2 && typ == pbwire.Bytes:
+			s, err := r.String()
+			if err != nil {
+				return err
+			}
+			m.Errors = append(m.Errors, s)
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Marshal encodes the request
+func (m *QueryRequest) Marshal() []byte {
+	var b []byte
+	if m.Metric != "" {
+		b = pbwire.AppendString(b, 1, m.Metric)
+	}
+	if m.Start != 0 {
+		b = pbwire.AppendInt64(b, 2, m.Start)
+	}
+	if m.End != 0 {
+		b = pbwire.AppendInt64(b, 3, m.End)
+	}
+	b = appendMap(b, 4, m.Tags)
+	if m.BatchSize != 0 {
+		b = pbwire.AppendUint64(b, 5, uint64(m.BatchSize))
+	}
+	return b
+}
+
+// Unmarshal decodes the request
+func (m *QueryRequest) Unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			if m.Metric, err = r.String(); err != nil {
+				return err
+			}
+		case (num == 2 || num == 3 || num == 5) && typ == pbwire.Varint:
+			v, err := r.Varint()
+			if err != nil {
+				return err
+			}
+			switch num {
+			case 2:
+				m.Start = int64(v)
+			case 3:
+				m.End = int64(v)
+			case 5:
+				m.BatchSize = uint32(v)
+			}
+		case num == 4 && typ == pbwire.Bytes:
+			if m.Tags, err = readMapEntry(r, m.Tags); err != nil {
+				return err
+			}
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Marshal encodes the response
+func (m *QueryResponse) Marshal() []byte {
+	return (*WriteRequest)(m).Marshal()
+}
+
+// Unmarshal decodes the response
+func (m *QueryResponse) Unmarshal(data []byte) error {
+	return (*WriteRequest)(m).Unmarshal(data)
+}
+
+// appendMap encodes a map<string, string> field as sorted entries
+func appendMap(b []byte, num int, m map[string]string) []byte {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry []byte
+		entry = pbwire.AppendString(entry, 1, k)
+		entry = pbwire.AppendString(entry, 2, m[k])
+		b = pbwire.AppendBytes(b, num, entry)
+	}
+	return b
+}
+
+// readMapEntry decodes one map<string, string> entry into m
+func readMapEntry(r *pbwire.Reader, m map[string]string) (map[string]string, error) {
+	data, err := r.Bytes()
+	if err != nil {
+		return m, err
+	}
+
+	var key, value string
+	er := pbwire.NewReader(data)
+	for {
+		num, typ, err := er.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return m, err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			key, err = er.String()
+		case num == 2 && typ == pbwire.Bytes:
+			value, err = er.String()
+		default:
+			err = er.Skip(typ)
+		}
+		if err != nil {
+			return m, err
+		}
+	}
+
+	if m == nil {
+		m = make(map[string]string)
+	}
+	m[key] = value
+	return m, nil
+}
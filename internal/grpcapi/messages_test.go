@@ -0,0 +1,56 @@
+package grpcapi
+
+import (
+	"testing"
+)
+
+func TestPointRoundTrip(t *testing.T) {
+	in := Point{Metric: "cpu", Timestamp: -5, Value: 0.25, Tags: map[string]string{"host": "a", "dc": "eu"}}
+
+	var out Point
+	if err := out.Unmarshal(in.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Metric != "cpu" || out.Timestamp != -5 || out.Value != 0.25 {
+		t.Errorf("unexpected point: %+v", out)
+	}
+	if len(out.Tags) != 2 || out.Tags["host"] != "a" || out.Tags["dc"] != "eu" {
+		t.Errorf("unexpected tags: %v", out.Tags)
+	}
+}
+
+func TestWriteMessagesRoundTrip(t *testing.T) {
+	req := &WriteRequest{Points: []Point{{Metric: "a", Value: 1}, {Metric: "b", Timestamp: 2}}}
+	var gotReq WriteRequest
+	if err := gotReq.Unmarshal(req.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(gotReq.Points) != 2 || gotReq.Points[1].Metric != "b" || gotReq.Points[1].Timestamp != 2 {
+		t.Errorf("unexpected request: %+v", gotReq)
+	}
+
+	resp := &WriteResponse{Written: 3, Errors: []string{"bad"}}
+	var gotResp WriteResponse
+	if err := gotResp.Unmarshal(resp.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if gotResp.Written != 3 || len(gotResp.Errors) != 1 || gotResp.Errors[0] != "bad" {
+		t.Errorf("unexpected response: %+v", gotResp)
+	}
+}
+
+func TestQueryRequestRoundTrip(t *testing.T) {
+	req := &QueryRequest{Metric: "cpu", Start: 10, End: 20, Tags: map[string]string{"host": "a"}, BatchSize: 50}
+
+	var got QueryRequest
+	if err := got.Unmarshal(req.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Metric != "cpu" || got.Start != 10 || got.End != 20 || got.BatchSize != 50 || got.Tags["host"] != "a" {
+		t.Errorf("unexpected request: %+v", got)
+	}
+
+	if err := got.Unmarshal([]byte{0x0a, 0x09, 'x'}); err == nil {
+		t.Error("expected error for truncated message")
+	}
+}
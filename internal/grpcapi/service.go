@@ -0,0 +1,309 @@
+// Package grpcapi serves the PulsarDB gRPC API described in
+// pulsardb.proto. Messages are encoded with hand-written protobuf
+// marshalers, so no generated code is needed.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// ServiceName is the fully qualified gRPC service name
+const ServiceName = "pulsardb.v1.PulsarDB"
+
+// DefaultBatchSize is the number of points per QueryStream message
+const DefaultBatchSize = 1000
+
+// stopTimeout bounds how long Close waits for in-flight calls
+const stopTimeout = 5 * time.Second
+
+// ErrUnavailable is wrapped by backend errors that the client may retry,
+// such as a full query queue
+var ErrUnavailable = errors.New("unavailable")
+
+// Backend is the storage shared with the HTTP server. Query calls fn
+// for every point of metric in [start, end] whose tags equal tags, in
+// timestamp order, while it reads them. It stops at the first error from
+// fn or when ctx is done.
+type Backend interface {
+	WriteBatch(points []*storage.DataPoint) error
+	Query(ctx context.Context, metric string, start, end int64, tags map[string]string, fn func(*storage.DataPoint) error) error
+}
+
+// PulsarDBServer is the service interface registered with gRPC
+type PulsarDBServer interface {
+	Write(ctx context.Context, req *WriteRequest) (*WriteResponse, error)
+	WriteStream(stream grpc.ServerStream) error
+	Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error)
+	QueryStream(req *QueryRequest, stream grpc.ServerStream) error
+}
+
+// Service implements PulsarDBServer on top of a Backend
+type Service struct {
+	backend Backend
+}
+
+// NewService creates a service backed by backend
+func NewService(backend Backend) *Service {
+	return &Service{backend: backend}
+}
+
+// Write stores a batch of points. Invalid points are reported in the
+// response and the rest are written.
+func (s *Service) Write(ctx context.Context, req *WriteRequest) (*WriteResponse, error) {
+	resp := &WriteResponse{}
+	if err := s.write(req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WriteStream stores every batch sent by the client and returns the
+// totals when the client closes the stream
+func (s *Service) WriteStream(stream grpc.ServerStream) error {
+	resp := &WriteResponse{}
+	for {
+		var req WriteRequest
+		if err := stream.RecvMsg(&req); err != nil {
+			if err == io.EOF {
+				return stream.SendMsg(resp)
+			}
+			return err
+		}
+		if err := s.write(&req, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Service) write(req *WriteRequest, resp *WriteResponse) error {
+	points := make([]*storage.DataPoint, 0, len(req.Points))
+	for i := range req.Points {
+		if req.Points[i].Metric == "" {
+			resp.Errors = append(resp.Errors, "missing or invalid metric")
+			continue
+		}
+		points = append(points, req.Points[i].ToStorage())
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+	written := len(points)
+	if err := s.backend.WriteBatch(points); err != nil {
+		// Points refused by the series limits are reported like invalid points
+		var partial *storage.PartialWriteError
+		if !errors.As(err, &partial) {
+			return status.Error(codes.Internal, err.Error())
+		}
+		for _, rej := range partial.Rejected {
+			resp.Errors = append(resp.Errors, rej.Err.Error())
+		}
+		written -= len(partial.Rejected)
+	}
+	resp.Written += uint64(written)
+	return nil
+}
+
+// Query returns all matching points in one response
+func (s *Service) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
+	resp := &QueryResponse{}
+	err := s.query(ctx, req, func(dp *storage.DataPoint) error {
+		resp.Points = append(resp.Points, FromStorage(dp))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// QueryStream sends matching points in messages of req.BatchSize points.
+// Each message is sent as soon as it fills, so only one batch is held
+// in memory.
+func (s *Service) QueryStream(req *QueryRequest, stream grpc.ServerStream) error {
+	batchSize := int(req.BatchSize)
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	resp := &QueryResponse{Points: make([]Point, 0, batchSize)}
+	err := s.query(stream.Context(), req, func(dp *storage.DataPoint) error {
+		resp.Points = append(resp.Points, FromStorage(dp))
+		if len(resp.Points) < batchSize {
+			return nil
+		}
+		if err := stream.SendMsg(resp); err != nil {
+			return err
+		}
+		resp.Points = resp.Points[:0]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Points) > 0 {
+		return stream.SendMsg(resp)
+	}
+	return nil
+}
+
+func (s *Service) query(ctx context.Context, req *QueryRequest, fn func(*storage.DataPoint) error) error {
+	if req.Metric == "" {
+		return status.Error(codes.InvalidArgument, "missing or invalid metric")
+	}
+	if req.Start > req.End {
+		return status.Error(codes.InvalidArgument, "start timestamp must be before end timestamp")
+	}
+
+	// Errors from fn are already statuses or stream errors
+	var sendErr error
+	err := s.backend.Query(ctx, req.Metric, req.Start, req.End, req.Tags, func(dp *storage.DataPoint) error {
+		sendErr = fn(dp)
+		return sendErr
+	})
+	if err != nil {
+		if sendErr != nil {
+			return sendErr
+		}
+		return queryError(err)
+	}
+	return nil
+}
+
+// queryError maps a backend query error to a gRPC status
+func queryError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrBudgetExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	case errors.Is(err, ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Server runs the gRPC API on its own port
+type Server struct {
+	cfg  *config.GRPCConfig
+	grpc *grpc.Server
+	ln   net.Listener
+}
+
+// NewServer creates a server for backend. The socket is opened by Start.
+func NewServer(cfg *config.GRPCConfig, backend Backend) *Server {
+	maxSize := cfg.MaxMessageSizeMB
+	if maxSize <= 0 {
+		maxSize = 16
+	}
+
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(codec{}),
+		grpc.MaxRecvMsgSize(maxSize<<20),
+		grpc.MaxSendMsgSize(maxSize<<20),
+	)
+	srv.RegisterService(&serviceDesc, NewService(backend))
+
+	return &Server{cfg: cfg, grpc: srv}
+}
+
+// Start opens the socket and begins serving
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.cfg.Address)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+
+	go s.grpc.Serve(ln)
+	return nil
+}
+
+// Addr returns the bound address
+func (s *Server) Addr() net.Addr {
+	if s.ln == nil {
+		return nil
+	}
+	return s.ln.Addr()
+}
+
+// Close waits for in-flight calls, then stops the server
+func (s *Server) Close() error {
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		s.grpc.Stop()
+	}
+	return nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*PulsarDBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Write", Handler: writeHandler},
+		{MethodName: "Query", Handler: queryHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "WriteStream", Handler: writeStreamHandler, ClientStreams: true},
+		{StreamName: "QueryStream", Handler: queryStreamHandler, ServerStreams: true},
+	},
+	Metadata: "pulsardb.proto",
+}
+
+func writeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(WriteRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PulsarDBServer).Write(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Write"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PulsarDBServer).Write(ctx, req.(*WriteRequest))
+	})
+}
+
+func queryHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(QueryRequest)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PulsarDBServer).Query(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Query"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PulsarDBServer).Query(ctx, req.(*QueryRequest))
+	})
+}
+
+func writeStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PulsarDBServer).WriteStream(stream)
+}
+
+func queryStreamHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(QueryRequest)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(PulsarDBServer).QueryStream(req, stream)
+}
@@ -0,0 +1,186 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// memBackend is an in-memory Backend
+type memBackend struct {
+	mu       sync.Mutex
+	points   []*storage.DataPoint
+	queryErr error // returned by Query when set
+}
+
+func (b *memBackend) WriteBatch(points []*storage.DataPoint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.points = append(b.points, points...)
+	return nil
+}
+
+func (b *memBackend) Query(ctx context.Context, metric string, start, end int64, tags map[string]string, fn func(*storage.DataPoint) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.queryErr != nil {
+		return b.queryErr
+	}
+
+	var out []*storage.DataPoint
+	for _, p := range b.points {
+		if p.Metric == metric && p.Timestamp >= start && p.Timestamp <= end && hasTags(p, tags) {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Timestamp < out[j].Timestamp })
+	for _, p := range out {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasTags(p *storage.DataPoint, tags map[string]string) bool {
+	for k, v := range tags {
+		if p.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func startTestServer(t *testing.T) (*Client, *memBackend) {
+	t.Helper()
+
+	backend := &memBackend{}
+	srv := NewServer(&config.GRPCConfig{Address: "127.0.0.1:0"}, backend)
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+
+	client, err := Dial(srv.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client, backend
+}
+
+func TestWriteAndQuery(t *testing.T) {
+	client, _ := startTestServer(t)
+	ctx := context.Background()
+
+	resp, err := client.Write(ctx, &WriteRequest{Points: []Point{
+		{Metric: "cpu", Timestamp: 1000, Value: 1, Tags: map[string]string{"host": "a"}},
+		{Metric: "cpu", Timestamp: 2000, Value: 2, Tags: map[string]string{"host": "b"}},
+		{Timestamp: 3000, Value: 3},
+	}})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if resp.Written != 2 || len(resp.Errors) != 1 {
+		t.Errorf("unexpected write response: %+v", resp)
+	}
+
+	result, err := client.Query(ctx, &QueryRequest{Metric: "cpu", Start: 0, End: 5000, Tags: map[string]string{"host": "b"}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(result.Points) != 1 || result.Points[0].Value != 2 {
+		t.Errorf("unexpected query result: %+v", result.Points)
+	}
+
+	_, err = client.Query(ctx, &QueryRequest{Metric: "cpu", Start: 10, End: 5})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestWriteStreamAndQueryStream(t *testing.T) {
+	client, backend := startTestServer(t)
+	ctx := context.Background()
+
+	stream, err := client.WriteStream(ctx)
+	if err != nil {
+		t.Fatalf("WriteStream failed: %v", err)
+	}
+	for batch := 0; batch < 5; batch++ {
+		req := &WriteRequest{}
+		for i := 0; i < 10; i++ {
+			req.Points = append(req.Points, Point{Metric: "temp", Timestamp: int64(batch*10 + i), Value: float64(i)})
+		}
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatalf("CloseAndRecv failed: %v", err)
+	}
+	if resp.Written != 50 || len(backend.points) != 50 {
+		t.Errorf("expected 50 points written, got %d (%d stored)", resp.Written, len(backend.points))
+	}
+
+	qs, err := client.QueryStream(ctx, &QueryRequest{Metric: "temp", Start: 0, End: 100, BatchSize: 15})
+	if err != nil {
+		t.Fatalf("QueryStream failed: %v", err)
+	}
+
+	var sizes []int
+	total := 0
+	for {
+		batch, err := qs.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		sizes = append(sizes, len(batch.Points))
+		total += len(batch.Points)
+	}
+
+	if total != 50 || len(sizes) != 4 || sizes[3] != 5 {
+		t.Errorf("expected batches of 15,15,15,5, got %v", sizes)
+	}
+}
+
+func TestQueryErrorCodes(t *testing.T) {
+	client, backend := startTestServer(t)
+
+	tests := []struct {
+		err  error
+		code codes.Code
+	}{
+		{fmt.Errorf("%w: more than 10 points", storage.ErrBudgetExceeded), codes.ResourceExhausted},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{fmt.Errorf("%w: too many concurrent queries", ErrUnavailable), codes.Unavailable},
+		{errors.New("disk on fire"), codes.Internal},
+	}
+
+	for _, tt := range tests {
+		backend.mu.Lock()
+		backend.queryErr = tt.err
+		backend.mu.Unlock()
+
+		_, err := client.Query(context.Background(), &QueryRequest{Metric: "cpu", End: 1000})
+		if status.Code(err) != tt.code {
+			t.Errorf("%v: expected code %v, got %v", tt.err, tt.code, status.Code(err))
+		}
+	}
+}
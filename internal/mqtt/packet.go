@@ -0,0 +1,244 @@
+// Package mqtt implements an MQTT 3.1.1 client that subscribes to topic
+// filters on a broker and writes the received payloads as data points.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Control packet types
+const (
+	packetConnect    = 1
+	packetConnack    = 2
+	packetPublish    = 3
+	packetPuback     = 4
+	packetSubscribe  = 8
+	packetSuback     = 9
+	packetPingreq    = 12
+	packetPingresp   = 13
+	packetDisconnect = 14
+)
+
+const (
+	protocolLevel311 = 4 // MQTT 3.1.1
+
+	// maxRemainingBytes is the longest remaining-length encoding
+	maxRemainingBytes = 4
+
+	// maxPacketSize bounds the memory used by one incoming packet
+	maxPacketSize = 16 << 20
+)
+
+// subackFailure is the SUBACK return code for a rejected filter
+const subackFailure = 0x80
+
+var errMalformed = errors.New("mqtt: malformed packet")
+
+// connackErrors describes the CONNACK return codes
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// packet is a control packet with its fixed header decoded
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads one control packet
+func readPacket(r *bufio.Reader) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	length := 0
+	for i, shift := 0, 0; ; i, shift = i+1, shift+7 {
+		if i == maxRemainingBytes {
+			return nil, errMalformed
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if length > maxPacketSize {
+		return nil, fmt.Errorf("mqtt: packet of %d bytes exceeds limit", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	return &packet{typ: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// encodePacket prepends the fixed header to body
+func encodePacket(typ, flags byte, body []byte) []byte {
+	b := []byte{typ<<4 | flags}
+	n := len(body)
+	for {
+		digit := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	return append(b, body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errMalformed
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errMalformed
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// connectOptions holds the CONNECT fields
+type connectOptions struct {
+	clientID     string
+	username     string
+	password     string
+	cleanSession bool
+	keepAlive    uint16
+}
+
+func encodeConnect(opts connectOptions) []byte {
+	var flags byte
+	if opts.cleanSession {
+		flags |= 0x02
+	}
+	if opts.username != "" {
+		flags |= 0x80
+		if opts.password != "" {
+			flags |= 0x40
+		}
+	}
+
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, flags)
+	body = binary.BigEndian.AppendUint16(body, opts.keepAlive)
+	body = appendString(body, opts.clientID)
+	if opts.username != "" {
+		body = appendString(body, opts.username)
+		if opts.password != "" {
+			body = appendString(body, opts.password)
+		}
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+// connackError returns the error for a CONNACK, or nil if accepted
+func connackError(p *packet) error {
+	if p.typ != packetConnack || len(p.body) != 2 {
+		return fmt.Errorf("mqtt: expected CONNACK, got packet type %d", p.typ)
+	}
+	if code := p.body[1]; code != 0 {
+		if msg, ok := connackErrors[code]; ok {
+			return fmt.Errorf("mqtt: connection refused: %s", msg)
+		}
+		return fmt.Errorf("mqtt: connection refused with code %d", code)
+	}
+	return nil
+}
+
+// topicFilter is one SUBSCRIBE entry
+type topicFilter struct {
+	filter string
+	qos    byte
+}
+
+func encodeSubscribe(id uint16, filters []topicFilter) []byte {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, f := range filters {
+		body = appendString(body, f.filter)
+		body = append(body, f.qos)
+	}
+	// SUBSCRIBE has reserved flags 0010
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+// publish is a decoded PUBLISH packet
+type publish struct {
+	topic    string
+	qos      byte
+	retain   bool
+	dup      bool
+	packetID uint16
+	payload  []byte
+}
+
+func decodePublish(p *packet) (*publish, error) {
+	pub := &publish{
+		qos:    (p.flags >> 1) & 0x03,
+		retain: p.flags&0x01 != 0,
+		dup:    p.flags&0x08 != 0,
+	}
+	if pub.qos > 2 {
+		return nil, errMalformed
+	}
+
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return nil, err
+	}
+	pub.topic = topic
+
+	if pub.qos > 0 {
+		if len(rest) < 2 {
+			return nil, errMalformed
+		}
+		pub.packetID = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	pub.payload = rest
+	return pub, nil
+}
+
+func encodePublish(pub *publish) []byte {
+	flags := pub.qos << 1
+	if pub.retain {
+		flags |= 0x01
+	}
+	if pub.dup {
+		flags |= 0x08
+	}
+
+	body := appendString(nil, pub.topic)
+	if pub.qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, pub.packetID)
+	}
+	body = append(body, pub.payload...)
+	return encodePacket(packetPublish, flags, body)
+}
+
+func encodePuback(id uint16) []byte {
+	return encodePacket(packetPuback, 0, binary.BigEndian.AppendUint16(nil, id))
+}
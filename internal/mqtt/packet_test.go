@@ -0,0 +1,69 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestRemainingLength(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097152} {
+		data := encodePacket(packetPublish, 0, make([]byte, n))
+
+		p, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatalf("readPacket(%d bytes) failed: %v", n, err)
+		}
+		if p.typ != packetPublish || len(p.body) != n {
+			t.Errorf("expected %d byte body, got type %d with %d bytes", n, p.typ, len(p.body))
+		}
+	}
+
+	malformed := []byte{0x30, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader(malformed))); err == nil {
+		t.Error("expected error for 5-byte remaining length")
+	}
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+	in := &publish{topic: "sensors/a", qos: 1, retain: true, packetID: 42, payload: []byte("hello")}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(encodePublish(in))))
+	if err != nil {
+		t.Fatalf("readPacket failed: %v", err)
+	}
+
+	out, err := decodePublish(p)
+	if err != nil {
+		t.Fatalf("decodePublish failed: %v", err)
+	}
+	if out.topic != in.topic || out.qos != 1 || !out.retain || out.packetID != 42 || string(out.payload) != "hello" {
+		t.Errorf("unexpected publish: %+v", out)
+	}
+
+	if _, err := decodePublish(&packet{typ: packetPublish, flags: 0x02, body: []byte{0, 1, 'a'}}); err == nil {
+		t.Error("expected error for QoS 1 publish without packet id")
+	}
+}
+
+func TestEncodeConnect(t *testing.T) {
+	data := encodeConnect(connectOptions{clientID: "db", username: "u", password: "p", keepAlive: 30})
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("readPacket failed: %v", err)
+	}
+
+	name, rest, err := readString(p.body)
+	if err != nil || name != "MQTT" {
+		t.Fatalf("unexpected protocol name %q: %v", name, err)
+	}
+	if rest[0] != protocolLevel311 || rest[1] != 0xc0 {
+		t.Errorf("unexpected level/flags: %d %#x", rest[0], rest[1])
+	}
+
+	if err := connackError(&packet{typ: packetConnack, body: []byte{0, 0}}); err != nil {
+		t.Errorf("expected accepted connection, got %v", err)
+	}
+	if err := connackError(&packet{typ: packetConnack, body: []byte{0, 5}}); err == nil {
+		t.Error("expected error for refused connection")
+	}
+}
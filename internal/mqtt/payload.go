@@ -0,0 +1,256 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Payload formats
+const (
+	FormatJSON = "json"
+	FormatLine = "line"
+)
+
+// ParseJSON parses a payload in the shape accepted by POST /write: one
+// point or an array of points. metric and tags come from the topic
+// template; metric is used when a point has none and payload tags win
+// over topic tags. A missing timestamp means now (milliseconds).
+func ParseJSON(data []byte, metric string, tags map[string]string, now int64) ([]*storage.DataPoint, []error) {
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, []error{fmt.Errorf("invalid JSON format")}
+	}
+
+	var items []map[string]interface{}
+	switch v := body.(type) {
+	case map[string]interface{}:
+		items = []map[string]interface{}{v}
+	case []interface{}:
+		for _, item := range v {
+			if point, ok := item.(map[string]interface{}); ok {
+				items = append(items, point)
+			}
+		}
+	default:
+		return nil, []error{fmt.Errorf("expected object or array")}
+	}
+
+	var points []*storage.DataPoint
+	var errs []error
+	for _, item := range items {
+		name := metric
+		if v, ok := item["metric"]; ok {
+			name, _ = v.(string)
+		}
+		if name == "" {
+			errs = append(errs, fmt.Errorf("missing or invalid metric"))
+			continue
+		}
+
+		timestamp := float64(now)
+		if v, ok := item["timestamp"]; ok {
+			if timestamp, ok = v.(float64); !ok {
+				errs = append(errs, fmt.Errorf("missing or invalid timestamp"))
+				continue
+			}
+		}
+
+		value, ok := item["value"].(float64)
+		if !ok {
+			errs = append(errs, fmt.Errorf("missing or invalid value"))
+			continue
+		}
+
+		pointTags := copyTags(tags)
+		if tagsData, ok := item["tags"].(map[string]interface{}); ok {
+			for k, v := range tagsData {
+				if strVal, ok := v.(string); ok {
+					pointTags[k] = strVal
+				}
+			}
+		}
+
+		points = append(points, &storage.DataPoint{
+			Metric:    name,
+			Timestamp: int64(timestamp),
+			Value:     value,
+			Tags:      pointTags,
+		})
+	}
+	return points, errs
+}
+
+// ParseLineProtocol parses InfluxDB line protocol, one point per line:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// A field named "value" is stored as the measurement; other fields are
+// stored as "<measurement>.<field>". String fields are skipped and
+// booleans are stored as 0 or 1. Timestamps are nanoseconds; a missing
+// timestamp means now (milliseconds). Topic tags are added to every
+// point unless the line sets the same tag.
+func ParseLineProtocol(data []byte, tags map[string]string, now int64) ([]*storage.DataPoint, []error) {
+	var points []*storage.DataPoint
+	var errs []error
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parsed, err := parseLine(line, tags, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+			continue
+		}
+		points = append(points, parsed...)
+	}
+	return points, errs
+}
+
+func parseLine(line string, tags map[string]string, now int64) ([]*storage.DataPoint, error) {
+	sections := splitUnescaped(line, ' ')
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, fmt.Errorf("expected measurement, fields and optional timestamp")
+	}
+
+	series := splitUnescaped(sections[0], ',')
+	measurement := unescape(series[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("missing measurement")
+	}
+
+	pointTags := copyTags(tags)
+	for _, tag := range series[1:] {
+		kv := splitUnescaped(tag, '=')
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		pointTags[unescape(kv[0])] = unescape(kv[1])
+	}
+
+	ts := now
+	if len(sections) == 3 {
+		ns, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", sections[2])
+		}
+		ts = ns / 1e6
+	}
+
+	var points []*storage.DataPoint
+	for _, field := range splitUnescaped(sections[1], ',') {
+		kv := splitUnescaped(field, '=')
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid field %q", field)
+		}
+
+		value, ok, err := parseFieldValue(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		name := measurement
+		if key := unescape(kv[0]); key != "value" {
+			name = measurement + "." + key
+		}
+
+		points = append(points, &storage.DataPoint{
+			Metric:    name,
+			Timestamp: ts,
+			Value:     value,
+			Tags:      copyTags(pointTags),
+		})
+	}
+
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no numeric fields")
+	}
+	return points, nil
+}
+
+// parseFieldValue returns the numeric value of a field. ok is false for
+// string fields, which have no numeric value.
+func parseFieldValue(s string) (float64, bool, error) {
+	if strings.HasPrefix(s, `"`) {
+		return 0, false, nil
+	}
+
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+
+	if strings.HasSuffix(s, "i") {
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid integer field %q", s)
+		}
+		return float64(v), true, nil
+	}
+	if strings.HasSuffix(s, "u") {
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid unsigned field %q", s)
+		}
+		return float64(v), true, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid field value %q", s)
+	}
+	return v, true, nil
+}
+
+// splitUnescaped splits s on sep, ignoring separators that are escaped
+// with a backslash or inside double quotes
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package mqtt
+
+import "testing"
+
+func TestParseJSON(t *testing.T) {
+	topicTags := map[string]string{"device": "d1", "site": "s1"}
+
+	points, errs := ParseJSON([]byte(`[
+		{"metric": "temp", "timestamp": 1000, "value": 21.5, "tags": {"site": "override"}},
+		{"value": 3},
+		{"metric": "temp", "value": "bad"}
+	]`), "pressure", topicTags, 5000)
+
+	if len(points) != 2 || len(errs) != 1 {
+		t.Fatalf("expected 2 points and 1 error, got %d and %v", len(points), errs)
+	}
+
+	if points[0].Metric != "temp" || points[0].Timestamp != 1000 || points[0].Tags["site"] != "override" || points[0].Tags["device"] != "d1" {
+		t.Errorf("unexpected first point: %+v", points[0])
+	}
+	if points[1].Metric != "pressure" || points[1].Timestamp != 5000 {
+		t.Errorf("expected topic metric and receive time, got %+v", points[1])
+	}
+
+	// Topic tags are copied, not shared
+	if topicTags["site"] != "s1" {
+		t.Error("topic tags were modified")
+	}
+
+	if _, errs := ParseJSON([]byte(`{"value": 1}`), "", nil, 0); len(errs) != 1 {
+		t.Errorf("expected missing metric error, got %v", errs)
+	}
+	if _, errs := ParseJSON([]byte(`not json`), "m", nil, 0); len(errs) != 1 {
+		t.Errorf("expected JSON error, got %v", errs)
+	}
+}
+
+func TestParseLineProtocol(t *testing.T) {
+	data := []byte(`weather,location=us\ west temperature=82,humidity=40i,raining=true,note="a b" 1699267200000000000
+cpu value=0.5
+# comment
+
+broken`)
+
+	points, errs := ParseLineProtocol(data, map[string]string{"site": "s1"}, 7000)
+	if len(errs) != 1 {
+		t.Errorf("expected 1 error, got %v", errs)
+	}
+
+	got := make(map[string]float64)
+	for _, p := range points {
+		got[p.Metric] = p.Value
+	}
+	want := map[string]float64{
+		"weather.temperature": 82,
+		"weather.humidity":    40,
+		"weather.raining":     1,
+		"cpu":                 0.5,
+	}
+	if len(got) != len(want) {
+		t.Errorf("unexpected points: %v", got)
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %f, want %f", name, got[name], v)
+		}
+	}
+
+	if points[0].Tags["location"] != "us west" || points[0].Tags["site"] != "s1" {
+		t.Errorf("unexpected tags: %v", points[0].Tags)
+	}
+	if points[0].Timestamp != 1699267200000 {
+		t.Errorf("expected nanoseconds converted to ms, got %d", points[0].Timestamp)
+	}
+	if points[len(points)-1].Timestamp != 7000 {
+		t.Errorf("expected receive time for line without timestamp, got %d", points[len(points)-1].Timestamp)
+	}
+}
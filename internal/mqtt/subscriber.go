@@ -0,0 +1,376 @@
+package mqtt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// dialTimeout bounds connecting and the CONNECT/CONNACK handshake
+const dialTimeout = 10 * time.Second
+
+var errClosed = errors.New("mqtt: subscriber closed")
+
+// BatchWriter persists a batch of data points
+type BatchWriter interface {
+	WriteBatch(points []*storage.DataPoint) error
+}
+
+// Stats holds subscriber counters
+type Stats struct {
+	Connects         int64
+	MessagesReceived int64
+	PointsWritten    int64
+	PointsRejected   int64 // refused by the series limits
+	ParseErrors      int64 // points or lines that could not be parsed
+	WriteErrors      int64 // failed batches
+}
+
+type subscription struct {
+	filter   string
+	qos      byte
+	format   string
+	template *Template
+}
+
+// Subscriber connects to an MQTT broker, subscribes to the configured
+// topic filters and writes every received payload. QoS 1 messages are
+// acknowledged only after the write succeeds; when a write fails the
+// connection is dropped so the broker redelivers the message after the
+// reconnect (this needs clean_session=false).
+type Subscriber struct {
+	cfg    *config.MQTTConfig
+	writer BatchWriter
+	subs   []subscription
+	now    func() time.Time
+
+	retryDelay time.Duration // wait between connection attempts
+
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	connMu sync.Mutex
+	conn   net.Conn
+
+	writeMu sync.Mutex // serializes packets sent by pings and acks
+
+	errMu   sync.Mutex
+	lastErr error
+
+	stats Stats // accessed via atomic
+}
+
+// NewSubscriber validates the subscriptions and creates a subscriber.
+// The connection is opened by Start.
+func NewSubscriber(cfg *config.MQTTConfig, writer BatchWriter) (*Subscriber, error) {
+	if len(cfg.Subscriptions) == 0 {
+		return nil, fmt.Errorf("no subscriptions configured")
+	}
+
+	subs := make([]subscription, 0, len(cfg.Subscriptions))
+	for _, sc := range cfg.Subscriptions {
+		if err := ValidateFilter(sc.Topic); err != nil {
+			return nil, err
+		}
+		if sc.QoS != 0 && sc.QoS != 1 {
+			return nil, fmt.Errorf("topic %q: unsupported QoS %d (must be 0 or 1)", sc.Topic, sc.QoS)
+		}
+
+		format := sc.Format
+		if format == "" {
+			format = FormatJSON
+		}
+		if format != FormatJSON && format != FormatLine {
+			return nil, fmt.Errorf("topic %q: unknown format %q", sc.Topic, sc.Format)
+		}
+
+		tmpl, err := ParseTemplate(sc.Template)
+		if err != nil {
+			return nil, err
+		}
+
+		subs = append(subs, subscription{
+			filter:   sc.Topic,
+			qos:      byte(sc.QoS),
+			format:   format,
+			template: tmpl,
+		})
+	}
+
+	retryDelay := time.Duration(cfg.ReconnectDelay) * time.Second
+	if retryDelay <= 0 {
+		retryDelay = 5 * time.Second
+	}
+
+	return &Subscriber{
+		cfg:        cfg,
+		writer:     writer,
+		subs:       subs,
+		now:        time.Now,
+		retryDelay: retryDelay,
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start connects in the background, reconnecting until Close
+func (s *Subscriber) Start() error {
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Stats returns a snapshot of the subscriber counters
+func (s *Subscriber) Stats() Stats {
+	return Stats{
+		Connects:         atomic.LoadInt64(&s.stats.Connects),
+		MessagesReceived: atomic.LoadInt64(&s.stats.MessagesReceived),
+		PointsWritten:    atomic.LoadInt64(&s.stats.PointsWritten),
+		PointsRejected:   atomic.LoadInt64(&s.stats.PointsRejected),
+		ParseErrors:      atomic.LoadInt64(&s.stats.ParseErrors),
+		WriteErrors:      atomic.LoadInt64(&s.stats.WriteErrors),
+	}
+}
+
+// LastError returns the error that ended the last connection, if any
+func (s *Subscriber) LastError() error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	return s.lastErr
+}
+
+// Close disconnects from the broker and waits for the subscriber to stop
+func (s *Subscriber) Close() error {
+	close(s.done)
+
+	s.connMu.Lock()
+	if s.conn != nil {
+		s.conn.SetWriteDeadline(time.Now().Add(time.Second))
+		s.send(s.conn, encodePacket(packetDisconnect, 0, nil))
+		s.conn.Close()
+	}
+	s.connMu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Subscriber) run() {
+	defer s.wg.Done()
+
+	for {
+		conn, r, err := s.connect()
+		if err == nil {
+			atomic.AddInt64(&s.stats.Connects, 1)
+			err = s.serve(conn, r)
+		}
+
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+
+		s.errMu.Lock()
+		s.lastErr = err
+		s.errMu.Unlock()
+
+		select {
+		case <-s.done:
+			return
+		case <-time.After(s.retryDelay):
+		}
+	}
+}
+
+// connect dials the broker, completes the handshake and subscribes
+func (s *Subscriber) connect() (net.Conn, *bufio.Reader, error) {
+	addr := s.cfg.Broker
+	for _, scheme := range []string{"tcp://", "mqtt://"} {
+		addr = strings.TrimPrefix(addr, scheme)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.connMu.Lock()
+	select {
+	case <-s.done:
+		s.connMu.Unlock()
+		conn.Close()
+		return nil, nil, errClosed
+	default:
+	}
+	s.conn = conn
+	s.connMu.Unlock()
+
+	fail := func(err error) (net.Conn, *bufio.Reader, error) {
+		s.dropConn(conn)
+		return nil, nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(dialTimeout))
+	r := bufio.NewReader(conn)
+
+	if err := s.send(conn, encodeConnect(connectOptions{
+		clientID:     s.cfg.ClientID,
+		username:     s.cfg.Username,
+		password:     s.cfg.Password,
+		cleanSession: s.cfg.CleanSession,
+		keepAlive:    uint16(s.cfg.KeepAlive),
+	})); err != nil {
+		return fail(err)
+	}
+
+	p, err := readPacket(r)
+	if err != nil {
+		return fail(err)
+	}
+	if err := connackError(p); err != nil {
+		return fail(err)
+	}
+
+	filters := make([]topicFilter, len(s.subs))
+	for i, sub := range s.subs {
+		filters[i] = topicFilter{filter: sub.filter, qos: sub.qos}
+	}
+	if err := s.send(conn, encodeSubscribe(1, filters)); err != nil {
+		return fail(err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, r, nil
+}
+
+func (s *Subscriber) dropConn(conn net.Conn) {
+	s.connMu.Lock()
+	if s.conn == conn {
+		s.conn = nil
+	}
+	s.connMu.Unlock()
+	conn.Close()
+}
+
+// serve reads packets until the connection fails. The SUBACK is handled
+// here because a persistent session may deliver queued messages first.
+func (s *Subscriber) serve(conn net.Conn, r *bufio.Reader) error {
+	defer s.dropConn(conn)
+
+	keepAlive := time.Duration(s.cfg.KeepAlive) * time.Second
+	if keepAlive > 0 {
+		stop := make(chan struct{})
+		defer close(stop)
+		go s.ping(conn, keepAlive/2, stop)
+	}
+
+	for {
+		if keepAlive > 0 {
+			conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2))
+		}
+
+		p, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+
+		switch p.typ {
+		case packetPublish:
+			if err := s.handlePublish(conn, p); err != nil {
+				return err
+			}
+		case packetSuback:
+			if len(p.body) < 2 {
+				return errMalformed
+			}
+			for i, code := range p.body[2:] {
+				if code == subackFailure && i < len(s.subs) {
+					return fmt.Errorf("subscription to %q rejected by broker", s.subs[i].filter)
+				}
+			}
+		}
+	}
+}
+
+func (s *Subscriber) ping(conn net.Conn, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := s.send(conn, encodePacket(packetPingreq, 0, nil)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Subscriber) handlePublish(conn net.Conn, p *packet) error {
+	pub, err := decodePublish(p)
+	if err != nil {
+		return err
+	}
+	if pub.qos > 1 {
+		return fmt.Errorf("unexpected QoS %d message on %q", pub.qos, pub.topic)
+	}
+	atomic.AddInt64(&s.stats.MessagesReceived, 1)
+
+	if sub := s.match(pub.topic); sub != nil {
+		metric, tags := sub.template.Apply(pub.topic)
+		now := s.now().UnixMilli()
+
+		var points []*storage.DataPoint
+		var errs []error
+		if sub.format == FormatLine {
+			points, errs = ParseLineProtocol(pub.payload, tags, now)
+		} else {
+			points, errs = ParseJSON(pub.payload, metric, tags, now)
+		}
+		atomic.AddInt64(&s.stats.ParseErrors, int64(len(errs)))
+
+		if len(points) > 0 {
+			// Points refused by the series limits would be refused again on
+			// redelivery, so the message is still acknowledged
+			rejected, err := storage.SplitWriteError(s.writer.WriteBatch(points))
+			if err != nil {
+				atomic.AddInt64(&s.stats.WriteErrors, 1)
+				return fmt.Errorf("write failed, message on %q not acknowledged: %w", pub.topic, err)
+			}
+			atomic.AddInt64(&s.stats.PointsWritten, int64(len(points)-rejected))
+			atomic.AddInt64(&s.stats.PointsRejected, int64(rejected))
+		}
+	}
+
+	if pub.qos == 1 {
+		return s.send(conn, encodePuback(pub.packetID))
+	}
+	return nil
+}
+
+// match returns the first subscription whose filter matches topic
+func (s *Subscriber) match(topic string) *subscription {
+	for i := range s.subs {
+		if MatchTopic(s.subs[i].filter, topic) {
+			return &s.subs[i]
+		}
+	}
+	return nil
+}
+
+func (s *Subscriber) send(conn net.Conn, data []byte) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_, err := conn.Write(data)
+	return err
+}
@@ -0,0 +1,290 @@
+package mqtt
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// memWriter records written points and fails while failures > 0. Points
+// of the refused metric are rejected as if by the series limits.
+type memWriter struct {
+	mu       sync.Mutex
+	failures int
+	refused  string
+	points   []*storage.DataPoint
+}
+
+func (w *memWriter) WriteBatch(points []*storage.DataPoint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return errors.New("disk full")
+	}
+
+	var rejected []storage.RejectedPoint
+	for i, p := range points {
+		if p.Metric == w.refused {
+			rejected = append(rejected, storage.RejectedPoint{Index: i, Err: storage.ErrSeriesLimit})
+			continue
+		}
+		w.points = append(w.points, p)
+	}
+	if len(rejected) > 0 {
+		return &storage.PartialWriteError{Rejected: rejected}
+	}
+	return nil
+}
+
+func (w *memWriter) written() []*storage.DataPoint {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*storage.DataPoint{}, w.points...)
+}
+
+// testBroker is an in-process broker stand-in. It completes the
+// CONNECT and SUBSCRIBE handshakes and hands each connection to the test.
+type testBroker struct {
+	t     *testing.T
+	ln    net.Listener
+	conns chan *brokerConn
+}
+
+type brokerConn struct {
+	t       *testing.T
+	conn    net.Conn
+	r       *bufio.Reader
+	filters []string
+}
+
+func newTestBroker(t *testing.T) *testBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	b := &testBroker{t: t, ln: ln, conns: make(chan *brokerConn, 4)}
+	go b.acceptLoop()
+	return b
+}
+
+func (b *testBroker) acceptLoop() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &brokerConn{t: b.t, conn: conn, r: bufio.NewReader(conn)}
+		if p, err := readPacket(c.r); err != nil || p.typ != packetConnect {
+			conn.Close()
+			continue
+		}
+		conn.Write(encodePacket(packetConnack, 0, []byte{0, 0}))
+
+		p, err := readPacket(c.r)
+		if err != nil || p.typ != packetSubscribe {
+			conn.Close()
+			continue
+		}
+		rest := p.body[2:]
+		var granted []byte
+		for len(rest) > 0 {
+			var filter string
+			filter, rest, _ = readString(rest)
+			c.filters = append(c.filters, filter)
+			granted = append(granted, rest[0])
+			rest = rest[1:]
+		}
+		conn.Write(encodePacket(packetSuback, 0, append(p.body[:2:2], granted...)))
+
+		b.conns <- c
+	}
+}
+
+func (b *testBroker) accept() *brokerConn {
+	select {
+	case c := <-b.conns:
+		return c
+	case <-time.After(2 * time.Second):
+		b.t.Fatal("timed out waiting for subscriber to connect")
+		return nil
+	}
+}
+
+func (b *testBroker) Close() {
+	b.ln.Close()
+}
+
+// next returns the next packet other than PINGREQ, or nil if the
+// subscriber closed the connection
+func (c *brokerConn) next() *packet {
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		p, err := readPacket(c.r)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				c.t.Fatal("timed out waiting for packet")
+			}
+			return nil
+		}
+		if p.typ != packetPingreq {
+			return p
+		}
+	}
+}
+
+func (c *brokerConn) publish(pub *publish) {
+	c.conn.Write(encodePublish(pub))
+}
+
+func newTestSubscriber(t *testing.T, addr string, writer BatchWriter) *Subscriber {
+	s, err := NewSubscriber(&config.MQTTConfig{
+		Broker:    "tcp://" + addr,
+		ClientID:  "pulsardb-test",
+		KeepAlive: 60,
+		Subscriptions: []config.MQTTSubscription{
+			{Topic: "sensors/+/+", QoS: 1, Format: FormatJSON, Template: "_/device/measurement"},
+			{Topic: "influx/#", QoS: 0, Format: FormatLine},
+		},
+	}, writer)
+	if err != nil {
+		t.Fatalf("NewSubscriber failed: %v", err)
+	}
+	s.retryDelay = 10 * time.Millisecond
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return s
+}
+
+func TestSubscriberAcksAfterWrite(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.Close()
+
+	writer := &memWriter{}
+	s := newTestSubscriber(t, broker.ln.Addr().String(), writer)
+	defer s.Close()
+
+	c := broker.accept()
+	if len(c.filters) != 2 || c.filters[0] != "sensors/+/+" {
+		t.Errorf("unexpected subscriptions: %v", c.filters)
+	}
+
+	c.publish(&publish{topic: "sensors/d1/temp", qos: 1, packetID: 7, payload: []byte(`{"timestamp": 1000, "value": 21.5}`)})
+
+	p := c.next()
+	if p == nil || p.typ != packetPuback || p.body[1] != 7 {
+		t.Fatalf("expected PUBACK for packet 7, got %+v", p)
+	}
+
+	points := writer.written()
+	if len(points) != 1 {
+		t.Fatalf("expected point to be written before the ack, got %d", len(points))
+	}
+	if points[0].Metric != "temp" || points[0].Tags["device"] != "d1" || points[0].Value != 21.5 {
+		t.Errorf("unexpected point: %+v", points[0])
+	}
+
+	c.publish(&publish{topic: "influx/raw", payload: []byte("cpu value=1 1000000\ncpu value=2 2000000")})
+	deadline := time.Now().Add(2 * time.Second)
+	for len(writer.written()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("line protocol points were not written")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if stats := s.Stats(); stats.MessagesReceived != 2 || stats.PointsWritten != 3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSubscriberRedeliveryAfterWriteFailure(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.Close()
+
+	writer := &memWriter{failures: 1}
+	s := newTestSubscriber(t, broker.ln.Addr().String(), writer)
+	defer s.Close()
+
+	msg := &publish{topic: "sensors/d1/temp", qos: 1, packetID: 9, payload: []byte(`{"timestamp": 1000, "value": 1}`)}
+
+	c := broker.accept()
+	c.publish(msg)
+
+	// The failed write is not acknowledged and the connection is dropped
+	if p := c.next(); p != nil {
+		t.Fatalf("expected connection to close without PUBACK, got packet type %d", p.typ)
+	}
+
+	// The broker redelivers after the reconnect
+	c = broker.accept()
+	msg.dup = true
+	c.publish(msg)
+
+	p := c.next()
+	if p == nil || p.typ != packetPuback || p.body[1] != 9 {
+		t.Fatalf("expected PUBACK after redelivery, got %+v", p)
+	}
+
+	if len(writer.written()) != 1 {
+		t.Errorf("expected 1 point after redelivery, got %d", len(writer.written()))
+	}
+	if stats := s.Stats(); stats.WriteErrors != 1 || stats.Connects != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestSubscriberAcksSeriesLimitRejections(t *testing.T) {
+	broker := newTestBroker(t)
+	defer broker.Close()
+
+	writer := &memWriter{refused: "humidity"}
+	s := newTestSubscriber(t, broker.ln.Addr().String(), writer)
+	defer s.Close()
+
+	c := broker.accept()
+	c.publish(&publish{topic: "sensors/d1/temp", qos: 1, packetID: 11, payload: []byte(
+		`[{"timestamp": 1000, "value": 21.5}, {"metric": "humidity", "timestamp": 1000, "value": 40}]`)})
+
+	// Redelivery would be refused again, so the message is acknowledged
+	p := c.next()
+	if p == nil || p.typ != packetPuback || p.body[1] != 11 {
+		t.Fatalf("expected PUBACK for packet 11, got %+v", p)
+	}
+
+	if points := writer.written(); len(points) != 1 || points[0].Metric != "temp" {
+		t.Errorf("expected only the temp point to be written, got %v", points)
+	}
+	stats := s.Stats()
+	if stats.PointsWritten != 1 || stats.PointsRejected != 1 || stats.WriteErrors != 0 || stats.Connects != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestNewSubscriberValidation(t *testing.T) {
+	invalid := []config.MQTTSubscription{
+		{Topic: "a/#/b"},
+		{Topic: "a", QoS: 2},
+		{Topic: "a", Format: "xml"},
+		{Topic: "a", Template: "a/+"},
+	}
+
+	for _, sub := range invalid {
+		if _, err := NewSubscriber(&config.MQTTConfig{Subscriptions: []config.MQTTSubscription{sub}}, &memWriter{}); err == nil {
+			t.Errorf("expected error for %+v", sub)
+		}
+	}
+
+	if _, err := NewSubscriber(&config.MQTTConfig{}, &memWriter{}); err == nil {
+		t.Error("expected error without subscriptions")
+	}
+}
@@ -0,0 +1,99 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MatchTopic reports whether topic matches filter. "+" matches one
+// level and a trailing "#" matches any number of levels, including the
+// parent. Topics starting with "$" are not matched by a leading wildcard.
+func MatchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+
+	for i, f := range fl {
+		if f == "#" {
+			return true
+		}
+		if i >= len(tl) {
+			return false
+		}
+		if f != "+" && f != tl[i] {
+			return false
+		}
+	}
+	return len(fl) == len(tl)
+}
+
+// ValidateFilter checks that wildcards occupy whole levels and that "#"
+// is the last level
+func ValidateFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("empty topic filter")
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+		if strings.Contains(level, "#") && (level != "#" || i != len(levels)-1) {
+			return fmt.Errorf("invalid topic filter %q: '#' must be the last level", filter)
+		}
+		if strings.Contains(level, "+") && level != "+" {
+			return fmt.Errorf("invalid topic filter %q: '+' must occupy a whole level", filter)
+		}
+	}
+	return nil
+}
+
+// Template maps topic levels to a metric name and tags. Each level of
+// the template is "measurement" (part of the metric name), "_" or empty
+// (ignored), or a tag name. Topic levels past the end of the template
+// are ignored.
+type Template struct {
+	levels []string
+}
+
+// ParseTemplate parses a template such as "_/site/device/measurement"
+func ParseTemplate(s string) (*Template, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	levels := strings.Split(s, "/")
+	for _, level := range levels {
+		if strings.ContainsAny(level, "+# ") {
+			return nil, fmt.Errorf("invalid template %q", s)
+		}
+	}
+	return &Template{levels: levels}, nil
+}
+
+// Apply returns the metric name and tags encoded in topic. Measurement
+// levels are joined with ".".
+func (t *Template) Apply(topic string) (string, map[string]string) {
+	tags := make(map[string]string)
+	if t == nil {
+		return "", tags
+	}
+
+	var measurement []string
+	for i, level := range strings.Split(topic, "/") {
+		if i >= len(t.levels) {
+			break
+		}
+		switch name := t.levels[i]; name {
+		case "", "_":
+		case "measurement":
+			measurement = append(measurement, level)
+		default:
+			if level != "" {
+				tags[name] = level
+			}
+		}
+	}
+	return strings.Join(measurement, "."), tags
+}
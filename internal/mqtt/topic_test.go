@@ -0,0 +1,65 @@
+package mqtt
+
+import "testing"
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"sensors/temp", "sensors/temp", true},
+		{"sensors/+", "sensors/temp", true},
+		{"sensors/+", "sensors/temp/a", false},
+		{"sensors/#", "sensors", true},
+		{"sensors/#", "sensors/a/b/c", true},
+		{"+/+/temp", "plant/a/temp", true},
+		{"+/+/temp", "plant/a/humidity", false},
+		{"#", "$SYS/broker/uptime", false},
+		{"$SYS/#", "$SYS/broker/uptime", true},
+		{"sensors/+/", "sensors/a/", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchTopic(tt.filter, tt.topic); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.filter, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	for _, f := range []string{"a/b", "a/+/c", "a/#", "#", "+"} {
+		if err := ValidateFilter(f); err != nil {
+			t.Errorf("ValidateFilter(%q) failed: %v", f, err)
+		}
+	}
+	for _, f := range []string{"", "a/#/c", "a/b#", "a/b+/c"} {
+		if err := ValidateFilter(f); err == nil {
+			t.Errorf("expected error for %q", f)
+		}
+	}
+}
+
+func TestTemplateApply(t *testing.T) {
+	tmpl, err := ParseTemplate("_/site/device/measurement/measurement")
+	if err != nil {
+		t.Fatalf("ParseTemplate failed: %v", err)
+	}
+
+	metric, tags := tmpl.Apply("factory/berlin/press-7/hydraulic/pressure")
+	if metric != "hydraulic.pressure" {
+		t.Errorf("expected metric hydraulic.pressure, got %q", metric)
+	}
+	if tags["site"] != "berlin" || tags["device"] != "press-7" || len(tags) != 2 {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+
+	// A nil template yields no metric and no tags
+	var none *Template
+	if metric, tags := none.Apply("a/b"); metric != "" || len(tags) != 0 {
+		t.Errorf("expected empty result, got %q %v", metric, tags)
+	}
+
+	if _, err := ParseTemplate("a/+/b"); err == nil {
+		t.Error("expected error for wildcard in template")
+	}
+}
@@ -0,0 +1,221 @@
+package opentsdb
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// BatchWriter persists a batch of data points
+type BatchWriter interface {
+	WriteBatch(points []*storage.DataPoint) error
+}
+
+// Stats holds listener counters
+type Stats struct {
+	PointsWritten  int64
+	PointsRejected int64 // refused by the series limits
+	ParseErrors    int64
+	WriteErrors    int64 // failed batches
+}
+
+// Listener serves the OpenTSDB telnet protocol. Points from a connection
+// are written when the batch is full or when the connection has no more
+// buffered input, so a burst of puts becomes one storage write.
+type Listener struct {
+	cfg    *config.OpenTSDBConfig
+	writer BatchWriter
+
+	ln   net.Listener
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+
+	stats Stats // accessed via atomic
+}
+
+// NewListener creates a listener. The socket is opened by Start.
+func NewListener(cfg *config.OpenTSDBConfig, writer BatchWriter) *Listener {
+	return &Listener{
+		cfg:    cfg,
+		writer: writer,
+		done:   make(chan struct{}),
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Start opens the TCP socket and begins accepting connections
+func (l *Listener) Start() error {
+	ln, err := net.Listen("tcp", l.cfg.Address)
+	if err != nil {
+		return err
+	}
+	l.ln = ln
+
+	l.wg.Add(1)
+	go l.acceptLoop()
+
+	return nil
+}
+
+// Addr returns the bound TCP address
+func (l *Listener) Addr() net.Addr {
+	if l.ln == nil {
+		return nil
+	}
+	return l.ln.Addr()
+}
+
+// Stats returns a snapshot of the listener counters
+func (l *Listener) Stats() Stats {
+	return Stats{
+		PointsWritten:  atomic.LoadInt64(&l.stats.PointsWritten),
+		PointsRejected: atomic.LoadInt64(&l.stats.PointsRejected),
+		ParseErrors:    atomic.LoadInt64(&l.stats.ParseErrors),
+		WriteErrors:    atomic.LoadInt64(&l.stats.WriteErrors),
+	}
+}
+
+// Close stops accepting connections and waits for open ones to finish
+func (l *Listener) Close() error {
+	close(l.done)
+	if l.ln != nil {
+		l.ln.Close()
+	}
+
+	l.connsMu.Lock()
+	for conn := range l.conns {
+		conn.Close()
+	}
+	l.connsMu.Unlock()
+
+	l.wg.Wait()
+	return nil
+}
+
+func (l *Listener) acceptLoop() {
+	defer l.wg.Done()
+
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return
+		}
+
+		l.connsMu.Lock()
+		select {
+		case <-l.done:
+			l.connsMu.Unlock()
+			conn.Close()
+			return
+		default:
+		}
+		l.conns[conn] = struct{}{}
+		l.connsMu.Unlock()
+
+		l.wg.Add(1)
+		go l.handleConn(conn)
+	}
+}
+
+func (l *Listener) handleConn(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.connsMu.Lock()
+		delete(l.conns, conn)
+		l.connsMu.Unlock()
+		conn.Close()
+	}()
+
+	batchSize := l.cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	reader := bufio.NewReader(conn)
+	batch := make([]*storage.DataPoint, 0, batchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := l.writer.WriteBatch(batch)
+		var partial *storage.PartialWriteError
+		switch {
+		case errors.As(err, &partial):
+			// Points refused by the series limits fail like invalid puts
+			for _, rej := range partial.Rejected {
+				fmt.Fprintf(conn, "put: %v\n", rej.Err)
+			}
+			atomic.AddInt64(&l.stats.PointsWritten, int64(len(batch)-len(partial.Rejected)))
+			atomic.AddInt64(&l.stats.PointsRejected, int64(len(partial.Rejected)))
+		case err != nil:
+			atomic.AddInt64(&l.stats.WriteErrors, 1)
+			fmt.Fprintf(conn, "put: unexpected error: %v\n", err)
+		default:
+			atomic.AddInt64(&l.stats.PointsWritten, int64(len(batch)))
+		}
+		batch = make([]*storage.DataPoint, 0, batchSize)
+	}
+	defer flush()
+
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			if !l.handleCommand(conn, line, &batch) {
+				return
+			}
+		}
+
+		if err != nil {
+			return
+		}
+
+		if len(batch) >= batchSize || reader.Buffered() == 0 {
+			flush()
+		}
+	}
+}
+
+// handleCommand runs one telnet command and reports whether the
+// connection should stay open
+func (l *Listener) handleCommand(conn net.Conn, line string, batch *[]*storage.DataPoint) bool {
+	cmd := strings.Fields(line)[0]
+
+	switch cmd {
+	case "put":
+		point, err := ParsePut(line)
+		if err != nil {
+			atomic.AddInt64(&l.stats.ParseErrors, 1)
+			fmt.Fprintf(conn, "put: illegal argument: %v\n", err)
+			return true
+		}
+		*batch = append(*batch, point)
+	case "version":
+		fmt.Fprintf(conn, "PulsarDB OpenTSDB-compatible telnet listener\n")
+	case "exit":
+		return false
+	default:
+		fmt.Fprintf(conn, "unknown command: %s.  Try `help'.\n", cmd)
+	}
+
+	return true
+}
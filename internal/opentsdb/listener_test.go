@@ -0,0 +1,78 @@
+package opentsdb
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// memWriter records written points
+type memWriter struct {
+	mu      sync.Mutex
+	batches int
+	points  []*storage.DataPoint
+}
+
+func (w *memWriter) WriteBatch(points []*storage.DataPoint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.batches++
+	w.points = append(w.points, points...)
+	return nil
+}
+
+func TestListener(t *testing.T) {
+	writer := &memWriter{}
+	l := NewListener(&config.OpenTSDBConfig{
+		Address:   "127.0.0.1:0",
+		BatchSize: 100,
+	}, writer)
+	if err := l.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "put sys.cpu 1699267200 1 host=a\nput sys.cpu 1699267201000 2 host=a\nput broken\n")
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("expected error reply: %v", err)
+	}
+	if !strings.HasPrefix(reply, "put: illegal argument") {
+		t.Errorf("unexpected reply %q", reply)
+	}
+
+	fmt.Fprintf(conn, "exit\n")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for l.Stats().PointsWritten < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for points")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats := l.Stats()
+	if stats.ParseErrors != 1 {
+		t.Errorf("expected 1 parse error, got %d", stats.ParseErrors)
+	}
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	if writer.points[0].Timestamp != 1699267200000 || writer.points[1].Timestamp != 1699267201000 {
+		t.Errorf("unexpected timestamps: %d, %d", writer.points[0].Timestamp, writer.points[1].Timestamp)
+	}
+}
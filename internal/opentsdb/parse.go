@@ -0,0 +1,160 @@
+// Package opentsdb implements the OpenTSDB telnet "put" protocol and the
+// JSON body of the HTTP /api/put endpoint.
+package opentsdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// maxSecondsTimestamp is the largest timestamp treated as seconds.
+// OpenTSDB uses 10 digits for seconds and 13 for milliseconds.
+const maxSecondsTimestamp = 9999999999
+
+// DataPoint is the JSON representation accepted by /api/put
+type DataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp json.Number       `json:"timestamp"`
+	Value     json.Number       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// ParseTimestamp converts an OpenTSDB timestamp to milliseconds.
+// Integers of up to 10 digits are seconds, longer ones are milliseconds,
+// and "1699267200.250" is seconds with a millisecond fraction.
+func ParseTimestamp(s string) (int64, error) {
+	if strings.Contains(s, ".") {
+		secs, ms, _ := strings.Cut(s, ".")
+		if len(ms) == 0 || len(ms) > 3 {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		sec, err := strconv.ParseInt(secs, 10, 64)
+		if err != nil || sec <= 0 || sec > maxSecondsTimestamp {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		frac, err := strconv.ParseInt(ms+strings.Repeat("0", 3-len(ms)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		return sec*1000 + frac, nil
+	}
+
+	ts, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || ts <= 0 {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+
+	if ts <= maxSecondsTimestamp {
+		return ts * 1000, nil
+	}
+	if ts <= maxSecondsTimestamp*1000+999 {
+		return ts, nil
+	}
+	return 0, fmt.Errorf("timestamp %q out of range", s)
+}
+
+// ParsePut parses a telnet line "put <metric> <timestamp> <value> <tagk=tagv>..."
+func ParsePut(line string) (*storage.DataPoint, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || fields[0] != "put" {
+		return nil, fmt.Errorf("not a put command")
+	}
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("not enough arguments (need at least 3, got %d)", len(fields)-1)
+	}
+
+	ts, err := ParseTimestamp(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parseValue(fields[3])
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(fields)-4)
+	for _, kv := range fields[4:] {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid tag %q", kv)
+		}
+		tags[k] = v
+	}
+
+	return &storage.DataPoint{
+		Metric:    fields[1],
+		Timestamp: ts,
+		Value:     value,
+		Tags:      tags,
+	}, nil
+}
+
+// ToStorage validates a JSON data point and converts it
+func (dp *DataPoint) ToStorage() (*storage.DataPoint, error) {
+	if dp.Metric == "" {
+		return nil, fmt.Errorf("missing metric")
+	}
+	if dp.Timestamp == "" {
+		return nil, fmt.Errorf("missing timestamp")
+	}
+	if dp.Value == "" {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	ts, err := ParseTimestamp(dp.Timestamp.String())
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := parseValue(dp.Value.String())
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(dp.Tags))
+	for k, v := range dp.Tags {
+		if k == "" || v == "" {
+			return nil, fmt.Errorf("invalid tag %q=%q", k, v)
+		}
+		tags[k] = v
+	}
+
+	return &storage.DataPoint{
+		Metric:    dp.Metric,
+		Timestamp: ts,
+		Value:     value,
+		Tags:      tags,
+	}, nil
+}
+
+// DecodePut decodes a /api/put body holding one data point or an array
+func DecodePut(data []byte) ([]DataPoint, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		var points []DataPoint
+		if err := json.Unmarshal(data, &points); err != nil {
+			return nil, err
+		}
+		return points, nil
+	}
+
+	var point DataPoint
+	if err := json.Unmarshal(data, &point); err != nil {
+		return nil, err
+	}
+	return []DataPoint{point}, nil
+}
+
+func parseValue(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
@@ -0,0 +1,100 @@
+package opentsdb
+
+import (
+	"testing"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"1699267200", 1699267200000, false},
+		{"1699267200123", 1699267200123, false},
+		{"1699267200.5", 1699267200500, false},
+		{"1699267200.250", 1699267200250, false},
+		{"1699267200.2501", 0, true},
+		{"0", 0, true},
+		{"-5", 0, true},
+		{"abc", 0, true},
+		{"99999999999999", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTimestamp(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTimestamp(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseTimestamp(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParsePut(t *testing.T) {
+	point, err := ParsePut("put sys.cpu.user 1699267200 42.5 host=web01 cpu=0")
+	if err != nil {
+		t.Fatalf("ParsePut failed: %v", err)
+	}
+
+	if point.Metric != "sys.cpu.user" || point.Timestamp != 1699267200000 || point.Value != 42.5 {
+		t.Errorf("unexpected point: %+v", point)
+	}
+	if point.Tags["host"] != "web01" || point.Tags["cpu"] != "0" {
+		t.Errorf("unexpected tags: %v", point.Tags)
+	}
+
+	invalid := []string{
+		"put sys.cpu.user 1699267200",
+		"put sys.cpu.user 1699267200 abc host=web01",
+		"put sys.cpu.user 1699267200 NaN",
+		"put sys.cpu.user 1699267200 1 host",
+		"get sys.cpu.user 1699267200 1",
+	}
+	for _, line := range invalid {
+		if _, err := ParsePut(line); err == nil {
+			t.Errorf("expected error for %q", line)
+		}
+	}
+}
+
+func TestDecodePut(t *testing.T) {
+	points, err := DecodePut([]byte(`{"metric":"temp","timestamp":1699267200,"value":21.5,"tags":{"room":"a"}}`))
+	if err != nil {
+		t.Fatalf("DecodePut failed: %v", err)
+	}
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point, got %d", len(points))
+	}
+
+	dp, err := points[0].ToStorage()
+	if err != nil {
+		t.Fatalf("ToStorage failed: %v", err)
+	}
+	if dp.Timestamp != 1699267200000 || dp.Value != 21.5 || dp.Tags["room"] != "a" {
+		t.Errorf("unexpected point: %+v", dp)
+	}
+
+	points, err = DecodePut([]byte(` [
+		{"metric":"temp","timestamp":1699267200500,"value":1},
+		{"metric":"","timestamp":1699267200,"value":2}
+	]`))
+	if err != nil {
+		t.Fatalf("DecodePut failed: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if dp, err := points[0].ToStorage(); err != nil || dp.Timestamp != 1699267200500 {
+		t.Errorf("expected millisecond timestamp, got %+v (%v)", dp, err)
+	}
+	if _, err := points[1].ToStorage(); err == nil {
+		t.Error("expected error for missing metric")
+	}
+
+	if _, err := DecodePut([]byte(`{invalid`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
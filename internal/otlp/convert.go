@@ -0,0 +1,440 @@
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Tag names added for the instrumentation scope
+const (
+	ScopeNameTag    = "otel_scope_name"
+	ScopeVersionTag = "otel_scope_version"
+)
+
+// deltaTotalTTL is how long the running total of a delta series is kept
+// after its last accepted point. A series that comes back later starts
+// again from zero, which rate() treats as a counter reset.
+const deltaTotalTTL = time.Hour
+
+// Converter turns OTLP metrics into data points. Delta sums and
+// histograms are stored as cumulative series, so the converter keeps a
+// running total per output series until it is idle for deltaTotalTTL.
+type Converter struct {
+	mu     sync.Mutex
+	totals map[string]*runningTotal
+	ttl    time.Duration
+	pruned time.Time // last pass over totals for idle series
+	now    func() time.Time
+}
+
+// runningTotal is the cumulative value of a delta series
+type runningTotal struct {
+	value float64
+	seen  time.Time // last accepted point
+}
+
+// NewConverter creates a converter with no accumulated state
+func NewConverter() *Converter {
+	return &Converter{
+		totals: make(map[string]*runningTotal),
+		ttl:    deltaTotalTTL,
+		now:    time.Now,
+	}
+}
+
+// Result holds the converted points and the data points that were rejected
+type Result struct {
+	Points   []*storage.DataPoint
+	Rejected int64
+	Message  string // reason for the first rejection
+
+	// Metadata holds the kind, unit and description of each metric.
+	// Histograms and summaries are described under their base name.
+	Metadata map[string]storage.MetricMetadata
+
+	// deltas are the increments of delta points, added to the running
+	// totals once the points are written
+	deltas  map[*storage.DataPoint]increment
+	pending map[string]float64 // series key -> increments so far
+}
+
+// increment is what a delta point adds to its series total
+type increment struct {
+	key   string
+	value float64
+}
+
+func (res *Result) reject(n int, format string, args ...interface{}) {
+	if n == 0 {
+		return
+	}
+	if res.Rejected == 0 {
+		res.Message = fmt.Sprintf(format, args...)
+	}
+	res.Rejected += int64(n)
+}
+
+// Export converts req, writes the points with write and adds the delta
+// points that were written to their running totals. Points refused by
+// the series limits are counted as rejected; any other write error is
+// returned and leaves the totals unchanged. Exports are serialized so
+// the totals of concurrent requests do not interleave.
+func (c *Converter) Export(req *ExportMetricsServiceRequest, write func([]*storage.DataPoint) error) (*Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	res := c.convert(req)
+	if len(res.Points) == 0 {
+		return res, nil
+	}
+
+	refused := make(map[*storage.DataPoint]bool)
+	if err := write(res.Points); err != nil {
+		var partial *storage.PartialWriteError
+		if !errors.As(err, &partial) {
+			return res, err
+		}
+		for _, r := range partial.Rejected {
+			refused[res.Points[r.Index]] = true
+		}
+		if res.Rejected == 0 {
+			res.Message = partial.Rejected[0].Err.Error()
+		}
+		res.Rejected += int64(len(partial.Rejected))
+	}
+
+	now := c.now()
+	for point, inc := range res.deltas {
+		if refused[point] {
+			continue
+		}
+		total := c.totals[inc.key]
+		if total == nil {
+			total = &runningTotal{}
+			c.totals[inc.key] = total
+		}
+		total.value += inc.value
+		total.seen = now
+	}
+	c.prune(now)
+	return res, nil
+}
+
+// Convert converts every metric in req without writing it. Delta points
+// are offset by the running totals, which only Export updates.
+func (c *Converter) Convert(req *ExportMetricsServiceRequest) *Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.convert(req)
+}
+
+// convert converts req. Callers must hold c.mu.
+func (c *Converter) convert(req *ExportMetricsServiceRequest) *Result {
+	res := &Result{
+		Metadata: make(map[string]storage.MetricMetadata),
+		deltas:   make(map[*storage.DataPoint]increment),
+		pending:  make(map[string]float64),
+	}
+	for _, rm := range req.ResourceMetrics {
+		resourceTags := addAttributes(nil, rm.Resource.Attributes)
+
+		for _, sm := range rm.ScopeMetrics {
+			scopeTags := addAttributes(resourceTags, sm.Scope.Attributes)
+			if sm.Scope.Name != "" {
+				scopeTags[ScopeNameTag] = sm.Scope.Name
+			}
+			if sm.Scope.Version != "" {
+				scopeTags[ScopeVersionTag] = sm.Scope.Version
+			}
+
+			for i := range sm.Metrics {
+				c.convertMetric(res, &sm.Metrics[i], scopeTags)
+			}
+		}
+	}
+	return res
+}
+
+// prune drops the totals of series idle for longer than the TTL, at
+// most once per TTL. Callers must hold c.mu.
+func (c *Converter) prune(now time.Time) {
+	if now.Sub(c.pruned) < c.ttl {
+		return
+	}
+	c.pruned = now
+	for key, total := range c.totals {
+		if now.Sub(total.seen) > c.ttl {
+			delete(c.totals, key)
+		}
+	}
+}
+
+func (c *Converter) convertMetric(res *Result, m *Metric, scopeTags map[string]string) {
+	if kind := m.kind(); kind != "" {
+		res.Metadata[m.Name] = storage.MetricMetadata{Kind: kind, Unit: m.Unit, Help: m.Description}
+	}
+
+	switch {
+	case m.Name == "":
+		res.reject(m.dataPointCount(), "metric name is required")
+	case m.Gauge != nil:
+		for i := range m.Gauge.DataPoints {
+			c.convertNumber(res, m.Name, &m.Gauge.DataPoints[i], scopeTags, false)
+		}
+	case m.Sum != nil:
+		if m.Sum.AggregationTemporality == TemporalityUnspecified {
+			res.reject(len(m.Sum.DataPoints), "metric %q: unspecified aggregation temporality", m.Name)
+			return
+		}
+		delta := m.Sum.AggregationTemporality == TemporalityDelta
+		for i := range m.Sum.DataPoints {
+			c.convertNumber(res, m.Name, &m.Sum.DataPoints[i], scopeTags, delta)
+		}
+	case m.Histogram != nil:
+		if m.Histogram.AggregationTemporality == TemporalityUnspecified {
+			res.reject(len(m.Histogram.DataPoints), "metric %q: unspecified aggregation temporality", m.Name)
+			return
+		}
+		delta := m.Histogram.AggregationTemporality == TemporalityDelta
+		for i := range m.Histogram.DataPoints {
+			c.convertHistogram(res, m.Name, &m.Histogram.DataPoints[i], scopeTags, delta)
+		}
+	case m.ExponentialHistogram != nil:
+		if m.ExponentialHistogram.AggregationTemporality == TemporalityUnspecified {
+			res.reject(len(m.ExponentialHistogram.DataPoints), "metric %q: unspecified aggregation temporality", m.Name)
+			return
+		}
+		delta := m.ExponentialHistogram.AggregationTemporality == TemporalityDelta
+		for i := range m.ExponentialHistogram.DataPoints {
+			c.convertExponential(res, m.Name, &m.ExponentialHistogram.DataPoints[i], scopeTags, delta)
+		}
+	case m.Summary != nil:
+		for i := range m.Summary.DataPoints {
+			c.convertSummary(res, m.Name, &m.Summary.DataPoints[i], scopeTags)
+		}
+	default:
+		res.reject(1, "metric %q has no data", m.Name)
+	}
+}
+
+// kind maps the OTLP data kind to a metric kind. Only monotonic sums
+// are counters.
+func (m *Metric) kind() storage.MetricKind {
+	switch {
+	case m.Gauge != nil:
+		return storage.KindGauge
+	case m.Sum != nil:
+		if m.Sum.IsMonotonic {
+			return storage.KindCounter
+		}
+		return storage.KindGauge
+	case m.Histogram != nil, m.ExponentialHistogram != nil:
+		return storage.KindHistogram
+	case m.Summary != nil:
+		return storage.KindSummary
+	}
+	return ""
+}
+
+func (m *Metric) dataPointCount() int {
+	switch {
+	case m.Gauge != nil:
+		return len(m.Gauge.DataPoints)
+	case m.Sum != nil:
+		return len(m.Sum.DataPoints)
+	case m.Histogram != nil:
+		return len(m.Histogram.DataPoints)
+	case m.ExponentialHistogram != nil:
+		return len(m.ExponentialHistogram.DataPoints)
+	case m.Summary != nil:
+		return len(m.Summary.DataPoints)
+	}
+	return 1
+}
+
+func (c *Converter) convertNumber(res *Result, name string, dp *NumberDataPoint, scopeTags map[string]string, delta bool) {
+	if dp.Flags&flagNoRecordedValue != 0 {
+		return
+	}
+
+	var value float64
+	switch {
+	case dp.AsDouble != nil:
+		value = *dp.AsDouble
+	case dp.AsInt != nil:
+		value = float64(*dp.AsInt)
+	default:
+		res.reject(1, "metric %q: data point has no value", name)
+		return
+	}
+
+	tags := addAttributes(scopeTags, dp.Attributes)
+	ts := c.timestamp(dp.TimeUnixNano)
+	res.Points = append(res.Points, c.point(res, name, ts, value, tags, delta))
+}
+
+// convertHistogram writes Prometheus-style series: <name>_bucket with a
+// cumulative "le" tag, <name>_count, <name>_sum, <name>_min and <name>_max.
+func (c *Converter) convertHistogram(res *Result, name string, dp *HistogramDataPoint, scopeTags map[string]string, delta bool) {
+	if dp.Flags&flagNoRecordedValue != 0 {
+		return
+	}
+	if len(dp.BucketCounts) != 0 && len(dp.BucketCounts) != len(dp.ExplicitBounds)+1 {
+		res.reject(1, "metric %q: %d bucket counts for %d bounds", name, len(dp.BucketCounts), len(dp.ExplicitBounds))
+		return
+	}
+
+	tags := addAttributes(scopeTags, dp.Attributes)
+	ts := c.timestamp(dp.TimeUnixNano)
+
+	var cumulative float64
+	for i, count := range dp.BucketCounts {
+		cumulative += float64(count)
+		le := "+Inf"
+		if i < len(dp.ExplicitBounds) {
+			le = formatBound(dp.ExplicitBounds[i])
+		}
+		res.Points = append(res.Points, c.point(res, name+"_bucket", ts, cumulative, withTag(tags, "le", le), delta))
+	}
+
+	c.appendSummaryStats(res, name, ts, tags, float64(dp.Count), dp.Sum, dp.Min, dp.Max, delta)
+}
+
+// convertExponential writes an exponential histogram using the same
+// series as convertHistogram. Each bucket's upper boundary becomes an
+// "le" tag; negative buckets come first, then the zero bucket as le="0".
+func (c *Converter) convertExponential(res *Result, name string, dp *ExponentialHistogramDataPoint, scopeTags map[string]string, delta bool) {
+	if dp.Flags&flagNoRecordedValue != 0 {
+		return
+	}
+
+	tags := addAttributes(scopeTags, dp.Attributes)
+	ts := c.timestamp(dp.TimeUnixNano)
+	bucket := func(le float64, cumulative float64) {
+		res.Points = append(res.Points, c.point(res, name+"_bucket", ts, cumulative, withTag(tags, "le", formatBound(le)), delta))
+	}
+
+	var cumulative float64
+	// Negative bucket i covers [-base^(i+1), -base^i), so walk from the
+	// highest index to the lowest to get increasing boundaries
+	for i := len(dp.Negative.BucketCounts) - 1; i >= 0; i-- {
+		cumulative += float64(dp.Negative.BucketCounts[i])
+		bucket(-exponentialBound(dp.Scale, int(dp.Negative.Offset)+i), cumulative)
+	}
+
+	cumulative += float64(dp.ZeroCount)
+	bucket(0, cumulative)
+
+	for i, count := range dp.Positive.BucketCounts {
+		cumulative += float64(count)
+		bucket(exponentialBound(dp.Scale, int(dp.Positive.Offset)+i+1), cumulative)
+	}
+
+	bucket(math.Inf(1), float64(dp.Count))
+
+	c.appendSummaryStats(res, name, ts, tags, float64(dp.Count), dp.Sum, dp.Min, dp.Max, delta)
+}
+
+// convertSummary writes <name>{quantile="q"}, <name>_count and <name>_sum
+func (c *Converter) convertSummary(res *Result, name string, dp *SummaryDataPoint, scopeTags map[string]string) {
+	if dp.Flags&flagNoRecordedValue != 0 {
+		return
+	}
+
+	tags := addAttributes(scopeTags, dp.Attributes)
+	ts := c.timestamp(dp.TimeUnixNano)
+
+	for _, q := range dp.QuantileValues {
+		res.Points = append(res.Points, c.point(res, name, ts, q.Value, withTag(tags, "quantile", formatBound(q.Quantile)), false))
+	}
+	sum := dp.Sum
+	c.appendSummaryStats(res, name, ts, tags, float64(dp.Count), &sum, nil, nil, false)
+}
+
+// appendSummaryStats writes the _count, _sum, _min and _max series.
+// Min and max describe one interval, so they are never accumulated.
+func (c *Converter) appendSummaryStats(res *Result, name string, ts int64, tags map[string]string, count float64, sum, min, max *float64, delta bool) {
+	res.Points = append(res.Points, c.point(res, name+"_count", ts, count, tags, delta))
+	if sum != nil {
+		res.Points = append(res.Points, c.point(res, name+"_sum", ts, *sum, tags, delta))
+	}
+	if min != nil {
+		res.Points = append(res.Points, c.point(res, name+"_min", ts, *min, tags, false))
+	}
+	if max != nil {
+		res.Points = append(res.Points, c.point(res, name+"_max", ts, *max, tags, false))
+	}
+}
+
+// point builds a data point. A delta value is added to the series total
+// and to the increments of earlier points of the same request.
+func (c *Converter) point(res *Result, name string, ts int64, value float64, tags map[string]string, delta bool) *storage.DataPoint {
+	point := &storage.DataPoint{
+		Metric:    name,
+		Timestamp: ts,
+		Value:     value,
+		Tags:      tags,
+	}
+
+	if delta {
+		key := point.SeriesKey()
+		res.pending[key] += value
+		point.Value = res.pending[key]
+		if total := c.totals[key]; total != nil {
+			point.Value += total.value
+		}
+		res.deltas[point] = increment{key: key, value: value}
+	}
+	return point
+}
+
+func (c *Converter) timestamp(nanos Uint64) int64 {
+	if nanos == 0 {
+		return c.now().UnixMilli()
+	}
+	return int64(nanos / 1e6)
+}
+
+// exponentialBound returns base^index where base = 2^(2^-scale)
+func exponentialBound(scale int32, index int) float64 {
+	return math.Exp2(float64(index) * math.Exp2(-float64(scale)))
+}
+
+func formatBound(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// addAttributes returns a copy of base with attrs added, attrs winning
+func addAttributes(base map[string]string, attrs []KeyValue) map[string]string {
+	tags := make(map[string]string, len(base)+len(attrs))
+	for k, v := range base {
+		tags[k] = v
+	}
+	for i := range attrs {
+		if attrs[i].Key == "" {
+			continue
+		}
+		if v := attrs[i].Value.String(); v != "" {
+			tags[attrs[i].Key] = v
+		}
+	}
+	return tags
+}
+
+func withTag(tags map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		out[k] = v
+	}
+	out[key] = value
+	return out
+}
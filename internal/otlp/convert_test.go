@@ -0,0 +1,259 @@
+package otlp
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func float(v float64) *float64 { return &v }
+
+func str(s string) AnyValue { return AnyValue{StringValue: &s} }
+
+// bySeries indexes points by series key
+func bySeries(points []*storage.DataPoint) map[string]float64 {
+	out := make(map[string]float64)
+	for _, p := range points {
+		out[p.SeriesKey()] = p.Value
+	}
+	return out
+}
+
+// accept is a write function that stores every point
+func accept([]*storage.DataPoint) error { return nil }
+
+func request(metrics ...Metric) *ExportMetricsServiceRequest {
+	return &ExportMetricsServiceRequest{
+		ResourceMetrics: []ResourceMetrics{{
+			Resource: Resource{Attributes: []KeyValue{{Key: "service", Value: str("api")}}},
+			ScopeMetrics: []ScopeMetrics{{
+				Scope:   InstrumentationScope{Name: "lib", Version: "1.0"},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func TestConvertGaugeTags(t *testing.T) {
+	c := NewConverter()
+	res := c.Convert(request(Metric{
+		Name: "temp",
+		Gauge: &Gauge{DataPoints: []NumberDataPoint{
+			{TimeUnixNano: 1699267200123456789, AsDouble: float(21.5), Attributes: []KeyValue{{Key: "service", Value: str("override")}}},
+			{AsDouble: float(1), Flags: flagNoRecordedValue},
+			{TimeUnixNano: 1},
+		}},
+	}))
+
+	if len(res.Points) != 1 {
+		t.Fatalf("expected 1 point, got %d", len(res.Points))
+	}
+	if res.Rejected != 1 || res.Message == "" {
+		t.Errorf("expected 1 rejected point, got %d (%q)", res.Rejected, res.Message)
+	}
+
+	p := res.Points[0]
+	if p.Timestamp != 1699267200123 || p.Value != 21.5 {
+		t.Errorf("unexpected point: %+v", p)
+	}
+	want := map[string]string{"service": "override", ScopeNameTag: "lib", ScopeVersionTag: "1.0"}
+	for k, v := range want {
+		if p.Tags[k] != v {
+			t.Errorf("tag %s = %q, want %q", k, p.Tags[k], v)
+		}
+	}
+}
+
+func TestConvertDeltaSum(t *testing.T) {
+	c := NewConverter()
+	delta := func(v int64) Metric {
+		i := Int64(v)
+		return Metric{Name: "requests", Sum: &Sum{
+			AggregationTemporality: TemporalityDelta,
+			IsMonotonic:            true,
+			DataPoints:             []NumberDataPoint{{TimeUnixNano: 1e9, AsInt: &i}},
+		}}
+	}
+
+	c.Export(request(delta(5)), accept)
+	res, _ := c.Export(request(delta(3)), accept)
+	if res.Points[0].Value != 8 {
+		t.Errorf("expected delta sums to accumulate to 8, got %f", res.Points[0].Value)
+	}
+
+	// Points that are not written leave the total unchanged
+	c.Export(request(delta(100)), func(points []*storage.DataPoint) error {
+		return &storage.PartialWriteError{Rejected: []storage.RejectedPoint{{Index: 0, Err: storage.ErrSeriesLimit}}}
+	})
+	if _, err := c.Export(request(delta(100)), func([]*storage.DataPoint) error { return errors.New("disk full") }); err == nil {
+		t.Error("expected the write error to be returned")
+	}
+	if res := c.Convert(request(delta(1))); res.Points[0].Value != 9 {
+		t.Errorf("expected refused points not to count, got %f", res.Points[0].Value)
+	}
+
+	res = c.Convert(request(Metric{Name: "bad", Sum: &Sum{DataPoints: []NumberDataPoint{{AsDouble: float(1)}}}}))
+	if res.Rejected != 1 {
+		t.Errorf("expected unspecified temporality to be rejected, got %d", res.Rejected)
+	}
+}
+
+func TestConvertDeltaTotalsExpire(t *testing.T) {
+	c := NewConverter()
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	sum := func(name string, v float64) Metric {
+		return Metric{Name: name, Sum: &Sum{
+			AggregationTemporality: TemporalityDelta,
+			DataPoints:             []NumberDataPoint{{TimeUnixNano: 1e9, AsDouble: float(v)}},
+		}}
+	}
+	c.Export(request(sum("idle", 5), sum("busy", 1)), accept)
+
+	now = now.Add(deltaTotalTTL / 2)
+	c.Export(request(sum("busy", 1)), accept)
+	now = now.Add(deltaTotalTTL)
+	c.Export(request(sum("busy", 1)), accept)
+
+	if len(c.totals) != 1 {
+		t.Fatalf("expected the idle total to be dropped, have %d totals", len(c.totals))
+	}
+	got := bySeries(c.Convert(request(sum("idle", 2), sum("busy", 1))).Points)
+	if got["idle{otel_scope_name=lib,otel_scope_version=1.0,service=api}"] != 2 {
+		t.Errorf("expected the idle series to restart from zero, got %v", got)
+	}
+	if got["busy{otel_scope_name=lib,otel_scope_version=1.0,service=api}"] != 4 {
+		t.Errorf("expected the busy series to keep its total, got %v", got)
+	}
+}
+
+func TestConvertHistogram(t *testing.T) {
+	c := NewConverter()
+	metric := Metric{Name: "latency", Histogram: &Histogram{
+		AggregationTemporality: TemporalityDelta,
+		DataPoints: []HistogramDataPoint{{
+			TimeUnixNano:   1e9,
+			Count:          6,
+			Sum:            float(4.2),
+			BucketCounts:   []Uint64{1, 2, 3},
+			ExplicitBounds: []float64{0.5, 1},
+			Max:            float(2),
+		}},
+	}}
+
+	c.Export(request(metric), accept)
+	got := bySeries(c.Convert(request(metric)).Points)
+
+	tags := "otel_scope_name=lib,otel_scope_version=1.0,service=api"
+	want := map[string]float64{
+		"latency_bucket{le=0.5," + tags + "}":  2,
+		"latency_bucket{le=1," + tags + "}":    6,
+		"latency_bucket{le=+Inf," + tags + "}": 12,
+		"latency_count{" + tags + "}":          12,
+		"latency_sum{" + tags + "}":            8.4,
+		"latency_max{" + tags + "}":            2,
+	}
+	for key, v := range want {
+		if math.Abs(got[key]-v) > 1e-9 {
+			t.Errorf("%s = %f, want %f", key, got[key], v)
+		}
+	}
+
+	res := c.Convert(request(Metric{Name: "bad", Histogram: &Histogram{
+		AggregationTemporality: TemporalityCumulative,
+		DataPoints:             []HistogramDataPoint{{BucketCounts: []Uint64{1}, ExplicitBounds: []float64{1}}},
+	}}))
+	if res.Rejected != 1 {
+		t.Errorf("expected mismatched buckets to be rejected, got %d", res.Rejected)
+	}
+}
+
+func TestConvertExponentialHistogram(t *testing.T) {
+	c := NewConverter()
+	res := c.Convert(request(Metric{Name: "size", ExponentialHistogram: &ExponentialHistogram{
+		AggregationTemporality: TemporalityCumulative,
+		DataPoints: []ExponentialHistogramDataPoint{{
+			TimeUnixNano: 1e9,
+			Count:        10,
+			Scale:        0,
+			ZeroCount:    1,
+			Positive:     Buckets{Offset: 1, BucketCounts: []Uint64{4, 2}},
+			Negative:     Buckets{Offset: 0, BucketCounts: []Uint64{3}},
+		}},
+	}}))
+
+	buckets := make(map[string]float64)
+	for _, p := range res.Points {
+		if p.Metric == "size_bucket" {
+			buckets[p.Tags["le"]] = p.Value
+		}
+	}
+
+	// Scale 0 has base 2: negative bucket 0 is [-2, -1), positive
+	// bucket 1 is (2, 4] and bucket 2 is (4, 8]
+	want := map[string]float64{"-1": 3, "0": 4, "4": 8, "8": 10, "+Inf": 10}
+	if len(buckets) != len(want) {
+		t.Errorf("unexpected buckets: %v", buckets)
+	}
+	for le, v := range want {
+		if buckets[le] != v {
+			t.Errorf("le=%s = %f, want %f", le, buckets[le], v)
+		}
+	}
+}
+
+func TestConvertSummary(t *testing.T) {
+	c := NewConverter()
+	res := c.Convert(request(Metric{Name: "rpc", Summary: &Summary{DataPoints: []SummaryDataPoint{{
+		TimeUnixNano:   1e9,
+		Count:          4,
+		Sum:            10,
+		QuantileValues: []ValueAtQuantile{{Quantile: 0.99, Value: 7}},
+	}}}}))
+
+	got := make(map[string]float64)
+	for _, p := range res.Points {
+		got[p.Metric+p.Tags["quantile"]] = p.Value
+	}
+	if got["rpc0.99"] != 7 || got["rpc_count"] != 4 || got["rpc_sum"] != 10 {
+		t.Errorf("unexpected summary points: %v", got)
+	}
+}
+
+func TestExponentialBound(t *testing.T) {
+	if b := exponentialBound(0, 3); b != 8 {
+		t.Errorf("expected 8, got %f", b)
+	}
+	if b := exponentialBound(1, 2); math.Abs(b-2) > 1e-12 {
+		t.Errorf("expected 2, got %f", b)
+	}
+	if b := exponentialBound(-1, 1); b != 4 {
+		t.Errorf("expected 4, got %f", b)
+	}
+}
+
+func TestConvertMetadata(t *testing.T) {
+	c := NewConverter()
+	res := c.Convert(request(
+		Metric{Name: "temp", Unit: "Cel", Description: "Temperature", Gauge: &Gauge{}},
+		Metric{Name: "requests", Sum: &Sum{IsMonotonic: true, AggregationTemporality: TemporalityCumulative}},
+		Metric{Name: "queue", Sum: &Sum{AggregationTemporality: TemporalityCumulative}},
+		Metric{Name: "latency", Histogram: &Histogram{AggregationTemporality: TemporalityCumulative}},
+	))
+
+	want := map[string]storage.MetricMetadata{
+		"temp":     {Kind: storage.KindGauge, Unit: "Cel", Help: "Temperature"},
+		"requests": {Kind: storage.KindCounter},
+		"queue":    {Kind: storage.KindGauge},
+		"latency":  {Kind: storage.KindHistogram},
+	}
+	for name, md := range want {
+		if res.Metadata[name] != md {
+			t.Errorf("%s: expected %+v, got %+v", name, md, res.Metadata[name])
+		}
+	}
+}
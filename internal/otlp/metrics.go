@@ -0,0 +1,274 @@
+// Package otlp decodes OpenTelemetry (OTLP) metrics export requests and
+// converts them to PulsarDB data points. Only the subset of the OTLP data
+// model needed for ingestion is implemented.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// AggregationTemporality describes how sums and histograms accumulate
+type AggregationTemporality int32
+
+const (
+	TemporalityUnspecified AggregationTemporality = 0
+	TemporalityDelta       AggregationTemporality = 1
+	TemporalityCumulative  AggregationTemporality = 2
+)
+
+var temporalityNames = map[string]AggregationTemporality{
+	"AGGREGATION_TEMPORALITY_UNSPECIFIED": TemporalityUnspecified,
+	"AGGREGATION_TEMPORALITY_DELTA":       TemporalityDelta,
+	"AGGREGATION_TEMPORALITY_CUMULATIVE":  TemporalityCumulative,
+}
+
+// UnmarshalJSON accepts the enum as a number or by name
+func (t *AggregationTemporality) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var name string
+		if err := json.Unmarshal(data, &name); err != nil {
+			return err
+		}
+		v, ok := temporalityNames[name]
+		if !ok {
+			return fmt.Errorf("unknown aggregation temporality %q", name)
+		}
+		*t = v
+		return nil
+	}
+	var v int32
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*t = AggregationTemporality(v)
+	return nil
+}
+
+// Uint64 is a uint64 that decodes from a JSON string or number, since
+// OTLP/JSON encodes 64-bit integers as strings
+type Uint64 uint64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (u *Uint64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseUint(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uint64 %s", data)
+	}
+	*u = Uint64(v)
+	return nil
+}
+
+// Int64 is an int64 that decodes from a JSON string or number
+type Int64 int64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	v, err := strconv.ParseInt(string(bytes.Trim(data, `"`)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 %s", data)
+	}
+	*i = Int64(v)
+	return nil
+}
+
+// ExportMetricsServiceRequest is the body of a /v1/metrics request
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []ResourceMetrics `json:"resourceMetrics"`
+}
+
+// ResourceMetrics groups metrics produced by one resource
+type ResourceMetrics struct {
+	Resource     Resource       `json:"resource"`
+	ScopeMetrics []ScopeMetrics `json:"scopeMetrics"`
+}
+
+// Resource describes the entity producing telemetry
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// ScopeMetrics groups metrics produced by one instrumentation scope
+type ScopeMetrics struct {
+	Scope   InstrumentationScope `json:"scope"`
+	Metrics []Metric             `json:"metrics"`
+}
+
+// InstrumentationScope identifies the library that produced the metrics
+type InstrumentationScope struct {
+	Name       string     `json:"name"`
+	Version    string     `json:"version"`
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// KeyValue is an attribute
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue holds exactly one attribute value. Unset fields are nil.
+type AnyValue struct {
+	StringValue *string       `json:"stringValue,omitempty"`
+	BoolValue   *bool         `json:"boolValue,omitempty"`
+	IntValue    *Int64        `json:"intValue,omitempty"`
+	DoubleValue *float64      `json:"doubleValue,omitempty"`
+	ArrayValue  *ArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *KeyValueList `json:"kvlistValue,omitempty"`
+	BytesValue  []byte        `json:"bytesValue,omitempty"`
+}
+
+// ArrayValue is a list of values
+type ArrayValue struct {
+	Values []AnyValue `json:"values"`
+}
+
+// KeyValueList is a nested set of attributes
+type KeyValueList struct {
+	Values []KeyValue `json:"values"`
+}
+
+// String renders the value as a tag value. Arrays and key/value lists
+// are rendered as JSON.
+func (v *AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	case v.ArrayValue != nil:
+		values := make([]interface{}, len(v.ArrayValue.Values))
+		for i := range v.ArrayValue.Values {
+			values[i] = v.ArrayValue.Values[i].String()
+		}
+		data, _ := json.Marshal(values)
+		return string(data)
+	case v.KvlistValue != nil:
+		values := make(map[string]string, len(v.KvlistValue.Values))
+		for _, kv := range v.KvlistValue.Values {
+			values[kv.Key] = kv.Value.String()
+		}
+		data, _ := json.Marshal(values)
+		return string(data)
+	case v.BytesValue != nil:
+		return fmt.Sprintf("%x", v.BytesValue)
+	}
+	return ""
+}
+
+// Metric is a named metric holding exactly one data kind
+type Metric struct {
+	Name                 string                `json:"name"`
+	Description          string                `json:"description"`
+	Unit                 string                `json:"unit"`
+	Gauge                *Gauge                `json:"gauge,omitempty"`
+	Sum                  *Sum                  `json:"sum,omitempty"`
+	Histogram            *Histogram            `json:"histogram,omitempty"`
+	ExponentialHistogram *ExponentialHistogram `json:"exponentialHistogram,omitempty"`
+	Summary              *Summary              `json:"summary,omitempty"`
+}
+
+// Gauge holds sampled values
+type Gauge struct {
+	DataPoints []NumberDataPoint `json:"dataPoints"`
+}
+
+// Sum holds counter values
+type Sum struct {
+	DataPoints             []NumberDataPoint      `json:"dataPoints"`
+	AggregationTemporality AggregationTemporality `json:"aggregationTemporality"`
+	IsMonotonic            bool                   `json:"isMonotonic"`
+}
+
+// Histogram holds explicit-bucket histograms
+type Histogram struct {
+	DataPoints             []HistogramDataPoint   `json:"dataPoints"`
+	AggregationTemporality AggregationTemporality `json:"aggregationTemporality"`
+}
+
+// ExponentialHistogram holds base-2 exponential histograms
+type ExponentialHistogram struct {
+	DataPoints             []ExponentialHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality AggregationTemporality          `json:"aggregationTemporality"`
+}
+
+// Summary holds precomputed quantiles
+type Summary struct {
+	DataPoints []SummaryDataPoint `json:"dataPoints"`
+}
+
+// flagNoRecordedValue marks a data point that carries no value
+const flagNoRecordedValue = 1
+
+// NumberDataPoint is a gauge or sum value. Exactly one of AsDouble and
+// AsInt is set.
+type NumberDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+	AsInt             *Int64     `json:"asInt,omitempty"`
+	Flags             uint32     `json:"flags"`
+}
+
+// HistogramDataPoint is one explicit-bucket histogram. BucketCounts has
+// one more entry than ExplicitBounds; the last bucket is unbounded.
+type HistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	Count             Uint64     `json:"count"`
+	Sum               *float64   `json:"sum,omitempty"`
+	BucketCounts      []Uint64   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+	Flags             uint32     `json:"flags"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+}
+
+// ExponentialHistogramDataPoint is one exponential histogram. Bucket
+// index i covers (base^i, base^(i+1)] where base = 2^(2^-scale).
+type ExponentialHistogramDataPoint struct {
+	Attributes        []KeyValue `json:"attributes"`
+	StartTimeUnixNano Uint64     `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64     `json:"timeUnixNano"`
+	Count             Uint64     `json:"count"`
+	Sum               *float64   `json:"sum,omitempty"`
+	Scale             int32      `json:"scale"`
+	ZeroCount         Uint64     `json:"zeroCount"`
+	Positive          Buckets    `json:"positive"`
+	Negative          Buckets    `json:"negative"`
+	Flags             uint32     `json:"flags"`
+	Min               *float64   `json:"min,omitempty"`
+	Max               *float64   `json:"max,omitempty"`
+}
+
+// Buckets is a contiguous run of exponential histogram buckets
+// starting at index Offset
+type Buckets struct {
+	Offset       int32    `json:"offset"`
+	BucketCounts []Uint64 `json:"bucketCounts"`
+}
+
+// SummaryDataPoint is one precomputed quantile summary
+type SummaryDataPoint struct {
+	Attributes        []KeyValue        `json:"attributes"`
+	StartTimeUnixNano Uint64            `json:"startTimeUnixNano"`
+	TimeUnixNano      Uint64            `json:"timeUnixNano"`
+	Count             Uint64            `json:"count"`
+	Sum               float64           `json:"sum"`
+	QuantileValues    []ValueAtQuantile `json:"quantileValues"`
+	Flags             uint32            `json:"flags"`
+}
+
+// ValueAtQuantile is one quantile of a summary
+type ValueAtQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
@@ -0,0 +1,92 @@
+package otlp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalJSON(t *testing.T) {
+	body := `{
+		"resourceMetrics": [{
+			"resource": {"attributes": [
+				{"key": "service.name", "value": {"stringValue": "checkout"}},
+				{"key": "replicas", "value": {"intValue": "3"}}
+			]},
+			"scopeMetrics": [{
+				"scope": {"name": "otel-go", "version": "1.2.0"},
+				"metrics": [{
+					"name": "requests",
+					"sum": {
+						"aggregationTemporality": "AGGREGATION_TEMPORALITY_DELTA",
+						"isMonotonic": true,
+						"dataPoints": [{"timeUnixNano": "1699267200000000000", "asInt": "5"}]
+					}
+				}, {
+					"name": "latency",
+					"histogram": {
+						"aggregationTemporality": 2,
+						"dataPoints": [{"count": 3, "sum": 1.5, "bucketCounts": ["1", "2"], "explicitBounds": [0.5]}]
+					}
+				}]
+			}]
+		}]
+	}`
+
+	var req ExportMetricsServiceRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	rm := req.ResourceMetrics[0]
+	if rm.Resource.Attributes[1].Value.String() != "3" {
+		t.Errorf("unexpected int attribute: %q", rm.Resource.Attributes[1].Value.String())
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	sum := metrics[0].Sum
+	if sum.AggregationTemporality != TemporalityDelta || !sum.IsMonotonic {
+		t.Errorf("unexpected sum: %+v", sum)
+	}
+	if sum.DataPoints[0].TimeUnixNano != 1699267200000000000 || *sum.DataPoints[0].AsInt != 5 {
+		t.Errorf("unexpected data point: %+v", sum.DataPoints[0])
+	}
+
+	hist := metrics[1].Histogram
+	if hist.AggregationTemporality != TemporalityCumulative {
+		t.Errorf("expected cumulative temporality, got %d", hist.AggregationTemporality)
+	}
+	if hist.DataPoints[0].Count != 3 || hist.DataPoints[0].BucketCounts[1] != 2 {
+		t.Errorf("unexpected histogram point: %+v", hist.DataPoints[0])
+	}
+
+	if err := json.Unmarshal([]byte(`{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"sum":{"aggregationTemporality":"BOGUS"}}]}]}]}`), &req); err == nil {
+		t.Error("expected error for unknown temporality")
+	}
+}
+
+func TestAnyValueString(t *testing.T) {
+	s := "a"
+	b := true
+	i := Int64(-7)
+	d := 2.5
+
+	tests := []struct {
+		value AnyValue
+		want  string
+	}{
+		{AnyValue{StringValue: &s}, "a"},
+		{AnyValue{BoolValue: &b}, "true"},
+		{AnyValue{IntValue: &i}, "-7"},
+		{AnyValue{DoubleValue: &d}, "2.5"},
+		{AnyValue{ArrayValue: &ArrayValue{Values: []AnyValue{{StringValue: &s}, {IntValue: &i}}}}, `["a","-7"]`},
+		{AnyValue{KvlistValue: &KeyValueList{Values: []KeyValue{{Key: "k", Value: AnyValue{StringValue: &s}}}}}, `{"k":"a"}`},
+		{AnyValue{BytesValue: []byte{0xab, 0x01}}, "ab01"},
+		{AnyValue{}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.value.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package otlp
+
+import (
+	"io"
+	"math"
+
+	"github.com/Pablo997/pulsardb/internal/pbwire"
+)
+
+// walk calls field for each field in data. Fields that field does not
+// consume are skipped, so unknown and unsupported fields are ignored.
+func walk(data []byte, field func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error)) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ok, err := field(r, num, typ)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// embedded decodes a length-delimited sub-message with unmarshal
+func embedded(r *pbwire.Reader, unmarshal func([]byte) error) (bool, error) {
+	buf, err := r.Bytes()
+	if err != nil {
+		return true, err
+	}
+	return true, unmarshal(buf)
+}
+
+func readDouble(r *pbwire.Reader) (*float64, error) {
+	v, err := r.Double()
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func readUint64(r *pbwire.Reader) (Uint64, error) {
+	v, err := r.Fixed64()
+	return Uint64(v), err
+}
+
+func readBucketCounts(r *pbwire.Reader, typ pbwire.WireType, dst []Uint64, fixed bool) ([]Uint64, error) {
+	var values []uint64
+	var err error
+	if fixed {
+		values, err = r.PackedFixed64(typ, nil)
+	} else {
+		values, err = r.PackedVarints(typ, nil)
+	}
+	for _, v := range values {
+		dst = append(dst, Uint64(v))
+	}
+	return dst, err
+}
+
+// Unmarshal decodes a protobuf-encoded request
+func (m *ExportMetricsServiceRequest) Unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if num == 1 && typ == pbwire.Bytes {
+			m.ResourceMetrics = append(m.ResourceMetrics, ResourceMetrics{})
+			return embedded(r, m.ResourceMetrics[len(m.ResourceMetrics)-1].unmarshal)
+		}
+		return false, nil
+	})
+}
+
+func (m *ResourceMetrics) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			return embedded(r, m.Resource.unmarshal)
+		case num == 2 && typ == pbwire.Bytes:
+			m.ScopeMetrics = append(m.ScopeMetrics, ScopeMetrics{})
+			return embedded(r, m.ScopeMetrics[len(m.ScopeMetrics)-1].unmarshal)
+		}
+		return false, nil
+	})
+}
+
+func (m *Resource) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if num == 1 && typ == pbwire.Bytes {
+			return appendKeyValue(r, &m.Attributes)
+		}
+		return false, nil
+	})
+}
+
+func (m *ScopeMetrics) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			return embedded(r, m.Scope.unmarshal)
+		case num == 2 && typ == pbwire.Bytes:
+			m.Metrics = append(m.Metrics, Metric{})
+			return embedded(r, m.Metrics[len(m.Metrics)-1].unmarshal)
+		}
+		return false, nil
+	})
+}
+
+func (m *InstrumentationScope) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			m.Name, err = r.String()
+		case num == 2 && typ == pbwire.Bytes:
+			m.Version, err = r.String()
+		case num == 3 && typ == pbwire.Bytes:
+			return appendKeyValue(r, &m.Attributes)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func appendKeyValue(r *pbwire.Reader, dst *[]KeyValue) (bool, error) {
+	*dst = append(*dst, KeyValue{})
+	return embedded(r, (*dst)[len(*dst)-1].unmarshal)
+}
+
+func (m *KeyValue) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			m.Key, err = r.String()
+		case num == 2 && typ == pbwire.Bytes:
+			return embedded(r, m.Value.unmarshal)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *AnyValue) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			s, err := r.String()
+			m.StringValue = &s
+			return true, err
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			b := v != 0
+			m.BoolValue = &b
+			return true, err
+		case num == 3 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			i := Int64(v)
+			m.IntValue = &i
+			return true, err
+		case num == 4 && typ == pbwire.Fixed64:
+			v, err := readDouble(r)
+			m.DoubleValue = v
+			return true, err
+		case num == 5 && typ == pbwire.Bytes:
+			m.ArrayValue = &ArrayValue{}
+			return embedded(r, m.ArrayValue.unmarshal)
+		case num == 6 && typ == pbwire.Bytes:
+			m.KvlistValue = &KeyValueList{}
+			return embedded(r, m.KvlistValue.unmarshal)
+		case num == 7 && typ == pbwire.Bytes:
+			b, err := r.Bytes()
+			m.BytesValue = append([]byte{}, b...)
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (m *ArrayValue) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if num == 1 && typ == pbwire.Bytes {
+			m.Values = append(m.Values, AnyValue{})
+			return embedded(r, m.Values[len(m.Values)-1].unmarshal)
+		}
+		return false, nil
+	})
+}
+
+func (m *KeyValueList) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if num == 1 && typ == pbwire.Bytes {
+			return appendKeyValue(r, &m.Values)
+		}
+		return false, nil
+	})
+}
+
+func (m *Metric) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if typ != pbwire.Bytes {
+			return false, nil
+		}
+
+		var err error
+		switch num {
+		case 1:
+			m.Name, err = r.String()
+		case 2:
+			m.Description, err = r.String()
+		case 3:
+			m.Unit, err = r.String()
+		case 5:
+			m.Gauge = &Gauge{}
+			return embedded(r, m.Gauge.unmarshal)
+		case 7:
+			m.Sum = &Sum{}
+			return embedded(r, m.Sum.unmarshal)
+		case 9:
+			m.Histogram = &Histogram{}
+			return embedded(r, m.Histogram.unmarshal)
+		case 10:
+			m.ExponentialHistogram = &ExponentialHistogram{}
+			return embedded(r, m.ExponentialHistogram.unmarshal)
+		case 11:
+			m.Summary = &Summary{}
+			return embedded(r, m.Summary.unmarshal)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *Gauge) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if num == 1 && typ == pbwire.Bytes {
+			m.DataPoints = append(m.DataPoints, NumberDataPoint{})
+			return embedded(r, m.DataPoints[len(m.DataPoints)-1].unmarshal)
+		}
+		return false, nil
+	})
+}
+
+func (m *Sum) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			m.DataPoints = append(m.DataPoints, NumberDataPoint{})
+			return embedded(r, m.DataPoints[len(m.DataPoints)-1].unmarshal)
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			m.AggregationTemporality = AggregationTemporality(v)
+			return true, err
+		case num == 3 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			m.IsMonotonic = v != 0
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (m *Histogram) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			m.DataPoints = append(m.DataPoints, HistogramDataPoint{})
+			return embedded(r, m.DataPoints[len(m.DataPoints)-1].unmarshal)
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			m.AggregationTemporality = AggregationTemporality(v)
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (m *ExponentialHistogram) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			m.DataPoints = append(m.DataPoints, ExponentialHistogramDataPoint{})
+			return embedded(r, m.DataPoints[len(m.DataPoints)-1].unmarshal)
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			m.AggregationTemporality = AggregationTemporality(v)
+			return true, err
+		}
+		return false, nil
+	})
+}
+
+func (m *Summary) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		if num == 1 && typ == pbwire.Bytes {
+			m.DataPoints = append(m.DataPoints, SummaryDataPoint{})
+			return embedded(r, m.DataPoints[len(m.DataPoints)-1].unmarshal)
+		}
+		return false, nil
+	})
+}
+
+func (m *NumberDataPoint) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 7 && typ == pbwire.Bytes:
+			return appendKeyValue(r, &m.Attributes)
+		case num == 2 && typ == pbwire.Fixed64:
+			m.StartTimeUnixNano, err = readUint64(r)
+		case num == 3 && typ == pbwire.Fixed64:
+			m.TimeUnixNano, err = readUint64(r)
+		case num == 4 && typ == pbwire.Fixed64:
+			m.AsDouble, err = readDouble(r)
+		case num == 6 && typ == pbwire.Fixed64:
+			var v uint64
+			v, err = r.Fixed64()
+			i := Int64(v)
+			m.AsInt = &i
+		case num == 8 && typ == pbwire.Varint:
+			var v uint64
+			v, err = r.Varint()
+			m.Flags = uint32(v)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *HistogramDataPoint) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 9 && typ == pbwire.Bytes:
+			return appendKeyValue(r, &m.Attributes)
+		case num == 2 && typ == pbwire.Fixed64:
+			m.StartTimeUnixNano, err = readUint64(r)
+		case num == 3 && typ == pbwire.Fixed64:
+			m.TimeUnixNano, err = readUint64(r)
+		case num == 4 && typ == pbwire.Fixed64:
+			m.Count, err = readUint64(r)
+		case num == 5 && typ == pbwire.Fixed64:
+			m.Sum, err = readDouble(r)
+		case num == 6 && (typ == pbwire.Bytes || typ == pbwire.Fixed64):
+			m.BucketCounts, err = readBucketCounts(r, typ, m.BucketCounts, true)
+		case num == 7 && (typ == pbwire.Bytes || typ == pbwire.Fixed64):
+			var bounds []uint64
+			bounds, err = r.PackedFixed64(typ, nil)
+			for _, b := range bounds {
+				m.ExplicitBounds = append(m.ExplicitBounds, math.Float64frombits(b))
+			}
+		case num == 10 && typ == pbwire.Varint:
+			var v uint64
+			v, err = r.Varint()
+			m.Flags = uint32(v)
+		case num == 11 && typ == pbwire.Fixed64:
+			m.Min, err = readDouble(r)
+		case num == 12 && typ == pbwire.Fixed64:
+			m.Max, err = readDouble(r)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *ExponentialHistogramDataPoint) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			return appendKeyValue(r, &m.Attributes)
+		case num == 2 && typ == pbwire.Fixed64:
+			m.StartTimeUnixNano, err = readUint64(r)
+		case num == 3 && typ == pbwire.Fixed64:
+			m.TimeUnixNano, err = readUint64(r)
+		case num == 4 && typ == pbwire.Fixed64:
+			m.Count, err = readUint64(r)
+		case num == 5 && typ == pbwire.Fixed64:
+			m.Sum, err = readDouble(r)
+		case num == 6 && typ == pbwire.Varint:
+			var v int64
+			v, err = r.Sint64()
+			m.Scale = int32(v)
+		case num == 7 && typ == pbwire.Fixed64:
+			m.ZeroCount, err = readUint64(r)
+		case num == 8 && typ == pbwire.Bytes:
+			return embedded(r, m.Positive.unmarshal)
+		case num == 9 && typ == pbwire.Bytes:
+			return embedded(r, m.Negative.unmarshal)
+		case num == 10 && typ == pbwire.Varint:
+			var v uint64
+			v, err = r.Varint()
+			m.Flags = uint32(v)
+		case num == 12 && typ == pbwire.Fixed64:
+			m.Min, err = readDouble(r)
+		case num == 13 && typ == pbwire.Fixed64:
+			m.Max, err = readDouble(r)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *Buckets) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 1 && typ == pbwire.Varint:
+			var v int64
+			v, err = r.Sint64()
+			m.Offset = int32(v)
+		case num == 2 && (typ == pbwire.Bytes || typ == pbwire.Varint):
+			m.BucketCounts, err = readBucketCounts(r, typ, m.BucketCounts, false)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *SummaryDataPoint) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 7 && typ == pbwire.Bytes:
+			return appendKeyValue(r, &m.Attributes)
+		case num == 2 && typ == pbwire.Fixed64:
+			m.StartTimeUnixNano, err = readUint64(r)
+		case num == 3 && typ == pbwire.Fixed64:
+			m.TimeUnixNano, err = readUint64(r)
+		case num == 4 && typ == pbwire.Fixed64:
+			m.Count, err = readUint64(r)
+		case num == 5 && typ == pbwire.Fixed64:
+			var v uint64
+			v, err = r.Fixed64()
+			m.Sum = math.Float64frombits(v)
+		case num == 6 && typ == pbwire.Bytes:
+			m.QuantileValues = append(m.QuantileValues, ValueAtQuantile{})
+			return embedded(r, m.QuantileValues[len(m.QuantileValues)-1].unmarshal)
+		case num == 8 && typ == pbwire.Varint:
+			var v uint64
+			v, err = r.Varint()
+			m.Flags = uint32(v)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+func (m *ValueAtQuantile) unmarshal(data []byte) error {
+	return walk(data, func(r *pbwire.Reader, num int, typ pbwire.WireType) (bool, error) {
+		var err error
+		switch {
+		case num == 1 && typ == pbwire.Fixed64:
+			m.Quantile, err = r.Double()
+		case num == 2 && typ == pbwire.Fixed64:
+			m.Value, err = r.Double()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+// MarshalResponse encodes an ExportMetricsServiceResponse. A partial
+// success is included only when points were rejected.
+func MarshalResponse(rejected int64, message string) []byte {
+	if rejected == 0 && message == "" {
+		return nil
+	}
+	var partial []byte
+	partial = pbwire.AppendInt64(partial, 1, rejected)
+	partial = pbwire.AppendString(partial, 2, message)
+	return pbwire.AppendBytes(nil, 1, partial)
+}
+
+// MarshalStatus encodes a google.rpc.Status, the OTLP/HTTP error body
+func MarshalStatus(code int32, message string) []byte {
+	var b []byte
+	b = pbwire.AppendInt64(b, 1, int64(code))
+	b = pbwire.AppendString(b, 2, message)
+	return b
+}
@@ -0,0 +1,133 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/pbwire"
+)
+
+func keyValue(key, value string) []byte {
+	var v []byte
+	v = pbwire.AppendString(v, 1, value)
+	var kv []byte
+	kv = pbwire.AppendString(kv, 1, key)
+	return pbwire.AppendBytes(kv, 2, v)
+}
+
+func TestUnmarshalProtobuf(t *testing.T) {
+	// Gauge data point with a double value and an attribute
+	var gaugePoint []byte
+	gaugePoint = pbwire.AppendFixed64(gaugePoint, 3, 1699267200000000000)
+	gaugePoint = pbwire.AppendDouble(gaugePoint, 4, 21.5)
+	gaugePoint = pbwire.AppendBytes(gaugePoint, 7, keyValue("room", "a"))
+	gauge := pbwire.AppendBytes(nil, 1, gaugePoint)
+
+	var gaugeMetric []byte
+	gaugeMetric = pbwire.AppendString(gaugeMetric, 1, "temperature")
+	gaugeMetric = pbwire.AppendBytes(gaugeMetric, 5, gauge)
+
+	// Histogram with packed bucket counts and bounds
+	var counts, bounds []byte
+	for _, c := range []uint64{1, 2, 3} {
+		counts = binary.LittleEndian.AppendUint64(counts, c)
+	}
+	for _, b := range []float64{0.5, 1} {
+		bounds = binary.LittleEndian.AppendUint64(bounds, math.Float64bits(b))
+	}
+	var histPoint []byte
+	histPoint = pbwire.AppendFixed64(histPoint, 4, 6)
+	histPoint = pbwire.AppendDouble(histPoint, 5, 4.2)
+	histPoint = pbwire.AppendBytes(histPoint, 6, counts)
+	histPoint = pbwire.AppendBytes(histPoint, 7, bounds)
+	histPoint = pbwire.AppendDouble(histPoint, 11, 0.1)
+	var hist []byte
+	hist = pbwire.AppendBytes(hist, 1, histPoint)
+	hist = pbwire.AppendUint64(hist, 2, uint64(TemporalityDelta))
+
+	var histMetric []byte
+	histMetric = pbwire.AppendString(histMetric, 1, "latency")
+	histMetric = pbwire.AppendBytes(histMetric, 9, hist)
+
+	// Exponential histogram with a negative scale and offset
+	var positive []byte
+	positive = pbwire.AppendSint64(positive, 1, -2)
+	positive = pbwire.AppendBytes(positive, 2, pbwire.AppendVarint(pbwire.AppendVarint(nil, 4), 5))
+	var expPoint []byte
+	expPoint = pbwire.AppendSint64(expPoint, 6, -1)
+	expPoint = pbwire.AppendBytes(expPoint, 8, positive)
+	var exp []byte
+	exp = pbwire.AppendBytes(exp, 1, expPoint)
+	exp = pbwire.AppendUint64(exp, 2, uint64(TemporalityCumulative))
+
+	var expMetric []byte
+	expMetric = pbwire.AppendString(expMetric, 1, "size")
+	expMetric = pbwire.AppendBytes(expMetric, 10, exp)
+
+	var scope []byte
+	scope = pbwire.AppendString(scope, 1, "otel-go")
+	var scopeMetrics []byte
+	scopeMetrics = pbwire.AppendBytes(scopeMetrics, 1, scope)
+	scopeMetrics = pbwire.AppendBytes(scopeMetrics, 2, gaugeMetric)
+	scopeMetrics = pbwire.AppendBytes(scopeMetrics, 2, histMetric)
+	scopeMetrics = pbwire.AppendBytes(scopeMetrics, 2, expMetric)
+
+	resource := pbwire.AppendBytes(nil, 1, keyValue("service.name", "checkout"))
+	var resourceMetrics []byte
+	resourceMetrics = pbwire.AppendBytes(resourceMetrics, 1, resource)
+	resourceMetrics = pbwire.AppendBytes(resourceMetrics, 2, scopeMetrics)
+	data := pbwire.AppendBytes(nil, 1, resourceMetrics)
+
+	var req ExportMetricsServiceRequest
+	if err := req.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	rm := req.ResourceMetrics[0]
+	if rm.Resource.Attributes[0].Key != "service.name" || rm.Resource.Attributes[0].Value.String() != "checkout" {
+		t.Errorf("unexpected resource: %+v", rm.Resource)
+	}
+	sm := rm.ScopeMetrics[0]
+	if sm.Scope.Name != "otel-go" || len(sm.Metrics) != 3 {
+		t.Fatalf("unexpected scope metrics: %+v", sm)
+	}
+
+	gp := sm.Metrics[0].Gauge.DataPoints[0]
+	if gp.TimeUnixNano != 1699267200000000000 || *gp.AsDouble != 21.5 || gp.Attributes[0].Key != "room" {
+		t.Errorf("unexpected gauge point: %+v", gp)
+	}
+
+	h := sm.Metrics[1].Histogram
+	hp := h.DataPoints[0]
+	if h.AggregationTemporality != TemporalityDelta || hp.Count != 6 || *hp.Sum != 4.2 || *hp.Min != 0.1 {
+		t.Errorf("unexpected histogram: %+v", hp)
+	}
+	if len(hp.BucketCounts) != 3 || hp.BucketCounts[2] != 3 || len(hp.ExplicitBounds) != 2 || hp.ExplicitBounds[1] != 1 {
+		t.Errorf("unexpected buckets: %v %v", hp.BucketCounts, hp.ExplicitBounds)
+	}
+
+	ep := sm.Metrics[2].ExponentialHistogram.DataPoints[0]
+	if ep.Scale != -1 || ep.Positive.Offset != -2 || len(ep.Positive.BucketCounts) != 2 || ep.Positive.BucketCounts[1] != 5 {
+		t.Errorf("unexpected exponential histogram: %+v", ep)
+	}
+}
+
+func TestUnmarshalProtobufTruncated(t *testing.T) {
+	var req ExportMetricsServiceRequest
+	if err := req.Unmarshal([]byte{0x0a, 0x05, 0x01}); err == nil {
+		t.Error("expected error for truncated message")
+	}
+}
+
+func TestMarshalResponse(t *testing.T) {
+	if b := MarshalResponse(0, ""); len(b) != 0 {
+		t.Errorf("expected empty response, got %x", b)
+	}
+
+	r := pbwire.NewReader(MarshalResponse(2, "bad"))
+	num, typ, err := r.Next()
+	if err != nil || num != 1 || typ != pbwire.Bytes {
+		t.Fatalf("unexpected field %d/%d: %v", num, typ, err)
+	}
+}
@@ -0,0 +1,249 @@
+// Package pbwire implements the subset of the protobuf wire format needed by
+// PulsarDB's binary protocols (Prometheus remote read, OTLP, gRPC).
+//
+// Messages are encoded and decoded by hand instead of through generated code,
+// which keeps the dependency footprint small for edge deployments.
+package pbwire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WireType is the protobuf wire type stored in the low bits of a field tag
+type WireType int
+
+const (
+	Varint  WireType = 0
+	Fixed64 WireType = 1
+	Bytes   WireType = 2
+	Fixed32 WireType = 5
+)
+
+// ErrTruncated is returned when a message ends in the middle of a field
+var ErrTruncated = errors.New("pbwire: truncated message")
+
+// AppendTag appends a field tag
+func AppendTag(b []byte, num int, typ WireType) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(typ))
+}
+
+// AppendVarint appends a raw varint without a tag
+func AppendVarint(b []byte, v uint64) []byte {
+	return binary.AppendUvarint(b, v)
+}
+
+// AppendUint64 appends a varint field
+func AppendUint64(b []byte, num int, v uint64) []byte {
+	b = AppendTag(b, num, Varint)
+	return binary.AppendUvarint(b, v)
+}
+
+// AppendInt64 appends a varint field using two's complement (proto int64)
+func AppendInt64(b []byte, num int, v int64) []byte {
+	return AppendUint64(b, num, uint64(v))
+}
+
+// AppendSint64 appends a zigzag-encoded varint field (proto sint64)
+func AppendSint64(b []byte, num int, v int64) []byte {
+	return AppendUint64(b, num, uint64(v<<1)^uint64(v>>63))
+}
+
+// AppendBool appends a varint field holding 0 or 1
+func AppendBool(b []byte, num int, v bool) []byte {
+	if v {
+		return AppendUint64(b, num, 1)
+	}
+	return AppendUint64(b, num, 0)
+}
+
+// AppendFixed64 appends a little-endian 8-byte field
+func AppendFixed64(b []byte, num int, v uint64) []byte {
+	b = AppendTag(b, num, Fixed64)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+// AppendDouble appends a double field
+func AppendDouble(b []byte, num int, v float64) []byte {
+	return AppendFixed64(b, num, math.Float64bits(v))
+}
+
+// AppendBytes appends a length-delimited field
+func AppendBytes(b []byte, num int, v []byte) []byte {
+	b = AppendTag(b, num, Bytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendString appends a length-delimited string field
+func AppendString(b []byte, num int, v string) []byte {
+	b = AppendTag(b, num, Bytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// Reader walks the fields of an encoded message
+type Reader struct {
+	buf []byte
+	off int
+}
+
+// NewReader creates a reader over an encoded message
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+// Done reports whether all bytes have been consumed
+func (r *Reader) Done() bool {
+	return r.off >= len(r.buf)
+}
+
+// Next reads the next field tag, returning io.EOF at the end of the message
+func (r *Reader) Next() (int, WireType, error) {
+	if r.Done() {
+		return 0, 0, io.EOF
+	}
+	tag, err := r.Varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	num := int(tag >> 3)
+	if num <= 0 {
+		return 0, 0, fmt.Errorf("pbwire: invalid field number %d", num)
+	}
+	return num, WireType(tag & 7), nil
+}
+
+// Varint reads a raw varint
+func (r *Reader) Varint() (uint64, error) {
+	v, n := binary.Uvarint(r.buf[r.off:])
+	if n <= 0 {
+		return 0, ErrTruncated
+	}
+	r.off += n
+	return v, nil
+}
+
+// Sint64 reads a zigzag-encoded varint
+func (r *Reader) Sint64() (int64, error) {
+	v, err := r.Varint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+// Fixed64 reads a little-endian 8-byte value
+func (r *Reader) Fixed64() (uint64, error) {
+	if len(r.buf)-r.off < 8 {
+		return 0, ErrTruncated
+	}
+	v := binary.LittleEndian.Uint64(r.buf[r.off:])
+	r.off += 8
+	return v, nil
+}
+
+// Double reads a double value
+func (r *Reader) Double() (float64, error) {
+	v, err := r.Fixed64()
+	return math.Float64frombits(v), err
+}
+
+// Fixed32 reads a little-endian 4-byte value
+func (r *Reader) Fixed32() (uint32, error) {
+	if len(r.buf)-r.off < 4 {
+		return 0, ErrTruncated
+	}
+	v := binary.LittleEndian.Uint32(r.buf[r.off:])
+	r.off += 4
+	return v, nil
+}
+
+// Bytes reads a length-delimited value. The returned slice aliases the
+// underlying buffer.
+func (r *Reader) Bytes() ([]byte, error) {
+	n, err := r.Varint()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(r.buf)-r.off) < n {
+		return nil, ErrTruncated
+	}
+	v := r.buf[r.off : r.off+int(n)]
+	r.off += int(n)
+	return v, nil
+}
+
+// String reads a length-delimited value as a string
+func (r *Reader) String() (string, error) {
+	v, err := r.Bytes()
+	return string(v), err
+}
+
+// Skip discards the value of a field with the given wire type
+func (r *Reader) Skip(typ WireType) error {
+	var err error
+	switch typ {
+	case Varint:
+		_, err = r.Varint()
+	case Fixed64:
+		_, err = r.Fixed64()
+	case Bytes:
+		_, err = r.Bytes()
+	case Fixed32:
+		_, err = r.Fixed32()
+	default:
+		err = fmt.Errorf("pbwire: unsupported wire type %d", typ)
+	}
+	return err
+}
+
+// PackedVarints decodes a packed repeated varint field. A non-packed
+// element (wire type Varint) is also accepted, as required by the spec.
+func (r *Reader) PackedVarints(typ WireType, dst []uint64) ([]uint64, error) {
+	if typ == Varint {
+		v, err := r.Varint()
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, v), nil
+	}
+	data, err := r.Bytes()
+	if err != nil {
+		return dst, err
+	}
+	pr := NewReader(data)
+	for !pr.Done() {
+		v, err := pr.Varint()
+		if err != nil {
+			return dst, err
+		}
+		dst = append(dst, v)
+	}
+	return dst, nil
+}
+
+// PackedFixed64 decodes a packed repeated fixed64/double field
+func (r *Reader) PackedFixed64(typ WireType, dst []uint64) ([]uint64, error) {
+	if typ == Fixed64 {
+		v, err := r.Fixed64()
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, v), nil
+	}
+	data, err := r.Bytes()
+	if err != nil {
+		return dst, err
+	}
+	if len(data)%8 != 0 {
+		return dst, ErrTruncated
+	}
+	for i := 0; i < len(data); i += 8 {
+		dst = append(dst, binary.LittleEndian.Uint64(data[i:]))
+	}
+	return dst, nil
+}
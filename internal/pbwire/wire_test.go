@@ -0,0 +1,106 @@
+package pbwire
+
+import (
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var b []byte
+	b = AppendUint64(b, 1, 150)
+	b = AppendString(b, 2, "hello")
+	b = AppendDouble(b, 3, 23.5)
+	b = AppendSint64(b, 4, -42)
+	b = AppendInt64(b, 5, -1)
+
+	r := NewReader(b)
+
+	num, typ, err := r.Next()
+	if err != nil || num != 1 || typ != Varint {
+		t.Fatalf("field 1: num=%d typ=%d err=%v", num, typ, err)
+	}
+	if v, _ := r.Varint(); v != 150 {
+		t.Errorf("expected 150, got %d", v)
+	}
+
+	num, typ, _ = r.Next()
+	if num != 2 || typ != Bytes {
+		t.Fatalf("field 2: num=%d typ=%d", num, typ)
+	}
+	if v, _ := r.String(); v != "hello" {
+		t.Errorf("expected hello, got %q", v)
+	}
+
+	r.Next()
+	if v, _ := r.Double(); v != 23.5 {
+		t.Errorf("expected 23.5, got %f", v)
+	}
+
+	r.Next()
+	if v, _ := r.Sint64(); v != -42 {
+		t.Errorf("expected -42, got %d", v)
+	}
+
+	r.Next()
+	if v, _ := r.Varint(); int64(v) != -1 {
+		t.Errorf("expected -1, got %d", int64(v))
+	}
+
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestSkipUnknownFields(t *testing.T) {
+	var b []byte
+	b = AppendFixed64(b, 7, 99)
+	b = AppendBytes(b, 8, []byte{1, 2, 3})
+	b = AppendUint64(b, 9, 5)
+
+	r := NewReader(b)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			t.Fatal("field 9 not found")
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		if num == 9 {
+			if v, _ := r.Varint(); v != 5 {
+				t.Errorf("expected 5, got %d", v)
+			}
+			return
+		}
+		if err := r.Skip(typ); err != nil {
+			t.Fatalf("Skip failed: %v", err)
+		}
+	}
+}
+
+func TestPackedVarints(t *testing.T) {
+	var packed []byte
+	for _, v := range []uint64{1, 300, 70000} {
+		packed = AppendVarint(packed, v)
+	}
+	b := AppendBytes(nil, 1, packed)
+
+	r := NewReader(b)
+	_, typ, _ := r.Next()
+	got, err := r.PackedVarints(typ, nil)
+	if err != nil {
+		t.Fatalf("PackedVarints failed: %v", err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 300 || got[2] != 70000 {
+		t.Errorf("unexpected values: %v", got)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	b := AppendString(nil, 1, "hello")
+	r := NewReader(b[:len(b)-2])
+	r.Next()
+	if _, err := r.Bytes(); err != ErrTruncated {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+}
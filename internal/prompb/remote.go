@@ -0,0 +1,288 @@
+// Package prompb contains the Prometheus remote storage messages used by
+// PulsarDB, encoded with the hand-written codec in internal/pbwire.
+//
+// Field numbers follow prometheus/prompb/remote.proto and types.proto.
+package prompb
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Pablo997/pulsardb/internal/pbwire"
+)
+
+// MatchType is the type of a label matcher
+type MatchType int32
+
+const (
+	MatchEqual     MatchType = 0 // =
+	MatchNotEqual  MatchType = 1 // !=
+	MatchRegexp    MatchType = 2 // =~
+	MatchNotRegexp MatchType = 3 // !~
+)
+
+// Label is a name/value pair identifying a series
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single timestamped value
+type Sample struct {
+	Value     float64
+	Timestamp int64 // milliseconds
+}
+
+// TimeSeries is a labelled list of samples
+type TimeSeries struct {
+	Labels  []Label
+	Samples []Sample
+}
+
+// LabelMatcher selects series by label value
+type LabelMatcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+}
+
+// Query is a single remote read query
+type Query struct {
+	StartTimestampMs int64
+	EndTimestampMs   int64
+	Matchers         []LabelMatcher
+}
+
+// ReadRequest is the body of a remote read call
+type ReadRequest struct {
+	Queries []Query
+}
+
+// QueryResult holds the series matched by one Query
+type QueryResult struct {
+	Timeseries []TimeSeries
+}
+
+// ReadResponse is the body returned by a remote read call
+type ReadResponse struct {
+	Results []QueryResult
+}
+
+// Marshal encodes the request
+func (m *ReadRequest) Marshal() []byte {
+	var b []byte
+	for i := range m.Queries {
+		b = pbwire.AppendBytes(b, 1, m.Queries[i].marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes the request
+func (m *ReadRequest) Unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if num == 1 && typ == pbwire.Bytes {
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var q Query
+			if err := q.unmarshal(buf); err != nil {
+				return fmt.Errorf("invalid query: %w", err)
+			}
+			m.Queries = append(m.Queries, q)
+			continue
+		}
+
+		if err := r.Skip(typ); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *Query) marshal() []byte {
+	var b []byte
+	if m.StartTimestampMs != 0 {
+		b = pbwire.AppendInt64(b, 1, m.StartTimestampMs)
+	}
+	if m.EndTimestampMs != 0 {
+		b = pbwire.AppendInt64(b, 2, m.EndTimestampMs)
+	}
+	for i := range m.Matchers {
+		b = pbwire.AppendBytes(b, 3, m.Matchers[i].marshal())
+	}
+	return b
+}
+
+func (m *Query) unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			if err != nil {
+				return err
+			}
+			m.StartTimestampMs = int64(v)
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			if err != nil {
+				return err
+			}
+			m.EndTimestampMs = int64(v)
+		case num == 3 && typ == pbwire.Bytes:
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var lm LabelMatcher
+			if err := lm.unmarshal(buf); err != nil {
+				return err
+			}
+			m.Matchers = append(m.Matchers, lm)
+		default:
+			// Includes ReadHints (4), which we don't use
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (m *LabelMatcher) marshal() []byte {
+	var b []byte
+	if m.Type != 0 {
+		b = pbwire.AppendUint64(b, 1, uint64(m.Type))
+	}
+	b = pbwire.AppendString(b, 2, m.Name)
+	b = pbwire.AppendString(b, 3, m.Value)
+	return b
+}
+
+func (m *LabelMatcher) unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			if err != nil {
+				return err
+			}
+			m.Type = MatchType(v)
+		case num == 2 && typ == pbwire.Bytes:
+			if m.Name, err = r.String(); err != nil {
+				return err
+			}
+		case num == 3 && typ == pbwire.Bytes:
+			if m.Value, err = r.String(); err != nil {
+				return err
+			}
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Marshal encodes the response
+func (m *ReadResponse) Marshal() []byte {
+	var b []byte
+	for i := range m.Results {
+		b = pbwire.AppendBytes(b, 1, m.Results[i].marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes the response
+func (m *ReadResponse) Unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if num == 1 && typ == pbwire.Bytes {
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var qr QueryResult
+			if err := qr.unmarshal(buf); err != nil {
+				return err
+			}
+			m.Results = append(m.Results, qr)
+			continue
+		}
+
+		if err := r.Skip(typ); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *QueryResult) marshal() []byte {
+	var b []byte
+	for i := range m.Timeseries {
+		b = pbwire.AppendBytes(b, 1, m.Timeseries[i].Marshal())
+	}
+	return b
+}
+
+func (m *QueryResult) unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if num == 1 && typ == pbwire.Bytes {
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var ts TimeSeries
+			if err := ts.Unmarshal(buf); err != nil {
+				return err
+			}
+			m.Timeseries = append(m.Timeseries, ts)
+			continue
+		}
+
+		if err := r.Skip(typ); err != nil {
+			return err
+		}
+	}
+}
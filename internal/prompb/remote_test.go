@@ -0,0 +1,88 @@
+package prompb
+
+import (
+	"testing"
+)
+
+func TestReadRequestRoundTrip(t *testing.T) {
+	req := &ReadRequest{
+		Queries: []Query{
+			{
+				StartTimestampMs: 1000,
+				EndTimestampMs:   5000,
+				Matchers: []LabelMatcher{
+					{Type: MatchEqual, Name: "__name__", Value: "temperature"},
+					{Type: MatchRegexp, Name: "sensor", Value: "sensor[12]"},
+				},
+			},
+		},
+	}
+
+	var got ReadRequest
+	if err := got.Unmarshal(req.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if len(got.Queries) != 1 {
+		t.Fatalf("expected 1 query, got %d", len(got.Queries))
+	}
+
+	q := got.Queries[0]
+	if q.StartTimestampMs != 1000 || q.EndTimestampMs != 5000 {
+		t.Errorf("unexpected time range: %d-%d", q.StartTimestampMs, q.EndTimestampMs)
+	}
+
+	if len(q.Matchers) != 2 {
+		t.Fatalf("expected 2 matchers, got %d", len(q.Matchers))
+	}
+
+	if q.Matchers[0] != req.Queries[0].Matchers[0] || q.Matchers[1] != req.Queries[0].Matchers[1] {
+		t.Errorf("matchers mismatch: %+v", q.Matchers)
+	}
+}
+
+func TestReadResponseRoundTrip(t *testing.T) {
+	resp := &ReadResponse{
+		Results: []QueryResult{
+			{
+				Timeseries: []TimeSeries{
+					{
+						Labels: []Label{
+							{Name: "__name__", Value: "temperature"},
+							{Name: "sensor", Value: "sensor1"},
+						},
+						Samples: []Sample{
+							{Value: 23.5, Timestamp: 1000},
+							{Value: -1.25, Timestamp: 2000},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var got ReadResponse
+	if err := got.Unmarshal(resp.Marshal()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if len(got.Results) != 1 || len(got.Results[0].Timeseries) != 1 {
+		t.Fatalf("unexpected shape: %+v", got)
+	}
+
+	ts := got.Results[0].Timeseries[0]
+	if len(ts.Labels) != 2 || ts.Labels[1].Value != "sensor1" {
+		t.Errorf("unexpected labels: %+v", ts.Labels)
+	}
+
+	if len(ts.Samples) != 2 || ts.Samples[1].Value != -1.25 || ts.Samples[1].Timestamp != 2000 {
+		t.Errorf("unexpected samples: %+v", ts.Samples)
+	}
+}
+
+func TestReadRequestInvalid(t *testing.T) {
+	var req ReadRequest
+	if err := req.Unmarshal([]byte{0x0a, 0x05, 0x08}); err == nil {
+		t.Error("expected error for truncated request")
+	}
+}
@@ -0,0 +1,135 @@
+package prompb
+
+import (
+	"io"
+
+	"github.com/Pablo997/pulsardb/internal/pbwire"
+)
+
+// Marshal encodes the series
+func (m *TimeSeries) Marshal() []byte {
+	var b []byte
+	for i := range m.Labels {
+		b = pbwire.AppendBytes(b, 1, m.Labels[i].marshal())
+	}
+	for i := range m.Samples {
+		b = pbwire.AppendBytes(b, 2, m.Samples[i].marshal())
+	}
+	return b
+}
+
+// Unmarshal decodes the series
+func (m *TimeSeries) Unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var l Label
+			if err := l.unmarshal(buf); err != nil {
+				return err
+			}
+			m.Labels = append(m.Labels, l)
+		case num == 2 && typ == pbwire.Bytes:
+			buf, err := r.Bytes()
+			if err != nil {
+				return err
+			}
+			var s Sample
+			if err := s.unmarshal(buf); err != nil {
+				return err
+			}
+			m.Samples = append(m.Samples, s)
+		default:
+			// Exemplars and native histograms are not supported
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (m *Label) marshal() []byte {
+	var b []byte
+	b = pbwire.AppendString(b, 1, m.Name)
+	b = pbwire.AppendString(b, 2, m.Value)
+	return b
+}
+
+func (m *Label) unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Bytes:
+			if m.Name, err = r.String(); err != nil {
+				return err
+			}
+		case num == 2 && typ == pbwire.Bytes:
+			if m.Value, err = r.String(); err != nil {
+				return err
+			}
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (m *Sample) marshal() []byte {
+	var b []byte
+	b = pbwire.AppendDouble(b, 1, m.Value)
+	if m.Timestamp != 0 {
+		b = pbwire.AppendInt64(b, 2, m.Timestamp)
+	}
+	return b
+}
+
+func (m *Sample) unmarshal(data []byte) error {
+	r := pbwire.NewReader(data)
+	for {
+		num, typ, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case num == 1 && typ == pbwire.Fixed64:
+			if m.Value, err = r.Double(); err != nil {
+				return err
+			}
+		case num == 2 && typ == pbwire.Varint:
+			v, err := r.Varint()
+			if err != nil {
+				return err
+			}
+			m.Timestamp = int64(v)
+		default:
+			if err := r.Skip(typ); err != nil {
+				return err
+			}
+		}
+	}
+}
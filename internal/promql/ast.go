@@ -0,0 +1,220 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// ValueType is the type an expression evaluates to
+type ValueType string
+
+const (
+	ValueTypeScalar ValueType = "scalar"
+	ValueTypeVector ValueType = "vector"
+	ValueTypeMatrix ValueType = "matrix"
+)
+
+// Expr is a node of a parsed query
+type Expr interface {
+	Type() ValueType
+	String() string
+}
+
+// NumberLiteral is a constant such as 100 or 2.5e3
+type NumberLiteral struct {
+	Val float64
+}
+
+// VectorSelector selects the latest sample of every matching series
+type VectorSelector struct {
+	Name     string
+	Matchers []*storage.Matcher
+	Offset   time.Duration
+}
+
+// MatrixSelector selects all samples of matching series within a range
+type MatrixSelector struct {
+	VectorSelector *VectorSelector
+	Range          time.Duration
+}
+
+// Call is a function call such as rate(x[5m])
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// AggregateExpr is an aggregation such as sum by (host) (x). Param is
+// the parameter of topk and bottomk.
+type AggregateExpr struct {
+	Op       string
+	Expr     Expr
+	Param    Expr
+	Grouping []string
+	Without  bool
+}
+
+// BinaryExpr is an arithmetic or comparison operation between two
+// expressions. Comparisons filter unless ReturnBool is set, in which case
+// they return 0 or 1. Matching is nil unless on or ignoring was given.
+type BinaryExpr struct {
+	Op         string
+	LHS        Expr
+	RHS        Expr
+	ReturnBool bool
+	Matching   *VectorMatching
+}
+
+// VectorMatching describes how the samples of two vectors are paired
+type VectorMatching struct {
+	Card    MatchCardinality
+	On      bool     // match on Labels only; otherwise ignore them
+	Labels  []string // from on(...) or ignoring(...)
+	Include []string // from group_left(...) or group_right(...)
+}
+
+// MatchCardinality is the cardinality of a vector matching
+type MatchCardinality string
+
+const (
+	CardOneToOne  MatchCardinality = "one-to-one"
+	CardManyToOne MatchCardinality = "many-to-one" // group_left
+	CardOneToMany MatchCardinality = "one-to-many" // group_right
+)
+
+// UnaryExpr negates an expression
+type UnaryExpr struct {
+	Op   string
+	Expr Expr
+}
+
+// ParenExpr is a parenthesized expression
+type ParenExpr struct {
+	Expr Expr
+}
+
+func (e *NumberLiteral) Type() ValueType  { return ValueTypeScalar }
+func (e *VectorSelector) Type() ValueType { return ValueTypeVector }
+func (e *MatrixSelector) Type() ValueType { return ValueTypeMatrix }
+func (e *Call) Type() ValueType           { return ValueTypeVector }
+func (e *AggregateExpr) Type() ValueType  { return ValueTypeVector }
+func (e *UnaryExpr) Type() ValueType      { return e.Expr.Type() }
+func (e *ParenExpr) Type() ValueType      { return e.Expr.Type() }
+
+func (e *BinaryExpr) Type() ValueType {
+	if e.LHS.Type() == ValueTypeScalar && e.RHS.Type() == ValueTypeScalar {
+		return ValueTypeScalar
+	}
+	return ValueTypeVector
+}
+
+func (e *NumberLiteral) String() string {
+	return formatFloat(e.Val)
+}
+
+func (e *VectorSelector) String() string {
+	var matchers []string
+	for _, m := range e.Matchers {
+		if m.Name == storage.MetricNameLabel && m.Type == storage.MatchEqual && m.Value == e.Name {
+			continue
+		}
+		matchers = append(matchers, m.String())
+	}
+
+	s := e.Name
+	if len(matchers) > 0 || e.Name == "" {
+		s += "{" + strings.Join(matchers, ",") + "}"
+	}
+	if e.Offset != 0 {
+		s += " offset " + FormatDuration(e.Offset)
+	}
+	return s
+}
+
+func (e *MatrixSelector) String() string {
+	vs := *e.VectorSelector
+	vs.Offset = 0
+	s := fmt.Sprintf("%s[%s]", vs.String(), FormatDuration(e.Range))
+	if e.VectorSelector.Offset != 0 {
+		s += " offset " + FormatDuration(e.VectorSelector.Offset)
+	}
+	return s
+}
+
+func (e *Call) String() string {
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = arg.String()
+	}
+	return fmt.Sprintf("%s(%s)", e.Func, strings.Join(args, ", "))
+}
+
+func (e *AggregateExpr) String() string {
+	s := e.Op
+	if e.Without {
+		s += fmt.Sprintf(" without (%s)", strings.Join(e.Grouping, ", "))
+	} else if len(e.Grouping) > 0 {
+		s += fmt.Sprintf(" by (%s)", strings.Join(e.Grouping, ", "))
+	}
+	if e.Param != nil {
+		return fmt.Sprintf("%s (%s, %s)", s, e.Param, e.Expr)
+	}
+	return fmt.Sprintf("%s (%s)", s, e.Expr)
+}
+
+func (e *BinaryExpr) String() string {
+	op := e.Op
+	if e.ReturnBool {
+		op += " bool"
+	}
+	if m := e.Matching; m != nil {
+		if m.On {
+			op += fmt.Sprintf(" on (%s)", strings.Join(m.Labels, ", "))
+		} else {
+			op += fmt.Sprintf(" ignoring (%s)", strings.Join(m.Labels, ", "))
+		}
+		switch m.Card {
+		case CardManyToOne:
+			op += fmt.Sprintf(" group_left (%s)", strings.Join(m.Include, ", "))
+		case CardOneToMany:
+			op += fmt.Sprintf(" group_right (%s)", strings.Join(m.Include, ", "))
+		}
+	}
+	return fmt.Sprintf("%s %s %s", e.LHS, op, e.RHS)
+}
+
+func (e *UnaryExpr) String() string {
+	return e.Op + e.Expr.String()
+}
+
+func (e *ParenExpr) String() string {
+	return "(" + e.Expr.String() + ")"
+}
+
+// Inspect calls f for every node of the tree in depth-first order
+func Inspect(expr Expr, f func(Expr)) {
+	f(expr)
+	switch e := expr.(type) {
+	case *MatrixSelector:
+		Inspect(e.VectorSelector, f)
+	case *Call:
+		for _, arg := range e.Args {
+			Inspect(arg, f)
+		}
+	case *AggregateExpr:
+		if e.Param != nil {
+			Inspect(e.Param, f)
+		}
+		Inspect(e.Expr, f)
+	case *BinaryExpr:
+		Inspect(e.LHS, f)
+		Inspect(e.RHS, f)
+	case *UnaryExpr:
+		Inspect(e.Expr, f)
+	case *ParenExpr:
+		Inspect(e.Expr, f)
+	}
+}
@@ -0,0 +1,740 @@
+package promql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// LookbackDelta is how far back an instant selector looks for a sample
+const LookbackDelta = 5 * time.Minute
+
+// MaxPointsPerSeries limits the resolution of range queries, matching
+// the limit enforced by Prometheus
+const MaxPointsPerSeries = 11000
+
+// evaluator evaluates an expression at individual timestamps using
+// series data fetched once for the whole query window
+type evaluator struct {
+	lookback int64
+	data     map[*VectorSelector][]Series
+}
+
+// Instant evaluates expr at timestamp ts (milliseconds). It returns
+// ctx.Err() if ctx is done before evaluation.
+func Instant(ctx context.Context, q Queryable, expr Expr, ts int64) (Value, error) {
+	ev, err := newEvaluator(q, expr, ts, ts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	val, err := ev.eval(expr, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	// topk and bottomk return their samples ranked
+	if vec, ok := val.(Vector); ok && !isRanking(expr) {
+		sortVector(vec)
+	}
+	return val, nil
+}
+
+// Range evaluates expr at every step in [start, end] (milliseconds). It
+// stops with ctx.Err() once ctx is done.
+func Range(ctx context.Context, q Queryable, expr Expr, start, end int64, step time.Duration) (Matrix, error) {
+	stepMs := step.Milliseconds()
+	if stepMs <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	}
+	if end < start {
+		return nil, fmt.Errorf("end must not be before start")
+	}
+	// end-start can overflow int64 but not uint64
+	steps := uint64(end-start) / uint64(stepMs)
+	if steps >= MaxPointsPerSeries {
+		return nil, fmt.Errorf("exceeded maximum resolution of %d points per series, try a larger step", MaxPointsPerSeries)
+	}
+
+	ev, err := newEvaluator(q, expr, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	bySeries := make(map[string]*Series)
+	add := func(metric Labels, p Point) {
+		key := metric.Key()
+		ser, ok := bySeries[key]
+		if !ok {
+			ser = &Series{Metric: metric}
+			bySeries[key] = ser
+		}
+		ser.Points = append(ser.Points, p)
+	}
+
+	// Stepping by index cannot wrap around past end
+	for i := uint64(0); i <= steps; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ts := start + int64(i)*stepMs
+		val, err := ev.eval(expr, ts)
+		if err != nil {
+			return nil, err
+		}
+
+		switch v := val.(type) {
+		case Scalar:
+			add(Labels{}, Point(v))
+		case Vector:
+			for _, s := range v {
+				add(s.Metric, s.Point)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(bySeries))
+	for key := range bySeries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(Matrix, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *bySeries[key])
+	}
+	return result, nil
+}
+
+// newEvaluator fetches the data for every selector in expr, widened by
+// the selector's range (or the lookback delta) and offset
+func newEvaluator(q Queryable, expr Expr, start, end int64) (*evaluator, error) {
+	ev := &evaluator{
+		lookback: LookbackDelta.Milliseconds(),
+		data:     make(map[*VectorSelector][]Series),
+	}
+
+	ranges := make(map[*VectorSelector]int64)
+	var selectors []*VectorSelector
+	Inspect(expr, func(node Expr) {
+		switch n := node.(type) {
+		case *MatrixSelector:
+			ranges[n.VectorSelector] = n.Range.Milliseconds()
+		case *VectorSelector:
+			selectors = append(selectors, n)
+		}
+	})
+
+	for _, vs := range selectors {
+		window, ok := ranges[vs]
+		if !ok {
+			window = ev.lookback
+		}
+		offset := vs.Offset.Milliseconds()
+
+		series, err := q.Select(vs.Matchers, start-offset-window, end-offset)
+		if err != nil {
+			return nil, err
+		}
+		ev.data[vs] = series
+	}
+
+	return ev, nil
+}
+
+func (ev *evaluator) eval(expr Expr, ts int64) (Value, error) {
+	switch e := expr.(type) {
+	case *NumberLiteral:
+		return Scalar{T: ts, V: e.Val}, nil
+
+	case *ParenExpr:
+		return ev.eval(e.Expr, ts)
+
+	case *UnaryExpr:
+		val, err := ev.eval(e.Expr, ts)
+		if err != nil {
+			return nil, err
+		}
+		switch v := val.(type) {
+		case Scalar:
+			return Scalar{T: ts, V: -v.V}, nil
+		case Vector:
+			out := make(Vector, len(v))
+			for i, s := range v {
+				out[i] = Sample{Metric: s.Metric.without(storage.MetricNameLabel), Point: Point{T: ts, V: -s.V}}
+			}
+			return out, nil
+		}
+
+	case *VectorSelector:
+		return ev.evalVectorSelector(e, ts), nil
+
+	case *Call:
+		return ev.evalCall(e, ts)
+
+	case *AggregateExpr:
+		val, err := ev.eval(e.Expr, ts)
+		if err != nil {
+			return nil, err
+		}
+		if e.Param != nil {
+			param, err := ev.eval(e.Param, ts)
+			if err != nil {
+				return nil, err
+			}
+			return topK(e, val.(Vector), param.(Scalar).V), nil
+		}
+		return aggregate(e, val.(Vector), ts), nil
+
+	case *BinaryExpr:
+		lhs, err := ev.eval(e.LHS, ts)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := ev.eval(e.RHS, ts)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(e, lhs, rhs, ts)
+	}
+
+	return nil, fmt.Errorf("unexpected expression %s", expr)
+}
+
+func (ev *evaluator) evalVectorSelector(vs *VectorSelector, ts int64) Vector {
+	refT := ts - vs.Offset.Milliseconds()
+	var out Vector
+
+	for _, ser := range ev.data[vs] {
+		// Index of the first point after refT
+		i := sort.Search(len(ser.Points), func(i int) bool {
+			return ser.Points[i].T > refT
+		})
+		if i == 0 {
+			continue
+		}
+
+		p := ser.Points[i-1]
+		if p.T <= refT-ev.lookback {
+			continue
+		}
+		out = append(out, Sample{Metric: ser.Metric, Point: Point{T: ts, V: p.V}})
+	}
+
+	return out
+}
+
+// evalMatrixSelector returns the points of every series within
+// (ts-range, ts], shifted by the offset
+func (ev *evaluator) evalMatrixSelector(ms *MatrixSelector, ts int64) Matrix {
+	vs := ms.VectorSelector
+	maxt := ts - vs.Offset.Milliseconds()
+	mint := maxt - ms.Range.Milliseconds()
+
+	var out Matrix
+	for _, ser := range ev.data[vs] {
+		lo := sort.Search(len(ser.Points), func(i int) bool {
+			return ser.Points[i].T > mint
+		})
+		hi := sort.Search(len(ser.Points), func(i int) bool {
+			return ser.Points[i].T > maxt
+		})
+		if lo >= hi {
+			continue
+		}
+		out = append(out, Series{Metric: ser.Metric, Points: ser.Points[lo:hi]})
+	}
+	return out
+}
+
+func (ev *evaluator) evalCall(call *Call, ts int64) (Value, error) {
+	if functions[call.Func].args[0] == ValueTypeVector {
+		return ev.evalMathCall(call, ts)
+	}
+
+	ms, ok := call.Args[0].(*MatrixSelector)
+	if !ok {
+		return nil, fmt.Errorf("function %q expects a range vector selector", call.Func)
+	}
+
+	rangeEnd := ts - ms.VectorSelector.Offset.Milliseconds()
+	rangeStart := rangeEnd - ms.Range.Milliseconds()
+
+	var out Vector
+	for _, ser := range ev.evalMatrixSelector(ms, ts) {
+		var v float64
+		var ok bool
+
+		switch call.Func {
+		case "rate":
+			v, ok = extrapolatedRate(ser.Points, rangeStart, rangeEnd, true)
+		case "increase":
+			v, ok = extrapolatedRate(ser.Points, rangeStart, rangeEnd, false)
+		case "irate":
+			v, ok = instantRate(ser.Points)
+		case "avg_over_time", "min_over_time", "max_over_time", "sum_over_time", "count_over_time", "last_over_time":
+			v, ok = aggregateOverTime(call.Func, ser.Points), true
+		default:
+			return nil, fmt.Errorf("unknown function %q", call.Func)
+		}
+
+		if ok {
+			out = append(out, Sample{Metric: ser.Metric.without(storage.MetricNameLabel), Point: Point{T: ts, V: v}})
+		}
+	}
+
+	return out, nil
+}
+
+// mathFuncs are the functions applied to each sample on their own
+var mathFuncs = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+	"sqrt":  math.Sqrt,
+	"exp":   math.Exp,
+	"ln":    math.Log,
+	"log2":  math.Log2,
+	"log10": math.Log10,
+}
+
+// evalMathCall applies a function to every sample of its instant vector
+// argument. The remaining arguments are scalars.
+func (ev *evaluator) evalMathCall(call *Call, ts int64) (Value, error) {
+	val, err := ev.eval(call.Args[0], ts)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]float64, len(call.Args)-1)
+	for i, arg := range call.Args[1:] {
+		v, err := ev.eval(arg, ts)
+		if err != nil {
+			return nil, err
+		}
+		params[i] = v.(Scalar).V
+	}
+
+	var f func(float64) float64
+	switch call.Func {
+	case "round":
+		toNearest := 1.0
+		if len(params) > 0 {
+			toNearest = params[0]
+		}
+		// Dividing by the inverse is more exact for fractions such as 0.1
+		inv := 1 / toNearest
+		f = func(v float64) float64 { return math.Floor(v*inv+0.5) / inv }
+	case "clamp":
+		lo, hi := params[0], params[1]
+		if hi < lo {
+			return Vector{}, nil
+		}
+		f = func(v float64) float64 { return math.Max(lo, math.Min(hi, v)) }
+	case "clamp_min":
+		f = func(v float64) float64 { return math.Max(params[0], v) }
+	case "clamp_max":
+		f = func(v float64) float64 { return math.Min(params[0], v) }
+	default:
+		var ok bool
+		if f, ok = mathFuncs[call.Func]; !ok {
+			return nil, fmt.Errorf("unknown function %q", call.Func)
+		}
+	}
+
+	vec := val.(Vector)
+	out := make(Vector, len(vec))
+	for i, s := range vec {
+		out[i] = Sample{Metric: s.Metric.without(storage.MetricNameLabel), Point: Point{T: ts, V: f(s.V)}}
+	}
+	return out, nil
+}
+
+// extrapolatedRate calculates the increase of a counter over the range,
+// handling counter resets and extrapolating to the range boundaries the
+// same way Prometheus does. If isRate is set the result is per second.
+func extrapolatedRate(points []Point, rangeStart, rangeEnd int64, isRate bool) (float64, bool) {
+	if len(points) < 2 {
+		return 0, false
+	}
+
+	first, last := points[0], points[len(points)-1]
+	sampledInterval := float64(last.T-first.T) / 1000
+	if sampledInterval <= 0 {
+		return 0, false
+	}
+
+	result := last.V - first.V
+	prev := first.V
+	for _, p := range points[1:] {
+		if p.V < prev {
+			result += prev
+		}
+		prev = p.V
+	}
+
+	durationToStart := float64(first.T-rangeStart) / 1000
+	durationToEnd := float64(rangeEnd-last.T) / 1000
+	averageInterval := sampledInterval / float64(len(points)-1)
+
+	// A counter can't go below zero, so don't extrapolate past that point
+	if result > 0 && first.V >= 0 {
+		durationToZero := sampledInterval * (first.V / result)
+		if durationToZero < durationToStart {
+			durationToStart = durationToZero
+		}
+	}
+
+	threshold := averageInterval * 1.1
+	extrapolateTo := sampledInterval
+	if durationToStart < threshold {
+		extrapolateTo += durationToStart
+	} else {
+		extrapolateTo += averageInterval / 2
+	}
+	if durationToEnd < threshold {
+		extrapolateTo += durationToEnd
+	} else {
+		extrapolateTo += averageInterval / 2
+	}
+
+	result *= extrapolateTo / sampledInterval
+	if isRate {
+		result /= float64(rangeEnd-rangeStart) / 1000
+	}
+
+	return result, true
+}
+
+// instantRate calculates the per-second rate from the last two points
+func instantRate(points []Point) (float64, bool) {
+	if len(points) < 2 {
+		return 0, false
+	}
+
+	prev, last := points[len(points)-2], points[len(points)-1]
+	dt := float64(last.T-prev.T) / 1000
+	if dt <= 0 {
+		return 0, false
+	}
+
+	delta := last.V - prev.V
+	if last.V < prev.V {
+		// Counter reset
+		delta = last.V
+	}
+	return delta / dt, true
+}
+
+// aggregateOverTime reduces the points of a range to one value
+func aggregateOverTime(fn string, points []Point) float64 {
+	v := points[0].V
+	for i, p := range points[1:] {
+		switch fn {
+		case "avg_over_time":
+			v += (p.V - v) / float64(i+2)
+		case "min_over_time":
+			if p.V < v || math.IsNaN(v) {
+				v = p.V
+			}
+		case "max_over_time":
+			if p.V > v || math.IsNaN(v) {
+				v = p.V
+			}
+		case "sum_over_time":
+			v += p.V
+		}
+	}
+
+	switch fn {
+	case "count_over_time":
+		return float64(len(points))
+	case "last_over_time":
+		return points[len(points)-1].V
+	}
+	return v
+}
+
+// aggregate groups the samples and reduces each group to one sample
+func aggregate(agg *AggregateExpr, vec Vector, ts int64) Vector {
+	type group struct {
+		metric Labels
+		value  float64
+		count  int
+	}
+
+	groups := make(map[string]*group)
+	var order []string
+
+	dropped := append([]string{storage.MetricNameLabel}, agg.Grouping...)
+
+	for _, s := range vec {
+		var metric Labels
+		if agg.Without {
+			metric = s.Metric.without(dropped...)
+		} else {
+			metric = Labels{}
+			for _, name := range agg.Grouping {
+				if v, ok := s.Metric[name]; ok {
+					metric[name] = v
+				}
+			}
+		}
+
+		key := metric.Key()
+		g, ok := groups[key]
+		if !ok {
+			g = &group{metric: metric, value: s.V}
+			groups[key] = g
+			order = append(order, key)
+			g.count = 1
+			continue
+		}
+
+		g.count++
+		switch agg.Op {
+		case "sum":
+			g.value += s.V
+		case "avg":
+			// Incremental mean avoids overflow on large sums
+			g.value += (s.V - g.value) / float64(g.count)
+		case "min":
+			if s.V < g.value || math.IsNaN(g.value) {
+				g.value = s.V
+			}
+		case "max":
+			if s.V > g.value || math.IsNaN(g.value) {
+				g.value = s.V
+			}
+		}
+	}
+
+	out := make(Vector, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		v := g.value
+		if agg.Op == "count" {
+			v = float64(g.count)
+		}
+		out = append(out, Sample{Metric: g.metric, Point: Point{T: ts, V: v}})
+	}
+
+	sortVector(out)
+	return out
+}
+
+// binaryOp applies an arithmetic or comparison operator. Comparisons keep
+// the samples for which they hold, with the left-hand value (the vector's
+// value against a scalar), or return 0 or 1 with bool. Vector/vector
+// operations match samples on all labels except the metric name, or as
+// given by the expression's on, ignoring and group modifiers.
+func binaryOp(e *BinaryExpr, lhs, rhs Value, ts int64) (Value, error) {
+	apply := func(l, r float64) (float64, bool) {
+		if !isComparison(e.Op) {
+			return applyOp(e.Op, l, r), true
+		}
+		ok := compareOp(e.Op, l, r)
+		if !e.ReturnBool {
+			return l, ok
+		}
+		if ok {
+			return 1, true
+		}
+		return 0, true
+	}
+	// Filters keep the metric name since they don't change the value
+	dropName := !isComparison(e.Op) || e.ReturnBool
+
+	ls, lScalar := lhs.(Scalar)
+	rs, rScalar := rhs.(Scalar)
+
+	switch {
+	case lScalar && rScalar:
+		v, _ := apply(ls.V, rs.V)
+		return Scalar{T: ts, V: v}, nil
+
+	case lScalar || rScalar:
+		var vec Vector
+		var scalar float64
+		if rScalar {
+			vec, scalar = lhs.(Vector), rs.V
+		} else {
+			vec, scalar = rhs.(Vector), ls.V
+		}
+
+		out := make(Vector, 0, len(vec))
+		for _, s := range vec {
+			l, r := s.V, scalar
+			if lScalar {
+				l, r = r, l
+			}
+			v, keep := apply(l, r)
+			if !keep {
+				continue
+			}
+			if isComparison(e.Op) && !e.ReturnBool {
+				v = s.V
+			}
+			metric := s.Metric
+			if dropName {
+				metric = metric.without(storage.MetricNameLabel)
+			}
+			out = append(out, Sample{Metric: metric, Point: Point{T: ts, V: v}})
+		}
+		return out, nil
+	}
+
+	matching := e.Matching
+	if matching == nil {
+		matching = &VectorMatching{Card: CardOneToOne}
+	}
+
+	// The "one" side must have a single sample per signature; with
+	// group_right it is the left-hand side
+	many, one := lhs.(Vector), rhs.(Vector)
+	oneSide, manySide := "right", "left"
+	if matching.Card == CardOneToMany {
+		many, one = one, many
+		oneSide, manySide = manySide, oneSide
+	}
+
+	oneBySig := make(map[string]Sample, len(one))
+	for _, s := range one {
+		sig := matching.signature(s.Metric)
+		if _, dup := oneBySig[sig]; dup {
+			return nil, fmt.Errorf("found duplicate series for the match group on the %s hand-side of the operation", oneSide)
+		}
+		oneBySig[sig] = s
+	}
+
+	seen := make(map[string]bool, len(many))
+	var out Vector
+	for _, s := range many {
+		sig := matching.signature(s.Metric)
+		o, ok := oneBySig[sig]
+		if !ok {
+			continue
+		}
+
+		l, r := s.V, o.V
+		if matching.Card == CardOneToMany {
+			l, r = r, l
+		}
+		v, keep := apply(l, r)
+		if !keep {
+			continue
+		}
+
+		metric := matching.resultMetric(s.Metric, o.Metric, dropName)
+		key := sig
+		if matching.Card != CardOneToOne {
+			key = metric.Key()
+		}
+		if seen[key] {
+			if matching.Card == CardOneToOne {
+				return nil, fmt.Errorf("many-to-many matching not allowed: found duplicate series on the %s hand-side of the operation", manySide)
+			}
+			return nil, fmt.Errorf("multiple matches for labels: grouping labels must ensure unique matches")
+		}
+		seen[key] = true
+
+		out = append(out, Sample{Metric: metric, Point: Point{T: ts, V: v}})
+	}
+
+	return out, nil
+}
+
+// signature returns the key on which a sample is matched
+func (m *VectorMatching) signature(metric Labels) string {
+	if !m.On {
+		return metric.without(append([]string{storage.MetricNameLabel}, m.Labels...)...).Key()
+	}
+	sig := Labels{}
+	for _, name := range m.Labels {
+		if v, ok := metric[name]; ok {
+			sig[name] = v
+		}
+	}
+	return sig.Key()
+}
+
+// resultMetric returns the labels of a result sample. One-to-one matches
+// keep only the labels matched on; group matches keep the "many" side's
+// labels plus the included labels of the "one" side.
+func (m *VectorMatching) resultMetric(many, one Labels, dropName bool) Labels {
+	metric := many.without()
+	if dropName {
+		delete(metric, storage.MetricNameLabel)
+	}
+
+	if m.Card == CardOneToOne {
+		if !m.On {
+			return metric.without(m.Labels...)
+		}
+		kept := Labels{}
+		for _, name := range m.Labels {
+			if v, ok := metric[name]; ok {
+				kept[name] = v
+			}
+		}
+		return kept
+	}
+
+	for _, name := range m.Include {
+		if v := one[name]; v != "" {
+			metric[name] = v
+		} else {
+			delete(metric, name)
+		}
+	}
+	return metric
+}
+
+func applyOp(op string, l, r float64) float64 {
+	switch op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		return l / r
+	case "%":
+		return math.Mod(l, r)
+	case "^":
+		return math.Pow(l, r)
+	}
+	return math.NaN()
+}
+
+func compareOp(op string, l, r float64) bool {
+	switch op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	case ">=":
+		return l >= r
+	}
+	return false
+}
+
+func sortVector(vec Vector) {
+	sort.Slice(vec, func(i, j int) bool {
+		return vec[i].Metric.Key() < vec[j].Metric.Key()
+	})
+}
@@ -0,0 +1,415 @@
+package promql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// memQueryable serves series from memory
+type memQueryable []Series
+
+func (q memQueryable) Select(matchers []*storage.Matcher, mint, maxt int64) ([]Series, error) {
+	var out []Series
+	for _, ser := range q {
+		matched := true
+		for _, m := range matchers {
+			if !m.Matches(ser.Metric[m.Name]) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		var points []Point
+		for _, p := range ser.Points {
+			if p.T >= mint && p.T <= maxt {
+				points = append(points, p)
+			}
+		}
+		if len(points) > 0 {
+			out = append(out, Series{Metric: ser.Metric, Points: points})
+		}
+	}
+	return out, nil
+}
+
+// counterSeries returns a series increasing by step every 10s from t=0 to t=60s
+func counterSeries(name, host string, step float64) Series {
+	ser := Series{Metric: Labels{"__name__": name, "host": host}}
+	for i := 0; i <= 6; i++ {
+		ser.Points = append(ser.Points, Point{T: int64(i) * 10000, V: float64(i) * step})
+	}
+	return ser
+}
+
+func mustInstant(t *testing.T, q Queryable, query string, ts int64) Value {
+	t.Helper()
+
+	expr, err := ParseExpr(query)
+	if err != nil {
+		t.Fatalf("ParseExpr(%q) failed: %v", query, err)
+	}
+
+	val, err := Instant(context.Background(), q, expr, ts)
+	if err != nil {
+		t.Fatalf("Instant(%q) failed: %v", query, err)
+	}
+	return val
+}
+
+func TestInstantSelector(t *testing.T) {
+	q := memQueryable{
+		counterSeries("requests", "a", 1),
+		counterSeries("requests", "b", 2),
+	}
+
+	vec := mustInstant(t, q, `requests{host="b"}`, 35000).(Vector)
+	if len(vec) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(vec))
+	}
+
+	// Latest point at or before 35s is t=30s
+	if vec[0].V != 6 || vec[0].T != 35000 {
+		t.Errorf("unexpected sample: %+v", vec[0])
+	}
+}
+
+func TestInstantLookback(t *testing.T) {
+	q := memQueryable{counterSeries("requests", "a", 1)}
+
+	ts := int64(60000) + LookbackDelta.Milliseconds() + 1
+	vec := mustInstant(t, q, `requests`, ts).(Vector)
+	if len(vec) != 0 {
+		t.Errorf("expected stale series to be dropped, got %d samples", len(vec))
+	}
+}
+
+func TestRate(t *testing.T) {
+	q := memQueryable{counterSeries("requests", "a", 10)}
+
+	vec := mustInstant(t, q, `rate(requests[1m])`, 60000).(Vector)
+	if len(vec) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(vec))
+	}
+
+	if math.Abs(vec[0].V-1.0) > 1e-9 {
+		t.Errorf("expected rate=1, got %f", vec[0].V)
+	}
+
+	if _, ok := vec[0].Metric["__name__"]; ok {
+		t.Error("rate should drop the metric name")
+	}
+}
+
+func TestRateCounterReset(t *testing.T) {
+	q := memQueryable{{
+		Metric: Labels{"__name__": "requests"},
+		Points: []Point{{0, 10}, {10000, 20}, {20000, 5}, {30000, 15}},
+	}}
+
+	vec := mustInstant(t, q, `increase(requests[30s])`, 30000).(Vector)
+	if len(vec) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(vec))
+	}
+
+	// The range (0s, 30s] holds 20, 5, 15: an increase of 15 across the
+	// reset, sampled over 20s and extrapolated to the full 30s
+	if math.Abs(vec[0].V-22.5) > 1e-9 {
+		t.Errorf("expected increase=22.5, got %f", vec[0].V)
+	}
+
+	irate := mustInstant(t, q, `irate(requests[30s])`, 30000).(Vector)
+	if math.Abs(irate[0].V-1.0) > 1e-9 {
+		t.Errorf("expected irate=1, got %f", irate[0].V)
+	}
+}
+
+func TestAggregation(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "temp", "room": "a", "sensor": "1"}, Points: []Point{{0, 10}}},
+		{Metric: Labels{"__name__": "temp", "room": "a", "sensor": "2"}, Points: []Point{{0, 20}}},
+		{Metric: Labels{"__name__": "temp", "room": "b", "sensor": "3"}, Points: []Point{{0, 30}}},
+	}
+
+	tests := []struct {
+		query string
+		want  map[string]float64 // room -> value
+	}{
+		{`sum by (room) (temp)`, map[string]float64{"a": 30, "b": 30}},
+		{`avg by (room) (temp)`, map[string]float64{"a": 15, "b": 30}},
+		{`max by (room) (temp)`, map[string]float64{"a": 20, "b": 30}},
+		{`count without (sensor) (temp)`, map[string]float64{"a": 2, "b": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			vec := mustInstant(t, q, tt.query, 0).(Vector)
+			if len(vec) != len(tt.want) {
+				t.Fatalf("expected %d groups, got %d", len(tt.want), len(vec))
+			}
+			for _, s := range vec {
+				if s.V != tt.want[s.Metric["room"]] {
+					t.Errorf("room %s: got %f, want %f", s.Metric["room"], s.V, tt.want[s.Metric["room"]])
+				}
+				if _, ok := s.Metric["__name__"]; ok {
+					t.Error("aggregation should drop the metric name")
+				}
+			}
+		})
+	}
+
+	vec := mustInstant(t, q, `sum(temp)`, 0).(Vector)
+	if len(vec) != 1 || vec[0].V != 60 || len(vec[0].Metric) != 0 {
+		t.Errorf("unexpected sum without grouping: %+v", vec)
+	}
+}
+
+func TestBinaryArithmetic(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "used", "disk": "a"}, Points: []Point{{0, 25}}},
+		{Metric: Labels{"__name__": "used", "disk": "b"}, Points: []Point{{0, 10}}},
+		{Metric: Labels{"__name__": "total", "disk": "a"}, Points: []Point{{0, 100}}},
+		{Metric: Labels{"__name__": "total", "disk": "c"}, Points: []Point{{0, 100}}},
+	}
+
+	vec := mustInstant(t, q, `used / total * 100`, 0).(Vector)
+	if len(vec) != 1 {
+		t.Fatalf("expected only disk a to match, got %d samples", len(vec))
+	}
+	if vec[0].V != 25 || vec[0].Metric["disk"] != "a" {
+		t.Errorf("unexpected sample: %+v", vec[0])
+	}
+
+	scalar := mustInstant(t, q, `2 ^ 3 ^ 2 - 1`, 0).(Scalar)
+	if scalar.V != 511 {
+		t.Errorf("expected 511, got %f", scalar.V)
+	}
+
+	neg := mustInstant(t, q, `-used{disk="b"}`, 0).(Vector)
+	if len(neg) != 1 || neg[0].V != -10 {
+		t.Errorf("unexpected negation: %+v", neg)
+	}
+}
+
+func TestComparison(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "temp", "sensor": "a"}, Points: []Point{{0, 20}}},
+		{Metric: Labels{"__name__": "temp", "sensor": "b"}, Points: []Point{{0, 35}}},
+		{Metric: Labels{"__name__": "limit", "sensor": "a"}, Points: []Point{{0, 10}}},
+		{Metric: Labels{"__name__": "limit", "sensor": "b"}, Points: []Point{{0, 50}}},
+	}
+
+	vec := mustInstant(t, q, `temp > 30`, 0).(Vector)
+	if len(vec) != 1 || vec[0].Metric["sensor"] != "b" || vec[0].V != 35 || vec[0].Metric["__name__"] != "temp" {
+		t.Errorf("unexpected filter result: %+v", vec)
+	}
+
+	// The vector's value is kept when the scalar is on the left
+	vec = mustInstant(t, q, `30 < temp`, 0).(Vector)
+	if len(vec) != 1 || vec[0].V != 35 {
+		t.Errorf("unexpected filter result: %+v", vec)
+	}
+
+	vec = mustInstant(t, q, `temp > bool limit`, 0).(Vector)
+	if len(vec) != 2 || vec[0].V != 1 || vec[1].V != 0 {
+		t.Errorf("unexpected bool result: %+v", vec)
+	}
+	if _, ok := vec[0].Metric["__name__"]; ok {
+		t.Error("bool comparison should drop the metric name")
+	}
+
+	vec = mustInstant(t, q, `temp > limit`, 0).(Vector)
+	if len(vec) != 1 || vec[0].Metric["sensor"] != "a" || vec[0].V != 20 {
+		t.Errorf("unexpected vector filter result: %+v", vec)
+	}
+
+	scalar := mustInstant(t, q, `2 >= bool 3`, 0).(Scalar)
+	if scalar.V != 0 {
+		t.Errorf("expected 0, got %f", scalar.V)
+	}
+}
+
+func TestVectorMatching(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "voltage", "sensor": "a", "unit": "V"}, Points: []Point{{0, 230}}},
+		{Metric: Labels{"__name__": "current", "sensor": "a", "unit": "A"}, Points: []Point{{0, 2}}},
+		{Metric: Labels{"__name__": "cpu", "host": "h1", "cpu": "0"}, Points: []Point{{0, 10}}},
+		{Metric: Labels{"__name__": "cpu", "host": "h1", "cpu": "1"}, Points: []Point{{0, 30}}},
+		{Metric: Labels{"__name__": "cores", "host": "h1", "role": "db"}, Points: []Point{{0, 2}}},
+	}
+
+	// Without on, unit differs and nothing matches
+	if vec := mustInstant(t, q, `voltage * current`, 0).(Vector); len(vec) != 0 {
+		t.Errorf("expected no match, got %+v", vec)
+	}
+
+	vec := mustInstant(t, q, `voltage * on(sensor) current`, 0).(Vector)
+	if len(vec) != 1 || vec[0].V != 460 || len(vec[0].Metric) != 1 || vec[0].Metric["sensor"] != "a" {
+		t.Errorf("unexpected on result: %+v", vec)
+	}
+
+	vec = mustInstant(t, q, `voltage * ignoring(unit) current`, 0).(Vector)
+	if len(vec) != 1 || vec[0].V != 460 {
+		t.Errorf("unexpected ignoring result: %+v", vec)
+	}
+
+	vec = mustInstant(t, q, `cpu / on(host) group_left(role) cores`, 0).(Vector)
+	if len(vec) != 2 {
+		t.Fatalf("expected 2 samples, got %+v", vec)
+	}
+	if vec[0].Metric["cpu"] != "0" || vec[0].V != 5 || vec[0].Metric["role"] != "db" || vec[1].V != 15 {
+		t.Errorf("unexpected group_left result: %+v", vec)
+	}
+
+	vec = mustInstant(t, q, `cores * on(host) group_right cpu`, 0).(Vector)
+	if len(vec) != 2 || vec[0].V != 20 || vec[0].Metric["cpu"] != "0" {
+		t.Errorf("unexpected group_right result: %+v", vec)
+	}
+
+	// Without group_left the left side has two series per host
+	expr, _ := ParseExpr(`cpu / on(host) cores`)
+	if _, err := Instant(context.Background(), q, expr, 0); err == nil {
+		t.Error("expected many-to-many error")
+	}
+}
+
+func TestMathFunctions(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "x", "id": "1"}, Points: []Point{{0, -2.26}}},
+		{Metric: Labels{"__name__": "x", "id": "2"}, Points: []Point{{0, 150}}},
+	}
+
+	tests := []struct {
+		query string
+		want  []float64
+	}{
+		{`abs(x)`, []float64{2.26, 150}},
+		{`round(x)`, []float64{-2, 150}},
+		{`round(x, 0.1)`, []float64{-2.3, 150}},
+		{`round(x, 25)`, []float64{0, 150}},
+		{`clamp(x, 0, 100)`, []float64{0, 100}},
+		{`clamp_min(x, 0)`, []float64{0, 150}},
+		{`clamp_max(x, 0)`, []float64{-2.26, 0}},
+		{`ceil(x)`, []float64{-2, 150}},
+		{`floor(x)`, []float64{-3, 150}},
+		{`log10(x{id="2"} / 1.5)`, []float64{2}},
+		{`log2(abs(x{id="1"}) - 0.26)`, []float64{1}},
+		{`ln(exp(x{id="2"} / 50))`, []float64{3}},
+		{`sqrt(x{id="2"} - 50)`, []float64{10}},
+	}
+
+	for _, tt := range tests {
+		vec := mustInstant(t, q, tt.query, 0).(Vector)
+		if len(vec) != len(tt.want) {
+			t.Errorf("%s: expected %d samples, got %+v", tt.query, len(tt.want), vec)
+			continue
+		}
+		for i, s := range vec {
+			if math.Abs(s.V-tt.want[i]) > 1e-9 {
+				t.Errorf("%s: sample %d = %f, want %f", tt.query, i, s.V, tt.want[i])
+			}
+			if _, ok := s.Metric["__name__"]; ok {
+				t.Errorf("%s: expected the metric name to be dropped", tt.query)
+			}
+		}
+	}
+
+	if vec := mustInstant(t, q, `clamp(x, 10, 0)`, 0).(Vector); len(vec) != 0 {
+		t.Errorf("expected empty result when min > max, got %+v", vec)
+	}
+}
+
+func TestBinaryManyToMany(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "a", "x": "1", "y": "1"}, Points: []Point{{0, 1}}},
+		{Metric: Labels{"__name__": "b", "x": "1", "y": "1"}, Points: []Point{{0, 1}}},
+	}
+
+	expr, _ := ParseExpr(`sum without (y) ({__name__=~"a|b"}) + {__name__="a"}`)
+	if _, err := Instant(context.Background(), q, expr, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expr, _ = ParseExpr(`{x="1"} + {x="1"}`)
+	if _, err := Instant(context.Background(), q, expr, 0); err == nil {
+		t.Error("expected duplicate series error")
+	}
+}
+
+func TestRange(t *testing.T) {
+	q := memQueryable{counterSeries("requests", "a", 10)}
+
+	expr, _ := ParseExpr(`requests * 2`)
+	mat, err := Range(context.Background(), q, expr, 0, 60000, 20*time.Second)
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+
+	if len(mat) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(mat))
+	}
+
+	want := []Point{{0, 0}, {20000, 40}, {40000, 80}, {60000, 120}}
+	if len(mat[0].Points) != len(want) {
+		t.Fatalf("expected %d points, got %d", len(want), len(mat[0].Points))
+	}
+	for i, p := range mat[0].Points {
+		if p != want[i] {
+			t.Errorf("point %d: got %+v, want %+v", i, p, want[i])
+		}
+	}
+}
+
+func TestRangeLimits(t *testing.T) {
+	expr, _ := ParseExpr(`1`)
+
+	if _, err := Range(context.Background(), memQueryable{}, expr, 0, 1000, 0); err == nil {
+		t.Error("expected error for zero step")
+	}
+
+	if _, err := Range(context.Background(), memQueryable{}, expr, 0, int64(MaxPointsPerSeries)*1000, time.Second); err == nil {
+		t.Error("expected error for too many points")
+	}
+
+	// end-start overflows int64
+	if _, err := Range(context.Background(), memQueryable{}, expr, math.MinInt64, math.MaxInt64, time.Hour); err == nil {
+		t.Error("expected error for too many points across the whole int64 range")
+	}
+
+	// The last step lands within stepMs of MaxInt64 and must not wrap
+	mat, err := Range(context.Background(), memQueryable{}, expr, math.MaxInt64-2500, math.MaxInt64, time.Second)
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(mat) != 1 || len(mat[0].Points) != 3 {
+		t.Errorf("expected 3 points near MaxInt64, got %+v", mat)
+	}
+
+	mat, err = Range(context.Background(), memQueryable{}, expr, 0, 2000, time.Second)
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(mat) != 1 || len(mat[0].Points) != 3 {
+		t.Errorf("expected scalar to become one series with 3 points, got %+v", mat)
+	}
+}
+
+func TestEvalCancelled(t *testing.T) {
+	expr, _ := ParseExpr(`1`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := Instant(ctx, memQueryable{}, expr, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Instant: expected context.Canceled, got %v", err)
+	}
+	if _, err := Range(ctx, memQueryable{}, expr, 0, 60000, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("Range: expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package promql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// itemType identifies a lexical token
+type itemType int
+
+const (
+	itemEOF itemType = iota
+	itemIdentifier
+	itemNumber
+	itemDuration
+	itemString
+	itemLeftParen
+	itemRightParen
+	itemLeftBrace
+	itemRightBrace
+	itemLeftBracket
+	itemRightBracket
+	itemComma
+	itemAssign   // =
+	itemNEQ      // !=
+	itemEQLRegex // =~
+	itemNEQRegex // !~
+	itemADD
+	itemSUB
+	itemMUL
+	itemDIV
+	itemMOD
+	itemPOW
+	itemEQLC // ==
+	itemLSS  // <
+	itemLTE  // <=
+	itemGTR  // >
+	itemGTE  // >=
+)
+
+// item is a token with its position in the input
+type item struct {
+	typ itemType
+	pos int
+	val string
+}
+
+func (i item) String() string {
+	if i.typ == itemEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", i.val)
+}
+
+// lex splits the input into tokens
+func lex(input string) ([]item, error) {
+	var items []item
+	pos := 0
+
+	for pos < len(input) {
+		c := input[pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+			continue
+		case c == '#':
+			// Comment until end of line
+			for pos < len(input) && input[pos] != '\n' {
+				pos++
+			}
+			continue
+		}
+
+		start := pos
+		switch c {
+		case '(':
+			items = append(items, item{itemLeftParen, start, "("})
+			pos++
+		case ')':
+			items = append(items, item{itemRightParen, start, ")"})
+			pos++
+		case '{':
+			items = append(items, item{itemLeftBrace, start, "{"})
+			pos++
+		case '}':
+			items = append(items, item{itemRightBrace, start, "}"})
+			pos++
+		case '[':
+			items = append(items, item{itemLeftBracket, start, "["})
+			pos++
+		case ']':
+			items = append(items, item{itemRightBracket, start, "]"})
+			pos++
+		case ',':
+			items = append(items, item{itemComma, start, ","})
+			pos++
+		case '+':
+			items = append(items, item{itemADD, start, "+"})
+			pos++
+		case '-':
+			items = append(items, item{itemSUB, start, "-"})
+			pos++
+		case '*':
+			items = append(items, item{itemMUL, start, "*"})
+			pos++
+		case '/':
+			items = append(items, item{itemDIV, start, "/"})
+			pos++
+		case '%':
+			items = append(items, item{itemMOD, start, "%"})
+			pos++
+		case '^':
+			items = append(items, item{itemPOW, start, "^"})
+			pos++
+		case '=':
+			if pos+1 < len(input) && input[pos+1] == '~' {
+				items = append(items, item{itemEQLRegex, start, "=~"})
+				pos += 2
+			} else if pos+1 < len(input) && input[pos+1] == '=' {
+				items = append(items, item{itemEQLC, start, "=="})
+				pos += 2
+			} else {
+				items = append(items, item{itemAssign, start, "="})
+				pos++
+			}
+		case '!':
+			if pos+1 < len(input) && input[pos+1] == '=' {
+				items = append(items, item{itemNEQ, start, "!="})
+			} else if pos+1 < len(input) && input[pos+1] == '~' {
+				items = append(items, item{itemNEQRegex, start, "!~"})
+			} else {
+				return nil, &ParseError{Pos: start, Err: "unexpected character '!'"}
+			}
+			pos += 2
+		case '<':
+			if pos+1 < len(input) && input[pos+1] == '=' {
+				items = append(items, item{itemLTE, start, "<="})
+				pos += 2
+			} else {
+				items = append(items, item{itemLSS, start, "<"})
+				pos++
+			}
+		case '>':
+			if pos+1 < len(input) && input[pos+1] == '=' {
+				items = append(items, item{itemGTE, start, ">="})
+				pos += 2
+			} else {
+				items = append(items, item{itemGTR, start, ">"})
+				pos++
+			}
+		case '"', '\'', '`':
+			end, err := scanString(input, pos)
+			if err != nil {
+				return nil, err
+			}
+			val, err := unquote(input[pos:end])
+			if err != nil {
+				return nil, &ParseError{Pos: start, Err: "invalid string literal"}
+			}
+			items = append(items, item{itemString, start, val})
+			pos = end
+		default:
+			switch {
+			case isDigit(c) || (c == '.' && pos+1 < len(input) && isDigit(input[pos+1])):
+				pos = scanNumber(input, pos)
+				typ := itemNumber
+				// A number directly followed by letters is a duration (5m, 1h30m)
+				if pos < len(input) && isAlpha(input[pos]) {
+					for pos < len(input) && (isAlpha(input[pos]) || isDigit(input[pos])) {
+						pos++
+					}
+					typ = itemDuration
+				}
+				items = append(items, item{typ, start, input[start:pos]})
+			case isAlpha(c) || c == '_' || c == ':':
+				for pos < len(input) && (isAlpha(input[pos]) || isDigit(input[pos]) || input[pos] == '_' || input[pos] == ':') {
+					pos++
+				}
+				items = append(items, item{itemIdentifier, start, input[start:pos]})
+			default:
+				return nil, &ParseError{Pos: start, Err: fmt.Sprintf("unexpected character %q", rune(c))}
+			}
+		}
+	}
+
+	items = append(items, item{itemEOF, len(input), ""})
+	return items, nil
+}
+
+func scanString(input string, pos int) (int, error) {
+	quote := input[pos]
+	i := pos + 1
+	for i < len(input) {
+		switch input[i] {
+		case '\\':
+			if quote != '`' {
+				i++
+			}
+		case quote:
+			return i + 1, nil
+		}
+		i++
+	}
+	return 0, &ParseError{Pos: pos, Err: "unterminated string"}
+}
+
+func unquote(s string) (string, error) {
+	if s[0] == '\'' {
+		// Convert to a double-quoted string for strconv
+		inner := s[1 : len(s)-1]
+		inner = strings.ReplaceAll(inner, `\'`, `'`)
+		inner = strings.ReplaceAll(inner, `"`, `\"`)
+		s = `"` + inner + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+func scanNumber(input string, pos int) int {
+	for pos < len(input) && (isDigit(input[pos]) || input[pos] == '.') {
+		pos++
+	}
+	// Exponent
+	if pos < len(input) && (input[pos] == 'e' || input[pos] == 'E') {
+		next := pos + 1
+		if next < len(input) && (input[next] == '+' || input[next] == '-') {
+			next++
+		}
+		if next < len(input) && isDigit(input[next]) {
+			pos = next
+			for pos < len(input) && isDigit(input[pos]) {
+				pos++
+			}
+		}
+	}
+	return pos
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return c < unicode.MaxASCII && unicode.IsLetter(rune(c))
+}
@@ -0,0 +1,687 @@
+package promql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// ParseError describes a syntax or type error and where it occurred
+type ParseError struct {
+	Pos int
+	Err string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at char %d: %s", e.Pos+1, e.Err)
+}
+
+// aggregators lists the supported aggregation operators
+var aggregators = map[string]bool{
+	"sum":     true,
+	"avg":     true,
+	"min":     true,
+	"max":     true,
+	"count":   true,
+	"topk":    true,
+	"bottomk": true,
+}
+
+// paramAggregators take a scalar parameter before the expression
+var paramAggregators = map[string]bool{
+	"topk":    true,
+	"bottomk": true,
+}
+
+// signature lists the argument types of a function. The last optional
+// arguments may be omitted.
+type signature struct {
+	args     []ValueType
+	optional int
+}
+
+var (
+	rangeFunc = signature{args: []ValueType{ValueTypeMatrix}}
+	mathFunc  = signature{args: []ValueType{ValueTypeVector}}
+)
+
+// functions maps supported function names to their signatures
+var functions = map[string]signature{
+	"rate":            rangeFunc,
+	"irate":           rangeFunc,
+	"increase":        rangeFunc,
+	"avg_over_time":   rangeFunc,
+	"min_over_time":   rangeFunc,
+	"max_over_time":   rangeFunc,
+	"sum_over_time":   rangeFunc,
+	"count_over_time": rangeFunc,
+	"last_over_time":  rangeFunc,
+	"abs":             mathFunc,
+	"ceil":            mathFunc,
+	"floor":           mathFunc,
+	"sqrt":            mathFunc,
+	"exp":             mathFunc,
+	"ln":              mathFunc,
+	"log2":            mathFunc,
+	"log10":           mathFunc,
+	"round":           {args: []ValueType{ValueTypeVector, ValueTypeScalar}, optional: 1},
+	"clamp":           {args: []ValueType{ValueTypeVector, ValueTypeScalar, ValueTypeScalar}},
+	"clamp_min":       {args: []ValueType{ValueTypeVector, ValueTypeScalar}},
+	"clamp_max":       {args: []ValueType{ValueTypeVector, ValueTypeScalar}},
+}
+
+// binary operator precedence, higher binds tighter
+var precedence = map[itemType]int{
+	itemEQLC: 1,
+	itemNEQ:  1,
+	itemLSS:  1,
+	itemLTE:  1,
+	itemGTR:  1,
+	itemGTE:  1,
+	itemADD:  2,
+	itemSUB:  2,
+	itemMUL:  3,
+	itemDIV:  3,
+	itemMOD:  3,
+	itemPOW:  4,
+}
+
+// isComparison reports whether op is a comparison operator
+func isComparison(op string) bool {
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+type parser struct {
+	items []item
+	pos   int
+}
+
+// ParseExpr parses a PromQL expression
+func ParseExpr(input string) (Expr, error) {
+	items, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{items: items}
+	expr, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.typ != itemEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok)
+	}
+
+	if expr.Type() == ValueTypeMatrix {
+		return nil, &ParseError{Pos: 0, Err: "range vector must be wrapped in a function"}
+	}
+
+	return expr, nil
+}
+
+// ParseExplain parses an expression that may be prefixed with EXPLAIN, in
+// any case, and reports whether it was. EXPLAIN is only recognized when
+// the whole input is not a valid expression, so a metric named explain
+// can still be queried. Error positions refer to the whole input.
+func ParseExplain(input string) (expr Expr, explain bool, err error) {
+	expr, err = ParseExpr(input)
+	if err == nil {
+		return expr, false, nil
+	}
+
+	const keyword, space = "EXPLAIN", " \t\r\n"
+	i := len(input) - len(strings.TrimLeft(input, space))
+	rest := input[i:]
+	if len(rest) <= len(keyword) || !strings.EqualFold(rest[:len(keyword)], keyword) || !strings.ContainsRune(space, rune(rest[len(keyword)])) {
+		return nil, false, err
+	}
+
+	// Blank out the keyword so positions stay the same
+	expr, err = ParseExpr(input[:i] + strings.Repeat(" ", len(keyword)) + rest[len(keyword):])
+	if err != nil {
+		return nil, false, err
+	}
+	return expr, true, nil
+}
+
+// ParseSelector parses a series selector such as temp{sensor="a"}
+func ParseSelector(input string) (*VectorSelector, error) {
+	expr, err := ParseExpr(input)
+	if err != nil {
+		return nil, err
+	}
+
+	vs, ok := expr.(*VectorSelector)
+	if !ok {
+		return nil, &ParseError{Pos: 0, Err: "expected a series selector"}
+	}
+	return vs, nil
+}
+
+// ParseDuration parses a duration such as 5m, 1h30m or 500ms
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	units := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+		"y":  365 * 24 * time.Hour,
+	}
+
+	var total time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+		if i == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		rest = rest[i:]
+
+		j := 0
+		for j < len(rest) && !isDigit(rest[j]) {
+			j++
+		}
+		unit, ok := units[rest[:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration unit in %q", s)
+		}
+		rest = rest[j:]
+
+		total += time.Duration(n) * unit
+	}
+
+	return total, nil
+}
+
+// FormatDuration formats d like ParseDuration expects, e.g. 1h30m
+func FormatDuration(d time.Duration) string {
+	ms := d.Milliseconds()
+	if ms == 0 {
+		return "0s"
+	}
+
+	var sb strings.Builder
+	for _, u := range []struct {
+		suffix string
+		ms     int64
+	}{
+		{"y", 365 * 24 * 3600 * 1000},
+		{"w", 7 * 24 * 3600 * 1000},
+		{"d", 24 * 3600 * 1000},
+		{"h", 3600 * 1000},
+		{"m", 60 * 1000},
+		{"s", 1000},
+		{"ms", 1},
+	} {
+		if ms >= u.ms {
+			fmt.Fprintf(&sb, "%d%s", ms/u.ms, u.suffix)
+			ms %= u.ms
+		}
+	}
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (p *parser) peek() item {
+	return p.items[p.pos]
+}
+
+func (p *parser) next() item {
+	tok := p.items[p.pos]
+	if tok.typ != itemEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) expect(typ itemType, context string) (item, error) {
+	tok := p.next()
+	if tok.typ != typ {
+		return tok, p.errorf(tok, "unexpected %s in %s", tok, context)
+	}
+	return tok, nil
+}
+
+func (p *parser) errorf(tok item, format string, args ...interface{}) error {
+	return &ParseError{Pos: tok.pos, Err: fmt.Sprintf(format, args...)}
+}
+
+// parseExpr parses binary expressions using precedence climbing
+func (p *parser) parseExpr(minPrec int) (Expr, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.peek()
+		prec, ok := precedence[op.typ]
+		if !ok || prec < minPrec {
+			return lhs, nil
+		}
+		p.next()
+
+		bin := &BinaryExpr{Op: op.val}
+		if err := p.parseBinaryModifiers(bin); err != nil {
+			return nil, err
+		}
+
+		// ^ is right-associative, everything else left-associative
+		nextMin := prec + 1
+		if op.typ == itemPOW {
+			nextMin = prec
+		}
+
+		rhs, err := p.parseExpr(nextMin)
+		if err != nil {
+			return nil, err
+		}
+		bin.LHS, bin.RHS = lhs, rhs
+
+		for _, side := range []Expr{lhs, rhs} {
+			if side.Type() != ValueTypeScalar && side.Type() != ValueTypeVector {
+				return nil, p.errorf(op, "binary operator %q requires scalar or instant vector operands", op.val)
+			}
+		}
+
+		bothVectors := lhs.Type() == ValueTypeVector && rhs.Type() == ValueTypeVector
+		if bin.Matching != nil && !bothVectors {
+			return nil, p.errorf(op, "vector matching only allowed between instant vectors")
+		}
+		if isComparison(op.val) && !bin.ReturnBool && lhs.Type() == ValueTypeScalar && rhs.Type() == ValueTypeScalar {
+			return nil, p.errorf(op, "comparisons between scalars must use the bool modifier")
+		}
+
+		lhs = bin
+	}
+}
+
+// parseBinaryModifiers parses the bool, on/ignoring and group_left/
+// group_right modifiers that may follow a binary operator
+func (p *parser) parseBinaryModifiers(bin *BinaryExpr) error {
+	if tok := p.peek(); tok.typ == itemIdentifier && tok.val == "bool" {
+		if !isComparison(bin.Op) {
+			return p.errorf(tok, "bool modifier can only be used on comparison operators")
+		}
+		p.next()
+		bin.ReturnBool = true
+	}
+
+	tok := p.peek()
+	if tok.typ != itemIdentifier || (tok.val != "on" && tok.val != "ignoring") {
+		return nil
+	}
+	p.next()
+
+	labels, err := p.parseLabelList(tok.val)
+	if err != nil {
+		return err
+	}
+	bin.Matching = &VectorMatching{Card: CardOneToOne, On: tok.val == "on", Labels: labels}
+
+	tok = p.peek()
+	if tok.typ != itemIdentifier || (tok.val != "group_left" && tok.val != "group_right") {
+		return nil
+	}
+	p.next()
+
+	bin.Matching.Card = CardManyToOne
+	if tok.val == "group_right" {
+		bin.Matching.Card = CardOneToMany
+	}
+	if p.peek().typ == itemLeftParen {
+		if bin.Matching.Include, err = p.parseLabelList(tok.val); err != nil {
+			return err
+		}
+	}
+
+	if bin.Matching.On {
+		for _, name := range bin.Matching.Include {
+			for _, on := range bin.Matching.Labels {
+				if name == on {
+					return p.errorf(tok, "label %q must not occur in on and %s at once", name, tok.val)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseLabelList parses a parenthesized, comma-separated list of labels
+func (p *parser) parseLabelList(context string) ([]string, error) {
+	if _, err := p.expect(itemLeftParen, context); err != nil {
+		return nil, err
+	}
+	labels := []string{}
+	for p.peek().typ != itemRightParen {
+		label, err := p.expect(itemIdentifier, context)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label.val)
+
+		if p.peek().typ == itemComma {
+			p.next()
+		}
+	}
+	p.next()
+	return labels, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	tok := p.peek()
+	if tok.typ != itemADD && tok.typ != itemSUB {
+		return p.parsePrimary()
+	}
+	p.next()
+
+	// Unary minus binds looser than ^, as in Prometheus: -2^2 == -4
+	expr, err := p.parseExpr(precedence[itemPOW])
+	if err != nil {
+		return nil, err
+	}
+
+	if expr.Type() != ValueTypeScalar && expr.Type() != ValueTypeVector {
+		return nil, p.errorf(tok, "unary %q requires scalar or instant vector", tok.val)
+	}
+
+	if tok.typ == itemADD {
+		return expr, nil
+	}
+	if num, ok := expr.(*NumberLiteral); ok {
+		return &NumberLiteral{Val: -num.Val}, nil
+	}
+	return &UnaryExpr{Op: "-", Expr: expr}, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.peek()
+
+	switch tok.typ {
+	case itemNumber:
+		p.next()
+		v, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid number %q", tok.val)
+		}
+		return &NumberLiteral{Val: v}, nil
+
+	case itemLeftParen:
+		p.next()
+		expr, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(itemRightParen, "parenthesized expression"); err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Expr: expr}, nil
+
+	case itemLeftBrace:
+		return p.parseSelector("")
+
+	case itemIdentifier:
+		switch strings.ToLower(tok.val) {
+		case "inf":
+			p.next()
+			return &NumberLiteral{Val: math.Inf(1)}, nil
+		case "nan":
+			p.next()
+			return &NumberLiteral{Val: math.NaN()}, nil
+		}
+
+		if aggregators[tok.val] {
+			return p.parseAggregate()
+		}
+
+		p.next()
+		if p.peek().typ == itemLeftParen {
+			return p.parseCall(tok)
+		}
+		return p.parseSelector(tok.val)
+	}
+
+	return nil, p.errorf(tok, "unexpected %s", tok)
+}
+
+func (p *parser) parseCall(name item) (Expr, error) {
+	sig, ok := functions[name.val]
+	if !ok {
+		return nil, p.errorf(name, "unknown function %q", name.val)
+	}
+
+	p.next() // (
+	var args []Expr
+	if p.peek().typ != itemRightParen {
+		for {
+			arg, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.peek().typ != itemComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if _, err := p.expect(itemRightParen, "function call"); err != nil {
+		return nil, err
+	}
+
+	if required := len(sig.args) - sig.optional; len(args) < required || len(args) > len(sig.args) {
+		want := fmt.Sprint(len(sig.args))
+		if sig.optional > 0 {
+			want = fmt.Sprintf("%d to %d", required, len(sig.args))
+		}
+		return nil, p.errorf(name, "function %q expects %s argument(s), got %d", name.val, want, len(args))
+	}
+	for i, arg := range args {
+		if arg.Type() != sig.args[i] {
+			return nil, p.errorf(name, "function %q expects argument %d of type %s, got %s", name.val, i+1, sig.args[i], arg.Type())
+		}
+	}
+
+	return &Call{Func: name.val, Args: args}, nil
+}
+
+func (p *parser) parseAggregate() (Expr, error) {
+	op := p.next()
+	agg := &AggregateExpr{Op: op.val}
+
+	parseGrouping := func() error {
+		mod := p.peek()
+		if mod.typ != itemIdentifier || (mod.val != "by" && mod.val != "without") {
+			return nil
+		}
+		p.next()
+		agg.Without = mod.val == "without"
+
+		labels, err := p.parseLabelList("grouping")
+		if err != nil {
+			return err
+		}
+		agg.Grouping = labels
+		return nil
+	}
+
+	// Grouping may come before or after the parameter list
+	if err := parseGrouping(); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(itemLeftParen, "aggregation"); err != nil {
+		return nil, err
+	}
+	if paramAggregators[op.val] {
+		param, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if param.Type() != ValueTypeScalar {
+			return nil, p.errorf(op, "aggregation %q expects a scalar parameter, got %s", op.val, param.Type())
+		}
+		if _, err := p.expect(itemComma, "aggregation"); err != nil {
+			return nil, err
+		}
+		agg.Param = param
+	}
+	expr, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(itemRightParen, "aggregation"); err != nil {
+		return nil, err
+	}
+
+	if agg.Grouping == nil && !agg.Without {
+		if err := parseGrouping(); err != nil {
+			return nil, err
+		}
+	}
+
+	if expr.Type() != ValueTypeVector {
+		return nil, p.errorf(op, "aggregation %q expects an instant vector, got %s", op.val, expr.Type())
+	}
+	agg.Expr = expr
+
+	return agg, nil
+}
+
+func (p *parser) parseSelector(name string) (Expr, error) {
+	vs := &VectorSelector{Name: name}
+	start := p.peek()
+
+	if name != "" {
+		m, _ := storage.NewMatcher(storage.MatchEqual, storage.MetricNameLabel, name)
+		vs.Matchers = append(vs.Matchers, m)
+	}
+
+	if p.peek().typ == itemLeftBrace {
+		p.next()
+		for p.peek().typ != itemRightBrace {
+			m, err := p.parseMatcher()
+			if err != nil {
+				return nil, err
+			}
+			if m.Name == storage.MetricNameLabel && m.Type == storage.MatchEqual {
+				if vs.Name != "" && vs.Name != m.Value {
+					return nil, p.errorf(start, "metric name specified twice")
+				}
+				vs.Name = m.Value
+			}
+			vs.Matchers = append(vs.Matchers, m)
+
+			if p.peek().typ == itemComma {
+				p.next()
+			} else if p.peek().typ != itemRightBrace {
+				return nil, p.errorf(p.peek(), "unexpected %s in label matching, expected \",\" or \"}\"", p.peek())
+			}
+		}
+		p.next()
+	}
+
+	// At least one matcher must not match the empty string, otherwise
+	// the selector would select every series
+	matchesNonEmpty := false
+	for _, m := range vs.Matchers {
+		if !m.Matches("") {
+			matchesNonEmpty = true
+			break
+		}
+	}
+	if !matchesNonEmpty {
+		return nil, p.errorf(start, "vector selector must contain at least one non-empty matcher")
+	}
+
+	var expr Expr = vs
+	if p.peek().typ == itemLeftBracket {
+		p.next()
+		tok, err := p.expect(itemDuration, "range selector")
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseDuration(tok.val)
+		if err != nil || d <= 0 {
+			return nil, p.errorf(tok, "invalid range %q", tok.val)
+		}
+		if _, err := p.expect(itemRightBracket, "range selector"); err != nil {
+			return nil, err
+		}
+		expr = &MatrixSelector{VectorSelector: vs, Range: d}
+	}
+
+	if tok := p.peek(); tok.typ == itemIdentifier && tok.val == "offset" {
+		p.next()
+		durTok, err := p.expect(itemDuration, "offset")
+		if err != nil {
+			return nil, err
+		}
+		d, err := ParseDuration(durTok.val)
+		if err != nil {
+			return nil, p.errorf(durTok, "invalid offset %q", durTok.val)
+		}
+		vs.Offset = d
+	}
+
+	return expr, nil
+}
+
+func (p *parser) parseMatcher() (*storage.Matcher, error) {
+	label, err := p.expect(itemIdentifier, "label matching")
+	if err != nil {
+		return nil, err
+	}
+
+	opTok := p.next()
+	var typ storage.MatchType
+	switch opTok.typ {
+	case itemAssign:
+		typ = storage.MatchEqual
+	case itemNEQ:
+		typ = storage.MatchNotEqual
+	case itemEQLRegex:
+		typ = storage.MatchRegexp
+	case itemNEQRegex:
+		typ = storage.MatchNotRegexp
+	default:
+		return nil, p.errorf(opTok, "unexpected %s in label matching, expected matcher operator", opTok)
+	}
+
+	value, err := p.expect(itemString, "label matching")
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := storage.NewMatcher(typ, label.val, value.val)
+	if err != nil {
+		return nil, p.errorf(value, "%v", err)
+	}
+	return m, nil
+}
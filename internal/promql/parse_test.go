@@ -0,0 +1,205 @@
+package promql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`temperature`, `temperature`},
+		{`temperature{sensor="a"}`, `temperature{sensor="a"}`},
+		{`{__name__=~"temp.*"}`, `{__name__=~"temp.*"}`},
+		{`temperature{sensor!='a', room=~"b.*"}`, `temperature{sensor!="a",room=~"b.*"}`},
+		{`rate(requests[5m])`, `rate(requests[5m])`},
+		{`rate(requests[1h30m] offset 1d)`, `rate(requests[1h30m] offset 1d)`},
+		{`sum by (host) (rate(requests[5m]))`, `sum by (host) (rate(requests[5m]))`},
+		{`sum(rate(requests[5m])) by (host)`, `sum by (host) (rate(requests[5m]))`},
+		{`avg without (sensor) (temperature)`, `avg without (sensor) (temperature)`},
+		{`used / total * 100`, `used / total * 100`},
+		{`-2 ^ 2`, `-(2 ^ 2)`},
+		{`(a + b) * 2`, `(a + b) * 2`},
+		{`temperature > 30`, `temperature > 30`},
+		{`a + b >= bool c * 2`, `a + b >= bool c * 2`},
+		{`1 == bool 1`, `1 == bool 1`},
+		{`voltage * on(sensor) current`, `voltage * on (sensor) current`},
+		{`a / ignoring(cpu) group_left b`, `a / ignoring (cpu) group_left () b`},
+		{`a * on(host) group_right(role) b`, `a * on (host) group_right (role) b`},
+		{`round(temperature, 0.5)`, `round(temperature, 0.5)`},
+		{`clamp(abs(x), 0, 100)`, `clamp(abs(x), 0, 100)`},
+		{`topk(5, temperature)`, `topk (5, temperature)`},
+		{`bottomk(2 + 1, x) by (room)`, `bottomk by (room) (2 + 1, x)`},
+		{`topk(10, max_over_time(temperature[1h]))`, `topk (10, max_over_time(temperature[1h]))`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, err := ParseExpr(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExpr failed: %v", err)
+			}
+
+			got := expr.String()
+			if tt.input == `-2 ^ 2` {
+				// Unary minus applies to the whole power expression
+				if _, ok := expr.(*UnaryExpr); !ok {
+					t.Errorf("expected unary expression, got %T", expr)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExprPrecedence(t *testing.T) {
+	expr, err := ParseExpr(`a + b * c`)
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+
+	bin, ok := expr.(*BinaryExpr)
+	if !ok || bin.Op != "+" {
+		t.Fatalf("expected + at the root, got %s", expr)
+	}
+
+	if rhs, ok := bin.RHS.(*BinaryExpr); !ok || rhs.Op != "*" {
+		t.Errorf("expected * on the right, got %s", bin.RHS)
+	}
+}
+
+func TestParseComparisonPrecedence(t *testing.T) {
+	expr, err := ParseExpr(`used / total * 100 > 90`)
+	if err != nil {
+		t.Fatalf("ParseExpr failed: %v", err)
+	}
+
+	bin, ok := expr.(*BinaryExpr)
+	if !ok || bin.Op != ">" {
+		t.Fatalf("expected > at the root, got %s", expr)
+	}
+	if bin.Type() != ValueTypeVector {
+		t.Errorf("expected vector, got %s", bin.Type())
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`temperature{sensor="a"`, "unexpected end of input"},
+		{`requests[5m]`, "range vector must be wrapped in a function"},
+		{`rate(requests)`, "expects argument 1 of type matrix"},
+		{`unknown(requests[5m])`, `unknown function "unknown"`},
+		{`{sensor=""}`, "at least one non-empty matcher"},
+		{`sum(requests[5m])`, "expects an instant vector"},
+		{`temperature{sensor=~"("}`, "invalid regex"},
+		{`temperature $`, "unexpected character"},
+		{`1 > 2`, "must use the bool modifier"},
+		{`a + bool b`, "bool modifier can only be used on comparison operators"},
+		{`a + on(x) 1`, "vector matching only allowed between instant vectors"},
+		{`a * on(x) group_left(x) b`, "must not occur in on and group_left"},
+		{`round(x, 1, 2)`, "expects 1 to 2 argument(s)"},
+		{`clamp_min(x, y)`, "expects argument 2 of type scalar"},
+		{`topk(x, y)`, "expects a scalar parameter"},
+		{`topk(5)`, "unexpected \")\" in aggregation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := ParseExpr(tt.input)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not contain %q", err, tt.want)
+			}
+
+			if _, ok := err.(*ParseError); !ok {
+				t.Errorf("expected *ParseError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := ParseExpr(`sum(temperature $)`)
+	perr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %v", err)
+	}
+
+	if perr.Pos != 16 {
+		t.Errorf("expected position 16, got %d", perr.Pos)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"5m", 5 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"500ms", 500 * time.Millisecond},
+		{"1d", 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.input)
+		if err != nil {
+			t.Errorf("ParseDuration(%q) failed: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "5", "m", "5x"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("ParseDuration(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseExplain(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		explain bool
+	}{
+		{`rate(requests[5m])`, `rate(requests[5m])`, false},
+		{`EXPLAIN rate(requests[5m])`, `rate(requests[5m])`, true},
+		{"  explain\nsum(temperature)", `sum (temperature)`, true},
+		{`explain`, `explain`, false},
+		{`explain + 1`, `explain + 1`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			expr, explain, err := ParseExplain(tt.input)
+			if err != nil {
+				t.Fatalf("ParseExplain failed: %v", err)
+			}
+			if expr.String() != tt.want || explain != tt.explain {
+				t.Errorf("got %q, %v; want %q, %v", expr, explain, tt.want, tt.explain)
+			}
+		})
+	}
+
+	// Positions refer to the input including EXPLAIN
+	_, _, err := ParseExplain(`EXPLAIN rate(requests)`)
+	if perr, ok := err.(*ParseError); !ok || perr.Pos != 8 {
+		t.Errorf("expected error at position 8, got %v", err)
+	}
+}
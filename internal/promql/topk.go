@@ -0,0 +1,113 @@
+package promql
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// rankHeap keeps the best k samples of a group with the worst at the root,
+// so each sample costs O(log k) and only k samples per group are held
+type rankHeap struct {
+	samples []Sample
+	worse   func(a, b float64) bool
+}
+
+func (h *rankHeap) Len() int           { return len(h.samples) }
+func (h *rankHeap) Less(i, j int) bool { return h.worse(h.samples[i].V, h.samples[j].V) }
+func (h *rankHeap) Swap(i, j int)      { h.samples[i], h.samples[j] = h.samples[j], h.samples[i] }
+func (h *rankHeap) Push(x interface{}) { h.samples = append(h.samples, x.(Sample)) }
+
+func (h *rankHeap) Pop() interface{} {
+	last := h.samples[len(h.samples)-1]
+	h.samples = h.samples[:len(h.samples)-1]
+	return last
+}
+
+// isRanking reports whether expr is a topk or bottomk aggregation, whose
+// result keeps its order
+func isRanking(expr Expr) bool {
+	for {
+		paren, ok := expr.(*ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.Expr
+	}
+	agg, ok := expr.(*AggregateExpr)
+	return ok && paramAggregators[agg.Op]
+}
+
+// topK returns the k largest (topk) or smallest (bottomk) samples of each
+// group, keeping their labels. Groups are ordered by their labels and the
+// samples of a group from best to worst. NaN ranks last in both.
+func topK(agg *AggregateExpr, vec Vector, param float64) Vector {
+	if math.IsNaN(param) || param < 1 {
+		return Vector{}
+	}
+	k := len(vec)
+	if param < float64(k) {
+		k = int(param)
+	}
+
+	worse := func(a, b float64) bool {
+		return a < b || (math.IsNaN(a) && !math.IsNaN(b))
+	}
+	if agg.Op == "bottomk" {
+		worse = func(a, b float64) bool {
+			return a > b || (math.IsNaN(a) && !math.IsNaN(b))
+		}
+	}
+
+	groups := make(map[string]*rankHeap)
+	dropped := append([]string{storage.MetricNameLabel}, agg.Grouping...)
+
+	for _, s := range vec {
+		var key string
+		if agg.Without {
+			key = s.Metric.without(dropped...).Key()
+		} else {
+			metric := Labels{}
+			for _, name := range agg.Grouping {
+				if v, ok := s.Metric[name]; ok {
+					metric[name] = v
+				}
+			}
+			key = metric.Key()
+		}
+
+		h, ok := groups[key]
+		if !ok {
+			h = &rankHeap{worse: worse}
+			groups[key] = h
+		}
+
+		switch {
+		case h.Len() < k:
+			heap.Push(h, s)
+		case worse(h.samples[0].V, s.V):
+			h.samples[0] = s
+			heap.Fix(h, 0)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var out Vector
+	for _, key := range keys {
+		h := groups[key]
+		// Popping yields worst first, so fill the group from the back
+		ranked := make(Vector, h.Len())
+		for i := len(ranked) - 1; i >= 0; i-- {
+			ranked[i] = heap.Pop(h).(Sample)
+		}
+		out = append(out, ranked...)
+	}
+	return out
+}
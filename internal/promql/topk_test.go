@@ -0,0 +1,124 @@
+package promql
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func sensorQueryable() memQueryable {
+	q := memQueryable{}
+	for i, v := range []float64{30, 10, 50, 20, 40} {
+		room := "lab"
+		if i%2 == 1 {
+			room = "office"
+		}
+		q = append(q, Series{
+			Metric: Labels{"__name__": "temp", "sensor": string(rune('a' + i)), "room": room},
+			Points: []Point{{0, v}, {60000, 100 - v}},
+		})
+	}
+	return q
+}
+
+func sensors(vec Vector) string {
+	var s string
+	for _, sample := range vec {
+		s += sample.Metric["sensor"]
+	}
+	return s
+}
+
+func TestTopK(t *testing.T) {
+	q := sensorQueryable()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`topk(3, temp)`, "cea"},
+		{`bottomk(2, temp)`, "bd"},
+		{`topk(10, temp)`, "ceadb"},
+		{`topk(0, temp)`, ""},
+		{`(topk(1.9, temp))`, "c"},
+		// Groups are ordered by their labels
+		{`topk by (room) (1, temp)`, "cd"},
+		{`bottomk without (sensor) (1, temp)`, "ab"},
+	}
+
+	for _, tt := range tests {
+		vec := mustInstant(t, q, tt.query, 0).(Vector)
+		if got := sensors(vec); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	vec := mustInstant(t, q, `topk(1, temp)`, 0).(Vector)
+	if vec[0].Metric["__name__"] != "temp" || vec[0].Metric["room"] != "lab" || vec[0].V != 50 {
+		t.Errorf("expected the sample to keep its labels, got %+v", vec[0])
+	}
+}
+
+func TestTopKNaN(t *testing.T) {
+	q := memQueryable{
+		{Metric: Labels{"__name__": "x", "sensor": "a"}, Points: []Point{{0, math.NaN()}}},
+		{Metric: Labels{"__name__": "x", "sensor": "b"}, Points: []Point{{0, 1}}},
+		{Metric: Labels{"__name__": "x", "sensor": "c"}, Points: []Point{{0, 2}}},
+	}
+
+	if got := sensors(mustInstant(t, q, `topk(2, x)`, 0).(Vector)); got != "cb" {
+		t.Errorf("topk: got %q, want \"cb\"", got)
+	}
+	if got := sensors(mustInstant(t, q, `bottomk(3, x)`, 0).(Vector)); got != "bca" {
+		t.Errorf("bottomk: got %q, want \"bca\"", got)
+	}
+}
+
+func TestTopKRange(t *testing.T) {
+	q := sensorQueryable()
+
+	// Each step ranks its own samples: c leads at 0s, b at 60s
+	expr, _ := ParseExpr(`topk(1, temp)`)
+	mat, err := Range(context.Background(), q, expr, 0, 60000, time.Minute)
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(mat) != 2 {
+		t.Fatalf("expected 2 series, got %+v", mat)
+	}
+	for _, ser := range mat {
+		if len(ser.Points) != 1 {
+			t.Errorf("expected one point for %s, got %+v", ser.Metric["sensor"], ser.Points)
+		}
+	}
+
+	// Over the whole range, rank by the maximum of each series
+	vec := mustInstant(t, q, `topk(2, max_over_time(temp[2m]))`, 60000).(Vector)
+	if got := sensors(vec); got != "bd" {
+		t.Errorf("got %q, want \"bd\"", got)
+	}
+}
+
+func TestOverTime(t *testing.T) {
+	q := memQueryable{counterSeries("requests", "a", 10)}
+
+	tests := []struct {
+		query string
+		want  float64
+	}{
+		{`avg_over_time(requests[1m])`, 35},
+		{`min_over_time(requests[1m])`, 10},
+		{`max_over_time(requests[1m])`, 60},
+		{`sum_over_time(requests[1m])`, 210},
+		{`count_over_time(requests[1m])`, 6},
+		{`last_over_time(requests[1m])`, 60},
+	}
+
+	for _, tt := range tests {
+		vec := mustInstant(t, q, tt.query, 60000).(Vector)
+		if len(vec) != 1 || vec[0].V != tt.want {
+			t.Errorf("%s: got %+v, want %f", tt.query, vec, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package promql
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Labels identifies a series, including the metric name as __name__
+type Labels map[string]string
+
+// Key returns a canonical string for grouping and sorting
+func (l Labels) Key() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte(0xff)
+		sb.WriteString(l[name])
+		sb.WriteByte(0xff)
+	}
+	return sb.String()
+}
+
+// without returns a copy of the labels without the given names
+func (l Labels) without(names ...string) Labels {
+	out := make(Labels, len(l))
+	for k, v := range l {
+		out[k] = v
+	}
+	for _, name := range names {
+		delete(out, name)
+	}
+	return out
+}
+
+// Point is a single value at a timestamp in milliseconds
+type Point struct {
+	T int64
+	V float64
+}
+
+// Series is a labelled list of points sorted by timestamp
+type Series struct {
+	Metric Labels
+	Points []Point
+}
+
+// Sample is an element of an instant vector
+type Sample struct {
+	Metric Labels
+	Point
+}
+
+// Value is the result of an evaluation: Scalar, Vector or Matrix
+type Value interface {
+	Type() ValueType
+}
+
+// Scalar is a single number
+type Scalar Point
+
+// Vector is a set of samples sharing a timestamp
+type Vector []Sample
+
+// Matrix is a set of series
+type Matrix []Series
+
+func (Scalar) Type() ValueType { return ValueTypeScalar }
+func (Vector) Type() ValueType { return ValueTypeVector }
+func (Matrix) Type() ValueType { return ValueTypeMatrix }
+
+// Queryable provides series data to the evaluator
+type Queryable interface {
+	// Select returns all series matching the matchers with points in
+	// [mint, maxt], each sorted by timestamp
+	Select(matchers []*storage.Matcher, mint, maxt int64) ([]Series, error)
+}
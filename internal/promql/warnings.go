@@ -0,0 +1,42 @@
+package promql
+
+import (
+	"fmt"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// counterFuncs are the functions whose result only makes sense on a counter
+var counterFuncs = map[string]bool{
+	"rate":     true,
+	"increase": true,
+	"irate":    true,
+}
+
+// KindLookup returns the registered kind of a metric
+type KindLookup func(metric string) (storage.MetricKind, bool)
+
+// Warnings returns problems in expr that do not stop its evaluation, such
+// as rate() applied to a gauge. Only selectors with a fixed metric name
+// are checked.
+func Warnings(expr Expr, kind KindLookup) []string {
+	var warnings []string
+	Inspect(expr, func(node Expr) {
+		call, ok := node.(*Call)
+		if !ok || !counterFuncs[call.Func] || len(call.Args) == 0 {
+			return
+		}
+		ms, ok := call.Args[0].(*MatrixSelector)
+		if !ok {
+			return
+		}
+		name := ms.VectorSelector.Name
+		if name == "" {
+			return
+		}
+		if k, ok := kind(name); ok && k == storage.KindGauge {
+			warnings = append(warnings, fmt.Sprintf("%s() should only be applied to counters, but %q is a gauge", call.Func, name))
+		}
+	})
+	return warnings
+}
@@ -0,0 +1,40 @@
+package promql
+
+import (
+	"testing"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func TestWarnings(t *testing.T) {
+	kinds := map[string]storage.MetricKind{
+		"temperature": storage.KindGauge,
+		"requests":    storage.KindCounter,
+	}
+	lookup := func(metric string) (storage.MetricKind, bool) {
+		k, ok := kinds[metric]
+		return k, ok
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{`rate(temperature[5m])`, 1},
+		{`sum by (room) (increase({__name__="temperature"}[1h]))`, 1},
+		{`rate(requests[5m]) + irate(temperature[1m])`, 1},
+		{`rate(requests[5m])`, 0},
+		{`rate(unknown[5m])`, 0},
+		{`rate({__name__=~"temp.*"}[5m])`, 0},
+		{`temperature`, 0},
+	}
+	for _, tt := range tests {
+		expr, err := ParseExpr(tt.query)
+		if err != nil {
+			t.Fatalf("%s: parse failed: %v", tt.query, err)
+		}
+		if got := Warnings(expr, lookup); len(got) != tt.want {
+			t.Errorf("%s: expected %d warnings, got %v", tt.query, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+const (
+	// cardinalityTopMetrics is the number of top contributors in /metrics
+	cardinalityTopMetrics = 5
+	// cardinalityDefaultLimit is the default limit of /admin/cardinality
+	cardinalityDefaultLimit = 10
+)
+
+// isSeriesLimit reports whether a write was refused by the series limits
+func isSeriesLimit(err error) bool {
+	return errors.Is(err, storage.ErrSeriesLimit)
+}
+
+// handleCardinality reports the active series and the metrics and tag
+// keys contributing most of them (GET /admin/cardinality). With metric
+// set, the tag keys are those of that metric.
+func (s *Server) handleCardinality(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := cardinalityDefaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	writeJSON(w, s.storage.Cardinality(q.Get("metric"), limit))
+}
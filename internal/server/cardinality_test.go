@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func setupLimitedServer(t *testing.T, maxPerMetric int) *Server {
+	t.Helper()
+	cfg := &config.Config{
+		Storage: config.StorageConfig{
+			DataDir:            t.TempDir(),
+			MaxMemoryMB:        128,
+			MaxSeriesPerMetric: maxPerMetric,
+		},
+	}
+
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.Stop() })
+	return srv
+}
+
+func TestWriteSeriesLimit(t *testing.T) {
+	srv := setupLimitedServer(t, 2)
+
+	body := `[
+		{"metric": "req", "timestamp": 1000, "value": 1, "tags": {"id": "1"}},
+		{"metric": "req", "timestamp": 1000, "value": 1, "tags": {"id": "2"}},
+		{"metric": "req", "timestamp": 1000, "value": 1, "tags": {"id": "3"}}
+	]`
+	req := httptest.NewRequest("POST", "/write", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status 206, got %d", w.Code)
+	}
+
+	var resp struct {
+		Written             int      `json:"written"`
+		SeriesLimitRejected int      `json:"series_limit_rejected"`
+		Errors              []string `json:"errors"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Written != 2 || resp.SeriesLimitRejected != 1 || len(resp.Errors) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if !strings.HasPrefix(resp.Errors[0], "series limit exceeded") {
+		t.Errorf("expected series limit error, got %q", resp.Errors[0])
+	}
+}
+
+func TestWriteStreamSeriesLimit(t *testing.T) {
+	srv := setupLimitedServer(t, 1)
+
+	body := strings.Join([]string{
+		`{"metric": "req", "timestamp": 1000, "value": 1, "tags": {"id": "1"}}`,
+		`{"metric": "req", "timestamp": 1000, "value": 1, "tags": {"id": "2"}}`,
+		`{"metric": "req", "timestamp": 2000, "value": 1, "tags": {"id": "1"}}`,
+	}, "\n")
+	req := httptest.NewRequest("POST", "/write/stream", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	var res writeStreamResult
+	json.NewDecoder(w.Body).Decode(&res)
+	if w.Code != http.StatusPartialContent || res.Accepted != 2 || res.Rejected != 1 {
+		t.Fatalf("unexpected response %d %+v", w.Code, res)
+	}
+	if len(res.Errors) != 1 || res.Errors[0].Line != 2 {
+		t.Errorf("expected line 2 rejected, got %+v", res.Errors)
+	}
+}
+
+func TestHandleCardinality(t *testing.T) {
+	srv := setupLimitedServer(t, 2)
+
+	for _, id := range []string{"1", "2", "3"} {
+		body := `{"metric": "req", "timestamp": 1000, "value": 1, "tags": {"id": "` + id + `"}}`
+		srv.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/write", strings.NewReader(body)))
+	}
+
+	req := httptest.NewRequest("GET", "/admin/cardinality?limit=1", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	var stats struct {
+		Series             int `json:"series"`
+		MaxSeriesPerMetric int `json:"max_series_per_metric"`
+		RejectedPoints     int `json:"rejected_points"`
+		TopTagKeys         []struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		} `json:"top_tag_keys"`
+	}
+	json.NewDecoder(w.Body).Decode(&stats)
+	if stats.Series != 2 || stats.MaxSeriesPerMetric != 2 || stats.RejectedPoints != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if len(stats.TopTagKeys) != 1 || stats.TopTagKeys[0].Name != "id" || stats.TopTagKeys[0].Count != 2 {
+		t.Errorf("unexpected top tag keys %+v", stats.TopTagKeys)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/cardinality?limit=x", nil)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid limit, got %d", w.Code)
+	}
+}
+
+func TestOpenTSDBPutSeriesLimit(t *testing.T) {
+	srv := setupLimitedServer(t, 1)
+
+	body := `[
+		{"metric":"req","timestamp":1699267200,"value":1,"tags":{"id":"1"}},
+		{"metric":"req","timestamp":1699267200,"value":1,"tags":{"id":"2"}}
+	]`
+	req := httptest.NewRequest("POST", "/api/put?details", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Success int `json:"success"`
+		Failed  int `json:"failed"`
+		Errors  []struct {
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Success != 1 || resp.Failed != 1 || len(resp.Errors) != 1 || !strings.HasPrefix(resp.Errors[0].Error, "series limit exceeded") {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// queryTrace follows a query through its stages. beginQuery attaches one
+// to every query; storage statistics and plan steps are only collected
+// once a handler asks for them with collect, for EXPLAIN or stats output.
+type queryTrace struct {
+	mu     sync.Mutex
+	start  time.Time
+	last   time.Time
+	stages []stageTiming
+	plan   []string
+	stats  *storage.QueryStats
+}
+
+// stageTiming is the time spent in one stage of a query
+type stageTiming struct {
+	Stage string  `json:"stage"`
+	Ms    float64 `json:"ms"`
+}
+
+// queryReport is the EXPLAIN output of a query, or its statistics when
+// included in a regular response
+type queryReport struct {
+	Plan []string `json:"plan,omitempty"`
+	*storage.QueryStats
+	Stages  []stageTiming `json:"stages"`
+	TotalMs float64       `json:"total_ms"`
+}
+
+// queryTraceKey is the context key of the per-request queryTrace
+type queryTraceKey struct{}
+
+func newQueryTrace() *queryTrace {
+	now := time.Now()
+	return &queryTrace{start: now, last: now}
+}
+
+// queryTraceFrom returns the trace set by beginQuery, or nil
+func queryTraceFrom(ctx context.Context) *queryTrace {
+	t, _ := ctx.Value(queryTraceKey{}).(*queryTrace)
+	return t
+}
+
+// mark ends the current stage, which began at the previous mark
+func (t *queryTrace) mark(stage string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.stages = append(t.stages, stageTiming{Stage: stage, Ms: milliseconds(now.Sub(t.last))})
+	t.last = now
+}
+
+// collect starts collecting storage statistics and plan steps
+func (t *queryTrace) collect() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stats == nil {
+		t.stats = &storage.QueryStats{}
+	}
+}
+
+// storageStats returns the stats to pass to the engine, or nil when the
+// trace is not collecting
+func (t *queryTrace) storageStats() *storage.QueryStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stats
+}
+
+// step adds plan steps when the trace is collecting
+func (t *queryTrace) step(steps ...string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stats != nil {
+		t.plan = append(t.plan, steps...)
+	}
+}
+
+// report returns the stages so far and, when collecting, the plan and
+// storage statistics
+func (t *queryTrace) report() *queryReport {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := &queryReport{
+		Plan:    append([]string{}, t.plan...),
+		Stages:  append([]stageTiming{}, t.stages...),
+		TotalMs: milliseconds(time.Since(t.start)),
+	}
+	if t.stats != nil {
+		r.QueryStats = t.stats.Snapshot()
+	}
+	return r
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// explainQuery runs a /query page without sending its points and writes
+// the plan and statistics instead. The scan stops when the request is
+// cancelled or times out.
+func (s *Server) explainQuery(w http.ResponseWriter, r *http.Request, metric string, start, end int64, page queryPage) {
+	trace := queryTraceFrom(r.Context())
+	opts := page.iteratorOptions(start, end)
+	opts.Stats = trace.storageStats()
+	trace.step(fmt.Sprintf("scan %s [%d, %d] order %s offset %d limit %d",
+		metric, opts.Start, opts.End, orderName(opts.Order), opts.Offset, page.limit))
+
+	it := s.storage.Iterator(r.Context(), metric, opts)
+	count := 0
+	for (page.limit == 0 || count < page.limit) && it.Next() {
+		count++
+	}
+	if err := it.Err(); err != nil {
+		status, _ := s.queryErrorStatus(err, http.StatusInternalServerError)
+		writeError(w, status, s.queryErrorMessage(err))
+		return
+	}
+	opts.Stats.AddReturned(count)
+	trace.mark("execute")
+
+	writeJSON(w, map[string]interface{}{"explain": trace.report()})
+}
+
+// selectStep describes a storage select for a plan
+func selectStep(matchers []*storage.Matcher, start, end int64) string {
+	parts := make([]string, len(matchers))
+	for i, m := range matchers {
+		parts[i] = m.String()
+	}
+	return fmt.Sprintf("select {%s} [%d, %d]", strings.Join(parts, ","), start, end)
+}
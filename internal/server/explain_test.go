@@ -0,0 +1,213 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// explainReport is the JSON form of a queryReport
+type explainReport struct {
+	Plan    []string `json:"plan"`
+	Sources []struct {
+		Kind     string `json:"kind"`
+		Metric   string `json:"metric"`
+		Selected bool   `json:"selected"`
+		Reason   string `json:"reason"`
+	} `json:"sources"`
+	IndexLookups []struct {
+		Matchers []string `json:"matchers"`
+		Series   int      `json:"series"`
+	} `json:"index_lookups"`
+	BlocksRead     int64 `json:"blocks_read"`
+	PointsScanned  int64 `json:"points_scanned"`
+	PointsReturned int64 `json:"points_returned"`
+	Stages         []struct {
+		Stage string  `json:"stage"`
+		Ms    float64 `json:"ms"`
+	} `json:"stages"`
+}
+
+func (r *explainReport) stageNames() string {
+	var names []string
+	for _, s := range r.Stages {
+		names = append(names, s.Stage)
+	}
+	return strings.Join(names, ",")
+}
+
+func decodeReport(t *testing.T, v interface{}) *explainReport {
+	t.Helper()
+	data, _ := json.Marshal(v)
+	var report explainReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("invalid report %s: %v", data, err)
+	}
+	return &report
+}
+
+func TestQueryExplain(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"metric": "requests", "start": 0, "end": 30000, "limit": 5, "explain": true,
+	})
+	req := httptest.NewRequest("POST", "/query", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	var resp struct {
+		Explain map[string]interface{} `json:"explain"`
+		Points  []interface{}          `json:"points"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp.Explain == nil || resp.Points != nil {
+		t.Fatalf("expected an explain-only response, got %d %+v", w.Code, resp)
+	}
+
+	report := decodeReport(t, resp.Explain)
+	if len(report.Plan) != 1 || report.Plan[0] != "scan requests [0, 30000] order asc offset 0 limit 5" {
+		t.Errorf("unexpected plan %q", report.Plan)
+	}
+	if len(report.Sources) != 1 || !report.Sources[0].Selected || report.Sources[0].Kind != "memtable" {
+		t.Errorf("unexpected sources %+v", report.Sources)
+	}
+	// 8 points of two hosts in [0, 30s], read in one block
+	if report.BlocksRead != 1 || report.PointsScanned != 8 || report.PointsReturned != 5 {
+		t.Errorf("unexpected counters %+v", report)
+	}
+	if got := report.stageNames(); got != "queue,plan,execute" {
+		t.Errorf("stages = %s", got)
+	}
+}
+
+func TestQueryExplainCancelled(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	// The scan stops before reading a block once the request is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := `{"metric": "requests", "start": 0, "end": 30000, "explain": true}`
+	req := httptest.NewRequest("POST", "/query", strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	srv.handleQuery(w, req)
+
+	if w.Code != http.StatusServiceUnavailable || strings.Contains(w.Body.String(), "explain") {
+		t.Errorf("expected status 503 without a report, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	body := []byte(`{"metric": "requests", "start": 0, "end": 60000, "stats": true}`)
+	req := httptest.NewRequest("POST", "/query", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	var resp struct {
+		Count int                    `json:"count"`
+		Stats map[string]interface{} `json:"stats"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	report := decodeReport(t, resp.Stats)
+	if resp.Count != 14 || report.PointsReturned != 14 || report.stageNames() != "queue,plan,execute" {
+		t.Errorf("unexpected response %d %+v", resp.Count, report)
+	}
+
+	// Without stats the response is unchanged
+	req = httptest.NewRequest("POST", "/query", strings.NewReader(`{"metric": "requests", "start": 0, "end": 60000}`))
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), `"stats"`) {
+		t.Errorf("unexpected stats in %s", w.Body.String())
+	}
+}
+
+func TestPromExplain(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	code, resp := doPromRequest(t, srv, "GET", "/api/v1/query", url.Values{
+		"query": {`EXPLAIN sum(requests{host="b"})`},
+		"time":  {"60"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %+v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	if _, ok := data["result"]; ok {
+		t.Errorf("EXPLAIN should not return a result")
+	}
+
+	report := decodeReport(t, data["explain"])
+	if len(report.Plan) != 2 || report.Plan[0] != `eval sum (requests{host="b"}) at 60000` || !strings.HasPrefix(report.Plan[1], `select {__name__="requests",host="b"}`) {
+		t.Errorf("unexpected plan %q", report.Plan)
+	}
+	if len(report.IndexLookups) != 1 || report.IndexLookups[0].Series != 1 {
+		t.Errorf("unexpected index lookups %+v", report.IndexLookups)
+	}
+	if report.stageNames() != "queue,parse,eval" {
+		t.Errorf("stages = %s", report.stageNames())
+	}
+
+	_, resp = doPromRequest(t, srv, "GET", "/api/v1/query_range", url.Values{
+		"query": {`requests`},
+		"start": {"0"},
+		"end":   {"60"},
+		"step":  {"30"},
+		"stats": {"all"},
+	})
+	data = resp.Data.(map[string]interface{})
+	if _, ok := data["result"]; !ok {
+		t.Fatalf("expected a result with stats, got %v", data)
+	}
+	if report := decodeReport(t, data["stats"]); report.PointsReturned == 0 || len(report.Plan) != 2 {
+		t.Errorf("unexpected stats %+v", report)
+	}
+}
+
+func TestSQLExplain(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	w := doSQLRequest(srv, "", `EXPLAIN SELECT max(value) FROM requests WHERE host = 'zzz'`)
+	var resp struct {
+		Explain map[string]interface{} `json:"explain"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	report := decodeReport(t, resp.Explain)
+	if len(report.Sources) != 1 || report.Sources[0].Selected || report.Sources[0].Reason != "no matching series" {
+		t.Errorf("expected the source to be pruned, got %+v", report.Sources)
+	}
+	if report.PointsScanned != 0 || report.stageNames() != "queue,parse,plan,execute" {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	req := httptest.NewRequest("POST", "/sql?stats=true", strings.NewReader(`SELECT count(value) FROM requests`))
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	var result struct {
+		Series []interface{}          `json:"series"`
+		Stats  map[string]interface{} `json:"stats"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if len(result.Series) != 1 || decodeReport(t, result.Stats).PointsReturned != 14 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
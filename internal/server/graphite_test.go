@@ -0,0 +1,63 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func TestGraphiteListenerIntegration(t *testing.T) {
+	cfg := &config.Config{
+		HTTP: config.HTTPConfig{Address: "127.0.0.1", Port: 0},
+		Storage: config.StorageConfig{
+			DataDir:     t.TempDir(),
+			MaxMemoryMB: 128,
+		},
+		Graphite: config.GraphiteConfig{
+			Enabled:      true,
+			TCPAddress:   "127.0.0.1:0",
+			Templates:    []string{"sensors.* .site.measurement*"},
+			BatchSize:    10,
+			BatchTimeout: 10,
+		},
+	}
+
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.graphite.Start(); err != nil {
+		t.Fatalf("Failed to start graphite listener: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", srv.graphite.TCPAddr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	fmt.Fprintf(conn, "sensors.plant1.temperature 21.5 1699267200\n")
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		points, _ := srv.storage.Query("temperature", 0, 1699267200000)
+		if len(points) == 1 {
+			if points[0].Tags["site"] != "plant1" {
+				t.Errorf("expected site=plant1, got %v", points[0].Tags)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("point was not written")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if pointsWritten, _, _ := srv.getMetrics(); pointsWritten != 1 {
+		t.Errorf("expected pointsWritten=1, got %d", pointsWritten)
+	}
+}
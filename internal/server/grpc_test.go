@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/internal/grpcapi"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func TestGRPCSharesStorage(t *testing.T) {
+	cfg := &config.Config{
+		HTTP: config.HTTPConfig{Address: "127.0.0.1", Port: 0},
+		Storage: config.StorageConfig{
+			DataDir:     t.TempDir(),
+			MaxMemoryMB: 128,
+		},
+		GRPC: config.GRPCConfig{Enabled: true, Address: "127.0.0.1:0"},
+	}
+
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.grpc.Start(); err != nil {
+		t.Fatalf("Failed to start grpc server: %v", err)
+	}
+
+	client, err := grpcapi.Dial(srv.grpc.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if _, err := client.Write(ctx, &grpcapi.WriteRequest{Points: []grpcapi.Point{
+		{Metric: "cpu", Timestamp: 1000, Value: 0.5},
+	}}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Points written over gRPC are visible to the HTTP side
+	points, _ := srv.storage.Query("cpu", 0, 2000)
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point in storage, got %d", len(points))
+	}
+
+	resp, err := client.Query(ctx, &grpcapi.QueryRequest{Metric: "cpu", Start: 0, End: 2000})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(resp.Points) != 1 {
+		t.Errorf("expected 1 point, got %d", len(resp.Points))
+	}
+
+	if pointsWritten, queriesServed, _ := srv.getMetrics(); pointsWritten != 1 || queriesServed != 1 {
+		t.Errorf("expected counters 1/1, got %d/%d", pointsWritten, queriesServed)
+	}
+}
+
+func TestGRPCQueryStreamBudgetCountsMatches(t *testing.T) {
+	cfg := &config.Config{
+		HTTP: config.HTTPConfig{Address: "127.0.0.1", Port: 0},
+		Storage: config.StorageConfig{
+			DataDir:     t.TempDir(),
+			MaxMemoryMB: 128,
+		},
+		GRPC:  config.GRPCConfig{Enabled: true, Address: "127.0.0.1:0"},
+		Query: config.QueryConfig{MaxPoints: 10},
+	}
+
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	defer srv.Stop()
+
+	if err := srv.grpc.Start(); err != nil {
+		t.Fatalf("Failed to start grpc server: %v", err)
+	}
+
+	client, err := grpcapi.Dial(srv.grpc.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+
+	// 100 points of host a and 5 of host b
+	var points []*storage.DataPoint
+	for i := 0; i < 100; i++ {
+		points = append(points, &storage.DataPoint{Metric: "cpu", Timestamp: int64(i), Value: 1, Tags: map[string]string{"host": "a"}})
+	}
+	for i := 0; i < 5; i++ {
+		points = append(points, &storage.DataPoint{Metric: "cpu", Timestamp: int64(i), Value: 2, Tags: map[string]string{"host": "b"}})
+	}
+	srv.storage.WriteBatch(points)
+
+	ctx := context.Background()
+	qs, err := client.QueryStream(ctx, &grpcapi.QueryRequest{Metric: "cpu", Start: 0, End: 1000, BatchSize: 2, Tags: map[string]string{"host": "b"}})
+	if err != nil {
+		t.Fatalf("QueryStream failed: %v", err)
+	}
+	total := 0
+	for {
+		batch, err := qs.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected the tag filter to stay within the budget, got %v", err)
+		}
+		total += len(batch.Points)
+	}
+	if total != 5 {
+		t.Errorf("expected 5 points, got %d", total)
+	}
+
+	// Without the filter the budget runs out
+	qs, err = client.QueryStream(ctx, &grpcapi.QueryRequest{Metric: "cpu", Start: 0, End: 1000})
+	if err != nil {
+		t.Fatalf("QueryStream failed: %v", err)
+	}
+	if _, err := qs.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/Pablo997/pulsardb/pkg/storage"
 )
@@ -15,12 +17,19 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleWrite handles data point writes
+// handleWrite handles data point writes. JSON is the default; a
+// protobuf Content-Type selects the binary format.
 func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	if isProtobuf(r.Header.Get("Content-Type")) {
+		s.handleWriteProtobuf(w, r)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
 	// Parse request body
-	defer r.Body.Close()
 	
 	var body interface{}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -51,48 +60,21 @@ func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	written := 0
+	limited := 0
 	errors := []string{}
 
 	for _, pointData := range points {
-		// Extract fields
-		metric, ok := pointData["metric"].(string)
-		if !ok || metric == "" {
-			errors = append(errors, "missing or invalid metric")
-			continue
-		}
-
-		timestamp, ok := pointData["timestamp"].(float64)
-		if !ok {
-			errors = append(errors, "missing or invalid timestamp")
-			continue
-		}
-
-		value, ok := pointData["value"].(float64)
-		if !ok {
-			errors = append(errors, "missing or invalid value")
+		dp, err := pointFromMap(pointData)
+		if err != nil {
+			errors = append(errors, err.Error())
 			continue
 		}
 
-		// Extract tags (optional)
-		tags := make(map[string]string)
-		if tagsData, ok := pointData["tags"].(map[string]interface{}); ok {
-			for k, v := range tagsData {
-				if strVal, ok := v.(string); ok {
-					tags[k] = strVal
-				}
-			}
-		}
-
-		// Create DataPoint
-		dp := &storage.DataPoint{
-			Metric:    metric,
-			Timestamp: int64(timestamp),
-			Value:     value,
-			Tags:      tags,
-		}
-
 		// Write to storage
 		if err := s.storage.Write(dp); err != nil {
+			if isSeriesLimit(err) {
+				limited++
+			}
 			errors = append(errors, err.Error())
 			continue
 		}
@@ -110,6 +92,10 @@ func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request) {
 		"written": written,
 	}
 
+	if limited > 0 {
+		response["series_limit_rejected"] = limited
+	}
+
 	if len(errors) > 0 {
 		response["errors"] = errors
 		w.WriteHeader(http.StatusPartialContent)
@@ -120,6 +106,42 @@ func (s *Server) handleWrite(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// pointFromMap validates a decoded /write point and converts it
+func pointFromMap(pointData map[string]interface{}) (*storage.DataPoint, error) {
+	// Extract fields
+	metric, ok := pointData["metric"].(string)
+	if !ok || metric == "" {
+		return nil, errors.New("missing or invalid metric")
+	}
+
+	timestamp, ok := pointData["timestamp"].(float64)
+	if !ok {
+		return nil, errors.New("missing or invalid timestamp")
+	}
+
+	value, ok := pointData["value"].(float64)
+	if !ok {
+		return nil, errors.New("missing or invalid value")
+	}
+
+	// Extract tags (optional)
+	tags := make(map[string]string)
+	if tagsData, ok := pointData["tags"].(map[string]interface{}); ok {
+		for k, v := range tagsData {
+			if strVal, ok := v.(string); ok {
+				tags[k] = strVal
+			}
+		}
+	}
+
+	return &storage.DataPoint{
+		Metric:    metric,
+		Timestamp: int64(timestamp),
+		Value:     value,
+		Tags:      tags,
+	}, nil
+}
+
 // handleQuery handles time-series queries
 func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -174,33 +196,47 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query storage
-	points, err := s.storage.Query(metric, int64(start), int64(end))
+	page, err := parseQueryPage(queryReq)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": err.Error(),
 		})
 		return
 	}
 
+	// explain runs the page for its plan and statistics; stats adds the
+	// statistics to the regular response
+	explain, _ := queryReq["explain"].(bool)
+	withStats, _ := queryReq["stats"].(bool)
+	trace := queryTraceFrom(r.Context())
+	if explain || withStats {
+		trace.collect()
+	}
+
+	if err := s.checkBudget(r.Context(), metric, int64(start), int64(end), page); err != nil {
+		status, _ := s.queryErrorStatus(err, http.StatusInternalServerError)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": s.queryErrorMessage(err),
+		})
+		return
+	}
+
 	// TODO: Filter by tags if provided
 	// tags, _ := queryReq["tags"].(map[string]interface{})
 
 	// Update metrics
 	s.incrementQueriesServed()
+	trace.mark("plan")
 
-	// Response
-	response := map[string]interface{}{
-		"metric": metric,
-		"start":  int64(start),
-		"end":    int64(end),
-		"points": points,
-		"count":  len(points),
+	if explain {
+		s.explainQuery(w, r, metric, int64(start), int64(end), page)
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	// Stream points from the storage iterator
+	s.writeQueryStream(w, r, metric, int64(start), int64(end), page)
 }
 
 // handleMetrics returns database metrics
@@ -209,11 +245,30 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	
 	// Get current metrics
 	pointsWritten, queriesServed, uptime := s.getMetrics()
+	card := s.storage.Cardinality("", cardinalityTopMetrics)
 	
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"points_written": pointsWritten,
-		"queries_served": queriesServed,
-		"uptime_seconds": uptime,
+		"points_written":        pointsWritten,
+		"queries_served":        queriesServed,
+		"uptime_seconds":        uptime,
+		"queries_running":       atomic.LoadInt64(&s.queries.running),
+		"queries_queued":        atomic.LoadInt64(&s.queries.queued),
+		"queries_rejected":      atomic.LoadInt64(&s.queries.rejected),
+		"query_timeouts":        atomic.LoadInt64(&s.queries.timeouts),
+		"query_budget_exceeded": atomic.LoadInt64(&s.queries.budgetExceeded),
+		"series_active":         card.Series,
+		"series_rejected":       card.RejectedPoints,
+		"top_series_metrics":    card.TopMetrics,
+		"top_series_tag_keys":   card.TopTagKeys,
 	})
 }
 
+
+// writeError writes a JSON error response with the given status code
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
@@ -19,7 +19,7 @@ func setupTestServer(t *testing.T) *Server {
 			Port:    8080,
 		},
 		Storage: config.StorageConfig{
-			DataDir:     "./test_data_handlers",
+			DataDir:     t.TempDir(),
 			MaxMemoryMB: 128,
 		},
 	}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/Pablo997/pulsardb/internal/promql"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// The metadata endpoints read the storage engine's series index, so they
+// answer without scanning points
+
+// handleMetricNames lists metric names (GET /metrics/names)
+func (s *Server) handleMetricNames(w http.ResponseWriter, r *http.Request) {
+	start, end, err := parseTimeRange(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"metrics": s.storage.MetricNames(start, end),
+	})
+}
+
+// handleTagKeys lists tag keys, optionally of one metric (GET /tags/keys)
+func (s *Server) handleTagKeys(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	start, end, err := parseTimeRange(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric := q.Get("metric")
+	writeJSON(w, map[string]interface{}{
+		"metric": metric,
+		"keys":   s.storage.TagKeys(metric, start, end),
+	})
+}
+
+// handleTagValues lists the values of a tag key, optionally of one metric
+// (GET /tags/values)
+func (s *Server) handleTagValues(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key := q.Get("key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing key")
+		return
+	}
+
+	start, end, err := parseTimeRange(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	metric := q.Get("metric")
+	writeJSON(w, map[string]interface{}{
+		"key":    key,
+		"metric": metric,
+		"values": s.storage.TagValues(key, metric, start, end),
+	})
+}
+
+// handleSeries lists the series matching any of the match selectors
+// (GET /series)
+func (s *Server) handleSeries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	selectors := q["match"]
+	if len(selectors) == 0 {
+		writeError(w, http.StatusBadRequest, "missing match")
+		return
+	}
+
+	start, end, err := parseTimeRange(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sets := make([][]*storage.Matcher, len(selectors))
+	for i, sel := range selectors {
+		vs, err := promql.ParseSelector(sel)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid match %q: %v", sel, err))
+			return
+		}
+		sets[i] = vs.Matchers
+	}
+
+	result := s.indexedSeries(sets, start, end)
+	infos := make([]storage.SeriesInfo, len(result))
+	for i, ser := range result {
+		infos[i] = ser.info
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"series": infos,
+		"count":  len(infos),
+	})
+}
+
+// indexedSeries is a series listed by the index, without points
+type indexedSeries struct {
+	key  string
+	info storage.SeriesInfo
+}
+
+// indexedSeries returns the union of the series matching each matcher
+// set, sorted by series key
+func (s *Server) indexedSeries(sets [][]*storage.Matcher, start, end int64) []indexedSeries {
+	seen := make(map[string]bool)
+	var result []indexedSeries
+	for _, matchers := range sets {
+		for _, info := range s.storage.Series(matchers, start, end) {
+			key := (&storage.DataPoint{Metric: info.Metric, Tags: info.Tags}).SeriesKey()
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, indexedSeries{key, info})
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].key < result[j].key
+	})
+	return result
+}
+
+// parseTimeRange reads the optional start and end parameters, in
+// milliseconds. Missing bounds are open.
+func parseTimeRange(q url.Values) (start, end int64, err error) {
+	start, end = math.MinInt64, math.MaxInt64
+	if v := q.Get("start"); v != "" {
+		if start, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid start: %s", v)
+		}
+	}
+	if v := q.Get("end"); v != "" {
+		if end, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, fmt.Errorf("invalid end: %s", v)
+		}
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("start timestamp must be before end timestamp")
+	}
+	return start, end, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func TestIndexEndpoints(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	srv.storage.WriteBatch([]*storage.DataPoint{
+		{Metric: "idx_cpu", Timestamp: 1000, Value: 1, Tags: map[string]string{"host": "a", "dc": "eu"}},
+		{Metric: "idx_cpu", Timestamp: 2000, Value: 2, Tags: map[string]string{"host": "b"}},
+		{Metric: "idx_mem", Timestamp: 3000, Value: 3, Tags: map[string]string{"host": "a"}},
+	})
+
+	tests := []struct {
+		path  string
+		field string
+		want  string
+	}{
+		{"/metrics/names?start=0&end=5000", "metrics", "[idx_cpu idx_mem]"},
+		{"/metrics/names?start=2500&end=5000", "metrics", "[idx_mem]"},
+		{"/tags/keys?metric=idx_cpu", "keys", "[dc host]"},
+		{"/tags/keys?metric=idx_mem", "keys", "[host]"},
+		{"/tags/values?key=host&metric=idx_cpu", "values", "[a b]"},
+		{"/tags/values?key=host&metric=idx_cpu&end=1500", "values", "[a]"},
+		{"/series?match=" + url.QueryEscape(`{host="a",__name__=~"idx_.*"}`), "count", "2"},
+		{"/series?match=idx_mem&match=" + url.QueryEscape(`idx_cpu{host="b"}`), "count", "2"},
+		{"/series?match=idx_cpu&start=1500", "count", "1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d: %s", tt.path, w.Code, w.Body.String())
+			continue
+		}
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: invalid response: %v", tt.path, err)
+		}
+		if got := fmt.Sprint(resp[tt.field]); got != tt.want {
+			t.Errorf("%s: expected %s %s, got %s", tt.path, tt.field, tt.want, got)
+		}
+	}
+}
+
+func TestIndexEndpointsBadRequest(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	for _, path := range []string{
+		"/metrics/names?start=abc",
+		"/tags/keys?start=2000&end=1000",
+		"/tags/values?metric=cpu",
+		"/series",
+		"/series?match=" + url.QueryEscape(`{host=`),
+	} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+)
+
+// handleLast returns the latest point of every series of a metric,
+// optionally filtered by "key=value,key=value" tags. Points come from the
+// engine's last-value cache, so no time range is scanned.
+func (s *Server) handleLast(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	metric := q.Get("metric")
+	if metric == "" {
+		writeError(w, http.StatusBadRequest, "missing or invalid metric")
+		return
+	}
+
+	matchers, err := subscribeMatchers(metric, q.Get("tags"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	points := s.storage.Last(matchers)
+	writeJSON(w, map[string]interface{}{
+		"metric": metric,
+		"points": points,
+		"count":  len(points),
+	})
+}
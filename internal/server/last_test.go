@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func TestHandleLast(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	srv.storage.WriteBatch([]*storage.DataPoint{
+		{Metric: "last_temp", Timestamp: 1000, Value: 20, Tags: map[string]string{"room": "a"}},
+		{Metric: "last_temp", Timestamp: 2000, Value: 21, Tags: map[string]string{"room": "a"}},
+		{Metric: "last_temp", Timestamp: 1500, Value: 18, Tags: map[string]string{"room": "b"}},
+	})
+
+	tests := []struct {
+		path string
+		want map[string]float64 // room -> value
+	}{
+		{"/last?metric=last_temp", map[string]float64{"a": 21, "b": 18}},
+		{"/last?metric=last_temp&tags=room=b", map[string]float64{"b": 18}},
+		{"/last?metric=missing", map[string]float64{}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d: %s", tt.path, w.Code, w.Body.String())
+			continue
+		}
+		var resp struct {
+			Points []*storage.DataPoint `json:"points"`
+			Count  int                  `json:"count"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: invalid response: %v", tt.path, err)
+		}
+		if resp.Count != len(tt.want) || len(resp.Points) != len(tt.want) {
+			t.Errorf("%s: expected %d points, got %d", tt.path, len(tt.want), resp.Count)
+			continue
+		}
+		for _, p := range resp.Points {
+			if v, ok := tt.want[p.Tags["room"]]; !ok || v != p.Value {
+				t.Errorf("%s: unexpected point %+v", tt.path, p)
+			}
+		}
+	}
+
+	for _, path := range []string{"/last", "/last?metric=last_temp&tags=room"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", path, w.Code)
+		}
+	}
+}
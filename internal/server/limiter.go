@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// errQueryQueueFull rejects a query when every slot is busy and the
+// queue is full
+var errQueryQueueFull = errors.New("too many concurrent queries")
+
+// queryLimiter caps concurrent queries. Queries over the cap wait in a
+// bounded queue for a free slot.
+type queryLimiter struct {
+	slots     chan struct{} // nil when concurrency is unlimited
+	maxQueued int64
+
+	// Counters (atomic operations)
+	running        int64
+	queued         int64
+	rejected       int64
+	timeouts       int64
+	budgetExceeded int64
+}
+
+func newQueryLimiter(cfg *config.QueryConfig) *queryLimiter {
+	l := &queryLimiter{maxQueued: int64(cfg.MaxQueued)}
+	if cfg.MaxConcurrent > 0 {
+		l.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	return l
+}
+
+// acquire waits for a slot until ctx is done. Every successful acquire
+// must be followed by release.
+func (l *queryLimiter) acquire(ctx context.Context) error {
+	if l.slots == nil {
+		atomic.AddInt64(&l.running, 1)
+		return nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		atomic.AddInt64(&l.running, 1)
+		return nil
+	default:
+	}
+
+	if atomic.AddInt64(&l.queued, 1) > l.maxQueued {
+		atomic.AddInt64(&l.queued, -1)
+		atomic.AddInt64(&l.rejected, 1)
+		return errQueryQueueFull
+	}
+	defer atomic.AddInt64(&l.queued, -1)
+
+	select {
+	case l.slots <- struct{}{}:
+		atomic.AddInt64(&l.running, 1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *queryLimiter) release() {
+	atomic.AddInt64(&l.running, -1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// queryBudgetKey is the context key of the per-request storage.QueryBudget
+type queryBudgetKey struct{}
+
+// queryBudgetFrom returns the budget set by beginQuery, or nil
+func queryBudgetFrom(ctx context.Context) *storage.QueryBudget {
+	b, _ := ctx.Value(queryBudgetKey{}).(*storage.QueryBudget)
+	return b
+}
+
+// beginQuery applies the query timeout, waits for a query slot and
+// attaches a fresh budget and trace to the returned context. done must
+// be called when the query finishes.
+func (s *Server) beginQuery(parent context.Context) (ctx context.Context, done func(), err error) {
+	cfg := s.config.Query
+	trace := newQueryTrace()
+
+	ctx, cancel := parent, context.CancelFunc(func() {})
+	if cfg.TimeoutSeconds > 0 {
+		ctx, cancel = context.WithTimeout(parent, time.Duration(cfg.TimeoutSeconds)*time.Second)
+	}
+
+	if err := s.queries.acquire(ctx); err != nil {
+		if err == context.DeadlineExceeded {
+			atomic.AddInt64(&s.queries.timeouts, 1)
+		}
+		cancel()
+		return nil, nil, err
+	}
+
+	trace.mark("queue")
+
+	budget := storage.NewQueryBudget(cfg.MaxPoints, cfg.MaxBytes)
+	ctx = context.WithValue(ctx, queryBudgetKey{}, budget)
+	ctx = context.WithValue(ctx, queryTraceKey{}, trace)
+
+	done = func() {
+		if ctx.Err() == context.DeadlineExceeded {
+			atomic.AddInt64(&s.queries.timeouts, 1)
+		}
+		if budget.Exceeded() {
+			atomic.AddInt64(&s.queries.budgetExceeded, 1)
+		}
+		s.queries.release()
+		cancel()
+	}
+	return ctx, done, nil
+}
+
+// queryErrorStatus maps a query error to an HTTP status and a Prometheus
+// error type. Other errors get fallback.
+func (s *Server) queryErrorStatus(err error, fallback int) (int, string) {
+	switch {
+	case errors.Is(err, storage.ErrBudgetExceeded):
+		return http.StatusUnprocessableEntity, promErrorExecution
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, promErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, promErrorCanceled
+	case err == errQueryQueueFull:
+		return http.StatusServiceUnavailable, promErrorUnavailable
+	}
+	if fallback == http.StatusUnprocessableEntity {
+		return fallback, promErrorExecution
+	}
+	return fallback, promErrorInternal
+}
+
+// queryErrorMessage explains timeouts, which otherwise read as
+// "context deadline exceeded"
+func (s *Server) queryErrorMessage(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "query timed out after " + (time.Duration(s.config.Query.TimeoutSeconds) * time.Second).String()
+	}
+	return err.Error()
+}
+
+// limitQuery wraps a query handler with beginQuery. Limit errors are
+// written with the Prometheus envelope when prom is set.
+func (s *Server) limitQuery(h http.HandlerFunc, prom bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, done, err := s.beginQuery(r.Context())
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				// The client went away while queued
+				return
+			}
+			status, errType := s.queryErrorStatus(err, http.StatusServiceUnavailable)
+			if prom {
+				writePromError(w, status, errType, s.queryErrorMessage(err))
+			} else {
+				writeError(w, status, s.queryErrorMessage(err))
+			}
+			return
+		}
+		defer done()
+
+		h(w, r.WithContext(ctx))
+	}
+}
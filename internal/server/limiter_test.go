@@ -0,0 +1,251 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func TestQueryLimiterQueue(t *testing.T) {
+	l := newQueryLimiter(&config.QueryConfig{MaxConcurrent: 1, MaxQueued: 1})
+
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	// The second query waits for the slot
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(context.Background()) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt64(&l.queued) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("second query was not queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full, so a third is rejected
+	if err := l.acquire(context.Background()); err != errQueryQueueFull {
+		t.Errorf("expected errQueryQueueFull, got %v", err)
+	}
+
+	l.release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued acquire failed: %v", err)
+	}
+	l.release()
+
+	if l.running != 0 || l.queued != 0 || l.rejected != 1 {
+		t.Errorf("unexpected counters: running=%d queued=%d rejected=%d", l.running, l.queued, l.rejected)
+	}
+}
+
+func TestQueryLimiterTimeout(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	srv.config.Query = config.QueryConfig{TimeoutSeconds: 1, MaxConcurrent: 1, MaxQueued: 1}
+	srv.queries = newQueryLimiter(&srv.config.Query)
+
+	// Hold the only slot
+	srv.queries.acquire(context.Background())
+	defer srv.queries.release()
+
+	body := `{"metric": "cpu", "start": 0, "end": 1000}`
+	w := httptest.NewRecorder()
+	srv.limitQuery(srv.handleQuery, false)(w, httptest.NewRequest("POST", "/query", strings.NewReader(body)))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "timed out after 1s") {
+		t.Errorf("unexpected error: %s", w.Body.String())
+	}
+	if srv.queries.timeouts != 1 {
+		t.Errorf("expected 1 timeout, got %d", srv.queries.timeouts)
+	}
+}
+
+func TestQueryBudgetExceeded(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	srv.config.Query.MaxPoints = 2
+
+	for i := int64(0); i < 3; i++ {
+		srv.storage.Write(&storage.DataPoint{Metric: "cpu", Timestamp: i * 1000, Value: 1, Tags: map[string]string{}})
+	}
+
+	// /query checks the budget before streaming
+	body := `{"metric": "cpu", "start": 0, "end": 5000}`
+	w := httptest.NewRecorder()
+	srv.limitQuery(srv.handleQuery, false)(w, httptest.NewRequest("POST", "/query", strings.NewReader(body)))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("/query: expected status 422, got %d", w.Code)
+	}
+
+	// A page within the budget is allowed
+	body = `{"metric": "cpu", "start": 0, "end": 5000, "limit": 2}`
+	w = httptest.NewRecorder()
+	srv.limitQuery(srv.handleQuery, false)(w, httptest.NewRequest("POST", "/query", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("/query with limit: expected status 200, got %d", w.Code)
+	}
+
+	// PromQL selects through the engine budget
+	w = httptest.NewRecorder()
+	srv.limitQuery(srv.handlePromQueryRange, true)(w, httptest.NewRequest("GET", "/api/v1/query_range?query=cpu&start=0&end=5&step=1", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("query_range: expected status 422, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w = httptest.NewRecorder()
+	srv.handleMetrics(w, req)
+
+	var metrics map[string]int64
+	json.NewDecoder(w.Body).Decode(&metrics)
+	if metrics["query_budget_exceeded"] != 2 {
+		t.Errorf("expected query_budget_exceeded=2, got %d", metrics["query_budget_exceeded"])
+	}
+}
+
+func TestQueryBudgetBytes(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	srv.config.Query.MaxBytes = 100
+
+	for i := int64(0); i < 3; i++ {
+		srv.storage.Write(&storage.DataPoint{Metric: "cpu", Timestamp: i * 1000, Value: 1, Tags: map[string]string{"host": "a"}})
+	}
+
+	// Bytes are charged while streaming, so the error ends the stream
+	body := `{"metric": "cpu", "start": 0, "end": 5000}`
+	w := httptest.NewRecorder()
+	srv.limitQuery(srv.handleQuery, false)(w, httptest.NewRequest("POST", "/query", strings.NewReader(body)))
+	var resp struct {
+		Count      int    `json:"count"`
+		Error      string `json:"error"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a complete JSON body, got %q: %v", w.Body.String(), err)
+	}
+	if resp.Count != 1 || !strings.Contains(resp.Error, "more than 100 bytes") || resp.NextCursor == "" {
+		t.Errorf("expected one point and a bytes error, got %d: %s", w.Code, w.Body.String())
+	}
+	if n := atomic.LoadInt64(&srv.queries.budgetExceeded); n != 1 {
+		t.Errorf("expected the query to be counted once, got %d", n)
+	}
+
+	body = `{"metric": "cpu", "start": 0, "end": 5000, "limit": 1}`
+	w = httptest.NewRecorder()
+	srv.limitQuery(srv.handleQuery, false)(w, httptest.NewRequest("POST", "/query", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Errorf("/query with limit: expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// slowWriter is a client that reads the response slowly
+type slowWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+	delay  time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes == 3 {
+		time.Sleep(w.delay)
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+// stalledWriter is a client that stops reading after the first flush
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	deadlines []time.Time
+}
+
+func (w *stalledWriter) SetWriteDeadline(deadline time.Time) error {
+	w.deadlines = append(w.deadlines, deadline)
+	return nil
+}
+
+func (w *stalledWriter) FlushError() error {
+	return errors.New("i/o timeout")
+}
+
+func TestQueryStreamWriteDeadline(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	points := make([]*storage.DataPoint, 2*queryFlushEvery+1)
+	for i := range points {
+		points[i] = &storage.DataPoint{Metric: "cpu", Timestamp: int64(i), Value: 1}
+	}
+	srv.storage.WriteBatch(points)
+
+	req := httptest.NewRequest("POST", "/query", strings.NewReader(`{"metric": "cpu", "start": 0, "end": 5000}`))
+	w := &stalledWriter{ResponseRecorder: httptest.NewRecorder()}
+	srv.handleQuery(w, req)
+
+	if len(w.deadlines) != 2 {
+		t.Fatalf("expected a deadline for the first and second chunks, got %v", w.deadlines)
+	}
+	for _, deadline := range w.deadlines {
+		if deadline.IsZero() {
+			t.Error("expected a write deadline, got none")
+		}
+	}
+
+	var resp struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Count != queryFlushEvery {
+		t.Errorf("expected the stream to stop at the failed flush, got %d points", resp.Count)
+	}
+}
+
+func TestQueryStreamTimeout(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	for i := int64(0); i < 5; i++ {
+		srv.storage.Write(&storage.DataPoint{Metric: "cpu", Timestamp: i * 1000, Value: 1})
+	}
+
+	srv.config.Query.TimeoutSeconds = 1
+
+	// The deadline passes while the client reads the first points
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/query", strings.NewReader(`{"metric": "cpu", "start": 0, "end": 5000}`)).WithContext(ctx)
+	w := &slowWriter{ResponseRecorder: httptest.NewRecorder(), delay: 100 * time.Millisecond}
+	srv.handleQuery(w, req)
+
+	var resp struct {
+		Points     []storage.DataPoint `json:"points"`
+		Count      int                 `json:"count"`
+		NextCursor string              `json:"next_cursor"`
+		Error      string              `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a complete JSON body, got %q: %v", w.Body.String(), err)
+	}
+	if resp.Count == 0 || resp.Count >= 5 || len(resp.Points) != resp.Count || resp.NextCursor == "" {
+		t.Errorf("expected a partial result and a cursor, got %+v", resp)
+	}
+	if !strings.Contains(resp.Error, "timed out") {
+		t.Errorf("expected a timeout error, got %q", resp.Error)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Pablo997/pulsardb/internal/promql"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// handleListMetadata returns the metadata of every registered metric
+// (GET /metadata)
+func (s *Server) handleListMetadata(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{
+		"metadata": s.storage.AllMetadata(),
+	})
+}
+
+// handleGetMetadata returns the metadata of one metric (GET /metadata/{metric})
+func (s *Server) handleGetMetadata(w http.ResponseWriter, r *http.Request) {
+	metric := mux.Vars(r)["metric"]
+
+	md, ok := s.storage.Metadata(metric)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no metadata for metric "+strconv.Quote(metric))
+		return
+	}
+	writeJSON(w, md)
+}
+
+// handlePutMetadata registers the kind, unit and help of a metric
+// (PUT /metadata/{metric})
+func (s *Server) handlePutMetadata(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	metric := mux.Vars(r)["metric"]
+
+	var md storage.MetricMetadata
+	if err := json.NewDecoder(r.Body).Decode(&md); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON format")
+		return
+	}
+	if _, err := storage.ParseMetricKind(string(md.Kind)); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.storage.SetMetadata(metric, md); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, md)
+}
+
+// handleDeleteMetadata removes the metadata of a metric
+// (DELETE /metadata/{metric})
+func (s *Server) handleDeleteMetadata(w http.ResponseWriter, r *http.Request) {
+	metric := mux.Vars(r)["metric"]
+
+	found, err := s.storage.DeleteMetadata(metric)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "no metadata for metric "+strconv.Quote(metric))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// promMetadata is one entry of the Prometheus /api/v1/metadata response
+type promMetadata struct {
+	Type string `json:"type"`
+	Help string `json:"help"`
+	Unit string `json:"unit"`
+}
+
+// handlePromMetadata lists metric metadata in the Prometheus format
+// (GET /api/v1/metadata)
+func (s *Server) handlePromMetadata(w http.ResponseWriter, r *http.Request) {
+	limit := -1
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writePromError(w, http.StatusBadRequest, promErrorBadData, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	all := s.storage.AllMetadata()
+	if metric := r.FormValue("metric"); metric != "" {
+		md, ok := all[metric]
+		all = map[string]storage.MetricMetadata{}
+		if ok {
+			all[metric] = md
+		}
+	}
+
+	result := make(map[string][]promMetadata)
+	for _, metric := range sortedMetadataNames(all) {
+		if limit >= 0 && len(result) >= limit {
+			break
+		}
+		md := all[metric]
+		kind := string(md.Kind)
+		if kind == "" {
+			kind = "unknown"
+		}
+		result[metric] = []promMetadata{{Type: kind, Help: md.Help, Unit: md.Unit}}
+	}
+
+	writePromData(w, result)
+}
+
+// promWarnings checks a PromQL expression against the registered metric
+// kinds
+func (s *Server) promWarnings(expr promql.Expr) []string {
+	return promql.Warnings(expr, func(metric string) (storage.MetricKind, bool) {
+		md, ok := s.storage.Metadata(metric)
+		return md.Kind, ok
+	})
+}
+
+func sortedMetadataNames(all map[string]storage.MetricMetadata) []string {
+	names := make(map[string]bool, len(all))
+	for name := range all {
+		names[name] = true
+	}
+	return sortedKeys(names)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func TestMetadataEndpoints(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("PUT", "/metadata/temperature", `{"kind": "gauge", "unit": "celsius", "help": "Room temperature"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/metadata/temperature", `{"kind": "timer"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid kind, got %d", w.Code)
+	}
+
+	w := do("GET", "/metadata/temperature", "")
+	var md storage.MetricMetadata
+	json.NewDecoder(w.Body).Decode(&md)
+	if w.Code != http.StatusOK || md.Kind != storage.KindGauge || md.Unit != "celsius" {
+		t.Errorf("unexpected metadata %d %+v", w.Code, md)
+	}
+
+	w = do("GET", "/metadata", "")
+	var list struct {
+		Metadata map[string]storage.MetricMetadata `json:"metadata"`
+	}
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list.Metadata) != 1 {
+		t.Errorf("expected 1 metric, got %v", list.Metadata)
+	}
+
+	if w := do("DELETE", "/metadata/temperature", ""); w.Code != http.StatusNoContent {
+		t.Errorf("expected status 204, got %d", w.Code)
+	}
+	if w := do("GET", "/metadata/temperature", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestPromMetadataAndWarnings(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+	srv.storage.SetMetadata("temperature", storage.MetricMetadata{Kind: storage.KindGauge, Unit: "celsius"})
+
+	code, resp := doPromRequest(t, srv, "GET", "/api/v1/metadata", url.Values{})
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	entries := resp.Data.(map[string]interface{})["temperature"].([]interface{})
+	if entry := entries[0].(map[string]interface{}); entry["type"] != "gauge" || entry["unit"] != "celsius" {
+		t.Errorf("unexpected metadata entry %v", entry)
+	}
+
+	_, resp = doPromRequest(t, srv, "GET", "/api/v1/query", url.Values{
+		"query": {`rate(temperature[5m])`},
+		"time":  {"60"},
+	})
+	if resp.Status != "success" || len(resp.Warnings) != 1 {
+		t.Errorf("expected success with 1 warning, got %+v", resp)
+	}
+
+	_, resp = doPromRequest(t, srv, "GET", "/api/v1/query", url.Values{
+		"query": {`rate(requests[1m])`},
+		"time":  {"60"},
+	})
+	if len(resp.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", resp.Warnings)
+	}
+}
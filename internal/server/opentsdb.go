@@ -0,0 +1,118 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Pablo997/pulsardb/internal/opentsdb"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// handleOpenTSDBPut serves the OpenTSDB /api/put endpoint.
+// Following OpenTSDB, success is 204 No Content unless the "summary" or
+// "details" query parameters ask for a body.
+func (s *Server) handleOpenTSDBPut(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	points, err := opentsdb.DecodePut(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON format")
+		return
+	}
+
+	valid := make([]*storage.DataPoint, 0, len(points))
+	validIdx := make([]int, 0, len(points)) // index in points of each valid point
+	var failed []map[string]interface{}
+	for i, point := range points {
+		dp, err := point.ToStorage()
+		if err != nil {
+			failed = append(failed, map[string]interface{}{
+				"datapoint": point,
+				"error":     err.Error(),
+			})
+			continue
+		}
+		valid = append(valid, dp)
+		validIdx = append(validIdx, i)
+	}
+
+	written := len(valid)
+	if len(valid) > 0 {
+		if err := (ingestWriter{s}).WriteBatch(valid); err != nil {
+			// Points refused by the series limits fail like invalid points
+			var partial *storage.PartialWriteError
+			if !errors.As(err, &partial) {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			for _, rej := range partial.Rejected {
+				failed = append(failed, map[string]interface{}{
+					"datapoint": points[validIdx[rej.Index]],
+					"error":     rej.Err.Error(),
+				})
+			}
+			written -= len(partial.Rejected)
+		}
+	}
+
+	query := r.URL.Query()
+	_, summary := query["summary"]
+	_, details := query["details"]
+
+	status := http.StatusOK
+	if len(failed) > 0 {
+		status = http.StatusBadRequest
+	}
+
+	if !summary && !details {
+		if len(failed) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    status,
+				"message": "One or more data points had errors",
+				"details": "Append \"details\" to the put request to see the errors",
+			},
+		})
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": written,
+		"failed":  len(failed),
+	}
+	if details {
+		if failed == nil {
+			failed = []map[string]interface{}{}
+		}
+		response["errors"] = failed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
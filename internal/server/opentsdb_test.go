@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleOpenTSDBPut(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	body := `[
+		{"metric":"sys.cpu","timestamp":1699267200,"value":1,"tags":{"host":"a"}},
+		{"metric":"sys.cpu","timestamp":1699267201500,"value":2,"tags":{"host":"a"}}
+	]`
+	req := httptest.NewRequest("POST", "/api/put", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.handleOpenTSDBPut(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+
+	points, _ := srv.storage.Query("sys.cpu", 0, 1699267300000)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points, got %d", len(points))
+	}
+	if points[0].Timestamp != 1699267200000 {
+		t.Errorf("expected seconds converted to ms, got %d", points[0].Timestamp)
+	}
+}
+
+func TestHandleOpenTSDBPutDetails(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	body := `[
+		{"metric":"sys.cpu","timestamp":1699267200,"value":1},
+		{"metric":"","timestamp":1699267200,"value":2}
+	]`
+	req := httptest.NewRequest("POST", "/api/put?details", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.handleOpenTSDBPut(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+
+	var resp struct {
+		Success int `json:"success"`
+		Failed  int `json:"failed"`
+		Errors  []struct {
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Success != 1 || resp.Failed != 1 || len(resp.Errors) != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleOpenTSDBPutGzip(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"metric":"temp","timestamp":1699267200,"value":21.5}`))
+	gz.Close()
+
+	req := httptest.NewRequest("POST", "/api/put?summary", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handleOpenTSDBPut(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"success":1`) {
+		t.Errorf("unexpected summary: %s", w.Body.String())
+	}
+}
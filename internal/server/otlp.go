@@ -0,0 +1,106 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Pablo997/pulsardb/internal/otlp"
+)
+
+// gRPC status codes used in OTLP/HTTP error bodies
+const (
+	rpcInvalidArgument = 3
+	rpcInternal        = 13
+)
+
+// handleOTLPMetrics serves the OTLP/HTTP metrics receiver. Requests and
+// responses are protobuf or JSON, following the request Content-Type.
+func (s *Server) handleOTLPMetrics(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isJSON := contentType == "application/json"
+	if !isJSON && contentType != "application/x-protobuf" {
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/x-protobuf or application/json")
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeOTLPStatus(w, isJSON, http.StatusBadRequest, rpcInvalidArgument, "invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		writeOTLPStatus(w, isJSON, http.StatusBadRequest, rpcInvalidArgument, "failed to read request body")
+		return
+	}
+
+	var req otlp.ExportMetricsServiceRequest
+	if isJSON {
+		err = json.Unmarshal(data, &req)
+	} else {
+		err = req.Unmarshal(data)
+	}
+	if err != nil {
+		writeOTLPStatus(w, isJSON, http.StatusBadRequest, rpcInvalidArgument, "failed to decode request: "+err.Error())
+		return
+	}
+
+	// Points refused by the series limits are a partial success
+	result, err := s.otlp.Export(&req, ingestWriter{s}.WriteBatch)
+	if err != nil {
+		writeOTLPStatus(w, isJSON, http.StatusInternalServerError, rpcInternal, err.Error())
+		return
+	}
+
+	// Metrics registered by hand keep their metadata
+	if err := s.storage.InferMetadata(result.Metadata); err != nil {
+		writeOTLPStatus(w, isJSON, http.StatusInternalServerError, rpcInternal, err.Error())
+		return
+	}
+
+	if isJSON {
+		resp := map[string]interface{}{}
+		if result.Rejected > 0 {
+			resp["partialSuccess"] = map[string]interface{}{
+				"rejectedDataPoints": result.Rejected,
+				"errorMessage":       result.Message,
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+	w.Write(otlp.MarshalResponse(result.Rejected, result.Message))
+}
+
+// writeOTLPStatus writes a google.rpc.Status error body
+func writeOTLPStatus(w http.ResponseWriter, isJSON bool, status int, code int32, message string) {
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":    code,
+			"message": message,
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(status)
+	w.Write(otlp.MarshalStatus(code, message))
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/pbwire"
+)
+
+func TestHandleOTLPMetricsJSON(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	body := `{"resourceMetrics": [{
+		"resource": {"attributes": [{"key": "host", "value": {"stringValue": "web01"}}]},
+		"scopeMetrics": [{"metrics": [
+			{"name": "cpu", "gauge": {"dataPoints": [{"timeUnixNano": "1699267200000000000", "asDouble": 0.5}]}},
+			{"name": "broken", "sum": {"dataPoints": [{"asInt": "1"}]}}
+		]}]
+	}]}`
+	req := httptest.NewRequest("POST", "/v1/metrics", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.handleOTLPMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"rejectedDataPoints":1`) {
+		t.Errorf("expected partial success, got %s", w.Body.String())
+	}
+
+	points, _ := srv.storage.Query("cpu", 0, 1699267300000)
+	if len(points) != 1 || points[0].Tags["host"] != "web01" || points[0].Timestamp != 1699267200000 {
+		t.Errorf("unexpected points: %+v", points)
+	}
+}
+
+func TestHandleOTLPMetricsProtobuf(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	var point []byte
+	point = pbwire.AppendFixed64(point, 3, 1699267200000000000)
+	point = pbwire.AppendDouble(point, 4, 42)
+	var metric []byte
+	metric = pbwire.AppendString(metric, 1, "queue_depth")
+	metric = pbwire.AppendBytes(metric, 5, pbwire.AppendBytes(nil, 1, point))
+	scopeMetrics := pbwire.AppendBytes(nil, 2, metric)
+	resourceMetrics := pbwire.AppendBytes(nil, 2, scopeMetrics)
+	body := pbwire.AppendBytes(nil, 1, resourceMetrics)
+
+	req := httptest.NewRequest("POST", "/v1/metrics", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	srv.handleOTLPMetrics(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty response, got %x", w.Body.Bytes())
+	}
+
+	points, _ := srv.storage.Query("queue_depth", 0, 1699267300000)
+	if len(points) != 1 || points[0].Value != 42 {
+		t.Errorf("unexpected points: %+v", points)
+	}
+}
+
+func TestHandleOTLPMetricsErrors(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	req := httptest.NewRequest("POST", "/v1/metrics", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	srv.handleOTLPMetrics(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status 415, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/v1/metrics", strings.NewReader("{invalid"))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	srv.handleOTLPMetrics(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/promql"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+	"github.com/gorilla/mux"
+)
+
+// Prometheus HTTP API error types
+const (
+	promErrorBadData     = "bad_data"
+	promErrorExecution   = "execution"
+	promErrorTimeout     = "timeout"
+	promErrorCanceled    = "canceled"
+	promErrorUnavailable = "unavailable"
+	promErrorInternal    = "internal"
+)
+
+// promResponse is the standard Prometheus HTTP API envelope
+type promResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Warnings  []string    `json:"warnings,omitempty"`
+}
+
+// promQueryable adapts the storage engine to the PromQL evaluator
+type promQueryable struct {
+	s   *Server
+	ctx context.Context
+}
+
+// Select implements promql.Queryable
+func (q promQueryable) Select(matchers []*storage.Matcher, mint, maxt int64) ([]promql.Series, error) {
+	selected, err := q.s.selectSeries(q.ctx, matchers, mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]promql.Series, len(selected))
+	for i, ser := range selected {
+		points := make([]promql.Point, len(ser.points))
+		for j, point := range ser.points {
+			points[j] = promql.Point{T: point.Timestamp, V: point.Value}
+		}
+		out[i] = promql.Series{Metric: seriesLabels(ser), Points: points}
+	}
+	return out, nil
+}
+
+// seriesLabels returns the tags of a series plus its metric as __name__
+func seriesLabels(ser *series) promql.Labels {
+	labels := make(promql.Labels, len(ser.tags)+1)
+	for k, v := range ser.tags {
+		labels[k] = v
+	}
+	labels[storage.MetricNameLabel] = ser.metric
+	return labels
+}
+
+// handlePromQuery evaluates an instant query (GET/POST /api/v1/query)
+func (s *Server) handlePromQuery(w http.ResponseWriter, r *http.Request) {
+	ts := time.Now().UnixMilli()
+	if v := r.FormValue("time"); v != "" {
+		t, err := parsePromTime(v)
+		if err != nil {
+			writePromError(w, http.StatusBadRequest, promErrorBadData, err.Error())
+			return
+		}
+		ts = t
+	}
+
+	expr, explain, err := promql.ParseExplain(r.FormValue("query"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, err.Error())
+		return
+	}
+	trace := s.tracePromQuery(r, explain, fmt.Sprintf("eval %s at %d", expr, ts))
+
+	val, err := promql.Instant(r.Context(), promQueryable{s, r.Context()}, expr, ts)
+	if err != nil {
+		status, errType := s.queryErrorStatus(err, http.StatusUnprocessableEntity)
+		writePromError(w, status, errType, s.queryErrorMessage(err))
+		return
+	}
+	trace.mark("eval")
+
+	s.incrementQueriesServed()
+	if explain {
+		writePromData(w, map[string]interface{}{"explain": trace.report()})
+		return
+	}
+
+	var result interface{}
+	switch v := val.(type) {
+	case promql.Scalar:
+		result = promValue(v.T, v.V)
+	case promql.Vector:
+		samples := make([]map[string]interface{}, len(v))
+		for i, sample := range v {
+			samples[i] = map[string]interface{}{
+				"metric": sample.Metric,
+				"value":  promValue(sample.T, sample.V),
+			}
+		}
+		result = samples
+	}
+
+	data := map[string]interface{}{
+		"resultType": val.Type(),
+		"result":     result,
+	}
+	if r.FormValue("stats") != "" {
+		data["stats"] = trace.report()
+	}
+	writePromResult(w, data, s.promWarnings(expr))
+}
+
+// handlePromQueryRange evaluates a range query (GET/POST /api/v1/query_range)
+func (s *Server) handlePromQueryRange(w http.ResponseWriter, r *http.Request) {
+	start, err := parsePromTime(r.FormValue("start"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, "invalid start: "+err.Error())
+		return
+	}
+
+	end, err := parsePromTime(r.FormValue("end"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, "invalid end: "+err.Error())
+		return
+	}
+
+	if end < start {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, "end timestamp must not be before start time")
+		return
+	}
+
+	step, err := parsePromDuration(r.FormValue("step"))
+	if err != nil || step <= 0 {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, "invalid step: must be a positive duration")
+		return
+	}
+
+	expr, explain, err := promql.ParseExplain(r.FormValue("query"))
+	if err != nil {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, err.Error())
+		return
+	}
+	trace := s.tracePromQuery(r, explain, fmt.Sprintf("eval %s over [%d, %d] step %s", expr, start, end, promql.FormatDuration(step)))
+
+	mat, err := promql.Range(r.Context(), promQueryable{s, r.Context()}, expr, start, end, step)
+	if err != nil {
+		status, errType := s.queryErrorStatus(err, http.StatusUnprocessableEntity)
+		writePromError(w, status, errType, s.queryErrorMessage(err))
+		return
+	}
+	trace.mark("eval")
+
+	s.incrementQueriesServed()
+	if explain {
+		writePromData(w, map[string]interface{}{"explain": trace.report()})
+		return
+	}
+
+	result := make([]map[string]interface{}, len(mat))
+	for i, ser := range mat {
+		values := make([][]interface{}, len(ser.Points))
+		for j, p := range ser.Points {
+			values[j] = promValue(p.T, p.V)
+		}
+		result[i] = map[string]interface{}{
+			"metric": ser.Metric,
+			"values": values,
+		}
+	}
+
+	data := map[string]interface{}{
+		"resultType": promql.ValueTypeMatrix,
+		"result":     result,
+	}
+	if r.FormValue("stats") != "" {
+		data["stats"] = trace.report()
+	}
+	writePromResult(w, data, s.promWarnings(expr))
+}
+
+// tracePromQuery ends the parse stage of a PromQL query and starts
+// collecting its plan for EXPLAIN or the stats parameter
+func (s *Server) tracePromQuery(r *http.Request, explain bool, eval string) *queryTrace {
+	trace := queryTraceFrom(r.Context())
+	if explain || r.FormValue("stats") != "" {
+		trace.collect()
+		trace.step(eval)
+	}
+	trace.mark("parse")
+	return trace
+}
+
+// handlePromLabels lists label names (GET/POST /api/v1/labels)
+func (s *Server) handlePromLabels(w http.ResponseWriter, r *http.Request) {
+	selected, ok := s.promMatchSeries(w, r)
+	if !ok {
+		return
+	}
+
+	names := make(map[string]bool)
+	for _, ser := range selected {
+		names[storage.MetricNameLabel] = true
+		for k := range ser.tags {
+			names[k] = true
+		}
+	}
+
+	writePromData(w, sortedKeys(names))
+}
+
+// handlePromLabelValues lists values of one label (GET /api/v1/label/{name}/values)
+func (s *Server) handlePromLabelValues(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	selected, ok := s.promMatchSeries(w, r)
+	if !ok {
+		return
+	}
+
+	values := make(map[string]bool)
+	for _, ser := range selected {
+		if name == storage.MetricNameLabel {
+			values[ser.metric] = true
+		} else if v, ok := ser.tags[name]; ok {
+			values[v] = true
+		}
+	}
+
+	writePromData(w, sortedKeys(values))
+}
+
+// handlePromSeries lists the label sets of matching series (GET/POST /api/v1/series)
+func (s *Server) handlePromSeries(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if len(r.Form["match[]"]) == 0 {
+		writePromError(w, http.StatusBadRequest, promErrorBadData, "no match[] parameter provided")
+		return
+	}
+
+	selected, ok := s.promMatchSeries(w, r)
+	if !ok {
+		return
+	}
+
+	result := make([]promql.Labels, len(selected))
+	for i, ser := range selected {
+		result[i] = seriesLabels(ser)
+	}
+
+	writePromData(w, result)
+}
+
+// promMatchSeries returns the series selected by the match[] selectors and
+// optional start/end parameters. Without match[] every series is returned.
+// On failure an error response has already been written.
+func (s *Server) promMatchSeries(w http.ResponseWriter, r *http.Request) ([]*series, bool) {
+	r.ParseForm()
+
+	start, end := int64(math.MinInt64), int64(math.MaxInt64)
+	if v := r.FormValue("start"); v != "" {
+		t, err := parsePromTime(v)
+		if err != nil {
+			writePromError(w, http.StatusBadRequest, promErrorBadData, "invalid start: "+err.Error())
+			return nil, false
+		}
+		start = t
+	}
+	if v := r.FormValue("end"); v != "" {
+		t, err := parsePromTime(v)
+		if err != nil {
+			writePromError(w, http.StatusBadRequest, promErrorBadData, "invalid end: "+err.Error())
+			return nil, false
+		}
+		end = t
+	}
+
+	// Label lookups only need the series, which the index lists without
+	// reading points
+	selectors := r.Form["match[]"]
+	sets := [][]*storage.Matcher{nil}
+	if len(selectors) > 0 {
+		sets = make([][]*storage.Matcher, len(selectors))
+		for i, sel := range selectors {
+			vs, err := promql.ParseSelector(sel)
+			if err != nil {
+				writePromError(w, http.StatusBadRequest, promErrorBadData, err.Error())
+				return nil, false
+			}
+			sets[i] = vs.Matchers
+		}
+	}
+
+	indexed := s.indexedSeries(sets, start, end)
+	result := make([]*series, len(indexed))
+	for i, ser := range indexed {
+		result[i] = &series{key: ser.key, metric: ser.info.Metric, tags: ser.info.Tags}
+	}
+	return result, true
+}
+
+// maxPromSeconds bounds Unix timestamps so they fit in int64 milliseconds
+const maxPromSeconds = math.MaxInt64 / 1000
+
+// parsePromTime parses a Unix timestamp in (fractional) seconds or an
+// RFC 3339 time into milliseconds
+func parsePromTime(s string) (int64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing timestamp")
+	}
+
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) || f < -maxPromSeconds || f > maxPromSeconds {
+			return 0, fmt.Errorf("timestamp %q out of range", s)
+		}
+		return int64(math.Round(f * 1000)), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+	}
+	return t.UnixMilli(), nil
+}
+
+// parsePromDuration parses seconds or a PromQL duration such as 15s
+func parsePromDuration(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(f) || math.Abs(f) > math.MaxInt64/float64(time.Second) {
+			return 0, fmt.Errorf("duration %q out of range", s)
+		}
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	return promql.ParseDuration(s)
+}
+
+// promValue formats a sample as [unix_seconds, "value"]
+func promValue(ts int64, v float64) []interface{} {
+	return []interface{}{float64(ts) / 1000, strconv.FormatFloat(v, 'f', -1, 64)}
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writePromData(w http.ResponseWriter, data interface{}) {
+	writePromResult(w, data, nil)
+}
+
+// writePromResult writes a successful response with optional warnings
+func writePromResult(w http.ResponseWriter, data interface{}, warnings []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(promResponse{Status: "success", Data: data, Warnings: warnings})
+}
+
+func writePromError(w http.ResponseWriter, status int, errorType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(promResponse{Status: "error", ErrorType: errorType, Error: message})
+}
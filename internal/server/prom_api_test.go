@@ -0,0 +1,286 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func writePromTestData(srv *Server) {
+	for i := int64(0); i <= 6; i++ {
+		srv.storage.Write(&storage.DataPoint{Metric: "requests", Timestamp: i * 10000, Value: float64(i * 10), Tags: map[string]string{"host": "a"}})
+		srv.storage.Write(&storage.DataPoint{Metric: "requests", Timestamp: i * 10000, Value: float64(i * 20), Tags: map[string]string{"host": "b"}})
+	}
+	srv.storage.Write(&storage.DataPoint{Metric: "temperature", Timestamp: 60000, Value: 21.5, Tags: map[string]string{"room": "lab"}})
+}
+
+func doPromRequest(t *testing.T, srv *Server, method, path string, params url.Values) (int, promResponse) {
+	t.Helper()
+
+	var req *http.Request
+	if method == "POST" {
+		req = httptest.NewRequest(method, path, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, path+"?"+params.Encode(), nil)
+	}
+	w := httptest.NewRecorder()
+
+	srv.router.ServeHTTP(w, req)
+
+	var resp promResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestHandlePromQueryVector(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	code, resp := doPromRequest(t, srv, "GET", "/api/v1/query", url.Values{
+		"query": {`sum(rate(requests[1m]))`},
+		"time":  {"60"},
+	})
+
+	if code != http.StatusOK || resp.Status != "success" {
+		t.Fatalf("expected success, got %d %+v", code, resp)
+	}
+
+	data := resp.Data.(map[string]interface{})
+	if data["resultType"] != "vector" {
+		t.Errorf("expected vector, got %v", data["resultType"])
+	}
+
+	result := data["result"].([]interface{})
+	if len(result) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(result))
+	}
+
+	value := result[0].(map[string]interface{})["value"].([]interface{})
+	if value[0].(float64) != 60 || value[1] != "3" {
+		t.Errorf("expected [60, \"3\"], got %v", value)
+	}
+}
+
+func TestHandlePromQueryScalarPost(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	code, resp := doPromRequest(t, srv, "POST", "/api/v1/query", url.Values{
+		"query": {`1 + 2`},
+		"time":  {"2023-11-06T10:00:00Z"},
+	})
+
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+
+	data := resp.Data.(map[string]interface{})
+	if data["resultType"] != "scalar" {
+		t.Errorf("expected scalar, got %v", data["resultType"])
+	}
+
+	value := data["result"].([]interface{})
+	if value[0].(float64) != 1699264800 || value[1] != "3" {
+		t.Errorf("unexpected scalar: %v", value)
+	}
+}
+
+func TestHandlePromQueryRange(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	code, resp := doPromRequest(t, srv, "GET", "/api/v1/query_range", url.Values{
+		"query": {`requests{host="b"} / 10`},
+		"start": {"0"},
+		"end":   {"60"},
+		"step":  {"30s"},
+	})
+
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, resp.Error)
+	}
+
+	data := resp.Data.(map[string]interface{})
+	if data["resultType"] != "matrix" {
+		t.Errorf("expected matrix, got %v", data["resultType"])
+	}
+
+	result := data["result"].([]interface{})
+	if len(result) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(result))
+	}
+
+	series := result[0].(map[string]interface{})
+	if series["metric"].(map[string]interface{})["host"] != "b" {
+		t.Errorf("unexpected metric: %v", series["metric"])
+	}
+
+	values := series["values"].([]interface{})
+	if len(values) != 3 {
+		t.Fatalf("expected 3 values, got %d", len(values))
+	}
+
+	last := values[2].([]interface{})
+	if last[0].(float64) != 60 || last[1] != "12" {
+		t.Errorf("unexpected last value: %v", last)
+	}
+}
+
+func TestHandlePromQueryCrossSeries(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	// 0 / 0 at the first step is NaN, which the comparison filters out
+	code, resp := doPromRequest(t, srv, "GET", "/api/v1/query_range", url.Values{
+		"query": {`requests{host="b"} / ignoring(host) requests{host="a"} > 1`},
+		"start": {"0"},
+		"end":   {"60"},
+		"step":  {"30s"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", code, resp.Error)
+	}
+
+	result := resp.Data.(map[string]interface{})["result"].([]interface{})
+	if len(result) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(result))
+	}
+
+	series := result[0].(map[string]interface{})
+	if len(series["metric"].(map[string]interface{})) != 0 {
+		t.Errorf("expected no labels, got %v", series["metric"])
+	}
+
+	values := series["values"].([]interface{})
+	if len(values) != 2 {
+		t.Fatalf("expected 2 values, got %v", values)
+	}
+	for _, v := range values {
+		if v.([]interface{})[1] != "2" {
+			t.Errorf("expected 2, got %v", v)
+		}
+	}
+}
+
+func TestHandlePromQueryErrors(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	tests := []struct {
+		name   string
+		path   string
+		params url.Values
+	}{
+		{"parse error", "/api/v1/query", url.Values{"query": {`sum(`}}},
+		{"bad time", "/api/v1/query", url.Values{"query": {`1`}, "time": {"yesterday"}}},
+		{"missing step", "/api/v1/query_range", url.Values{"query": {`1`}, "start": {"0"}, "end": {"10"}}},
+		{"end before start", "/api/v1/query_range", url.Values{"query": {`1`}, "start": {"10"}, "end": {"0"}, "step": {"1"}}},
+		{"NaN time", "/api/v1/query", url.Values{"query": {`1`}, "time": {"NaN"}}},
+		{"NaN start", "/api/v1/query_range", url.Values{"query": {`1`}, "start": {"NaN"}, "end": {"10"}, "step": {"1"}}},
+		{"infinite end", "/api/v1/query_range", url.Values{"query": {`1`}, "start": {"0"}, "end": {"+Inf"}, "step": {"1"}}},
+		{"start out of range", "/api/v1/query_range", url.Values{"query": {`1`}, "start": {"-1e19"}, "end": {"10"}, "step": {"1"}}},
+		{"infinite step", "/api/v1/query_range", url.Values{"query": {`1`}, "start": {"0"}, "end": {"10"}, "step": {"Inf"}}},
+		{"series without match", "/api/v1/series", url.Values{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, resp := doPromRequest(t, srv, "GET", tt.path, tt.params)
+
+			if code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", code)
+			}
+			if resp.Status != "error" || resp.ErrorType != promErrorBadData {
+				t.Errorf("unexpected error envelope: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandlePromQueryRangeTimeout(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	srv.config.Query.TimeoutSeconds = 1
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	params := url.Values{"query": {`1`}, "start": {"0"}, "end": {"3600"}, "step": {"1"}}
+	req := httptest.NewRequest("GET", "/api/v1/query_range?"+params.Encode(), nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	srv.handlePromQueryRange(w, req)
+
+	var resp promResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusServiceUnavailable || resp.ErrorType != promErrorTimeout {
+		t.Errorf("expected a 503 timeout, got %d %+v", w.Code, resp)
+	}
+}
+
+func TestHandlePromLabels(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	_, resp := doPromRequest(t, srv, "GET", "/api/v1/labels", url.Values{})
+	labels := resp.Data.([]interface{})
+	if len(labels) != 3 || labels[0] != "__name__" || labels[1] != "host" || labels[2] != "room" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+
+	_, resp = doPromRequest(t, srv, "GET", "/api/v1/labels", url.Values{"match[]": {"temperature"}})
+	labels = resp.Data.([]interface{})
+	if len(labels) != 2 || labels[1] != "room" {
+		t.Errorf("unexpected labels for temperature: %v", labels)
+	}
+}
+
+func TestHandlePromLabelValues(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	_, resp := doPromRequest(t, srv, "GET", "/api/v1/label/__name__/values", url.Values{})
+	values := resp.Data.([]interface{})
+	if len(values) != 2 || values[0] != "requests" || values[1] != "temperature" {
+		t.Errorf("unexpected metric names: %v", values)
+	}
+
+	_, resp = doPromRequest(t, srv, "GET", "/api/v1/label/host/values", url.Values{})
+	values = resp.Data.([]interface{})
+	if len(values) != 2 || values[0] != "a" || values[1] != "b" {
+		t.Errorf("unexpected host values: %v", values)
+	}
+}
+
+func TestHandlePromSeries(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	_, resp := doPromRequest(t, srv, "POST", "/api/v1/series", url.Values{
+		"match[]": {`requests{host="a"}`, `temperature`},
+	})
+
+	series := resp.Data.([]interface{})
+	if len(series) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(series))
+	}
+
+	first := series[0].(map[string]interface{})
+	if first["__name__"] != "requests" || first["host"] != "a" {
+		t.Errorf("unexpected series: %v", first)
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// queryFlushEvery is the number of points written between flushes of a
+// streamed /query response
+const queryFlushEvery = 1000
+
+// queryWriteTimeout bounds the writing of each flushed chunk of a
+// streamed /query response, so a stalled client cannot hold the query
+const queryWriteTimeout = 15 * time.Second
+
+// queryPage holds the /query pagination parameters
+type queryPage struct {
+	limit  int // 0 means no limit
+	offset int
+	order  storage.Order
+	cursor *queryCursor
+}
+
+// queryCursor resumes a query after the last point of a page. It is sent
+// to clients as an opaque string.
+type queryCursor struct {
+	order     storage.Order
+	timestamp int64
+	skip      int
+}
+
+func (c *queryCursor) String() string {
+	raw := fmt.Sprintf("%s:%d:%d", orderName(c.order), c.timestamp, c.skip)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseQueryCursor(s string) (*queryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid cursor")
+	}
+
+	c := &queryCursor{}
+	var errOrder, errTS, errSkip error
+	c.order, errOrder = parseOrder(parts[0])
+	c.timestamp, errTS = strconv.ParseInt(parts[1], 10, 64)
+	c.skip, errSkip = strconv.Atoi(parts[2])
+	if parts[0] == "" || errOrder != nil || errTS != nil || errSkip != nil || c.skip < 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+func orderName(o storage.Order) string {
+	if o == storage.Descending {
+		return "desc"
+	}
+	return "asc"
+}
+
+func parseOrder(s string) (storage.Order, error) {
+	switch s {
+	case "", "asc":
+		return storage.Ascending, nil
+	case "desc":
+		return storage.Descending, nil
+	default:
+		return 0, errors.New("order must be asc or desc")
+	}
+}
+
+// parseQueryPage reads limit, offset, order and cursor from a /query body
+func parseQueryPage(req map[string]interface{}) (queryPage, error) {
+	var page queryPage
+
+	count := func(name string) (int, error) {
+		v, ok := req[name]
+		if !ok {
+			return 0, nil
+		}
+		n, ok := v.(float64)
+		if !ok || n < 0 || n != float64(int(n)) {
+			return 0, fmt.Errorf("%s must be a non-negative integer", name)
+		}
+		return int(n), nil
+	}
+
+	var err error
+	if page.limit, err = count("limit"); err != nil {
+		return page, err
+	}
+	if page.offset, err = count("offset"); err != nil {
+		return page, err
+	}
+
+	if v, ok := req["order"]; ok {
+		s, _ := v.(string)
+		if page.order, err = parseOrder(s); err != nil {
+			return page, err
+		}
+	}
+
+	if v, ok := req["cursor"]; ok {
+		s, _ := v.(string)
+		if page.cursor, err = parseQueryCursor(s); err != nil {
+			return page, err
+		}
+		if page.offset > 0 {
+			return page, errors.New("cursor and offset cannot be combined")
+		}
+		if page.cursor.order != page.order {
+			return page, errors.New("cursor was issued for order " + orderName(page.cursor.order))
+		}
+	}
+
+	return page, nil
+}
+
+// iteratorOptions returns the storage range for the page of [start, end]
+func (page queryPage) iteratorOptions(start, end int64) storage.IteratorOptions {
+	opts := storage.IteratorOptions{
+		Start:  start,
+		End:    end,
+		Order:  page.order,
+		Offset: page.offset,
+	}
+	if c := page.cursor; c != nil {
+		if c.order == storage.Descending {
+			opts.End = c.timestamp
+		} else {
+			opts.Start = c.timestamp
+		}
+		opts.Offset = c.skip
+	}
+	return opts
+}
+
+// checkBudget fails if the page holds more points than the query budget
+// allows. Points are counted without reading them, so the error can still
+// be sent as a status code. Bytes are only known once points are read and
+// are charged while streaming.
+func (s *Server) checkBudget(ctx context.Context, metric string, start, end int64, page queryPage) error {
+	budget := queryBudgetFrom(ctx)
+	if budget == nil || budget.MaxPoints <= 0 {
+		return nil
+	}
+
+	opts := page.iteratorOptions(start, end)
+	n := s.storage.Count(metric, opts.Start, opts.End) - opts.Offset
+	if page.limit > 0 && n > page.limit {
+		n = page.limit
+	}
+	if err := budget.Check(int64(n)); err != nil {
+		return fmt.Errorf("%w, use limit to page through the result", err)
+	}
+	return nil
+}
+
+// writeQueryStream streams the points of a /query as JSON. Points are read
+// from a storage iterator and flushed in chunks, so the response is never
+// held in memory. When limit cuts the result short, next_cursor resumes
+// after the last point. Statistics follow the points when the query's
+// trace collects them.
+//
+// Every point sent is charged to the query budget. If the query times out
+// or the points exhaust the byte budget, or points written since
+// checkBudget exhaust the point budget, the response
+// ends early with the points so far, an "error" field and a next_cursor
+// after the last point sent.
+func (s *Server) writeQueryStream(w http.ResponseWriter, r *http.Request, metric string, start, end int64, page queryPage) {
+	trace := queryTraceFrom(r.Context())
+	opts := page.iteratorOptions(start, end)
+	opts.Stats = trace.storageStats()
+	it := s.storage.Iterator(r.Context(), metric, opts)
+
+	// Large results outlive the server's write timeout, so the deadline
+	// moves forward with every chunk instead
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(queryWriteTimeout))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	metricJSON, _ := json.Marshal(metric)
+	fmt.Fprintf(w, `{"metric":%s,"start":%d,"end":%d,"order":%q,"points":[`,
+		metricJSON, start, end, orderName(page.order))
+
+	budget := queryBudgetFrom(r.Context())
+	count := 0
+	pos := &queryCursor{order: page.order}
+	var next *queryCursor
+	var streamErr error
+	for it.Next() {
+		if page.limit > 0 && count == page.limit {
+			// More points remain after this page
+			next = pos
+			break
+		}
+		if streamErr = r.Context().Err(); streamErr != nil {
+			break
+		}
+		if budget != nil {
+			if streamErr = budget.Charge(it.At()); streamErr != nil {
+				break
+			}
+		}
+
+		if count > 0 {
+			w.Write([]byte{','})
+		}
+		data, _ := json.Marshal(it.At())
+		w.Write(data)
+		count++
+		pos.timestamp, pos.skip = it.Position()
+
+		if count%queryFlushEvery == 0 {
+			rc.SetWriteDeadline(time.Now().Add(queryWriteTimeout))
+			if streamErr = rc.Flush(); streamErr != nil {
+				// The client is gone or stalled
+				break
+			}
+		}
+	}
+
+	if streamErr == nil {
+		streamErr = it.Err()
+	}
+	if streamErr != nil && count > 0 {
+		// The client may resume after the points it did get
+		next = pos
+	}
+
+	fmt.Fprintf(w, `],"count":%d`, count)
+	if streamErr != nil {
+		msg, _ := json.Marshal(s.queryErrorMessage(streamErr))
+		fmt.Fprintf(w, `,"error":%s`, msg)
+	}
+	if next != nil {
+		fmt.Fprintf(w, `,"next_cursor":%q`, next.String())
+	}
+	if opts.Stats != nil {
+		opts.Stats.AddReturned(count)
+		trace.mark("execute")
+		data, _ := json.Marshal(trace.report())
+		fmt.Fprintf(w, `,"stats":%s`, data)
+	}
+	w.Write([]byte("}\n"))
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+type pagedQueryResponse struct {
+	Points     []storage.DataPoint `json:"points"`
+	Count      int                 `json:"count"`
+	Order      string              `json:"order"`
+	NextCursor string              `json:"next_cursor"`
+}
+
+func postQuery(t *testing.T, srv *Server, body map[string]interface{}) (*httptest.ResponseRecorder, pagedQueryResponse) {
+	t.Helper()
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/query", bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	srv.handleQuery(w, req)
+
+	var resp pagedQueryResponse
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", w.Body.String(), err)
+		}
+	}
+	return w, resp
+}
+
+func TestHandleQueryPagination(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	// Two points share each timestamp so pages split equal timestamps
+	for i := 0; i < 7; i++ {
+		srv.storage.Write(&storage.DataPoint{Metric: "paged", Timestamp: int64(1000 * (i/2 + 1)), Value: float64(i)})
+	}
+
+	tests := []struct {
+		order string
+		want  string
+	}{
+		{"asc", "[0 1 2 3 4 5 6]"},
+		{"desc", "[6 5 4 3 2 1 0]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			body := map[string]interface{}{"metric": "paged", "start": 0, "end": 10000, "limit": 3, "order": tt.order}
+
+			var values []float64
+			pages := 0
+			for {
+				w, resp := postQuery(t, srv, body)
+				if w.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+				}
+				if resp.Count != len(resp.Points) || resp.Order != tt.order {
+					t.Fatalf("inconsistent page: %+v", resp)
+				}
+				for _, p := range resp.Points {
+					values = append(values, p.Value)
+				}
+				pages++
+				if resp.NextCursor == "" {
+					break
+				}
+				body["cursor"] = resp.NextCursor
+			}
+
+			if got := fmt.Sprint(values); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+			if pages != 3 {
+				t.Errorf("expected 3 pages, got %d", pages)
+			}
+		})
+	}
+}
+
+func TestHandleQueryOffset(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	for i := 0; i < 5; i++ {
+		srv.storage.Write(&storage.DataPoint{Metric: "off", Timestamp: int64(1000 * (i + 1)), Value: float64(i)})
+	}
+
+	_, resp := postQuery(t, srv, map[string]interface{}{"metric": "off", "start": 0, "end": 10000, "offset": 3})
+	if resp.Count != 2 || resp.Points[0].Value != 3 || resp.NextCursor != "" {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleQueryPaginationErrors(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	cursor := (&queryCursor{order: storage.Descending, timestamp: 1000}).String()
+	tests := []struct {
+		name  string
+		extra map[string]interface{}
+	}{
+		{"negative limit", map[string]interface{}{"limit": -1}},
+		{"fractional offset", map[string]interface{}{"offset": 1.5}},
+		{"invalid order", map[string]interface{}{"order": "up"}},
+		{"invalid cursor", map[string]interface{}{"cursor": "not-a-cursor"}},
+		{"cursor with offset", map[string]interface{}{"cursor": cursor, "order": "desc", "offset": 1}},
+		{"cursor order mismatch", map[string]interface{}{"cursor": cursor}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]interface{}{"metric": "cpu", "start": 0, "end": 1000}
+			for k, v := range tt.extra {
+				body[k] = v
+			}
+			if w, _ := postQuery(t, srv, body); w.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400, got %d", w.Code)
+			}
+		})
+	}
+}
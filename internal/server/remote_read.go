@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/Pablo997/pulsardb/internal/prompb"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+	"github.com/golang/snappy"
+)
+
+// handleRemoteRead serves the Prometheus remote_read protocol.
+// Request and response bodies are snappy-compressed protobuf messages;
+// only the SAMPLES response type is supported.
+func (s *Server) handleRemoteRead(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	max := s.maxBodySize()
+	var body io.Reader = r.Body
+	if max > 0 {
+		body = http.MaxBytesReader(w, r.Body, max)
+	}
+	compressed, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	size, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid snappy payload")
+		return
+	}
+	if max > 0 && int64(size) > max {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid snappy payload")
+		return
+	}
+
+	var req prompb.ReadRequest
+	if err := req.Unmarshal(data); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid read request: "+err.Error())
+		return
+	}
+
+	resp := &prompb.ReadResponse{
+		Results: make([]prompb.QueryResult, len(req.Queries)),
+	}
+
+	for i, q := range req.Queries {
+		matchers, err := toStorageMatchers(q.Matchers)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		selected, err := s.selectSeries(r.Context(), matchers, q.StartTimestampMs, q.EndTimestampMs)
+		if err != nil {
+			status, _ := s.queryErrorStatus(err, http.StatusInternalServerError)
+			writeError(w, status, s.queryErrorMessage(err))
+			return
+		}
+
+		resp.Results[i].Timeseries = toPromSeries(selected)
+		s.incrementQueriesServed()
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	w.WriteHeader(http.StatusOK)
+	w.Write(snappy.Encode(nil, resp.Marshal()))
+}
+
+// toStorageMatchers converts remote read matchers into storage matchers
+func toStorageMatchers(in []prompb.LabelMatcher) ([]*storage.Matcher, error) {
+	matchers := make([]*storage.Matcher, 0, len(in))
+	for _, lm := range in {
+		var t storage.MatchType
+		switch lm.Type {
+		case prompb.MatchEqual:
+			t = storage.MatchEqual
+		case prompb.MatchNotEqual:
+			t = storage.MatchNotEqual
+		case prompb.MatchRegexp:
+			t = storage.MatchRegexp
+		case prompb.MatchNotRegexp:
+			t = storage.MatchNotRegexp
+		default:
+			return nil, fmt.Errorf("unknown matcher type %d", lm.Type)
+		}
+
+		m, err := storage.NewMatcher(t, lm.Name, lm.Value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// toPromSeries converts selected series into remote read time series.
+// Labels are sorted by name as Prometheus requires.
+func toPromSeries(selected []*series) []prompb.TimeSeries {
+	out := make([]prompb.TimeSeries, 0, len(selected))
+	for _, ser := range selected {
+		labels := make([]prompb.Label, 0, len(ser.tags)+1)
+		labels = append(labels, prompb.Label{Name: storage.MetricNameLabel, Value: ser.metric})
+		for k, v := range ser.tags {
+			labels = append(labels, prompb.Label{Name: k, Value: v})
+		}
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+
+		samples := make([]prompb.Sample, len(ser.points))
+		for i, point := range ser.points {
+			samples[i] = prompb.Sample{Value: point.Value, Timestamp: point.Timestamp}
+		}
+
+		out = append(out, prompb.TimeSeries{Labels: labels, Samples: samples})
+	}
+	return out
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/prompb"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+	"github.com/golang/snappy"
+)
+
+func doRemoteRead(t *testing.T, srv *Server, req *prompb.ReadRequest) (*http.Response, *prompb.ReadResponse) {
+	t.Helper()
+
+	body := snappy.Encode(nil, req.Marshal())
+	httpReq := httptest.NewRequest("POST", "/api/v1/read", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	w := httptest.NewRecorder()
+
+	srv.handleRemoteRead(w, httpReq)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	compressed, _ := io.ReadAll(resp.Body)
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		t.Fatalf("failed to decompress response: %v", err)
+	}
+
+	var readResp prompb.ReadResponse
+	if err := readResp.Unmarshal(data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	return resp, &readResp
+}
+
+func TestHandleRemoteRead(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	srv.storage.Write(&storage.DataPoint{Metric: "temp", Timestamp: 2000, Value: 2.0, Tags: map[string]string{"sensor": "a"}})
+	srv.storage.Write(&storage.DataPoint{Metric: "temp", Timestamp: 1000, Value: 1.0, Tags: map[string]string{"sensor": "a"}})
+	srv.storage.Write(&storage.DataPoint{Metric: "temp", Timestamp: 1000, Value: 5.0, Tags: map[string]string{"sensor": "b"}})
+	srv.storage.Write(&storage.DataPoint{Metric: "temp", Timestamp: 1000, Value: 9.0, Tags: map[string]string{"sensor": "c"}})
+	srv.storage.Write(&storage.DataPoint{Metric: "humidity", Timestamp: 1000, Value: 40.0})
+
+	req := &prompb.ReadRequest{
+		Queries: []prompb.Query{
+			{
+				StartTimestampMs: 0,
+				EndTimestampMs:   5000,
+				Matchers: []prompb.LabelMatcher{
+					{Type: prompb.MatchEqual, Name: "__name__", Value: "temp"},
+					{Type: prompb.MatchNotRegexp, Name: "sensor", Value: "c"},
+				},
+			},
+		},
+	}
+
+	resp, readResp := doRemoteRead(t, srv, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	if resp.Header.Get("Content-Encoding") != "snappy" {
+		t.Errorf("expected snappy content encoding, got %q", resp.Header.Get("Content-Encoding"))
+	}
+
+	if len(readResp.Results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(readResp.Results))
+	}
+
+	series := readResp.Results[0].Timeseries
+	if len(series) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(series))
+	}
+
+	first := series[0]
+	if first.Labels[0].Name != "__name__" || first.Labels[1].Value != "a" {
+		t.Errorf("unexpected labels: %+v", first.Labels)
+	}
+
+	if len(first.Samples) != 2 || first.Samples[0].Timestamp != 1000 || first.Samples[1].Value != 2.0 {
+		t.Errorf("expected samples sorted by timestamp, got %+v", first.Samples)
+	}
+}
+
+func TestHandleRemoteReadNameRegex(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	srv.storage.Write(&storage.DataPoint{Metric: "temp_inside", Timestamp: 1000, Value: 1.0})
+	srv.storage.Write(&storage.DataPoint{Metric: "temp_outside", Timestamp: 1000, Value: 2.0})
+	srv.storage.Write(&storage.DataPoint{Metric: "humidity", Timestamp: 1000, Value: 3.0})
+
+	req := &prompb.ReadRequest{
+		Queries: []prompb.Query{
+			{
+				EndTimestampMs: 5000,
+				Matchers: []prompb.LabelMatcher{
+					{Type: prompb.MatchRegexp, Name: "__name__", Value: "temp_.*"},
+				},
+			},
+		},
+	}
+
+	_, readResp := doRemoteRead(t, srv, req)
+	if readResp == nil {
+		t.Fatal("expected successful response")
+	}
+
+	if got := len(readResp.Results[0].Timeseries); got != 2 {
+		t.Errorf("expected 2 series, got %d", got)
+	}
+}
+
+func TestHandleRemoteReadInvalid(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	t.Run("not snappy", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/read", bytes.NewBufferString("garbage"))
+		w := httptest.NewRecorder()
+
+		srv.handleRemoteRead(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("bad regex", func(t *testing.T) {
+		req := &prompb.ReadRequest{
+			Queries: []prompb.Query{
+				{Matchers: []prompb.LabelMatcher{{Type: prompb.MatchRegexp, Name: "sensor", Value: "("}}},
+			},
+		}
+
+		resp, _ := doRemoteRead(t, srv, req)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected status 400, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("body too large", func(t *testing.T) {
+		srv.config.HTTP.MaxBodySizeMB = 1
+		defer func() { srv.config.HTTP.MaxBodySizeMB = 0 }()
+
+		// Compresses to a few KB but decodes past the cap
+		bomb := snappy.Encode(nil, make([]byte, 2<<20))
+		// Does not compress, so it is over the cap before decoding
+		noise := make([]byte, 2<<20)
+		rand.Read(noise)
+
+		for name, body := range map[string][]byte{"decoded": bomb, "compressed": snappy.Encode(nil, noise)} {
+			req := httptest.NewRequest("POST", "/api/v1/read", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			srv.handleRemoteRead(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("%s: expected status 413, got %d", name, w.Code)
+			}
+		}
+	})
+}
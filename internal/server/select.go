@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// series is a group of points sharing the same metric and tags
+type series struct {
+	key    string
+	metric string
+	tags   map[string]string
+	points []*storage.DataPoint
+}
+
+// selectSeries returns all series matching the matchers within [start, end],
+// sorted by series key with points sorted by timestamp. It stops early
+// if ctx is cancelled or the query budget from ctx runs out.
+func (s *Server) selectSeries(ctx context.Context, matchers []*storage.Matcher, start, end int64) ([]*series, error) {
+	trace := queryTraceFrom(ctx)
+	trace.step(selectStep(matchers, start, end))
+
+	it, err := s.storage.Select(ctx, storage.QuerySpec{
+		Matchers: matchers,
+		Start:    start,
+		End:      end,
+		Budget:   queryBudgetFrom(ctx),
+		Stats:    trace.storageStats(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	// Points arrive in timestamp order, so each series stays sorted
+	bySeries := make(map[string]*series)
+	for it.Next() {
+		point := it.At()
+		key := point.SeriesKey()
+		ser, ok := bySeries[key]
+		if !ok {
+			ser = &series{key: key, metric: point.Metric, tags: point.Tags}
+			bySeries[key] = ser
+		}
+		ser.points = append(ser.points, point)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*series, 0, len(bySeries))
+	for _, ser := range bySeries {
+		result = append(result, ser)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].key < result[j].key
+	})
+
+	return result, nil
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync/atomic"
@@ -9,6 +10,12 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/internal/graphite"
+	"github.com/Pablo997/pulsardb/internal/grpcapi"
+	"github.com/Pablo997/pulsardb/internal/mqtt"
+	"github.com/Pablo997/pulsardb/internal/opentsdb"
+	"github.com/Pablo997/pulsardb/internal/otlp"
+	"github.com/Pablo997/pulsardb/internal/statsd"
 	"github.com/Pablo997/pulsardb/pkg/storage"
 )
 
@@ -18,6 +25,19 @@ type Server struct {
 	storage *storage.Engine
 	router  *mux.Router
 	server  *http.Server
+
+	// Optional protocol listeners started alongside HTTP
+	graphite *graphite.Listener
+	statsd   *statsd.Listener
+	opentsdb *opentsdb.Listener
+	mqtt     *mqtt.Subscriber
+	grpc     *grpcapi.Server
+
+	// OTLP converter; holds running totals for delta temporality
+	otlp *otlp.Converter
+
+	// Concurrent query limit shared by HTTP and gRPC queries
+	queries *queryLimiter
 	
 	// Metrics (atomic operations, no mutex needed)
 	startTime     time.Time
@@ -36,9 +56,39 @@ func New(cfg *config.Config) (*Server, error) {
 		config:    cfg,
 		storage:   engine,
 		router:    mux.NewRouter(),
+		otlp:      otlp.NewConverter(),
+		queries:   newQueryLimiter(&cfg.Query),
 		startTime: time.Now(),
 	}
 
+	if cfg.Graphite.Enabled {
+		listener, err := graphite.NewListener(&cfg.Graphite, ingestWriter{s})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create graphite listener: %w", err)
+		}
+		s.graphite = listener
+	}
+
+	if cfg.StatsD.Enabled {
+		s.statsd = statsd.NewListener(&cfg.StatsD, ingestWriter{s})
+	}
+
+	if cfg.OpenTSDB.Enabled {
+		s.opentsdb = opentsdb.NewListener(&cfg.OpenTSDB, ingestWriter{s})
+	}
+
+	if cfg.MQTT.Enabled {
+		subscriber, err := mqtt.NewSubscriber(&cfg.MQTT, ingestWriter{s})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mqtt subscriber: %w", err)
+		}
+		s.mqtt = subscriber
+	}
+
+	if cfg.GRPC.Enabled {
+		s.grpc = grpcapi.NewServer(&cfg.GRPC, grpcBackend{ingestWriter{s}})
+	}
+
 	s.setupRoutes()
 
 	s.server = &http.Server{
@@ -52,8 +102,38 @@ func New(cfg *config.Config) (*Server, error) {
 	return s, nil
 }
 
-// Start starts the HTTP server
+// Start starts the protocol listeners and the HTTP server
 func (s *Server) Start() error {
+	if s.graphite != nil {
+		if err := s.graphite.Start(); err != nil {
+			return fmt.Errorf("failed to start graphite listener: %w", err)
+		}
+	}
+
+	if s.statsd != nil {
+		if err := s.statsd.Start(); err != nil {
+			return fmt.Errorf("failed to start statsd listener: %w", err)
+		}
+	}
+
+	if s.opentsdb != nil {
+		if err := s.opentsdb.Start(); err != nil {
+			return fmt.Errorf("failed to start opentsdb listener: %w", err)
+		}
+	}
+
+	if s.mqtt != nil {
+		if err := s.mqtt.Start(); err != nil {
+			return fmt.Errorf("failed to start mqtt subscriber: %w", err)
+		}
+	}
+
+	if s.grpc != nil {
+		if err := s.grpc.Start(); err != nil {
+			return fmt.Errorf("failed to start grpc server: %w", err)
+		}
+	}
+
 	return s.server.ListenAndServe()
 }
 
@@ -62,6 +142,37 @@ func (s *Server) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Stop listeners first so their pending batches reach storage
+	if s.graphite != nil {
+		if err := s.graphite.Close(); err != nil {
+			return fmt.Errorf("failed to close graphite listener: %w", err)
+		}
+	}
+
+	if s.statsd != nil {
+		if err := s.statsd.Close(); err != nil {
+			return fmt.Errorf("failed to close statsd listener: %w", err)
+		}
+	}
+
+	if s.opentsdb != nil {
+		if err := s.opentsdb.Close(); err != nil {
+			return fmt.Errorf("failed to close opentsdb listener: %w", err)
+		}
+	}
+
+	if s.mqtt != nil {
+		if err := s.mqtt.Close(); err != nil {
+			return fmt.Errorf("failed to close mqtt subscriber: %w", err)
+		}
+	}
+
+	if s.grpc != nil {
+		if err := s.grpc.Close(); err != nil {
+			return fmt.Errorf("failed to close grpc server: %w", err)
+		}
+	}
+
 	if err := s.storage.Close(); err != nil {
 		return fmt.Errorf("failed to close storage: %w", err)
 	}
@@ -75,12 +186,126 @@ func (s *Server) setupRoutes() {
 	
 	// Write endpoint
 	s.router.HandleFunc("/write", s.handleWrite).Methods("POST")
+	s.router.HandleFunc("/write/stream", s.handleWriteStream).Methods("POST")
 	
 	// Query endpoint
-	s.router.HandleFunc("/query", s.handleQuery).Methods("POST")
+	s.router.HandleFunc("/query", s.limitQuery(s.handleQuery, false)).Methods("POST")
+	s.router.HandleFunc("/sql", s.limitQuery(s.handleSQL, false)).Methods("POST")
 	
 	// Metrics endpoint
 	s.router.HandleFunc("/metrics", s.handleMetrics).Methods("GET")
+
+	// Series index lookups
+	s.router.HandleFunc("/metrics/names", s.handleMetricNames).Methods("GET")
+	s.router.HandleFunc("/tags/keys", s.handleTagKeys).Methods("GET")
+	s.router.HandleFunc("/tags/values", s.handleTagValues).Methods("GET")
+	s.router.HandleFunc("/series", s.handleSeries).Methods("GET")
+
+	// Latest point per series from the last-value cache
+	s.router.HandleFunc("/last", s.handleLast).Methods("GET")
+
+	// Metric metadata registry
+	s.router.HandleFunc("/metadata", s.handleListMetadata).Methods("GET")
+	s.router.HandleFunc("/metadata/{metric}", s.handleGetMetadata).Methods("GET")
+	s.router.HandleFunc("/metadata/{metric}", s.handlePutMetadata).Methods("PUT")
+	s.router.HandleFunc("/metadata/{metric}", s.handleDeleteMetadata).Methods("DELETE")
+
+	// Admin
+	s.router.HandleFunc("/admin/cardinality", s.handleCardinality).Methods("GET")
+
+	// Prometheus remote read
+	s.router.HandleFunc("/api/v1/read", s.limitQuery(s.handleRemoteRead, false)).Methods("POST")
+
+	// Prometheus-compatible query API (Grafana datasource)
+	s.router.HandleFunc("/api/v1/query", s.limitQuery(s.handlePromQuery, true)).Methods("GET", "POST")
+	s.router.HandleFunc("/api/v1/query_range", s.limitQuery(s.handlePromQueryRange, true)).Methods("GET", "POST")
+	s.router.HandleFunc("/api/v1/labels", s.limitQuery(s.handlePromLabels, true)).Methods("GET", "POST")
+	s.router.HandleFunc("/api/v1/label/{name}/values", s.limitQuery(s.handlePromLabelValues, true)).Methods("GET")
+	s.router.HandleFunc("/api/v1/series", s.limitQuery(s.handlePromSeries, true)).Methods("GET", "POST")
+	s.router.HandleFunc("/api/v1/metadata", s.handlePromMetadata).Methods("GET")
+
+	// OpenTSDB-compatible write
+	s.router.HandleFunc("/api/put", s.handleOpenTSDBPut).Methods("POST")
+
+	// OTLP/HTTP metrics receiver
+	s.router.HandleFunc("/v1/metrics", s.handleOTLPMetrics).Methods("POST")
+
+	// Live tail of written points (SSE or WebSocket)
+	s.router.HandleFunc("/subscribe", s.handleSubscribe).Methods("GET")
+}
+
+// ingestWriter writes batches from protocol listeners and counts them
+// in the server metrics
+type ingestWriter struct {
+	s *Server
+}
+
+// WriteBatch implements the listeners' batch writer interface. Points
+// refused by the series limits are not counted as written.
+func (w ingestWriter) WriteBatch(points []*storage.DataPoint) error {
+	err := w.s.storage.WriteBatch(points)
+	var partial *storage.PartialWriteError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+	written := len(points)
+	if partial != nil {
+		written -= len(partial.Rejected)
+	}
+	w.s.incrementPointsWritten(int64(written))
+	return err
+}
+
+// grpcBackend gives the gRPC API the same storage and counters as HTTP
+type grpcBackend struct {
+	ingestWriter
+}
+
+// Query implements grpcapi.Backend with the HTTP query limits. Points
+// are passed to fn as the iterator reads them, so the budget is only
+// charged for points that match the tags.
+func (b grpcBackend) Query(ctx context.Context, metric string, start, end int64, tags map[string]string, fn func(*storage.DataPoint) error) error {
+	ctx, done, err := b.s.beginQuery(ctx)
+	if err == errQueryQueueFull {
+		return fmt.Errorf("%w: %v", grpcapi.ErrUnavailable, err)
+	}
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	matchers := make([]*storage.Matcher, 0, len(tags))
+	for k, v := range tags {
+		m, err := storage.NewMatcher(storage.MatchEqual, k, v)
+		if err != nil {
+			return err
+		}
+		matchers = append(matchers, m)
+	}
+
+	it, err := b.s.storage.Select(ctx, storage.QuerySpec{
+		Metric:   metric,
+		Matchers: matchers,
+		Start:    start,
+		End:      end,
+		Budget:   queryBudgetFrom(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := fn(it.At()); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	b.s.incrementQueriesServed()
+	return nil
 }
 
 // incrementPointsWritten atomically increments the points written counter
@@ -93,6 +318,12 @@ func (s *Server) incrementQueriesServed() {
 	atomic.AddInt64(&s.queriesServed, 1)
 }
 
+// maxBodySize returns the cap on decoded binary request bodies in bytes,
+// or 0 if there is none
+func (s *Server) maxBodySize() int64 {
+	return int64(s.config.HTTP.MaxBodySizeMB) << 20
+}
+
 // getMetrics returns current metrics (thread-safe via atomic loads)
 func (s *Server) getMetrics() (int64, int64, int64) {
 	points := atomic.LoadInt64(&s.pointsWritten)
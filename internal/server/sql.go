@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/sql"
+)
+
+// maxSQLBodySize caps the body of a /sql request
+const maxSQLBodySize = 1 << 20
+
+// handleSQL runs a SQL statement (POST /sql). The body is either the
+// statement itself or a JSON object {"query": "...", "stats": true}.
+func (s *Server) handleSQL(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	req, err := readSQLRequest(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stmt, err := sql.Parse(req.Query)
+	if err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	trace := queryTraceFrom(r.Context())
+	trace.mark("parse")
+
+	plan, err := sql.NewPlan(stmt, req.Query, time.Now())
+	if err != nil {
+		writeSQLError(w, err)
+		return
+	}
+	if plan.Explain || req.Stats {
+		trace.collect()
+		trace.step(plan.Steps()...)
+	}
+	trace.mark("plan")
+
+	stats := trace.storageStats()
+	result, err := sql.Execute(r.Context(), s.storage, plan, queryBudgetFrom(r.Context()), stats)
+	if err != nil {
+		if errors.Is(err, sql.ErrTooManyWindows) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status, _ := s.queryErrorStatus(err, http.StatusInternalServerError)
+		writeError(w, status, s.queryErrorMessage(err))
+		return
+	}
+	trace.mark("execute")
+
+	s.incrementQueriesServed()
+	switch {
+	case plan.Explain:
+		writeJSON(w, map[string]interface{}{"explain": trace.report()})
+	case req.Stats:
+		writeJSON(w, map[string]interface{}{"series": result.Series, "stats": trace.report()})
+	default:
+		writeJSON(w, result)
+	}
+}
+
+// sqlRequest is a /sql request
+type sqlRequest struct {
+	Query string `json:"query"`
+	Stats bool   `json:"stats"`
+}
+
+// readSQLRequest reads a /sql request. A plain text body is the
+// statement; stats can then be asked for with ?stats=true.
+func readSQLRequest(r *http.Request) (sqlRequest, error) {
+	var req sqlRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSQLBodySize+1))
+	if err != nil {
+		return req, err
+	}
+	if len(body) > maxSQLBodySize {
+		return req, errors.New("query too large")
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return req, errors.New("invalid JSON format")
+		}
+	} else {
+		req.Query = string(body)
+		req.Stats, _ = strconv.ParseBool(r.URL.Query().Get("stats"))
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return req, errors.New("missing query")
+	}
+	return req, nil
+}
+
+// writeSQLError writes a parse error with its position
+func writeSQLError(w http.ResponseWriter, err error) {
+	var perr *sql.ParseError
+	if !errors.As(err, &perr) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":    perr.Error(),
+		"line":     perr.Line,
+		"column":   perr.Column,
+		"position": perr.Pos,
+	})
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/sql"
+)
+
+func doSQLRequest(srv *Server, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/sql", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleSQL(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	writePromTestData(srv)
+
+	query := `SELECT max(value) FROM requests WHERE time >= 0 AND time < 60000 GROUP BY time(30s), host ORDER BY time DESC`
+	for _, tt := range []struct{ contentType, body string }{
+		{"text/plain", query},
+		{"application/json", `{"query": ` + jsonString(query) + `}`},
+	} {
+		w := doSQLRequest(srv, tt.contentType, tt.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+
+		var result sql.Result
+		json.NewDecoder(w.Body).Decode(&result)
+		if len(result.Series) != 2 || result.Series[1].Tags["host"] != "b" {
+			t.Fatalf("unexpected result %+v", result)
+		}
+		// host b: 0,20,40 in [0,30s) and 60,80,100 in [30s,60s), newest first
+		values := result.Series[1].Values
+		if len(values) != 2 || values[0][0] != 30000.0 || values[0][1] != 100.0 || values[1][1] != 40.0 {
+			t.Errorf("unexpected values %v", values)
+		}
+	}
+}
+
+func TestHandleSQLErrors(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	w := doSQLRequest(srv, "", "SELECT value\nFROM requests\nWHERE host = a")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+	var resp map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp["line"] != 3.0 || resp["column"] != 14.0 || resp["position"] != 40.0 {
+		t.Errorf("unexpected error position %v", resp)
+	}
+
+	if w := doSQLRequest(srv, "", "  "); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an empty query, got %d", w.Code)
+	}
+	if w := doSQLRequest(srv, "application/json", `{"query": 1}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for invalid JSON, got %d", w.Code)
+	}
+	w = doSQLRequest(srv, "", `SELECT mean(value) FROM requests WHERE time >= 0 AND time < 1000000000 GROUP BY time(1s)`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "too many") {
+		t.Errorf("expected status 400 for too many windows, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
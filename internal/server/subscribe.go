@@ -0,0 +1,264 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// wsWriteTimeout bounds each WebSocket write so a stalled client cannot
+// hold a subscription open
+const wsWriteTimeout = 10 * time.Second
+
+var upgrader = websocket.Upgrader{
+	// Subscriptions are read-only; any page may open one
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// subscribeEvent is one message sent to a subscriber. Over SSE the type
+// is the event name and the remaining fields are the data.
+type subscribeEvent struct {
+	Type    string             `json:"type,omitempty"`
+	Point   *storage.DataPoint `json:"point,omitempty"`
+	Dropped int64              `json:"dropped,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// handleSubscribe streams points written to a metric as they arrive, as
+// Server-Sent Events or over a WebSocket when the request asks to upgrade
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	cfg := s.config.Subscribe
+
+	metric := q.Get("metric")
+	if metric == "" {
+		writeError(w, http.StatusBadRequest, "missing or invalid metric")
+		return
+	}
+
+	matchers, err := subscribeMatchers(metric, q.Get("tags"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slow := cfg.SlowConsumer
+	if v := q.Get("slow_consumer"); v != "" {
+		slow = v
+	}
+	policy, err := parseSlowConsumer(slow)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bufferSize := cfg.BufferSize
+	if v := q.Get("buffer"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "buffer must be a positive integer")
+			return
+		}
+		if cfg.BufferSize <= 0 || n < cfg.BufferSize {
+			bufferSize = n
+		}
+	}
+
+	if cfg.MaxSubscribers > 0 && s.storage.Subscriptions() >= cfg.MaxSubscribers {
+		writeError(w, http.StatusServiceUnavailable, "too many subscribers")
+		return
+	}
+
+	// Streams outlive the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	opts := storage.SubscribeOptions{
+		Matchers:   matchers,
+		BufferSize: bufferSize,
+		Policy:     policy,
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, opts)
+		return
+	}
+	s.serveSSE(w, r, opts)
+}
+
+// serveSSE writes the subscription as a text/event-stream
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request, opts storage.SubscribeOptions) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+
+	sub := s.storage.Subscribe(opts)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeatInterval())
+	defer heartbeat.Stop()
+
+	var reported int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case point, ok := <-sub.C:
+			if !ok {
+				if err := sub.Err(); err != nil {
+					writeSSE(w, subscribeEvent{Type: "error", Error: err.Error()})
+					flusher.Flush()
+				}
+				return
+			}
+			writeSSE(w, subscribeEvent{Type: "point", Point: point})
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if dropped := sub.Dropped(); dropped > reported {
+			reported = dropped
+			writeSSE(w, subscribeEvent{Type: "dropped", Dropped: dropped})
+		}
+		flusher.Flush()
+	}
+}
+
+// writeSSE writes one event. The type becomes the event name; point
+// events carry the bare data point.
+func writeSSE(w http.ResponseWriter, ev subscribeEvent) {
+	name := ev.Type
+	var data []byte
+	if ev.Point != nil {
+		data, _ = json.Marshal(ev.Point)
+	} else {
+		ev.Type = ""
+		data, _ = json.Marshal(ev)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data)
+}
+
+// serveWebSocket upgrades the connection and sends one JSON text message
+// per event
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, opts storage.SubscribeOptions) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client
+		return
+	}
+	defer conn.Close()
+
+	sub := s.storage.Subscribe(opts)
+	defer sub.Close()
+
+	// Incoming messages are ignored; reading is needed to notice the
+	// client going away and to process control frames
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(s.heartbeatInterval())
+	defer heartbeat.Stop()
+
+	send := func(ev subscribeEvent) error {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(ev)
+	}
+
+	var reported int64
+	for {
+		var err error
+		select {
+		case <-closed:
+			return
+		case point, ok := <-sub.C:
+			if !ok {
+				reason := "subscription closed"
+				if subErr := sub.Err(); subErr != nil {
+					reason = subErr.Error()
+					send(subscribeEvent{Type: "error", Error: reason})
+				}
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, reason),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			err = send(subscribeEvent{Type: "point", Point: point})
+		case <-heartbeat.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+		}
+		if err != nil {
+			return
+		}
+
+		if dropped := sub.Dropped(); dropped > reported {
+			reported = dropped
+			if err := send(subscribeEvent{Type: "dropped", Dropped: dropped}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) heartbeatInterval() time.Duration {
+	if sec := s.config.Subscribe.HeartbeatSeconds; sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	return 15 * time.Second
+}
+
+// subscribeMatchers builds equality matchers from a metric name and a
+// "key=value,key=value" tag list, for /subscribe and /last
+func subscribeMatchers(metric, tags string) ([]*storage.Matcher, error) {
+	m, err := storage.NewMatcher(storage.MatchEqual, storage.MetricNameLabel, metric)
+	if err != nil {
+		return nil, err
+	}
+	matchers := []*storage.Matcher{m}
+
+	if tags == "" {
+		return matchers, nil
+	}
+	for _, pair := range strings.Split(tags, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag filter %q, expected key=value", pair)
+		}
+		m, err := storage.NewMatcher(storage.MatchEqual, key, value)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+func parseSlowConsumer(v string) (storage.SlowConsumerPolicy, error) {
+	switch v {
+	case "", "drop":
+		return storage.DropPoints, nil
+	case "disconnect":
+		return storage.Disconnect, nil
+	default:
+		return 0, fmt.Errorf("slow_consumer must be drop or disconnect")
+	}
+}
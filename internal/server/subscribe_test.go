@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// waitForSubscribers waits until the handler has registered n subscriptions
+func waitForSubscribers(t *testing.T, srv *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.storage.Subscriptions() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d subscribers, got %d", n, srv.storage.Subscriptions())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleSubscribeSSE(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/subscribe?metric=cpu&tags=host=web01")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %s", ct)
+	}
+	waitForSubscribers(t, srv, 1)
+
+	srv.storage.WriteBatch([]*storage.DataPoint{
+		{Metric: "cpu", Timestamp: 1000, Value: 1, Tags: map[string]string{"host": "web02"}},
+		{Metric: "mem", Timestamp: 1000, Value: 2, Tags: map[string]string{"host": "web01"}},
+		{Metric: "cpu", Timestamp: 2000, Value: 3, Tags: map[string]string{"host": "web01"}},
+	})
+
+	reader := bufio.NewReader(resp.Body)
+	var event, data string
+	for event == "" || data == "" {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+
+	if event != "point" {
+		t.Fatalf("expected point event, got %s", event)
+	}
+	var point storage.DataPoint
+	if err := json.Unmarshal([]byte(data), &point); err != nil {
+		t.Fatalf("invalid event data %q: %v", data, err)
+	}
+	if point.Metric != "cpu" || point.Value != 3 || point.Tags["host"] != "web01" {
+		t.Errorf("unexpected point: %+v", point)
+	}
+}
+
+func TestHandleSubscribeWebSocket(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/subscribe?metric=cpu"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	waitForSubscribers(t, srv, 1)
+
+	srv.storage.Write(&storage.DataPoint{Metric: "cpu", Timestamp: 1000, Value: 0.5, Tags: map[string]string{}})
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ev subscribeEvent
+	if err := conn.ReadJSON(&ev); err != nil {
+		t.Fatalf("ReadJSON failed: %v", err)
+	}
+	if ev.Type != "point" || ev.Point == nil || ev.Point.Value != 0.5 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	// Closing the socket ends the subscription
+	conn.Close()
+	waitForSubscribers(t, srv, 0)
+}
+
+func TestHandleSubscribeErrors(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing metric", "", http.StatusBadRequest},
+		{"invalid tags", "metric=cpu&tags=host", http.StatusBadRequest},
+		{"invalid policy", "metric=cpu&slow_consumer=block", http.StatusBadRequest},
+		{"invalid buffer", "metric=cpu&buffer=0", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/subscribe?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			srv.handleSubscribe(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleSubscribeMaxSubscribers(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	srv.config.Subscribe.MaxSubscribers = 1
+
+	sub := srv.storage.Subscribe(storage.SubscribeOptions{})
+	defer sub.Close()
+
+	req := httptest.NewRequest("GET", "/subscribe?metric=cpu", nil)
+	w := httptest.NewRecorder()
+	srv.handleSubscribe(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+}
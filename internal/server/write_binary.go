@@ -0,0 +1,118 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/Pablo997/pulsardb/internal/grpcapi"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// contentTypeProtobuf selects the binary /write format: a WriteRequest
+// message from pulsardb.proto
+const contentTypeProtobuf = "application/x-protobuf"
+
+// isProtobuf reports whether a Content-Type or Accept value names the
+// protobuf format
+func isProtobuf(value string) bool {
+	mediaType, _, _ := mime.ParseMediaType(value)
+	return mediaType == contentTypeProtobuf || mediaType == "application/protobuf"
+}
+
+// handleWriteProtobuf writes a protobuf WriteRequest. The response is a
+// protobuf WriteResponse if the client accepts it, else the /write JSON.
+func (s *Server) handleWriteProtobuf(w http.ResponseWriter, r *http.Request) {
+	max := s.maxBodySize()
+	var body io.Reader = r.Body
+	if max > 0 {
+		body = http.MaxBytesReader(w, r.Body, max)
+	}
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	if max > 0 {
+		// One byte over the cap tells a full body from a truncated one
+		body = io.LimitReader(body, max+1)
+	}
+
+	data, err := io.ReadAll(body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) || (max > 0 && int64(len(data)) > max) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to read request body")
+		return
+	}
+
+	var req grpcapi.WriteRequest
+	if err := req.Unmarshal(data); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid protobuf format: "+err.Error())
+		return
+	}
+
+	resp := &grpcapi.WriteResponse{}
+	points := make([]*storage.DataPoint, 0, len(req.Points))
+	for i := range req.Points {
+		if req.Points[i].Metric == "" {
+			resp.Errors = append(resp.Errors, "missing or invalid metric")
+			continue
+		}
+		points = append(points, req.Points[i].ToStorage())
+	}
+
+	limited := 0
+	if len(points) > 0 {
+		written := len(points)
+		if err := s.storage.WriteBatch(points); err != nil {
+			var partial *storage.PartialWriteError
+			if !errors.As(err, &partial) {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			for _, rej := range partial.Rejected {
+				resp.Errors = append(resp.Errors, rej.Err.Error())
+			}
+			limited = len(partial.Rejected)
+			written -= limited
+		}
+		resp.Written = uint64(written)
+		s.incrementPointsWritten(int64(written))
+	}
+
+	status := http.StatusOK
+	if len(resp.Errors) > 0 {
+		status = http.StatusPartialContent
+	}
+
+	if isProtobuf(r.Header.Get("Accept")) {
+		w.Header().Set("Content-Type", contentTypeProtobuf)
+		w.WriteHeader(status)
+		w.Write(resp.Marshal())
+		return
+	}
+
+	response := map[string]interface{}{
+		"written": resp.Written,
+	}
+	if limited > 0 {
+		response["series_limit_rejected"] = limited
+	}
+	if len(resp.Errors) > 0 {
+		response["errors"] = resp.Errors
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
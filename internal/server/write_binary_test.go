@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/grpcapi"
+)
+
+func TestHandleWriteProtobuf(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	body := (&grpcapi.WriteRequest{Points: []grpcapi.Point{
+		{Metric: "temp", Timestamp: 1000, Value: 21.5, Tags: map[string]string{"device": "mcu1"}},
+		{Timestamp: 2000, Value: 1},
+	}}).Marshal()
+
+	req := httptest.NewRequest("POST", "/write", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	srv.handleWrite(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status 206, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"written":1`) {
+		t.Errorf("expected JSON response, got %s", w.Body.String())
+	}
+
+	points, _ := srv.storage.Query("temp", 0, 5000)
+	if len(points) != 1 || points[0].Tags["device"] != "mcu1" || points[0].Value != 21.5 {
+		t.Errorf("unexpected points: %+v", points)
+	}
+}
+
+func TestHandleWriteProtobufResponse(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write((&grpcapi.WriteRequest{Points: []grpcapi.Point{
+		{Metric: "temp", Timestamp: 1000, Value: 1},
+		{Metric: "temp", Timestamp: 2000, Value: 2},
+	}}).Marshal())
+	gz.Close()
+
+	req := httptest.NewRequest("POST", "/write", &buf)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	srv.handleWrite(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-protobuf" {
+		t.Fatalf("expected protobuf response, got %s", ct)
+	}
+
+	var resp grpcapi.WriteResponse
+	if err := resp.Unmarshal(w.Body.Bytes()); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.Written != 2 || len(resp.Errors) != 0 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleWriteProtobufInvalid(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	req := httptest.NewRequest("POST", "/write", bytes.NewReader([]byte{0x0a, 0x05, 0x01}))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	w := httptest.NewRecorder()
+	srv.handleWrite(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestHandleWriteProtobufTooLarge(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+	srv.config.HTTP.MaxBodySizeMB = 1
+
+	// Compresses to a few KB but decompresses past the cap
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(make([]byte, 2<<20))
+	gz.Close()
+
+	req := httptest.NewRequest("POST", "/write", &buf)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handleWrite(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", w.Code)
+	}
+}
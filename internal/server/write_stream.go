@@ -0,0 +1,196 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+const (
+	// streamBatchSize is the number of points buffered before each
+	// storage write
+	streamBatchSize = 5000
+	// streamMaxLineBytes rejects lines longer than this
+	streamMaxLineBytes = 1 << 20
+	// streamMaxErrors caps the line errors listed in the response
+	streamMaxErrors = 100
+)
+
+// errLineTooLong is reported for lines over streamMaxLineBytes
+var errLineTooLong = fmt.Errorf("line exceeds %d bytes", streamMaxLineBytes)
+
+// lineError reports a rejected NDJSON line
+type lineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// writeStreamResult is the /write/stream response body
+type writeStreamResult struct {
+	Accepted        int         `json:"accepted"`
+	Rejected        int         `json:"rejected"`
+	Errors          []lineError `json:"errors,omitempty"`
+	ErrorsTruncated bool        `json:"errors_truncated,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+func (res *writeStreamResult) reject(line int, err error) {
+	res.Rejected++
+	if len(res.Errors) < streamMaxErrors {
+		res.Errors = append(res.Errors, lineError{Line: line, Error: err.Error()})
+	} else {
+		res.ErrorsTruncated = true
+	}
+}
+
+// handleWriteStream writes newline-delimited JSON points. The body is
+// decoded and written in batches, so memory use does not grow with its
+// size. Each line has the same shape as a /write point.
+func (s *Server) handleWriteStream(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	// Large uploads outlive the server's read and write timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid gzip body")
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	res := &writeStreamResult{}
+	batch := make([]*storage.DataPoint, 0, streamBatchSize)
+	batchLines := make([]int, 0, streamBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		written := len(batch)
+		if err := s.storage.WriteBatch(batch); err != nil {
+			var partial *storage.PartialWriteError
+			if !errors.As(err, &partial) {
+				return err
+			}
+			for _, rej := range partial.Rejected {
+				res.reject(batchLines[rej.Index], rej.Err)
+			}
+			written -= len(partial.Rejected)
+		}
+		res.Accepted += written
+		s.incrementPointsWritten(int64(written))
+		batch, batchLines = batch[:0], batchLines[:0]
+		return nil
+	}
+
+	reader := bufio.NewReaderSize(body, 64<<10)
+	for lineNum := 1; ; lineNum++ {
+		line, err := readLine(reader, streamMaxLineBytes)
+		if err == errLineTooLong {
+			res.reject(lineNum, err)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			// Points decoded before the error are still written
+			if flush() != nil {
+				res.Rejected += len(batch)
+			}
+			res.Error = fmt.Sprintf("failed to read request body at line %d: %v", lineNum, err)
+			writeStreamResponse(w, http.StatusBadRequest, res)
+			return
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if dp, perr := parseStreamLine(line); perr != nil {
+				res.reject(lineNum, perr)
+			} else {
+				batch = append(batch, dp)
+				batchLines = append(batchLines, lineNum)
+			}
+		}
+
+		if len(batch) == streamBatchSize || err == io.EOF {
+			if ferr := flush(); ferr != nil {
+				res.Rejected += len(batch)
+				res.Error = fmt.Sprintf("write failed before line %d: %v", lineNum+1, ferr)
+				writeStreamResponse(w, http.StatusInternalServerError, res)
+				return
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	status := http.StatusOK
+	if res.Rejected > 0 {
+		status = http.StatusPartialContent
+	}
+	writeStreamResponse(w, status, res)
+}
+
+// parseStreamLine decodes one NDJSON line into a point
+func parseStreamLine(line []byte) (*storage.DataPoint, error) {
+	var pointData map[string]interface{}
+	if err := json.Unmarshal(line, &pointData); err != nil {
+		return nil, errors.New("invalid JSON format")
+	}
+	if pointData == nil {
+		return nil, errors.New("expected object")
+	}
+	return pointFromMap(pointData)
+}
+
+// readLine returns the next line without its newline. A line longer
+// than max is skipped and reported as errLineTooLong. The last line is
+// returned together with io.EOF.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > max+1 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case nil:
+			if tooLong {
+				return nil, errLineTooLong
+			}
+			return line[:len(line)-1], nil
+		default:
+			if tooLong {
+				// The next call returns the read error
+				return nil, errLineTooLong
+			}
+			return line, err
+		}
+	}
+}
+
+func writeStreamResponse(w http.ResponseWriter, status int, res *writeStreamResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(res)
+}
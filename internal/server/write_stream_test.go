@@ -0,0 +1,118 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleWriteStream(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	body := strings.Join([]string{
+		`{"metric": "cpu", "timestamp": 1000, "value": 1, "tags": {"host": "a"}}`,
+		``,
+		`{"metric": "cpu", "timestamp": 2000}`,
+		`not json`,
+		`{"metric": "cpu", "timestamp": 3000, "value": 3}`,
+	}, "\n")
+
+	req := httptest.NewRequest("POST", "/write/stream", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	srv.handleWriteStream(w, req)
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status 206, got %d", w.Code)
+	}
+
+	var res writeStreamResult
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if res.Accepted != 2 || res.Rejected != 2 {
+		t.Errorf("expected 2 accepted and 2 rejected, got %+v", res)
+	}
+	want := []lineError{
+		{Line: 3, Error: "missing or invalid value"},
+		{Line: 4, Error: "invalid JSON format"},
+	}
+	if fmt.Sprint(res.Errors) != fmt.Sprint(want) {
+		t.Errorf("expected errors %v, got %v", want, res.Errors)
+	}
+
+	points, _ := srv.storage.Query("cpu", 0, 5000)
+	if len(points) != 2 {
+		t.Errorf("expected 2 points in storage, got %d", len(points))
+	}
+}
+
+func TestHandleWriteStreamGzipBatches(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	const n = streamBatchSize*2 + 10
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(gz, `{"metric": "bulk", "timestamp": %d, "value": %d}`+"\n", i, i)
+	}
+	gz.Close()
+
+	req := httptest.NewRequest("POST", "/write/stream", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handleWriteStream(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), fmt.Sprintf(`"accepted":%d`, n)) {
+		t.Errorf("unexpected response: %s", w.Body.String())
+	}
+	if pointsWritten, _, _ := srv.getMetrics(); pointsWritten != n {
+		t.Errorf("expected points_written=%d, got %d", n, pointsWritten)
+	}
+}
+
+func TestHandleWriteStreamInvalidGzip(t *testing.T) {
+	srv := setupTestServer(t)
+	defer srv.Stop()
+
+	req := httptest.NewRequest("POST", "/write/stream", strings.NewReader("plain"))
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	srv.handleWriteStream(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestReadLine(t *testing.T) {
+	long := strings.Repeat("x", 20)
+	r := bufio.NewReaderSize(strings.NewReader("a\n"+long+"\nbb\ncc"), 16)
+
+	want := []struct {
+		line string
+		err  error
+	}{
+		{"a", nil},
+		{"", errLineTooLong},
+		{"bb", nil},
+		{"cc", io.EOF},
+	}
+	for i, tt := range want {
+		line, err := readLine(r, 10)
+		if string(line) != tt.line || err != tt.err {
+			t.Errorf("line %d: expected %q, %v; got %q, %v", i+1, tt.line, tt.err, line, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package sql
+
+import (
+	"math"
+	"sort"
+)
+
+// zscores returns how many standard deviations each value is from the
+// mean of vals. All scores are 0 when the values do not vary.
+func zscores(vals []float64) []float64 {
+	mean, sd := meanStddev(vals)
+	scores := make([]float64, len(vals))
+	for i, v := range vals {
+		if sd > 0 {
+			scores[i] = (v - mean) / sd
+		}
+	}
+	return scores
+}
+
+// madScores returns the modified z-score of each value, which uses the
+// median and the median absolute deviation (MAD) so that the outliers
+// being looked for do not skew it. 0.6745 scales the MAD to a standard
+// deviation for normally distributed values.
+func madScores(vals []float64) []float64 {
+	med := median(vals)
+	deviations := make([]float64, len(vals))
+	for i, v := range vals {
+		deviations[i] = math.Abs(v - med)
+	}
+	mad := median(deviations)
+
+	scores := make([]float64, len(vals))
+	for i, v := range vals {
+		switch {
+		case mad > 0:
+			scores[i] = 0.6745 * (v - med) / mad
+		case v != med:
+			// Most values are equal, so any other one is an outlier
+			scores[i] = math.Copysign(math.Inf(1), v-med)
+		}
+	}
+	return scores
+}
+
+// seasonalResiduals decomposes vals, a series with a season of period
+// values, into trend, seasonal and residual components, and returns the
+// residuals as z-scores. The trend is a centered moving average over one
+// season and the seasonal component the mean detrended value of each
+// position in the season. Values within half a season of either end have
+// no trend and their score is NaN, as are all scores when there are fewer
+// than two seasons of values.
+func seasonalResiduals(vals []float64, period int) []float64 {
+	n := len(vals)
+	scores := make([]float64, n)
+	for i := range scores {
+		scores[i] = math.NaN()
+	}
+	if period < 2 || n < 2*period {
+		return scores
+	}
+
+	// A centered moving average over an even period averages two windows
+	// offset by one, weighting the ends by half
+	half := period / 2
+	trend := make([]float64, n)
+	for i := half; i < n-half; i++ {
+		var sum float64
+		if period%2 == 1 {
+			for _, v := range vals[i-half : i+half+1] {
+				sum += v
+			}
+		} else {
+			sum = (vals[i-half] + vals[i+half]) / 2
+			for _, v := range vals[i-half+1 : i+half] {
+				sum += v
+			}
+		}
+		trend[i] = sum / float64(period)
+	}
+
+	seasonal := make([]float64, period)
+	counts := make([]int, period)
+	for i := half; i < n-half; i++ {
+		seasonal[i%period] += vals[i] - trend[i]
+		counts[i%period]++
+	}
+	var mean float64
+	for p := range seasonal {
+		seasonal[p] /= float64(counts[p])
+		mean += seasonal[p] / float64(period)
+	}
+
+	residuals := make([]float64, 0, n-2*half)
+	for i := half; i < n-half; i++ {
+		residuals = append(residuals, vals[i]-trend[i]-(seasonal[i%period]-mean))
+	}
+	for i, z := range zscores(residuals) {
+		scores[half+i] = z
+	}
+	return scores
+}
+
+func meanStddev(vals []float64) (float64, float64) {
+	if len(vals) == 0 {
+		return math.NaN(), math.NaN()
+	}
+	var mean, m2 float64
+	for i, v := range vals {
+		delta := v - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (v - mean)
+	}
+	if len(vals) < 2 {
+		return mean, 0
+	}
+	return mean, math.Sqrt(m2 / float64(len(vals)-1))
+}
+
+func median(vals []float64) float64 {
+	if len(vals) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64{}, vals...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
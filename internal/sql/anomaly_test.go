@@ -0,0 +1,120 @@
+package sql
+
+import (
+	"math"
+	"testing"
+)
+
+// seasonalSeries returns seasons of the pattern 0, 10, 0, -10 on a rising
+// trend, with spike added at index spikeAt
+func seasonalSeries(seasons, spikeAt int, spike float64) []float64 {
+	pattern := []float64{0, 10, 0, -10}
+	vals := make([]float64, seasons*len(pattern))
+	for i := range vals {
+		vals[i] = float64(i)*0.5 + pattern[i%len(pattern)]
+	}
+	vals[spikeAt] += spike
+	return vals
+}
+
+// argmaxAbs returns the index of the score with the largest magnitude
+func argmaxAbs(scores []float64) int {
+	best := -1
+	for i, s := range scores {
+		if !math.IsNaN(s) && (best < 0 || math.Abs(s) > math.Abs(scores[best])) {
+			best = i
+		}
+	}
+	return best
+}
+
+func TestZScores(t *testing.T) {
+	vals := make([]float64, 50)
+	for i := range vals {
+		vals[i] = 10 + float64(i%2)
+	}
+	vals[20] = 30
+
+	out := flagged(zscores(vals), 3)
+	for i, flag := range out[1] {
+		if flag != (i == 20) {
+			t.Errorf("value %d flagged %v", i, flag)
+		}
+	}
+
+	for _, z := range zscores([]float64{5, 5, 5}) {
+		if z != 0 {
+			t.Errorf("expected 0 for constant values, got %f", z)
+		}
+	}
+}
+
+func TestMADScores(t *testing.T) {
+	scores := madScores([]float64{10, 11, 10, 11, 10, 50, 11})
+	if math.Abs(scores[5]-0.6745*39) > 1e-9 {
+		t.Errorf("score of 50 = %f, want %f", scores[5], 0.6745*39)
+	}
+	if math.Abs(scores[0]+0.6745) > 1e-9 {
+		t.Errorf("score of 10 = %f, want -0.6745", scores[0])
+	}
+
+	// With a MAD of 0, any value off the median is an outlier
+	scores = madScores([]float64{5, 5, 5, 9})
+	if scores[0] != 0 || !math.IsInf(scores[3], 1) {
+		t.Errorf("unexpected scores %v", scores)
+	}
+}
+
+func TestSeasonalResiduals(t *testing.T) {
+	vals := seasonalSeries(6, 13, 20)
+	scores := seasonalResiduals(vals, 4)
+
+	for _, i := range []int{0, 1, 22, 23} {
+		if !math.IsNaN(scores[i]) {
+			t.Errorf("expected no score within half a season of the ends, got %f at %d", scores[i], i)
+		}
+	}
+	if i := argmaxAbs(scores); i != 13 {
+		t.Errorf("largest residual at %d, want 13 (scores %v)", i, scores)
+	}
+	if scores[13] <= 3 {
+		t.Errorf("expected the spike to score above 3, got %f", scores[13])
+	}
+
+	for _, s := range seasonalResiduals(vals[:7], 4) {
+		if !math.IsNaN(s) {
+			t.Errorf("expected no scores with fewer than two seasons, got %v", s)
+		}
+	}
+}
+
+func TestHoltWintersBands(t *testing.T) {
+	// A linear series is predicted exactly
+	linear := make([]float64, 8)
+	for i := range linear {
+		linear[i] = float64(i)
+	}
+	out := holtWintersBands(linear, 2, 3)
+	for i := range linear {
+		if i < 2 {
+			if out[0][i] != nil || out[3][i] != nil {
+				t.Errorf("expected no prediction in the first season, got %v at %d", out[0][i], i)
+			}
+			continue
+		}
+		if p := out[0][i].(float64); math.Abs(p-linear[i]) > 1e-9 || out[3][i] != false {
+			t.Errorf("row %d: predicted %v, flagged %v", i, p, out[3][i])
+		}
+	}
+
+	vals := seasonalSeries(8, 21, 25)
+	out = holtWintersBands(vals, 4, 3)
+	for i, flag := range out[3] {
+		if i >= 4 && flag != (i == 21) {
+			t.Errorf("value %d = %f flagged %v, band [%v, %v]", i, vals[i], flag, out[1][i], out[2][i])
+		}
+	}
+	if lo, hi := out[1][21].(float64), out[2][21].(float64); !(lo < out[0][21].(float64) && out[0][21].(float64) < hi) {
+		t.Errorf("prediction outside its band: %v [%v, %v]", out[0][21], lo, hi)
+	}
+}
@@ -0,0 +1,262 @@
+// Package sql implements PulsarDB's SQL dialect: a lexer, a parser, a
+// planner that pushes tag conditions down to the series index, and an
+// executor over the storage engine.
+//
+//	SELECT mean(value) FROM temperature
+//	WHERE sensor = 'a' AND time > now() - 1h
+//	GROUP BY time(5m), host FILL(previous)
+//	ORDER BY time DESC LIMIT 100
+//
+// A statement prefixed with EXPLAIN is run for its plan and statistics
+// instead of its result.
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statement is a parsed SELECT
+type Statement struct {
+	Explain  bool // EXPLAIN SELECT ...
+	Fields   []*Field
+	Metric   string
+	Where    Expr // nil without WHERE
+	Interval time.Duration
+	GroupBy  []string // tag keys
+	Fill     Fill
+	Desc     bool
+	Limit    int // 0 means no limit
+	Offset   int
+}
+
+// Field is a selected column: value, a tag, * or an aggregate of value,
+// optionally wrapped in a transformation
+type Field struct {
+	Func      string // aggregate function, "" for a raw column
+	Name      string // value, a tag key or *
+	Alias     string
+	Transform *Transform // nil if none
+	Pos       int
+}
+
+// Column returns the name of the field's column in the result
+func (f *Field) Column() string {
+	switch {
+	case f.Alias != "":
+		return f.Alias
+	case f.Transform != nil:
+		return f.Transform.Func
+	case f.Func != "":
+		return f.Func
+	}
+	return f.Name
+}
+
+// Transform is a function over the rows of a series, applied after
+// aggregation and FILL, such as moving_average(mean(value), 3). Anomaly
+// detectors such as zscore add flag and band columns, and forecasts add
+// bounds and future rows.
+type Transform struct {
+	Func      string
+	N         int           // rows of moving_average(field, n), or rows in a season
+	Period    time.Duration // window of moving_average(field, 5m), unit of elapsed or season as given
+	Alpha     float64       // smoothing factor of ewma
+	Threshold float64       // of detectors; 0 for the default
+	Horizon   time.Duration // of forecasts
+	Step      time.Duration // between forecast rows: the GROUP BY time() interval, or 0
+}
+
+// FillMode chooses the value of GROUP BY time() windows without points
+type FillMode int
+
+const (
+	FillNull FillMode = iota
+	FillNone
+	FillPrevious
+	FillLinear
+	FillValue
+)
+
+// Fill is a FILL() clause
+type Fill struct {
+	Mode  FillMode
+	Value float64 // for FillValue
+}
+
+func (f Fill) String() string {
+	switch f.Mode {
+	case FillNone:
+		return "none"
+	case FillPrevious:
+		return "previous"
+	case FillLinear:
+		return "linear"
+	case FillValue:
+		return formatFloat(f.Value)
+	}
+	return "null"
+}
+
+// Expr is a WHERE condition
+type Expr interface {
+	String() string
+}
+
+// BinaryExpr combines two conditions with AND or OR
+type BinaryExpr struct {
+	Op  string // AND or OR
+	LHS Expr
+	RHS Expr
+}
+
+// Comparison compares time, value or a tag to a literal
+type Comparison struct {
+	Name  string // time, value or a tag key
+	Op    string // = != < <= > >= =~ !~
+	Value Literal
+	Pos   int
+}
+
+// Literal is the right-hand side of a comparison
+type Literal interface {
+	String() string
+}
+
+// StringLiteral is a quoted string such as 'a'
+type StringLiteral struct {
+	Val string
+}
+
+// NumberLiteral is a number. For time it is milliseconds since the epoch.
+type NumberLiteral struct {
+	Val float64
+}
+
+// RegexLiteral is a regular expression such as /^a.*/
+type RegexLiteral struct {
+	Val string
+}
+
+// NowLiteral is now() plus an optional offset, resolved when planning
+type NowLiteral struct {
+	Offset time.Duration
+}
+
+func (e *BinaryExpr) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.LHS, e.Op, e.RHS)
+}
+
+func (e *Comparison) String() string {
+	return fmt.Sprintf("%s %s %s", quoteIdent(e.Name), e.Op, e.Value)
+}
+
+func (l *StringLiteral) String() string {
+	return "'" + strings.ReplaceAll(l.Val, "'", `\'`) + "'"
+}
+
+func (l *NumberLiteral) String() string {
+	return formatFloat(l.Val)
+}
+
+func (l *RegexLiteral) String() string {
+	return "/" + strings.ReplaceAll(l.Val, "/", `\/`) + "/"
+}
+
+func (l *NowLiteral) String() string {
+	switch {
+	case l.Offset > 0:
+		return "now() + " + formatDuration(l.Offset)
+	case l.Offset < 0:
+		return "now() - " + formatDuration(-l.Offset)
+	}
+	return "now()"
+}
+
+func (s *Statement) String() string {
+	var sb strings.Builder
+	if s.Explain {
+		sb.WriteString("EXPLAIN ")
+	}
+	sb.WriteString("SELECT ")
+	for i, f := range s.Fields {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(f.String())
+	}
+	sb.WriteString(" FROM " + quoteIdent(s.Metric))
+	if s.Where != nil {
+		sb.WriteString(" WHERE " + s.Where.String())
+	}
+
+	var groups []string
+	if s.Interval > 0 {
+		groups = append(groups, "time("+formatDuration(s.Interval)+")")
+	}
+	for _, g := range s.GroupBy {
+		groups = append(groups, quoteIdent(g))
+	}
+	if len(groups) > 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(groups, ", "))
+	}
+
+	if s.Fill.Mode != FillNull {
+		sb.WriteString(" FILL(" + s.Fill.String() + ")")
+	}
+
+	if s.Desc {
+		sb.WriteString(" ORDER BY time DESC")
+	}
+	if s.Limit > 0 {
+		fmt.Fprintf(&sb, " LIMIT %d", s.Limit)
+	}
+	if s.Offset > 0 {
+		fmt.Fprintf(&sb, " OFFSET %d", s.Offset)
+	}
+	return sb.String()
+}
+
+func (f *Field) String() string {
+	s := quoteIdent(f.Name)
+	if f.Name == "*" {
+		s = "*"
+	}
+	if f.Func != "" {
+		s = f.Func + "(" + s + ")"
+	}
+	if t := f.Transform; t != nil {
+		switch {
+		case t.Func == "ewma":
+			s += ", " + formatFloat(t.Alpha)
+		case t.Period > 0:
+			s += ", " + formatDuration(t.Period)
+		case t.N > 0:
+			s += fmt.Sprintf(", %d", t.N)
+		}
+		if t.Threshold > 0 {
+			s += ", " + formatFloat(t.Threshold)
+		}
+		if t.Horizon > 0 {
+			s += ", " + formatDuration(t.Horizon)
+		}
+		s = t.Func + "(" + s + ")"
+	}
+	if f.Alias != "" {
+		s += " AS " + quoteIdent(f.Alias)
+	}
+	return s
+}
+
+// quoteIdent quotes a name unless it lexes back as the same identifier
+func quoteIdent(name string) string {
+	plain := name != "" && (isAlpha(name[0]) || name[0] == '_') && !keywords[strings.ToUpper(name)]
+	for i := 0; i < len(name) && plain; i++ {
+		plain = isIdentChar(name[i])
+	}
+	if plain {
+		return name
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+}
@@ -0,0 +1,445 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// MaxWindows caps the GROUP BY time() windows of a series, so a tiny
+// interval over a wide range cannot exhaust memory
+const MaxWindows = 100000
+
+// ErrTooManyWindows is returned when GROUP BY time() exceeds MaxWindows
+var ErrTooManyWindows = errors.New("too many GROUP BY time() windows")
+
+// Source is what statements are executed against, normally the engine
+type Source interface {
+	Select(ctx context.Context, spec storage.QuerySpec) (storage.SeriesIterator, error)
+}
+
+// Result is the output of a statement: one series per GROUP BY group
+type Result struct {
+	Series []*Series `json:"series"`
+}
+
+// Series is a table of rows sharing the same GROUP BY tag values. The
+// first column is always time, in milliseconds.
+type Series struct {
+	Name    string            `json:"name"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	Columns []string          `json:"columns"`
+	Values  [][]interface{}   `json:"values"`
+}
+
+// group collects the points or aggregates of one GROUP BY group
+type group struct {
+	tags    map[string]string
+	points  []storage.DataPoint    // raw queries
+	windows map[int64]*accumulator // aggregate queries, by window start
+}
+
+// Execute runs plan against src. budget and stats may be nil.
+func Execute(ctx context.Context, src Source, plan *Plan, budget *storage.QueryBudget, stats *storage.QueryStats) (*Result, error) {
+	it, err := src.Select(ctx, storage.QuerySpec{
+		Metric:   plan.Metric,
+		Matchers: plan.Matchers,
+		Start:    plan.Start,
+		End:      plan.End,
+		Budget:   budget,
+		Stats:    stats,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	groups := make(map[string]*group)
+	for it.Next() {
+		point := it.At()
+		if !plan.match(point) {
+			continue
+		}
+
+		key, tags := plan.groupKey(point)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{tags: tags, windows: make(map[int64]*accumulator)}
+			groups[key] = g
+		}
+
+		if !plan.Aggregate() {
+			g.points = append(g.points, *point)
+			continue
+		}
+		window := plan.window(point.Timestamp)
+		acc, ok := g.windows[window]
+		if !ok {
+			acc = &accumulator{}
+			g.windows[window] = acc
+		}
+		acc.add(point)
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lo, hi, err := plan.windowRange(groups)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Series: []*Series{}}
+	for _, key := range keys {
+		g := groups[key]
+		series := &Series{Name: plan.Metric}
+		if len(plan.GroupBy) > 0 {
+			series.Tags = g.tags
+		}
+		if plan.Aggregate() {
+			plan.aggregateRows(series, g, lo, hi)
+		} else {
+			plan.rawRows(series, g)
+		}
+		plan.page(series)
+		result.Series = append(result.Series, series)
+	}
+	return result, nil
+}
+
+// groupKey returns the key and tags of the GROUP BY group of a point
+func (p *Plan) groupKey(point *storage.DataPoint) (string, map[string]string) {
+	if len(p.GroupBy) == 0 {
+		return "", nil
+	}
+	tags := make(map[string]string, len(p.GroupBy))
+	values := make([]string, len(p.GroupBy))
+	for i, k := range p.GroupBy {
+		tags[k] = point.Tags[k]
+		values[i] = point.Tags[k]
+	}
+	return strings.Join(values, "\xff"), tags
+}
+
+// window returns the start of the GROUP BY time() window containing ts.
+// Without GROUP BY time() all points share one window.
+func (p *Plan) window(ts int64) int64 {
+	if p.Interval == 0 {
+		return 0
+	}
+	w := ts / p.Interval * p.Interval
+	if ts < 0 && ts%p.Interval != 0 {
+		w -= p.Interval
+	}
+	return w
+}
+
+// windowRange returns the first and last window to output. Bounds of the
+// time range are used when given, the data's extent otherwise.
+func (p *Plan) windowRange(groups map[string]*group) (int64, int64, error) {
+	if p.Interval == 0 {
+		return 0, 0, nil
+	}
+
+	lo, hi := int64(math.MaxInt64), int64(math.MinInt64)
+	for _, g := range groups {
+		for w := range g.windows {
+			lo, hi = min64(lo, w), max64(hi, w)
+		}
+	}
+	if p.Start != math.MinInt64 {
+		lo = p.window(p.Start)
+	}
+	if p.End != math.MaxInt64 {
+		hi = p.window(p.End)
+	}
+	if lo > hi {
+		return 0, -1, nil
+	}
+	if n := uint64(hi-lo)/uint64(p.Interval) + 1; n > MaxWindows {
+		return 0, 0, fmt.Errorf("%w: time(%s) gives %d per series, more than %d; narrow the time range or widen the interval",
+			ErrTooManyWindows, formatDuration(time.Duration(p.Interval)*time.Millisecond), n, MaxWindows)
+	}
+	return lo, hi, nil
+}
+
+// rawRows outputs the points of a group, one row each
+func (p *Plan) rawRows(series *Series, g *group) {
+	type column struct {
+		name      string
+		tag       string // "" for value
+		transform *Transform
+	}
+	columns := []column{}
+	for _, f := range p.Fields {
+		switch f.Name {
+		case "time":
+		case "value":
+			columns = append(columns, column{name: f.Column(), transform: f.Transform})
+		case "*":
+			columns = append(columns, column{name: "value"})
+			for _, k := range tagKeys(g.points) {
+				columns = append(columns, column{name: k, tag: k})
+			}
+		default:
+			columns = append(columns, column{name: f.Column(), tag: f.Name})
+		}
+	}
+
+	series.Columns = []string{"time"}
+	for _, c := range columns {
+		series.Columns = append(series.Columns, c.name)
+	}
+	series.Values = make([][]interface{}, 0, len(g.points))
+	for i := range g.points {
+		point := &g.points[i]
+		row := []interface{}{point.Timestamp}
+		for _, c := range columns {
+			if c.tag == "" {
+				row = append(row, number(point.Value))
+			} else if v, ok := point.Tags[c.tag]; ok {
+				row = append(row, v)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		series.Values = append(series.Values, row)
+	}
+
+	// Right to left, since a transformation may add columns
+	for i := len(columns) - 1; i >= 0; i-- {
+		if t := columns[i].transform; t != nil {
+			t.apply(series, i+1)
+		}
+	}
+}
+
+// tagKeys returns the sorted tag keys found on points
+func tagKeys(points []storage.DataPoint) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, point := range points {
+		for k := range point.Tags {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// aggregateRows outputs one row per window of a group, filling empty
+// windows according to FILL()
+func (p *Plan) aggregateRows(series *Series, g *group, lo, hi int64) {
+	series.Columns = []string{"time"}
+	for _, f := range p.Fields {
+		series.Columns = append(series.Columns, f.Column())
+	}
+	series.Values = [][]interface{}{}
+
+	if p.Interval == 0 {
+		ts := int64(0)
+		if p.Start != math.MinInt64 {
+			ts = p.Start
+		}
+		series.Values = append(series.Values, p.aggregateRow(ts, g.windows[0]))
+		p.transform(series)
+		return
+	}
+
+	var empty []int // rows to interpolate for FILL(linear)
+	var prev []interface{}
+	for w := lo; w <= hi; w += p.Interval {
+		acc, ok := g.windows[w]
+		if ok {
+			row := p.aggregateRow(w, acc)
+			series.Values = append(series.Values, row)
+			prev = row
+		} else {
+			switch p.Fill.Mode {
+			case FillNone:
+			case FillPrevious:
+				row := make([]interface{}, len(series.Columns))
+				row[0] = w
+				if prev != nil {
+					copy(row[1:], prev[1:])
+				}
+				series.Values = append(series.Values, row)
+			case FillValue:
+				row := []interface{}{w}
+				for range p.Fields {
+					row = append(row, number(p.Fill.Value))
+				}
+				series.Values = append(series.Values, row)
+			default: // FillNull, FillLinear
+				row := make([]interface{}, len(series.Columns))
+				row[0] = w
+				if p.Fill.Mode == FillLinear {
+					empty = append(empty, len(series.Values))
+				}
+				series.Values = append(series.Values, row)
+			}
+		}
+		if w > math.MaxInt64-p.Interval {
+			break
+		}
+	}
+
+	if len(empty) > 0 {
+		interpolate(series.Values, empty)
+	}
+	p.transform(series)
+}
+
+// transform applies the fields' transformations to the rows of a series,
+// right to left since a transformation may add columns
+func (p *Plan) transform(series *Series) {
+	for i := len(p.Fields) - 1; i >= 0; i-- {
+		if t := p.Fields[i].Transform; t != nil {
+			t.apply(series, i+1)
+		}
+	}
+}
+
+func (p *Plan) aggregateRow(ts int64, acc *accumulator) []interface{} {
+	row := []interface{}{ts}
+	for _, f := range p.Fields {
+		row = append(row, number(acc.result(f.Func)))
+	}
+	return row
+}
+
+// interpolate fills the given rows, per column, on the line between the
+// nearest rows before and after them that have a value
+func interpolate(rows [][]interface{}, empty []int) {
+	for _, i := range empty {
+		for col := 1; col < len(rows[i]); col++ {
+			before, after := -1, -1
+			for j := i - 1; j >= 0; j-- {
+				if rows[j][col] != nil {
+					before = j
+					break
+				}
+			}
+			for j := i + 1; j < len(rows); j++ {
+				if rows[j][col] != nil {
+					after = j
+					break
+				}
+			}
+			if before < 0 || after < 0 {
+				continue
+			}
+			t0, t1, t := rows[before][0].(int64), rows[after][0].(int64), rows[i][0].(int64)
+			v0, v1 := rows[before][col].(float64), rows[after][col].(float64)
+			rows[i][col] = v0 + (v1-v0)*float64(t-t0)/float64(t1-t0)
+		}
+	}
+}
+
+// page applies ORDER BY, OFFSET and LIMIT to a series
+func (p *Plan) page(series *Series) {
+	rows := series.Values
+	if p.Desc {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if p.Offset >= len(rows) {
+		rows = rows[:0]
+	} else {
+		rows = rows[p.Offset:]
+	}
+	if p.Limit > 0 && p.Limit < len(rows) {
+		rows = rows[:p.Limit]
+	}
+	series.Values = rows
+}
+
+// number converts a float for JSON, which has no NaN or infinities
+func number(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
+
+// accumulator computes every aggregate of a window in one pass
+type accumulator struct {
+	count     int
+	sum       float64
+	min, max  float64
+	first     float64
+	firstTime int64
+	last      float64
+	lastTime  int64
+	mean, m2  float64 // Welford's running mean and sum of squares
+}
+
+func (a *accumulator) add(point *storage.DataPoint) {
+	v := point.Value
+	if a.count == 0 {
+		a.min, a.max = v, v
+		a.first, a.firstTime = v, point.Timestamp
+		a.last, a.lastTime = v, point.Timestamp
+	}
+	a.count++
+	a.sum += v
+	a.min = math.Min(a.min, v)
+	a.max = math.Max(a.max, v)
+	if point.Timestamp < a.firstTime {
+		a.first, a.firstTime = v, point.Timestamp
+	}
+	if point.Timestamp >= a.lastTime {
+		a.last, a.lastTime = v, point.Timestamp
+	}
+	delta := v - a.mean
+	a.mean += delta / float64(a.count)
+	a.m2 += delta * (v - a.mean)
+}
+
+// result returns the aggregate fn, NaN when it is undefined
+func (a *accumulator) result(fn string) float64 {
+	if fn == "count" {
+		return float64(a.count)
+	}
+	if a.count == 0 {
+		return math.NaN()
+	}
+	switch fn {
+	case "sum":
+		return a.sum
+	case "mean":
+		return a.sum / float64(a.count)
+	case "min":
+		return a.min
+	case "max":
+		return a.max
+	case "first":
+		return a.first
+	case "last":
+		return a.last
+	case "spread":
+		return a.max - a.min
+	case "stddev":
+		if a.count < 2 {
+			return math.NaN()
+		}
+		return math.Sqrt(a.m2 / float64(a.count-1))
+	}
+	return math.NaN()
+}
@@ -0,0 +1,265 @@
+package sql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// newTestEngine returns an engine holding temperature points of sensors
+// a and b every minute from t=0 to t=5m. Sensor a reads 0..5, b 10..15.
+func newTestEngine(t *testing.T) *storage.Engine {
+	t.Helper()
+
+	engine, err := storage.NewEngine(&config.StorageConfig{DataDir: t.TempDir(), MaxMemoryMB: 128})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+
+	for i := 0; i < 6; i++ {
+		for sensor, base := range map[string]float64{"a": 0, "b": 10} {
+			err := engine.Write(&storage.DataPoint{
+				Metric:    "temperature",
+				Timestamp: int64(i) * 60_000,
+				Value:     base + float64(i),
+				Tags:      map[string]string{"sensor": sensor, "room": "r" + sensor},
+			})
+			if err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+	}
+	return engine
+}
+
+func mustExecute(t *testing.T, engine *storage.Engine, query string) *Result {
+	t.Helper()
+
+	plan := mustPlan(t, query, time.UnixMilli(360_000))
+	result, err := Execute(context.Background(), engine, plan, nil, nil)
+	if err != nil {
+		t.Fatalf("Execute(%q) failed: %v", query, err)
+	}
+	return result
+}
+
+func TestExecuteRaw(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result := mustExecute(t, engine, `SELECT value, room FROM temperature WHERE sensor = 'a' AND time >= 60000 AND value < 4 ORDER BY time DESC LIMIT 2`)
+	if len(result.Series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(result.Series))
+	}
+	series := result.Series[0]
+	if !reflect.DeepEqual(series.Columns, []string{"time", "value", "room"}) {
+		t.Errorf("unexpected columns %v", series.Columns)
+	}
+	want := [][]interface{}{{int64(180_000), 3.0, "ra"}, {int64(120_000), 2.0, "ra"}}
+	if !reflect.DeepEqual(series.Values, want) {
+		t.Errorf("values = %v, want %v", series.Values, want)
+	}
+
+	result = mustExecute(t, engine, `SELECT * FROM temperature WHERE sensor = 'b' LIMIT 1`)
+	if cols := result.Series[0].Columns; !reflect.DeepEqual(cols, []string{"time", "value", "room", "sensor"}) {
+		t.Errorf("unexpected * columns %v", cols)
+	}
+}
+
+func TestExecuteAggregate(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result := mustExecute(t, engine, `SELECT count(value), mean(value), spread(value) AS range FROM temperature GROUP BY sensor`)
+	if len(result.Series) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(result.Series))
+	}
+	b := result.Series[1]
+	if b.Tags["sensor"] != "b" || !reflect.DeepEqual(b.Columns, []string{"time", "count", "mean", "range"}) {
+		t.Errorf("unexpected series %+v", b)
+	}
+	if want := []interface{}{int64(0), 6.0, 12.5, 5.0}; !reflect.DeepEqual(b.Values[0], want) {
+		t.Errorf("row = %v, want %v", b.Values[0], want)
+	}
+}
+
+func TestExecuteGroupByTime(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result := mustExecute(t, engine, `SELECT mean(value) FROM temperature WHERE sensor = 'a' AND time >= now() - 6m AND time <= now() GROUP BY time(2m)`)
+	want := [][]interface{}{
+		{int64(0), 0.5},
+		{int64(120_000), 2.5},
+		{int64(240_000), 4.5},
+		{int64(360_000), nil}, // empty window up to now()
+	}
+	if got := result.Series[0].Values; !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteFill(t *testing.T) {
+	engine := newTestEngine(t)
+
+	// Windows of 1m from 0 to 5m; drop the points at 2m and 3m
+	base := `SELECT max(value) FROM temperature WHERE sensor = 'a' AND (value < 2 OR value > 3) AND time >= 0 AND time < 360000 GROUP BY time(1m) `
+	tests := []struct {
+		fill string
+		want []interface{}
+	}{
+		{"", []interface{}{0.0, 1.0, nil, nil, 4.0, 5.0}},
+		{"FILL(none)", []interface{}{0.0, 1.0, 4.0, 5.0}},
+		{"FILL(previous)", []interface{}{0.0, 1.0, 1.0, 1.0, 4.0, 5.0}},
+		{"FILL(linear)", []interface{}{0.0, 1.0, 2.0, 3.0, 4.0, 5.0}},
+		{"FILL(-1)", []interface{}{0.0, 1.0, -1.0, -1.0, 4.0, 5.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fill, func(t *testing.T) {
+			result := mustExecute(t, engine, base+tt.fill)
+			var got []interface{}
+			for _, row := range result.Series[0].Values {
+				got = append(got, row[1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("values = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteTransform(t *testing.T) {
+	engine := newTestEngine(t)
+
+	column := func(result *Result, col int) []interface{} {
+		var got []interface{}
+		for _, row := range result.Series[0].Values {
+			got = append(got, row[col])
+		}
+		return got
+	}
+
+	result := mustExecute(t, engine, `SELECT value, moving_average(value, 3), cumulative_sum(value), difference(value), elapsed(value, 1m) FROM temperature WHERE sensor = 'a'`)
+	if cols := result.Series[0].Columns; !reflect.DeepEqual(cols, []string{"time", "value", "moving_average", "cumulative_sum", "difference", "elapsed"}) {
+		t.Errorf("unexpected columns %v", cols)
+	}
+	tests := []struct {
+		col  int
+		want []interface{}
+	}{
+		{2, []interface{}{nil, nil, 1.0, 2.0, 3.0, 4.0}},
+		{3, []interface{}{0.0, 1.0, 3.0, 6.0, 10.0, 15.0}},
+		{4, []interface{}{nil, 1.0, 1.0, 1.0, 1.0, 1.0}},
+		{5, []interface{}{nil, 1.0, 1.0, 1.0, 1.0, 1.0}},
+	}
+	for _, tt := range tests {
+		if got := column(result, tt.col); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("column %d = %v, want %v", tt.col, got, tt.want)
+		}
+	}
+
+	result = mustExecute(t, engine, `SELECT moving_average(value, 2m), ewma(value, 0.5) FROM temperature WHERE sensor = 'a' LIMIT 4`)
+	if got, want := column(result, 1), []interface{}{0.0, 0.5, 1.5, 2.5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("moving_average(value, 2m) = %v, want %v", got, want)
+	}
+	if got, want := column(result, 2), []interface{}{0.0, 0.5, 1.25, 2.125}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ewma = %v, want %v", got, want)
+	}
+
+	// Transformations run on the filled windows and skip empty ones
+	result = mustExecute(t, engine, `SELECT difference(max(value)) FROM temperature WHERE sensor = 'a' AND (value < 2 OR value > 3) AND time >= 0 AND time < 360000 GROUP BY time(1m)`)
+	if got, want := column(result, 1), []interface{}{nil, 1.0, nil, nil, 3.0, 1.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("difference(max(value)) = %v, want %v", got, want)
+	}
+
+	// ... and before ORDER BY and LIMIT
+	result = mustExecute(t, engine, `SELECT cumulative_sum(mean(value)) FROM temperature GROUP BY time(1m), sensor ORDER BY time DESC LIMIT 2`)
+	if got, want := column(result, 1), []interface{}{15.0, 10.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("cumulative_sum(mean(value)) = %v, want %v", got, want)
+	}
+}
+
+func TestExecuteDetectors(t *testing.T) {
+	engine := newTestEngine(t)
+
+	result := mustExecute(t, engine, `SELECT value, zscore(value), holt_winters_bands(value, 2, 2) AS hw FROM temperature WHERE sensor = 'a' ORDER BY time DESC LIMIT 1`)
+	series := result.Series[0]
+	want := []string{"time", "value", "zscore", "zscore_anomaly", "hw", "hw_lower", "hw_upper", "hw_anomaly"}
+	if !reflect.DeepEqual(series.Columns, want) {
+		t.Fatalf("columns = %v, want %v", series.Columns, want)
+	}
+
+	// Values 0..5: mean 2.5, standard deviation sqrt(3.5). The series is
+	// linear, so Holt-Winters predicts it exactly.
+	row := series.Values[0]
+	if z := row[2].(float64); math.Abs(z-2.5/math.Sqrt(3.5)) > 1e-9 || row[3] != false {
+		t.Errorf("unexpected zscore %v, %v", row[2], row[3])
+	}
+	if !reflect.DeepEqual(row[4:], []interface{}{5.0, 5.0, 5.0, false}) {
+		t.Errorf("unexpected bands %v", row[4:])
+	}
+}
+
+func TestExecuteForecast(t *testing.T) {
+	engine := newTestEngine(t)
+
+	// Sensor a reads 0..5 at 0..5m, a line: the forecast continues it with
+	// no uncertainty
+	result := mustExecute(t, engine, `SELECT mean(value), predict_linear(mean(value), 3m) FROM temperature WHERE sensor = 'a' GROUP BY time(1m)`)
+	series := result.Series[0]
+	if want := []string{"time", "mean", "predict_linear", "predict_linear_lower", "predict_linear_upper"}; !reflect.DeepEqual(series.Columns, want) {
+		t.Fatalf("columns = %v, want %v", series.Columns, want)
+	}
+	if len(series.Values) != 9 {
+		t.Fatalf("expected 6 rows and 3 forecast rows, got %v", series.Values)
+	}
+	for i, row := range series.Values {
+		if row[0] != int64(i)*60_000 || math.Abs(row[2].(float64)-float64(i)) > 1e-9 || row[3] != row[2] || row[4] != row[2] {
+			t.Errorf("row %d = %v", i, row)
+		}
+		if i >= 6 && row[1] != nil {
+			t.Errorf("expected no mean in forecast row %v", row)
+		}
+	}
+
+	// Forecasts use the empty windows FILL left up to the end of the range
+	result = mustExecute(t, engine, `SELECT predict_linear(mean(value), 2m) FROM temperature WHERE sensor = 'a' AND time <= 480000 GROUP BY time(1m)`)
+	var got []interface{}
+	for _, row := range result.Series[0].Values {
+		got = append(got, row[1])
+	}
+	if want := []interface{}{0.0, 1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("values = %v, want %v", got, want)
+	}
+
+	// Without GROUP BY time() there is one row at the horizon
+	result = mustExecute(t, engine, `SELECT predict_linear(value, 1h) FROM temperature WHERE sensor = 'b' ORDER BY time DESC LIMIT 1`)
+	if row := result.Series[0].Values[0]; row[0] != int64(3_900_000) || math.Abs(row[1].(float64)-75) > 1e-9 {
+		t.Errorf("unexpected forecast row %v", row)
+	}
+}
+
+func TestExecuteTooManyWindows(t *testing.T) {
+	engine := newTestEngine(t)
+
+	plan := mustPlan(t, `SELECT mean(value) FROM temperature WHERE time >= 0 AND time < 1000000000 GROUP BY time(1s)`, time.Now())
+	_, err := Execute(context.Background(), engine, plan, nil, nil)
+	if !errors.Is(err, ErrTooManyWindows) {
+		t.Errorf("expected ErrTooManyWindows, got %v", err)
+	}
+}
+
+func TestExecuteBudget(t *testing.T) {
+	engine := newTestEngine(t)
+
+	plan := mustPlan(t, `SELECT value FROM temperature`, time.Now())
+	_, err := Execute(context.Background(), engine, plan, storage.NewQueryBudget(3, 0), nil)
+	if !errors.Is(err, storage.ErrBudgetExceeded) {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package sql
+
+import (
+	"math"
+	"time"
+)
+
+// z95 is the normal quantile of 95% prediction intervals
+const z95 = 1.96
+
+// futureRow is a row a forecast adds after the last value of a series
+type futureRow struct {
+	time  int64
+	cells []interface{}
+}
+
+// forecast returns, for each value, its fitted prediction and bounds,
+// and the rows projected up to the horizon after the last value: one
+// every Step, or a single row at the horizon without GROUP BY time()
+func (t *Transform) forecast(times []int64, vals []float64) ([][]interface{}, []futureRow) {
+	outs := [][]interface{}{
+		make([]interface{}, len(vals)),
+		make([]interface{}, len(vals)),
+		make([]interface{}, len(vals)),
+	}
+	cells := func(pred, bound float64) []interface{} {
+		return []interface{}{number(pred), number(pred - bound), number(pred + bound)}
+	}
+	if len(vals) == 0 {
+		return outs, nil
+	}
+
+	last := times[len(times)-1]
+	var future []int64
+	if t.Step > 0 {
+		for h := int64(1); h <= int64(t.Horizon/t.Step); h++ {
+			future = append(future, last+h*t.Step.Milliseconds())
+		}
+	} else {
+		future = append(future, last+t.Horizon.Milliseconds())
+	}
+
+	var rows []futureRow
+	switch t.Func {
+	case "predict_linear":
+		fit, ok := fitLinear(times, vals)
+		if !ok {
+			return outs, nil
+		}
+		for i, ts := range times {
+			pred, bound := fit.predict(ts)
+			for k, c := range cells(pred, bound) {
+				outs[k][i] = c
+			}
+		}
+		for _, ts := range future {
+			rows = append(rows, futureRow{time: ts, cells: cells(fit.predict(ts))})
+		}
+
+	case "holt_winters_forecast":
+		hw, predictions, sigma := fitHoltWinters(vals, t.N)
+		if hw == nil {
+			return outs, nil
+		}
+		for i, p := range predictions {
+			if !math.IsNaN(p) {
+				for k, c := range cells(p, z95*sigma) {
+					outs[k][i] = c
+				}
+			}
+		}
+		for h, ts := range future {
+			rows = append(rows, futureRow{time: ts, cells: cells(hw.forecast(h+1), z95*sigma*hw.spread(h+1))})
+		}
+	}
+	return outs, rows
+}
+
+// linearFit is a least-squares line through a series. x is seconds
+// relative to the last value, which keeps the sums small.
+type linearFit struct {
+	last       int64
+	n          float64
+	slope      float64
+	intercept  float64
+	meanX, sxx float64
+	stderr     float64 // standard deviation of the residuals
+}
+
+// fitLinear fits a line to the values, which needs two distinct times
+func fitLinear(times []int64, vals []float64) (*linearFit, bool) {
+	fit := &linearFit{last: times[len(times)-1], n: float64(len(vals))}
+	x := func(ts int64) float64 {
+		return float64(ts-fit.last) / float64(time.Second/time.Millisecond)
+	}
+
+	var meanY float64
+	for i, ts := range times {
+		fit.meanX += x(ts) / fit.n
+		meanY += vals[i] / fit.n
+	}
+	var sxy float64
+	for i, ts := range times {
+		dx := x(ts) - fit.meanX
+		fit.sxx += dx * dx
+		sxy += dx * (vals[i] - meanY)
+	}
+	if fit.sxx == 0 {
+		return nil, false
+	}
+	fit.slope = sxy / fit.sxx
+	fit.intercept = meanY - fit.slope*fit.meanX
+
+	// Two points fit exactly and leave no error to estimate
+	if len(vals) > 2 {
+		var sse float64
+		for i, ts := range times {
+			r := vals[i] - (fit.intercept + fit.slope*x(ts))
+			sse += r * r
+		}
+		fit.stderr = math.Sqrt(sse / (fit.n - 2))
+	}
+	return fit, true
+}
+
+// predict returns the value of the line at ts and the half-width of its
+// 95% prediction interval, which widens away from the fitted times
+func (f *linearFit) predict(ts int64) (float64, float64) {
+	x := float64(ts-f.last) / float64(time.Second/time.Millisecond)
+	dx := x - f.meanX
+	bound := z95 * f.stderr * math.Sqrt(1+1/f.n+dx*dx/f.sxx)
+	return f.intercept + f.slope*x, bound
+}
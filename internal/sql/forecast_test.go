@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFitLinear(t *testing.T) {
+	// y = 2x + 1 per second, with alternating noise of 1
+	var times []int64
+	var vals []float64
+	for i := 0; i < 20; i++ {
+		noise := 1.0
+		if i%2 == 1 {
+			noise = -1
+		}
+		times = append(times, int64(i)*1000)
+		vals = append(vals, 2*float64(i)+1+noise)
+	}
+
+	fit, ok := fitLinear(times, vals)
+	if !ok {
+		t.Fatal("fitLinear failed")
+	}
+	if math.Abs(fit.slope-2) > 0.1 {
+		t.Errorf("slope = %f, want about 2", fit.slope)
+	}
+
+	near, nearBound := fit.predict(20_000)
+	far, farBound := fit.predict(100_000)
+	if math.Abs(near-41) > 1 || math.Abs(far-201) > 5 {
+		t.Errorf("predictions %f, %f, want about 41 and 201", near, far)
+	}
+	if !(nearBound > 0 && farBound > nearBound) {
+		t.Errorf("expected bounds to widen with distance, got %f then %f", nearBound, farBound)
+	}
+
+	if _, ok := fitLinear([]int64{5, 5}, []float64{1, 2}); ok {
+		t.Error("expected no fit without distinct times")
+	}
+}
+
+func TestHoltWintersForecast(t *testing.T) {
+	vals := seasonalSeries(6, 0, 0)
+	tr := &Transform{Func: "holt_winters_forecast", N: 4, Horizon: 8 * time.Minute, Step: time.Minute}
+	times := make([]int64, len(vals))
+	for i := range times {
+		times[i] = int64(i) * 60_000
+	}
+
+	_, future := tr.forecast(times, vals)
+	if len(future) != 8 {
+		t.Fatalf("expected 8 forecast rows, got %d", len(future))
+	}
+
+	// The series is an exact trend and season, so the forecast continues
+	// it and the bounds only widen
+	prevWidth := -1.0
+	for h, row := range future {
+		i := len(vals) + h
+		want := float64(i)*0.5 + []float64{0, 10, 0, -10}[i%4]
+		if row.time != int64(i)*60_000 || math.Abs(row.cells[0].(float64)-want) > 1e-6 {
+			t.Errorf("row %d = %d %v, want %f", h, row.time, row.cells, want)
+		}
+		width := row.cells[2].(float64) - row.cells[1].(float64)
+		if width < prevWidth {
+			t.Errorf("bounds narrowed at row %d", h)
+		}
+		prevWidth = width
+	}
+
+	hw := &holtWinters{period: 4, alpha: 0.5, beta: 0.1, gamma: 0.2}
+	if hw.spread(1) != 1 || !(hw.spread(5) > hw.spread(4)) {
+		t.Errorf("unexpected spread %f, %f, %f", hw.spread(1), hw.spread(4), hw.spread(5))
+	}
+}
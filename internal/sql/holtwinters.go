@@ -0,0 +1,143 @@
+package sql
+
+import "math"
+
+// holtWinters is an additive Holt-Winters model: a level, a trend and a
+// seasonal component with a season of period values, each updated by
+// exponential smoothing
+type holtWinters struct {
+	period             int
+	alpha, beta, gamma float64
+
+	level, trend float64
+	seasonal     []float64 // indexed by position in the season
+	n            int       // values seen
+}
+
+// holtWintersGrid are the smoothing factors tried when fitting a model
+var holtWintersGrid = struct{ alpha, beta, gamma []float64 }{
+	alpha: []float64{0.1, 0.3, 0.5, 0.7, 0.9},
+	beta:  []float64{0.01, 0.1, 0.3},
+	gamma: []float64{0.05, 0.2, 0.5},
+}
+
+// fitHoltWinters fits a model to vals, choosing the smoothing factors
+// from holtWintersGrid with the smallest squared one-step-ahead error. It
+// returns the fitted model after all values, its one-step-ahead
+// predictions, NaN for the first season, and the standard deviation of
+// their errors. It returns nil when there are fewer than two seasons of
+// values.
+func fitHoltWinters(vals []float64, period int) (*holtWinters, []float64, float64) {
+	if period < 2 || len(vals) < 2*period {
+		return nil, nil, math.NaN()
+	}
+
+	var best *holtWinters
+	var bestPred []float64
+	bestSSE := math.Inf(1)
+	for _, alpha := range holtWintersGrid.alpha {
+		for _, beta := range holtWintersGrid.beta {
+			for _, gamma := range holtWintersGrid.gamma {
+				hw := newHoltWinters(vals, period, alpha, beta, gamma)
+				pred := make([]float64, len(vals))
+				var sse float64
+				for i, v := range vals {
+					if i < period {
+						pred[i] = math.NaN()
+						continue
+					}
+					pred[i] = hw.forecast(1)
+					sse += (v - pred[i]) * (v - pred[i])
+					hw.update(v)
+				}
+				if sse < bestSSE || best == nil {
+					best, bestPred, bestSSE = hw, pred, sse
+				}
+			}
+		}
+	}
+
+	sigma := math.Sqrt(bestSSE / float64(len(vals)-period))
+	return best, bestPred, sigma
+}
+
+// newHoltWinters initializes a model from the first two seasons of vals.
+// The trend is the change in mean per value between them, the level the
+// first season's mean carried forward to its last value, and the seasonal
+// component the first season's deviation from that trend line. The model
+// has seen the first season.
+func newHoltWinters(vals []float64, period int, alpha, beta, gamma float64) *holtWinters {
+	var first, second float64
+	for i := 0; i < period; i++ {
+		first += vals[i] / float64(period)
+		second += vals[period+i] / float64(period)
+	}
+	trend := (second - first) / float64(period)
+	center := float64(period-1) / 2
+
+	hw := &holtWinters{
+		period:   period,
+		alpha:    alpha,
+		beta:     beta,
+		gamma:    gamma,
+		level:    first + center*trend,
+		trend:    trend,
+		seasonal: make([]float64, period),
+		n:        period,
+	}
+	for i := 0; i < period; i++ {
+		hw.seasonal[i] = vals[i] - (first + (float64(i)-center)*trend)
+	}
+	return hw
+}
+
+// forecast returns the prediction h values ahead of the last one seen
+func (hw *holtWinters) forecast(h int) float64 {
+	return hw.level + float64(h)*hw.trend + hw.seasonal[(hw.n+h-1)%hw.period]
+}
+
+// spread returns how much wider the prediction error is h values ahead
+// than one value ahead, as the errors of the intermediate values carry
+// into the level, trend and season
+func (hw *holtWinters) spread(h int) float64 {
+	variance := 1.0
+	for j := 1; j < h; j++ {
+		c := hw.alpha * (1 + float64(j)*hw.beta)
+		if j%hw.period == 0 {
+			c += hw.gamma
+		}
+		variance += c * c
+	}
+	return math.Sqrt(variance)
+}
+
+// update adds the next value to the model
+func (hw *holtWinters) update(v float64) {
+	pos := hw.n % hw.period
+	level := hw.alpha*(v-hw.seasonal[pos]) + (1-hw.alpha)*(hw.level+hw.trend)
+	hw.trend = hw.beta*(level-hw.level) + (1-hw.beta)*hw.trend
+	hw.seasonal[pos] = hw.gamma*(v-level) + (1-hw.gamma)*hw.seasonal[pos]
+	hw.level = level
+	hw.n++
+}
+
+// holtWintersBands returns the one-step-ahead prediction of each value,
+// a band of k standard deviations of the prediction error around it and
+// whether the value falls outside the band
+func holtWintersBands(vals []float64, period int, k float64) [][]interface{} {
+	pred := make([]interface{}, len(vals))
+	lower := make([]interface{}, len(vals))
+	upper := make([]interface{}, len(vals))
+	flags := make([]interface{}, len(vals))
+
+	_, predictions, sigma := fitHoltWinters(vals, period)
+	for i, p := range predictions {
+		if math.IsNaN(p) {
+			continue
+		}
+		lo, hi := p-k*sigma, p+k*sigma
+		pred[i], lower[i], upper[i] = number(p), number(lo), number(hi)
+		flags[i] = vals[i] < lo || vals[i] > hi
+	}
+	return [][]interface{}{pred, lower, upper, flags}
+}
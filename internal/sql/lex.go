@@ -0,0 +1,256 @@
+package sql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// itemType identifies a lexical token
+type itemType int
+
+const (
+	itemEOF itemType = iota
+	itemIdentifier
+	itemQuotedIdentifier // "name", never a keyword
+	itemNumber
+	itemDuration
+	itemString
+	itemRegex
+	itemLeftParen
+	itemRightParen
+	itemComma
+	itemStar
+	itemADD
+	itemSUB
+	itemEQL      // =
+	itemNEQ      // != or <>
+	itemLSS      // <
+	itemLTE      // <=
+	itemGTR      // >
+	itemGTE      // >=
+	itemEQLRegex // =~
+	itemNEQRegex // !~
+)
+
+// item is a token with its position in the input
+type item struct {
+	typ itemType
+	pos int
+	val string
+}
+
+func (i item) String() string {
+	switch i.typ {
+	case itemEOF:
+		return "end of input"
+	case itemString:
+		return fmt.Sprintf("string '%s'", i.val)
+	case itemRegex:
+		return fmt.Sprintf("regex /%s/", i.val)
+	}
+	return fmt.Sprintf("%q", i.val)
+}
+
+// is reports whether the token is the given keyword, in any case
+func (i item) is(keyword string) bool {
+	return i.typ == itemIdentifier && strings.EqualFold(i.val, keyword)
+}
+
+// isName reports whether the token names a metric, tag or column
+func (i item) isName() bool {
+	return i.typ == itemIdentifier || i.typ == itemQuotedIdentifier
+}
+
+// lex splits the input into tokens. Keywords are lexed as identifiers
+// and recognized by the parser.
+func lex(input string) ([]item, error) {
+	var items []item
+	pos := 0
+
+	for pos < len(input) {
+		c := input[pos]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pos++
+			continue
+		case c == '-' && pos+1 < len(input) && input[pos+1] == '-':
+			// Comment until end of line
+			for pos < len(input) && input[pos] != '\n' {
+				pos++
+			}
+			continue
+		}
+
+		start := pos
+		two := ""
+		if pos+1 < len(input) {
+			two = input[pos : pos+2]
+		}
+
+		switch {
+		case two == "!=" || two == "<>":
+			items = append(items, item{itemNEQ, start, two})
+			pos += 2
+		case two == "<=":
+			items = append(items, item{itemLTE, start, two})
+			pos += 2
+		case two == ">=":
+			items = append(items, item{itemGTE, start, two})
+			pos += 2
+		case two == "=~":
+			items = append(items, item{itemEQLRegex, start, two})
+			pos += 2
+		case two == "!~":
+			items = append(items, item{itemNEQRegex, start, two})
+			pos += 2
+		case c == '=':
+			items = append(items, item{itemEQL, start, "="})
+			pos++
+		case c == '<':
+			items = append(items, item{itemLSS, start, "<"})
+			pos++
+		case c == '>':
+			items = append(items, item{itemGTR, start, ">"})
+			pos++
+		case c == '(':
+			items = append(items, item{itemLeftParen, start, "("})
+			pos++
+		case c == ')':
+			items = append(items, item{itemRightParen, start, ")"})
+			pos++
+		case c == ',':
+			items = append(items, item{itemComma, start, ","})
+			pos++
+		case c == '*':
+			items = append(items, item{itemStar, start, "*"})
+			pos++
+		case c == '+':
+			items = append(items, item{itemADD, start, "+"})
+			pos++
+		case c == '-':
+			items = append(items, item{itemSUB, start, "-"})
+			pos++
+		case c == '/' && afterRegexOp(items):
+			val, end, err := scanQuoted(input, pos, '/')
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item{itemRegex, start, val})
+			pos = end
+		case c == '\'':
+			val, end, err := scanQuoted(input, pos, '\'')
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item{itemString, start, val})
+			pos = end
+		case c == '"':
+			val, end, err := scanQuoted(input, pos, '"')
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item{itemQuotedIdentifier, start, val})
+			pos = end
+		case isDigit(c) || (c == '.' && pos+1 < len(input) && isDigit(input[pos+1])):
+			pos = scanNumber(input, pos)
+			typ := itemNumber
+			// A number directly followed by letters is a duration (5m, 1h30m)
+			if pos < len(input) && isAlpha(input[pos]) {
+				for pos < len(input) && (isAlpha(input[pos]) || isDigit(input[pos])) {
+					pos++
+				}
+				typ = itemDuration
+			}
+			items = append(items, item{typ, start, input[start:pos]})
+		case isAlpha(c) || c == '_':
+			for pos < len(input) && isIdentChar(input[pos]) {
+				pos++
+			}
+			items = append(items, item{itemIdentifier, start, input[start:pos]})
+		default:
+			return nil, newParseError(input, start, fmt.Sprintf("unexpected character %q", rune(c)))
+		}
+	}
+
+	items = append(items, item{itemEOF, len(input), ""})
+	return items, nil
+}
+
+// afterRegexOp reports whether a '/' starts a regex literal, which is
+// only the case right after =~ or !~
+func afterRegexOp(items []item) bool {
+	if len(items) == 0 {
+		return false
+	}
+	typ := items[len(items)-1].typ
+	return typ == itemEQLRegex || typ == itemNEQRegex
+}
+
+// scanQuoted reads a literal delimited by quote. A backslash escapes the
+// quote and itself; other escapes are kept, so regexes keep theirs.
+func scanQuoted(input string, pos int, quote byte) (string, int, error) {
+	var sb strings.Builder
+	i := pos + 1
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == '\\' && i+1 < len(input) && (input[i+1] == quote || input[i+1] == '\\'):
+			if quote == '/' && input[i+1] == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(input[i+1])
+			i += 2
+			continue
+		case c == quote:
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	return "", 0, newParseError(input, pos, "unterminated "+quoteName(quote))
+}
+
+func quoteName(quote byte) string {
+	switch quote {
+	case '/':
+		return "regex"
+	case '"':
+		return "quoted identifier"
+	}
+	return "string"
+}
+
+func scanNumber(input string, pos int) int {
+	for pos < len(input) && (isDigit(input[pos]) || input[pos] == '.') {
+		pos++
+	}
+	// Exponent
+	if pos < len(input) && (input[pos] == 'e' || input[pos] == 'E') {
+		next := pos + 1
+		if next < len(input) && (input[next] == '+' || input[next] == '-') {
+			next++
+		}
+		if next < len(input) && isDigit(input[next]) {
+			pos = next
+			for pos < len(input) && isDigit(input[pos]) {
+				pos++
+			}
+		}
+	}
+	return pos
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlpha(c byte) bool {
+	return c < unicode.MaxASCII && unicode.IsLetter(rune(c))
+}
+
+// isIdentChar allows dots so metric names such as cpu.usage need no quotes
+func isIdentChar(c byte) bool {
+	return isAlpha(c) || isDigit(c) || c == '_' || c == '.' || c == ':'
+}
@@ -0,0 +1,747 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/promql"
+)
+
+// ParseError describes a syntax or semantic error and where it occurred
+type ParseError struct {
+	Pos    int // byte offset
+	Line   int // 1-based
+	Column int // 1-based
+	Err    string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse error at line %d, column %d: %s", e.Line, e.Column, e.Err)
+}
+
+func newParseError(input string, pos int, msg string) *ParseError {
+	if pos > len(input) {
+		pos = len(input)
+	}
+	before := input[:pos]
+	line := strings.Count(before, "\n") + 1
+	column := pos - strings.LastIndexByte(before, '\n')
+	return &ParseError{Pos: pos, Line: line, Column: column, Err: msg}
+}
+
+// keywords cannot be used as unquoted names
+var keywords = map[string]bool{
+	"EXPLAIN": true, "SELECT": true, "FROM": true, "WHERE": true, "AND": true,
+	"OR": true, "GROUP": true, "BY": true, "FILL": true, "ORDER": true,
+	"ASC": true, "DESC": true, "LIMIT": true, "OFFSET": true, "AS": true,
+}
+
+// aggregates lists the supported aggregate functions
+var aggregates = map[string]bool{
+	"count":  true,
+	"sum":    true,
+	"mean":   true,
+	"min":    true,
+	"max":    true,
+	"first":  true,
+	"last":   true,
+	"spread": true,
+	"stddev": true,
+}
+
+// transforms lists the supported transformations
+var transforms = map[string]bool{
+	"moving_average": true,
+	"ewma":           true,
+	"cumulative_sum": true,
+	"difference":     true,
+	"elapsed":        true,
+	// Anomaly detectors
+	"zscore":             true,
+	"mad":                true,
+	"seasonal_residual":  true,
+	"holt_winters_bands": true,
+	// Forecasts
+	"predict_linear":        true,
+	"holt_winters_forecast": true,
+}
+
+// comparisonOps maps comparison tokens to their operator
+var comparisonOps = map[itemType]string{
+	itemEQL:      "=",
+	itemNEQ:      "!=",
+	itemLSS:      "<",
+	itemLTE:      "<=",
+	itemGTR:      ">",
+	itemGTE:      ">=",
+	itemEQLRegex: "=~",
+	itemNEQRegex: "!~",
+}
+
+type parser struct {
+	input string
+	items []item
+	pos   int
+}
+
+// Parse parses a SELECT statement
+func Parse(input string) (*Statement, error) {
+	items, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{input: input, items: items}
+	stmt, err := p.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != itemEOF {
+		return nil, p.errorf(tok, "unexpected %s after end of statement", tok)
+	}
+	return stmt, nil
+}
+
+func (p *parser) peek() item {
+	return p.items[p.pos]
+}
+
+func (p *parser) next() item {
+	tok := p.items[p.pos]
+	if tok.typ != itemEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok item, format string, args ...interface{}) error {
+	return newParseError(p.input, tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(typ itemType, want string) (item, error) {
+	tok := p.next()
+	if tok.typ != typ {
+		return tok, p.errorf(tok, "expected %s, got %s", want, tok)
+	}
+	return tok, nil
+}
+
+func (p *parser) expectKeyword(keyword string) error {
+	tok := p.next()
+	if !tok.is(keyword) {
+		return p.errorf(tok, "expected %s, got %s", keyword, tok)
+	}
+	return nil
+}
+
+// acceptKeyword consumes the next token if it is keyword
+func (p *parser) acceptKeyword(keyword string) bool {
+	if p.peek().is(keyword) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// parseName reads a metric, tag or column name
+func (p *parser) parseName(what string) (item, error) {
+	tok := p.next()
+	if !tok.isName() || (tok.typ == itemIdentifier && keywords[strings.ToUpper(tok.val)]) {
+		return tok, p.errorf(tok, "expected %s, got %s", what, tok)
+	}
+	return tok, nil
+}
+
+func (p *parser) parseSelect() (*Statement, error) {
+	stmt := &Statement{Explain: p.acceptKeyword("EXPLAIN")}
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	for {
+		f, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Fields = append(stmt.Fields, f)
+		if p.peek().typ != itemComma {
+			break
+		}
+		p.next()
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	metric, err := p.parseName("metric name")
+	if err != nil {
+		return nil, err
+	}
+	stmt.Metric = metric.val
+
+	if p.acceptKeyword("WHERE") {
+		if stmt.Where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("GROUP") {
+		if err := p.parseGroupBy(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	if tok := p.peek(); tok.is("FILL") {
+		if stmt.Interval == 0 {
+			return nil, p.errorf(tok, "FILL requires GROUP BY time()")
+		}
+		if stmt.Fill, err = p.parseFill(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		tok := p.next()
+		if !tok.is("time") && !(tok.typ == itemQuotedIdentifier && tok.val == "time") {
+			return nil, p.errorf(tok, "only ORDER BY time is supported, got %s", tok)
+		}
+		if p.acceptKeyword("DESC") {
+			stmt.Desc = true
+		} else {
+			p.acceptKeyword("ASC")
+		}
+	}
+
+	if p.acceptKeyword("LIMIT") {
+		if stmt.Limit, err = p.parseCount("LIMIT"); err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("OFFSET") {
+		if stmt.Offset, err = p.parseCount("OFFSET"); err != nil {
+			return nil, err
+		}
+	}
+
+	return stmt, p.check(stmt)
+}
+
+func (p *parser) parseField() (*Field, error) {
+	tok := p.peek()
+	if tok.typ == itemStar {
+		p.next()
+		return &Field{Name: "*", Pos: tok.pos}, nil
+	}
+
+	name, err := p.parseName("field")
+	if err != nil {
+		return nil, err
+	}
+
+	var f *Field
+	if fn := strings.ToLower(name.val); name.typ == itemIdentifier && p.peek().typ == itemLeftParen && transforms[fn] {
+		p.next()
+		inner, err := p.parseName("field in " + fn + "()")
+		if err != nil {
+			return nil, err
+		}
+		if f, err = p.parseColumn(inner); err != nil {
+			return nil, err
+		}
+		if f.Name != "value" {
+			return nil, p.errorf(inner, "%s() can only be applied to value or an aggregate of value, got %q", fn, f.Name)
+		}
+		if f.Transform, err = p.parseTransformArgs(name, fn); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(itemRightParen, `")"`); err != nil {
+			return nil, err
+		}
+		f.Pos = name.pos
+	} else if f, err = p.parseColumn(name); err != nil {
+		return nil, err
+	}
+
+	if p.acceptKeyword("AS") {
+		alias, err := p.parseName("alias")
+		if err != nil {
+			return nil, err
+		}
+		f.Alias = alias.val
+	}
+	return f, nil
+}
+
+// parseColumn parses a column or an aggregate whose name was consumed
+func (p *parser) parseColumn(name item) (*Field, error) {
+	f := &Field{Name: name.val, Pos: name.pos}
+	if name.typ != itemIdentifier || p.peek().typ != itemLeftParen {
+		return f, nil
+	}
+
+	fn := strings.ToLower(name.val)
+	if !aggregates[fn] {
+		return nil, p.errorf(name, "unknown function %q", name.val)
+	}
+	p.next()
+	arg, err := p.parseName("field name in " + fn + "()")
+	if err != nil {
+		return nil, err
+	}
+	if arg.val != "value" {
+		return nil, p.errorf(arg, "%s() can only be applied to value, got %q", fn, arg.val)
+	}
+	if _, err := p.expect(itemRightParen, `")"`); err != nil {
+		return nil, err
+	}
+	f.Func, f.Name = fn, arg.val
+	return f, nil
+}
+
+// parseTransformArgs parses the arguments of a transformation after its
+// field: a row count or duration for moving_average, a smoothing factor
+// for ewma, an optional unit for elapsed, a season and an optional
+// threshold for detectors and a horizon for forecasts
+func (p *parser) parseTransformArgs(name item, fn string) (*Transform, error) {
+	t := &Transform{Func: fn}
+	next := func() (item, error) {
+		if _, err := p.expect(itemComma, `","`); err != nil {
+			return item{}, err
+		}
+		return p.next(), nil
+	}
+	optional := func(parse func() error) error {
+		if p.peek().typ != itemComma {
+			return nil
+		}
+		return parse()
+	}
+	threshold := func() error {
+		tok, err := next()
+		if err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(tok.val, 64)
+		if tok.typ != itemNumber || err != nil || v <= 0 {
+			return p.errorf(tok, "%s() expects a positive threshold, got %s", name.val, tok)
+		}
+		t.Threshold = v
+		return nil
+	}
+	horizon := func() error {
+		tok, err := next()
+		if err != nil {
+			return err
+		}
+		if tok.typ != itemDuration {
+			return p.errorf(tok, "%s() expects a horizon such as 7d, got %s", name.val, tok)
+		}
+		t.Horizon, err = p.parsePositiveDuration(tok)
+		return err
+	}
+
+	switch fn {
+	case "moving_average":
+		tok, err := next()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(tok.val)
+		switch {
+		case tok.typ == itemDuration:
+			t.Period, err = p.parsePositiveDuration(tok)
+		case tok.typ == itemNumber && err == nil && n >= 1:
+			t.N = n
+		default:
+			err = p.errorf(tok, "%s() expects a positive number of rows or a duration, got %s", name.val, tok)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	case "ewma":
+		tok, err := next()
+		if err != nil {
+			return nil, err
+		}
+		alpha, err := strconv.ParseFloat(tok.val, 64)
+		if tok.typ != itemNumber || err != nil || alpha <= 0 || alpha > 1 {
+			return nil, p.errorf(tok, "%s() expects a smoothing factor in (0, 1], got %s", name.val, tok)
+		}
+		t.Alpha = alpha
+
+	case "elapsed":
+		err := optional(func() error {
+			tok, err := next()
+			if err != nil {
+				return err
+			}
+			if tok.typ != itemDuration {
+				return p.errorf(tok, "%s() expects a unit such as 1s, got %s", name.val, tok)
+			}
+			t.Period, err = p.parsePositiveDuration(tok)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "zscore", "mad":
+		if err := optional(threshold); err != nil {
+			return nil, err
+		}
+
+	case "seasonal_residual", "holt_winters_bands", "holt_winters_forecast":
+		tok, err := next()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(tok.val)
+		switch {
+		case tok.typ == itemDuration:
+			t.Period, err = p.parsePositiveDuration(tok)
+		case tok.typ == itemNumber && err == nil && n >= 2:
+			t.N = n
+		default:
+			err = p.errorf(tok, "%s() expects a season of at least 2 rows or a duration, got %s", name.val, tok)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if fn == "holt_winters_forecast" {
+			err = horizon()
+		} else {
+			err = optional(threshold)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+	case "predict_linear":
+		if err := horizon(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) parsePositiveDuration(tok item) (time.Duration, error) {
+	d, err := promql.ParseDuration(tok.val)
+	if err != nil || d <= 0 {
+		return 0, p.errorf(tok, "invalid duration %q", tok.val)
+	}
+	return d, nil
+}
+
+func (p *parser) parseGroupBy(stmt *Statement) error {
+	if err := p.expectKeyword("BY"); err != nil {
+		return err
+	}
+
+	for {
+		tok := p.peek()
+		if tok.is("time") && p.items[p.pos+1].typ == itemLeftParen {
+			if stmt.Interval > 0 {
+				return p.errorf(tok, "time() specified twice in GROUP BY")
+			}
+			p.next()
+			p.next()
+			d, err := p.expect(itemDuration, "interval such as 5m")
+			if err != nil {
+				return err
+			}
+			interval, err := promql.ParseDuration(d.val)
+			if err != nil || interval <= 0 {
+				return p.errorf(d, "invalid interval %q", d.val)
+			}
+			if _, err := p.expect(itemRightParen, `")"`); err != nil {
+				return err
+			}
+			stmt.Interval = interval
+		} else {
+			name, err := p.parseName("tag key or time()")
+			if err != nil {
+				return err
+			}
+			stmt.GroupBy = append(stmt.GroupBy, name.val)
+		}
+
+		if p.peek().typ != itemComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseFill() (Fill, error) {
+	p.next() // FILL
+	if _, err := p.expect(itemLeftParen, `"("`); err != nil {
+		return Fill{}, err
+	}
+
+	var fill Fill
+	tok := p.next()
+	switch {
+	case tok.is("null"):
+		fill.Mode = FillNull
+	case tok.is("none"):
+		fill.Mode = FillNone
+	case tok.is("previous"):
+		fill.Mode = FillPrevious
+	case tok.is("linear"):
+		fill.Mode = FillLinear
+	case tok.typ == itemNumber || tok.typ == itemSUB:
+		v, err := p.parseSignedNumber(tok)
+		if err != nil {
+			return Fill{}, err
+		}
+		fill = Fill{Mode: FillValue, Value: v}
+	default:
+		return Fill{}, p.errorf(tok, "expected null, none, previous, linear or a number in FILL, got %s", tok)
+	}
+
+	if _, err := p.expect(itemRightParen, `")"`); err != nil {
+		return Fill{}, err
+	}
+	return fill, nil
+}
+
+// parseSignedNumber parses a number whose first token, possibly a minus
+// sign, was already consumed
+func (p *parser) parseSignedNumber(tok item) (float64, error) {
+	sign := 1.0
+	if tok.typ == itemSUB {
+		sign = -1
+		tok = p.next()
+	}
+	if tok.typ != itemNumber {
+		return 0, p.errorf(tok, "expected number, got %s", tok)
+	}
+	v, err := strconv.ParseFloat(tok.val, 64)
+	if err != nil {
+		return 0, p.errorf(tok, "invalid number %q", tok.val)
+	}
+	return sign * v, nil
+}
+
+func (p *parser) parseCount(clause string) (int, error) {
+	tok := p.next()
+	n, err := strconv.Atoi(tok.val)
+	if tok.typ != itemNumber || err != nil || n < 0 {
+		return 0, p.errorf(tok, "%s expects a non-negative integer, got %s", clause, tok)
+	}
+	return n, nil
+}
+
+// parseOr parses conditions joined by OR, which binds looser than AND
+func (p *parser) parseOr() (Expr, error) {
+	lhs, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		rhs, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: "OR", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	lhs, err := p.parseCondition()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		rhs, err := p.parseCondition()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &BinaryExpr{Op: "AND", LHS: lhs, RHS: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseCondition() (Expr, error) {
+	if p.peek().typ == itemLeftParen {
+		p.next()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(itemRightParen, `")"`); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	name, err := p.parseName("time, value or a tag key")
+	if err != nil {
+		return nil, err
+	}
+	opTok := p.next()
+	op, ok := comparisonOps[opTok.typ]
+	if !ok {
+		return nil, p.errorf(opTok, "expected comparison operator after %q, got %s", name.val, opTok)
+	}
+	cmp := &Comparison{Name: name.val, Op: op, Pos: name.pos}
+
+	switch name.val {
+	case "time":
+		cmp.Value, err = p.parseTime(opTok)
+	case "value":
+		cmp.Value, err = p.parseValue(opTok)
+	default:
+		cmp.Value, err = p.parseTagValue(opTok)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cmp, nil
+}
+
+// parseTime reads now() [+|- duration], an RFC 3339 string or
+// milliseconds since the epoch
+func (p *parser) parseTime(op item) (Literal, error) {
+	if op.typ == itemEQLRegex || op.typ == itemNEQRegex || op.typ == itemNEQ {
+		return nil, p.errorf(op, "operator %s is not supported for time", op.val)
+	}
+
+	tok := p.next()
+	switch {
+	case tok.is("now"):
+		if _, err := p.expect(itemLeftParen, `"("`); err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(itemRightParen, `")"`); err != nil {
+			return nil, err
+		}
+		now := &NowLiteral{}
+		if sign := p.peek(); sign.typ == itemADD || sign.typ == itemSUB {
+			p.next()
+			d, err := p.expect(itemDuration, "duration such as 1h")
+			if err != nil {
+				return nil, err
+			}
+			offset, err := promql.ParseDuration(d.val)
+			if err != nil {
+				return nil, p.errorf(d, "invalid duration %q", d.val)
+			}
+			if sign.typ == itemSUB {
+				offset = -offset
+			}
+			now.Offset = offset
+		}
+		return now, nil
+
+	case tok.typ == itemString:
+		t, err := time.Parse(time.RFC3339Nano, tok.val)
+		if err != nil {
+			return nil, p.errorf(tok, "invalid time %s, expected RFC 3339 such as '2024-01-02T15:04:05Z'", tok)
+		}
+		return &NumberLiteral{Val: float64(t.UnixMilli())}, nil
+
+	case tok.typ == itemNumber || tok.typ == itemSUB:
+		v, err := p.parseSignedNumber(tok)
+		if err != nil {
+			return nil, err
+		}
+		return &NumberLiteral{Val: v}, nil
+	}
+	return nil, p.errorf(tok, "expected now(), a time string or milliseconds, got %s", tok)
+}
+
+func (p *parser) parseValue(op item) (Literal, error) {
+	if op.typ == itemEQLRegex || op.typ == itemNEQRegex {
+		return nil, p.errorf(op, "operator %s is not supported for value", op.val)
+	}
+	tok := p.next()
+	v, err := p.parseSignedNumber(tok)
+	if err != nil {
+		return nil, p.errorf(tok, "value must be compared to a number, got %s", tok)
+	}
+	return &NumberLiteral{Val: v}, nil
+}
+
+func (p *parser) parseTagValue(op item) (Literal, error) {
+	tok := p.next()
+	switch op.typ {
+	case itemEQLRegex, itemNEQRegex:
+		if tok.typ != itemRegex && tok.typ != itemString {
+			return nil, p.errorf(tok, "expected regex such as /^a.*/ after %s, got %s", op.val, tok)
+		}
+		return &RegexLiteral{Val: tok.val}, nil
+	case itemEQL, itemNEQ:
+		if tok.typ != itemString {
+			return nil, p.errorf(tok, "tag values must be single-quoted strings, got %s", tok)
+		}
+		return &StringLiteral{Val: tok.val}, nil
+	}
+	return nil, p.errorf(op, "operator %s is not supported for tags", op.val)
+}
+
+// check validates the combination of fields and clauses
+func (p *parser) check(stmt *Statement) error {
+	var raw, agg *Field
+	for _, f := range stmt.Fields {
+		if f.Func != "" {
+			agg = f
+		} else {
+			raw = f
+		}
+	}
+
+	if raw != nil && agg != nil {
+		return newParseError(p.input, raw.Pos, fmt.Sprintf("cannot mix %s with aggregate %s() in SELECT", raw.Name, agg.Func))
+	}
+	if raw != nil && stmt.Interval > 0 {
+		return newParseError(p.input, raw.Pos, "GROUP BY time() requires aggregate fields such as mean(value)")
+	}
+
+	for _, f := range stmt.Fields {
+		t := f.Transform
+		if t == nil {
+			continue
+		}
+
+		// Forecast rows are GROUP BY time() windows
+		switch {
+		case t.Func == "holt_winters_forecast" && stmt.Interval == 0:
+			return newParseError(p.input, f.Pos, fmt.Sprintf("%s() requires GROUP BY time()", t.Func))
+		case t.Horizon > 0 && t.Horizon < stmt.Interval:
+			return newParseError(p.input, f.Pos, fmt.Sprintf("%s() horizon must be at least one GROUP BY time() interval", t.Func))
+		case stmt.Interval > 0 && t.Horizon/stmt.Interval > MaxWindows:
+			return newParseError(p.input, f.Pos, fmt.Sprintf("%s() horizon is more than %d GROUP BY time() intervals", t.Func, MaxWindows))
+		case t.Horizon > 0:
+			t.Step = stmt.Interval
+		}
+
+		// A season given as a duration is a number of GROUP BY time() windows
+		if (t.Func != "seasonal_residual" && !strings.HasPrefix(t.Func, "holt_winters")) || t.Period == 0 {
+			continue
+		}
+		if stmt.Interval == 0 {
+			return newParseError(p.input, f.Pos, fmt.Sprintf("%s() with a season duration requires GROUP BY time()", t.Func))
+		}
+		if t.Period%stmt.Interval != 0 || t.Period/stmt.Interval < 2 {
+			return newParseError(p.input, f.Pos, fmt.Sprintf("%s() season must be a multiple of at least 2 GROUP BY time() intervals", t.Func))
+		}
+		t.N = int(t.Period / stmt.Interval)
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatDuration(d time.Duration) string {
+	return promql.FormatDuration(d)
+}
@@ -0,0 +1,157 @@
+package sql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`SELECT value FROM temperature`, `SELECT value FROM temperature`},
+		{`select * from cpu.usage limit 10`, `SELECT * FROM cpu.usage LIMIT 10`},
+		{`explain SELECT value FROM m`, `EXPLAIN SELECT value FROM m`},
+		{
+			`SELECT mean(value) FROM temperature WHERE sensor='a' AND time > now()-1h GROUP BY time(5m), host FILL(previous) ORDER BY time DESC LIMIT 100`,
+			`SELECT mean(value) FROM temperature WHERE (sensor = 'a' AND time > now() - 1h) GROUP BY time(5m), host FILL(previous) ORDER BY time DESC LIMIT 100`,
+		},
+		{
+			`SELECT max(value) AS peak, min(value) FROM "my metric" WHERE host =~ /web-\d+/ OR value >= -1.5`,
+			`SELECT max(value) AS peak, min(value) FROM "my metric" WHERE (host =~ /web-\d+/ OR value >= -1.5)`,
+		},
+		{
+			`SELECT count(value) FROM m WHERE a = 'x' AND (b != 'y' OR c <> 'z') GROUP BY time(1h30m) FILL(0) OFFSET 5`,
+			`SELECT count(value) FROM m WHERE (a = 'x' AND (b != 'y' OR c != 'z')) GROUP BY time(1h30m) FILL(0) OFFSET 5`,
+		},
+		{
+			"SELECT value -- the raw points\nFROM m WHERE time >= '2024-01-02T00:00:00Z'",
+			`SELECT value FROM m WHERE time >= 1704153600000`,
+		},
+		{`SELECT "group", value FROM m WHERE "group" = 'it\'s'`, `SELECT "group", value FROM m WHERE "group" = 'it\'s'`},
+		{
+			`SELECT MOVING_AVERAGE(mean(value), 3) AS smooth, moving_average(mean(value), 15m), ewma(max(value), 0.3) FROM m GROUP BY time(5m)`,
+			`SELECT moving_average(mean(value), 3) AS smooth, moving_average(mean(value), 15m), ewma(max(value), 0.3) FROM m GROUP BY time(5m)`,
+		},
+		{
+			`SELECT value, cumulative_sum(value), difference(value), elapsed(value), elapsed(value, 1s) FROM m`,
+			`SELECT value, cumulative_sum(value), difference(value), elapsed(value), elapsed(value, 1s) FROM m`,
+		},
+		{
+			`SELECT zscore(mean(value)), mad(mean(value), 5), seasonal_residual(mean(value), 24, 2.5), holt_winters_bands(mean(value), 1d) AS hw FROM m GROUP BY time(1h)`,
+			`SELECT zscore(mean(value)), mad(mean(value), 5), seasonal_residual(mean(value), 24, 2.5), holt_winters_bands(mean(value), 1d) AS hw FROM m GROUP BY time(1h)`,
+		},
+		{
+			`SELECT predict_linear(mean(value), 7d), holt_winters_forecast(mean(value), 24, 2d) FROM m GROUP BY time(1h)`,
+			`SELECT predict_linear(mean(value), 1w), holt_winters_forecast(mean(value), 24, 2d) FROM m GROUP BY time(1h)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			stmt, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if got := stmt.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+
+			// The printed statement parses back to itself
+			again, err := Parse(stmt.String())
+			if err != nil {
+				t.Fatalf("Parse(String()) failed: %v", err)
+			}
+			if again.String() != stmt.String() {
+				t.Errorf("round trip = %q, want %q", again.String(), stmt.String())
+			}
+		})
+	}
+}
+
+func TestParseStatement(t *testing.T) {
+	stmt, err := Parse(`SELECT mean(value) FROM temperature WHERE sensor='a' AND time > now()-1h GROUP BY time(5m), host FILL(previous) ORDER BY time DESC LIMIT 100`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if stmt.Metric != "temperature" || len(stmt.Fields) != 1 || stmt.Fields[0].Func != "mean" {
+		t.Errorf("unexpected statement %+v", stmt)
+	}
+	if stmt.Interval != 5*time.Minute || len(stmt.GroupBy) != 1 || stmt.GroupBy[0] != "host" {
+		t.Errorf("unexpected GROUP BY %v %v", stmt.Interval, stmt.GroupBy)
+	}
+	if stmt.Fill.Mode != FillPrevious || !stmt.Desc || stmt.Limit != 100 {
+		t.Errorf("unexpected FILL/ORDER/LIMIT %+v", stmt)
+	}
+
+	and, ok := stmt.Where.(*BinaryExpr)
+	if !ok || and.Op != "AND" {
+		t.Fatalf("expected AND, got %s", stmt.Where)
+	}
+	now, ok := and.RHS.(*Comparison).Value.(*NowLiteral)
+	if !ok || now.Offset != -time.Hour {
+		t.Errorf("expected now() - 1h, got %s", and.RHS)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+		msg    string
+	}{
+		{`SELEC value FROM m`, 1, 1, `expected SELECT`},
+		{`SELECT value FROM`, 1, 18, `expected metric name, got end of input`},
+		{`SELECT median(value) FROM m`, 1, 8, `unknown function "median"`},
+		{`SELECT mean(host) FROM m`, 1, 13, `mean() can only be applied to value`},
+		{`SELECT value, mean(value) FROM m`, 1, 8, `cannot mix value with aggregate mean()`},
+		{`SELECT value FROM m GROUP BY time(5m)`, 1, 8, `GROUP BY time() requires aggregate fields`},
+		{`SELECT mean(value) FROM m FILL(none)`, 1, 27, `FILL requires GROUP BY time()`},
+		{"SELECT value\nFROM m\nWHERE host = web", 3, 14, `tag values must be single-quoted strings`},
+		{`SELECT value FROM m WHERE host = 'web`, 1, 34, `unterminated string`},
+		{`SELECT value FROM m WHERE time =~ /x/`, 1, 32, `operator =~ is not supported for time`},
+		{`SELECT value FROM m WHERE value > 'a'`, 1, 35, `value must be compared to a number`},
+		{`SELECT value FROM m WHERE time > now() - 5`, 1, 42, `expected duration such as 1h`},
+		{`SELECT value FROM m ORDER BY host`, 1, 30, `only ORDER BY time is supported`},
+		{`SELECT value FROM m LIMIT -1`, 1, 27, `LIMIT`},
+		{`SELECT value FROM m WHERE host = 'a' extra`, 1, 38, `unexpected "extra" after end of statement`},
+		{`SELECT value FROM m WHERE host = 'a' # x`, 1, 38, `unexpected character '#'`},
+		{`SELECT moving_average(host, 3) FROM m`, 1, 23, `can only be applied to value or an aggregate of value`},
+		{`SELECT moving_average(value, 0) FROM m`, 1, 30, `expects a positive number of rows or a duration`},
+		{`SELECT moving_average(value) FROM m`, 1, 28, `expected ","`},
+		{`SELECT ewma(value, 2) FROM m`, 1, 20, `smoothing factor in (0, 1]`},
+		{`SELECT elapsed(value, 5) FROM m`, 1, 23, `expects a unit such as 1s`},
+		{`SELECT difference(value, 1) FROM m`, 1, 24, `expected ")"`},
+		{`SELECT value, difference(mean(value)) FROM m`, 1, 8, `cannot mix value with aggregate mean()`},
+		{`SELECT zscore(value, 0) FROM m`, 1, 22, `zscore() expects a positive threshold`},
+		{`SELECT holt_winters_bands(value, 1) FROM m`, 1, 34, `expects a season of at least 2 rows or a duration`},
+		{`SELECT seasonal_residual(value) FROM m`, 1, 31, `expected ","`},
+		{`SELECT seasonal_residual(value, 1d) FROM m`, 1, 8, `season duration requires GROUP BY time()`},
+		{`SELECT seasonal_residual(mean(value), 90m) FROM m GROUP BY time(1h)`, 1, 8, `multiple of at least 2 GROUP BY time() intervals`},
+		{`SELECT predict_linear(value, 10) FROM m`, 1, 30, `expects a horizon such as 7d`},
+		{`SELECT predict_linear(mean(value), 30m) FROM m GROUP BY time(1h)`, 1, 8, `horizon must be at least one GROUP BY time() interval`},
+		{`SELECT holt_winters_forecast(value, 24, 1d) FROM m`, 1, 8, `holt_winters_forecast() requires GROUP BY time()`},
+		{`SELECT holt_winters_forecast(mean(value), 1d) FROM m GROUP BY time(1h)`, 1, 45, `expected ","`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if perr.Line != tt.line || perr.Column != tt.column {
+				t.Errorf("error at %d:%d, want %d:%d (%v)", perr.Line, perr.Column, tt.line, tt.column, err)
+			}
+			if !strings.Contains(perr.Err, tt.msg) {
+				t.Errorf("error %q does not contain %q", perr.Err, tt.msg)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package sql
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Plan is a statement resolved for execution. Conditions on time become
+// the scanned range, tag conditions joined by AND are pushed down to the
+// engine as matchers and everything else is evaluated per point.
+type Plan struct {
+	Explain  bool
+	Metric   string
+	Start    int64 // inclusive, milliseconds
+	End      int64 // inclusive, milliseconds
+	Matchers []*storage.Matcher
+	Filter   Expr // residual condition, nil if none
+
+	Fields   []*Field
+	Interval int64 // GROUP BY time() in milliseconds, 0 if none
+	GroupBy  []string
+	Fill     Fill
+	Desc     bool
+	Limit    int
+	Offset   int
+
+	// regexes holds the compiled regexes of Filter
+	regexes map[*Comparison]*regexp.Regexp
+}
+
+// Aggregate reports whether the plan computes aggregates
+func (p *Plan) Aggregate() bool {
+	return p.Fields[0].Func != ""
+}
+
+// NewPlan plans stmt. now() in conditions is resolved against now.
+// input is the statement text, used to report error positions.
+func NewPlan(stmt *Statement, input string, now time.Time) (*Plan, error) {
+	plan := &Plan{
+		Explain:  stmt.Explain,
+		Metric:   stmt.Metric,
+		Start:    math.MinInt64,
+		End:      math.MaxInt64,
+		Fields:   stmt.Fields,
+		Interval: stmt.Interval.Milliseconds(),
+		GroupBy:  stmt.GroupBy,
+		Fill:     stmt.Fill,
+		Desc:     stmt.Desc,
+		Limit:    stmt.Limit,
+		Offset:   stmt.Offset,
+		regexes:  make(map[*Comparison]*regexp.Regexp),
+	}
+
+	var residual []Expr
+	for _, cond := range conjuncts(stmt.Where) {
+		cmp, ok := cond.(*Comparison)
+		switch {
+		case ok && cmp.Name == "time":
+			if err := plan.narrow(cmp, now); err != nil {
+				return nil, newParseError(input, cmp.Pos, err.Error())
+			}
+		case ok && cmp.Name != "value":
+			m, err := cmp.matcher()
+			if err != nil {
+				return nil, newParseError(input, cmp.Pos, err.Error())
+			}
+			plan.Matchers = append(plan.Matchers, m)
+		default:
+			residual = append(residual, cond)
+		}
+	}
+
+	for _, cond := range residual {
+		if err := plan.compile(cond, input); err != nil {
+			return nil, err
+		}
+		if plan.Filter == nil {
+			plan.Filter = cond
+		} else {
+			plan.Filter = &BinaryExpr{Op: "AND", LHS: plan.Filter, RHS: cond}
+		}
+	}
+
+	if plan.Start > plan.End {
+		return nil, newParseError(input, 0, "time range is empty")
+	}
+	return plan, nil
+}
+
+// conjuncts splits a condition on its top-level ANDs
+func conjuncts(expr Expr) []Expr {
+	if expr == nil {
+		return nil
+	}
+	if bin, ok := expr.(*BinaryExpr); ok && bin.Op == "AND" {
+		return append(conjuncts(bin.LHS), conjuncts(bin.RHS)...)
+	}
+	return []Expr{expr}
+}
+
+// narrow applies a time condition to the scanned range
+func (p *Plan) narrow(cmp *Comparison, now time.Time) error {
+	var t int64
+	switch v := cmp.Value.(type) {
+	case *NowLiteral:
+		t = now.Add(v.Offset).UnixMilli()
+	case *NumberLiteral:
+		t = int64(v.Val)
+	default:
+		return fmt.Errorf("invalid time %s", cmp.Value)
+	}
+
+	switch cmp.Op {
+	case ">":
+		p.Start = max64(p.Start, t+1)
+	case ">=":
+		p.Start = max64(p.Start, t)
+	case "<":
+		p.End = min64(p.End, t-1)
+	case "<=":
+		p.End = min64(p.End, t)
+	case "=":
+		p.Start, p.End = max64(p.Start, t), min64(p.End, t)
+	default:
+		return fmt.Errorf("operator %s is not supported for time", cmp.Op)
+	}
+	return nil
+}
+
+func (cmp *Comparison) matcher() (*storage.Matcher, error) {
+	types := map[string]storage.MatchType{
+		"=":  storage.MatchEqual,
+		"!=": storage.MatchNotEqual,
+		"=~": storage.MatchRegexp,
+		"!~": storage.MatchNotRegexp,
+	}
+	typ, ok := types[cmp.Op]
+	if !ok {
+		return nil, fmt.Errorf("operator %s is not supported for tags", cmp.Op)
+	}
+
+	var value string
+	switch v := cmp.Value.(type) {
+	case *StringLiteral:
+		value = v.Val
+	case *RegexLiteral:
+		value = v.Val
+	}
+	return storage.NewMatcher(typ, cmp.Name, value)
+}
+
+// compile checks a residual condition and compiles its regexes
+func (p *Plan) compile(expr Expr, input string) error {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if err := p.compile(e.LHS, input); err != nil {
+			return err
+		}
+		return p.compile(e.RHS, input)
+	case *Comparison:
+		if e.Name == "time" {
+			return newParseError(input, e.Pos, "time conditions must be joined to the rest of WHERE with AND")
+		}
+		if re, ok := e.Value.(*RegexLiteral); ok {
+			compiled, err := regexp.Compile("^(?:" + re.Val + ")$")
+			if err != nil {
+				return newParseError(input, e.Pos, "invalid regex: "+err.Error())
+			}
+			p.regexes[e] = compiled
+		}
+	}
+	return nil
+}
+
+// match evaluates the residual filter on a point
+func (p *Plan) match(point *storage.DataPoint) bool {
+	return p.Filter == nil || p.eval(p.Filter, point)
+}
+
+func (p *Plan) eval(expr Expr, point *storage.DataPoint) bool {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Op == "AND" {
+			return p.eval(e.LHS, point) && p.eval(e.RHS, point)
+		}
+		return p.eval(e.LHS, point) || p.eval(e.RHS, point)
+	case *Comparison:
+		if e.Name == "value" {
+			return compareNumber(point.Value, e.Op, e.Value.(*NumberLiteral).Val)
+		}
+		tag := point.Tags[e.Name]
+		switch e.Op {
+		case "=":
+			return tag == e.Value.(*StringLiteral).Val
+		case "!=":
+			return tag != e.Value.(*StringLiteral).Val
+		case "=~":
+			return p.regexes[e].MatchString(tag)
+		case "!~":
+			return !p.regexes[e].MatchString(tag)
+		}
+	}
+	return false
+}
+
+func compareNumber(v float64, op string, x float64) bool {
+	switch op {
+	case "=":
+		return v == x
+	case "!=":
+		return v != x
+	case "<":
+		return v < x
+	case "<=":
+		return v <= x
+	case ">":
+		return v > x
+	case ">=":
+		return v >= x
+	}
+	return false
+}
+
+// Steps describes the plan, one step per line
+func (p *Plan) Steps() []string {
+	steps := []string{fmt.Sprintf("scan %s [%s, %s]", quoteIdent(p.Metric), formatBound(p.Start), formatBound(p.End))}
+	for _, m := range p.Matchers {
+		steps = append(steps, "index "+m.String())
+	}
+	if p.Filter != nil {
+		steps = append(steps, "filter "+p.Filter.String())
+	}
+
+	var groups []string
+	if p.Interval > 0 {
+		groups = append(groups, "time("+formatDuration(time.Duration(p.Interval)*time.Millisecond)+")")
+	}
+	for _, g := range p.GroupBy {
+		groups = append(groups, quoteIdent(g))
+	}
+	if len(groups) > 0 {
+		steps = append(steps, "group by "+strings.Join(groups, ", "))
+	}
+	if p.Aggregate() {
+		fields := make([]string, len(p.Fields))
+		for i, f := range p.Fields {
+			fields[i] = f.String()
+		}
+		steps = append(steps, "aggregate "+strings.Join(fields, ", "))
+	}
+	if p.Interval > 0 {
+		steps = append(steps, "fill "+p.Fill.String())
+	}
+	var transforms []string
+	for _, f := range p.Fields {
+		if f.Transform != nil {
+			transforms = append(transforms, f.String())
+		}
+	}
+	if len(transforms) > 0 {
+		steps = append(steps, "transform "+strings.Join(transforms, ", "))
+	}
+
+	page := "order by time asc"
+	if p.Desc {
+		page = "order by time desc"
+	}
+	if p.Offset > 0 {
+		page += fmt.Sprintf(" offset %d", p.Offset)
+	}
+	if p.Limit > 0 {
+		page += fmt.Sprintf(" limit %d", p.Limit)
+	}
+	return append(steps, page)
+}
+
+// String returns the steps of the plan, one per line
+func (p *Plan) String() string {
+	return strings.Join(p.Steps(), "\n")
+}
+
+func formatBound(t int64) string {
+	switch t {
+	case math.MinInt64:
+		return "-inf"
+	case math.MaxInt64:
+		return "+inf"
+	}
+	return time.UnixMilli(t).UTC().Format(time.RFC3339Nano)
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package sql
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustPlan(t *testing.T, query string, now time.Time) *Plan {
+	t.Helper()
+
+	stmt, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", query, err)
+	}
+	plan, err := NewPlan(stmt, query, now)
+	if err != nil {
+		t.Fatalf("NewPlan(%q) failed: %v", query, err)
+	}
+	return plan
+}
+
+func TestPlanTimeRange(t *testing.T) {
+	now := time.UnixMilli(10_000_000)
+
+	tests := []struct {
+		where      string
+		start, end int64
+	}{
+		{`host = 'a'`, math.MinInt64, math.MaxInt64},
+		{`time > now() - 1h`, 10_000_000 - 3_600_000 + 1, math.MaxInt64},
+		{`time >= 1000 AND time < 2000`, 1000, 1999},
+		{`time >= 1000 AND time <= 5000 AND time > 3000`, 3001, 5000},
+		{`time = 1500`, 1500, 1500},
+		{`time <= now() + 1m`, math.MinInt64, 10_060_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			plan := mustPlan(t, "SELECT value FROM m WHERE "+tt.where, now)
+			if plan.Start != tt.start || plan.End != tt.end {
+				t.Errorf("range = [%d, %d], want [%d, %d]", plan.Start, plan.End, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestPlanPushdown(t *testing.T) {
+	plan := mustPlan(t, `SELECT value FROM m WHERE host = 'a' AND dc =~ /eu-.*/ AND time > 0 AND (value > 5 OR rack = 'r1')`, time.Now())
+
+	if len(plan.Matchers) != 2 || plan.Matchers[0].String() != `host="a"` || plan.Matchers[1].String() != `dc=~"eu-.*"` {
+		t.Errorf("unexpected matchers %v", plan.Matchers)
+	}
+	if plan.Filter == nil || plan.Filter.String() != `(value > 5 OR rack = 'r1')` {
+		t.Errorf("unexpected filter %v", plan.Filter)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		msg   string
+	}{
+		{`SELECT value FROM m WHERE host = 'a' OR time > 0`, "time conditions must be joined to the rest of WHERE with AND"},
+		{`SELECT value FROM m WHERE time > 2000 AND time < 1000`, "time range is empty"},
+		{`SELECT value FROM m WHERE host =~ /(/`, "invalid regex"},
+		{`SELECT value FROM m WHERE value > 1 OR host =~ /(/`, "invalid regex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			stmt, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			_, err = NewPlan(stmt, tt.query, time.Now())
+			var perr *ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected ParseError, got %v", err)
+			}
+			if !strings.Contains(perr.Err, tt.msg) {
+				t.Errorf("error %q does not contain %q", perr.Err, tt.msg)
+			}
+		})
+	}
+}
+
+func TestPlanSteps(t *testing.T) {
+	plan := mustPlan(t, `EXPLAIN SELECT mean(value) FROM temperature WHERE sensor = 'a' AND value > 0 AND time >= 0 AND time < 3600000 GROUP BY time(5m), host FILL(previous) ORDER BY time DESC LIMIT 100`, time.Now())
+
+	want := []string{
+		"scan temperature [1970-01-01T00:00:00Z, 1970-01-01T00:59:59.999Z]",
+		`index sensor="a"`,
+		"filter value > 0",
+		"group by time(5m), host",
+		"aggregate mean(value)",
+		"fill previous",
+		"order by time desc limit 100",
+	}
+	if !plan.Explain || !reflect.DeepEqual(plan.Steps(), want) {
+		t.Errorf("steps = %q, want %q", plan.Steps(), want)
+	}
+}
@@ -0,0 +1,184 @@
+package sql
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// columns returns the names of the columns a transformation outputs in
+// place of a field's column. Detectors add flags and bands after it.
+func (t *Transform) columns(name string) []string {
+	switch t.Func {
+	case "zscore", "mad", "seasonal_residual":
+		return []string{name, name + "_anomaly"}
+	case "holt_winters_bands":
+		return []string{name, name + "_lower", name + "_upper", name + "_anomaly"}
+	case "predict_linear", "holt_winters_forecast":
+		return []string{name, name + "_lower", name + "_upper"}
+	}
+	return []string{name}
+}
+
+// apply replaces column col of series, whose rows are in ascending time
+// order, with the transformation of its values. Null cells are skipped
+// and stay null in every output column, as do rows before a
+// transformation has enough values, such as the first row of difference.
+// Forecasts also fill rows after the last value.
+func (t *Transform) apply(series *Series, col int) {
+	var rows []int
+	var times []int64
+	var vals []float64
+	for i, row := range series.Values {
+		if v, ok := row[col].(float64); ok {
+			rows = append(rows, i)
+			times = append(times, row[0].(int64))
+			vals = append(vals, v)
+		}
+	}
+
+	var outs [][]interface{}
+	var future []futureRow
+	if t.Func == "predict_linear" || t.Func == "holt_winters_forecast" {
+		outs, future = t.forecast(times, vals)
+	} else {
+		outs = t.compute(times, vals)
+	}
+	names := t.columns(series.Columns[col])
+	if extra := len(names) - 1; extra > 0 {
+		columns := append([]string{}, series.Columns[:col]...)
+		columns = append(columns, names...)
+		series.Columns = append(columns, series.Columns[col+1:]...)
+		for i, row := range series.Values {
+			wide := make([]interface{}, len(row)+extra)
+			copy(wide, row[:col])
+			copy(wide[col+len(names):], row[col+1:])
+			series.Values[i] = wide
+		}
+	}
+
+	for j, i := range rows {
+		for k, out := range outs {
+			series.Values[i][col+k] = out[j]
+		}
+	}
+
+	// Future rows go in the empty rows FILL left at their times, or are
+	// added in time order
+	if len(future) == 0 {
+		return
+	}
+	byTime := make(map[int64][]interface{}, len(series.Values))
+	for _, row := range series.Values {
+		byTime[row[0].(int64)] = row
+	}
+	for _, f := range future {
+		row, ok := byTime[f.time]
+		if !ok {
+			row = make([]interface{}, len(series.Columns))
+			row[0] = f.time
+			series.Values = append(series.Values, row)
+		}
+		copy(row[col:], f.cells)
+	}
+	sort.SliceStable(series.Values, func(i, j int) bool {
+		return series.Values[i][0].(int64) < series.Values[j][0].(int64)
+	})
+}
+
+// compute returns the output columns of the transformation of vals, one
+// cell per value: a number, a flag or nil
+func (t *Transform) compute(times []int64, vals []float64) [][]interface{} {
+	switch t.Func {
+	case "zscore":
+		return flagged(zscores(vals), t.threshold(3))
+	case "mad":
+		return flagged(madScores(vals), t.threshold(3.5))
+	case "seasonal_residual":
+		return flagged(seasonalResiduals(vals, t.N), t.threshold(3))
+	case "holt_winters_bands":
+		return holtWintersBands(vals, t.N, t.threshold(3))
+	}
+
+	out := make([]interface{}, len(vals))
+	var (
+		state float64   // running sum or ewma
+		ring  []float64 // last N values of moving_average(field, n)
+		start int       // first value in the window of moving_average(field, 5m)
+	)
+	if t.N > 0 {
+		ring = make([]float64, t.N)
+	}
+
+	for i, v := range vals {
+		switch t.Func {
+		case "moving_average":
+			if t.N > 0 {
+				// state is the sum of the values in the ring
+				slot := i % t.N
+				if i >= t.N {
+					state -= ring[slot]
+				}
+				ring[slot] = v
+				state += v
+				if i+1 >= t.N {
+					out[i] = number(state / float64(t.N))
+				}
+				break
+			}
+			// Keep the values in (ts - period, ts]
+			state += v
+			for times[start] <= times[i]-t.Period.Milliseconds() {
+				state -= vals[start]
+				start++
+			}
+			out[i] = number(state / float64(i-start+1))
+		case "ewma":
+			if i == 0 {
+				state = v
+			} else {
+				state = t.Alpha*v + (1-t.Alpha)*state
+			}
+			out[i] = number(state)
+		case "cumulative_sum":
+			state += v
+			out[i] = number(state)
+		case "difference":
+			if i > 0 {
+				out[i] = number(v - vals[i-1])
+			}
+		case "elapsed":
+			if i > 0 {
+				unit := time.Millisecond
+				if t.Period > 0 {
+					unit = t.Period
+				}
+				out[i] = float64(times[i]-times[i-1]) * float64(time.Millisecond) / float64(unit)
+			}
+		}
+	}
+	return [][]interface{}{out}
+}
+
+// threshold returns the transformation's threshold or def if none was given
+func (t *Transform) threshold(def float64) float64 {
+	if t.Threshold > 0 {
+		return t.Threshold
+	}
+	return def
+}
+
+// flagged returns scores and whether their magnitude exceeds threshold.
+// NaN scores are undefined: both cells are nil.
+func flagged(scores []float64, threshold float64) [][]interface{} {
+	values := make([]interface{}, len(scores))
+	flags := make([]interface{}, len(scores))
+	for i, s := range scores {
+		if math.IsNaN(s) {
+			continue
+		}
+		values[i] = number(s)
+		flags[i] = math.Abs(s) > threshold
+	}
+	return [][]interface{}{values, flags}
+}
@@ -0,0 +1,186 @@
+package statsd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// Aggregator accumulates metrics between flushes.
+//
+// Each flush produces these points (timestamped at the flush time):
+//
+//	counter: <name>.count, <name>.rate (per second)
+//	gauge:   <name> (kept across flushes until updated)
+//	timer:   <name>.count, .rate, .sum, .min, .max, .mean, .median, .p<N>
+//	set:     <name>.count (unique values)
+type Aggregator struct {
+	mu          sync.Mutex
+	percentiles []float64
+
+	counters map[string]*counter
+	gauges   map[string]*gauge
+	timers   map[string]*timer
+	sets     map[string]*set
+}
+
+type series struct {
+	name string
+	tags map[string]string
+}
+
+type counter struct {
+	series
+	value float64
+}
+
+type gauge struct {
+	series
+	value float64
+}
+
+type timer struct {
+	series
+	values []float64
+	count  float64 // sample-rate adjusted
+}
+
+type set struct {
+	series
+	values map[string]struct{}
+}
+
+// NewAggregator creates an aggregator emitting the given timer percentiles
+func NewAggregator(percentiles []float64) *Aggregator {
+	return &Aggregator{
+		percentiles: percentiles,
+		counters:    make(map[string]*counter),
+		gauges:      make(map[string]*gauge),
+		timers:      make(map[string]*timer),
+		sets:        make(map[string]*set),
+	}
+}
+
+// Add records a parsed metric
+func (a *Aggregator) Add(m *Metric) {
+	key := (&storage.DataPoint{Metric: m.Name, Tags: m.Tags}).SeriesKey()
+	s := series{name: m.Name, tags: m.Tags}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch m.Type {
+	case Counter:
+		c, ok := a.counters[key]
+		if !ok {
+			c = &counter{series: s}
+			a.counters[key] = c
+		}
+		c.value += m.Value / m.SampleRate
+
+	case Gauge:
+		g, ok := a.gauges[key]
+		if !ok {
+			g = &gauge{series: s}
+			a.gauges[key] = g
+		}
+		if m.GaugeDelta {
+			g.value += m.Value
+		} else {
+			g.value = m.Value
+		}
+
+	case Timer:
+		t, ok := a.timers[key]
+		if !ok {
+			t = &timer{series: s}
+			a.timers[key] = t
+		}
+		t.values = append(t.values, m.Value)
+		t.count += 1 / m.SampleRate
+
+	case Set:
+		st, ok := a.sets[key]
+		if !ok {
+			st = &set{series: s, values: make(map[string]struct{})}
+			a.sets[key] = st
+		}
+		st.values[m.SetValue] = struct{}{}
+	}
+}
+
+// Flush returns the summary points for the interval and resets counters,
+// timers and sets
+func (a *Aggregator) Flush(now time.Time, interval time.Duration) []*storage.DataPoint {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ts := now.UnixMilli()
+	secs := interval.Seconds()
+	var points []*storage.DataPoint
+
+	emit := func(s series, suffix string, v float64) {
+		points = append(points, &storage.DataPoint{
+			Metric:    s.name + suffix,
+			Timestamp: ts,
+			Value:     v,
+			Tags:      s.tags,
+		})
+	}
+
+	for _, c := range a.counters {
+		emit(c.series, ".count", c.value)
+		emit(c.series, ".rate", c.value/secs)
+	}
+
+	for _, g := range a.gauges {
+		emit(g.series, "", g.value)
+	}
+
+	for _, t := range a.timers {
+		values := t.values
+		sort.Float64s(values)
+
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+
+		emit(t.series, ".count", t.count)
+		emit(t.series, ".rate", t.count/secs)
+		emit(t.series, ".sum", sum)
+		emit(t.series, ".min", values[0])
+		emit(t.series, ".max", values[len(values)-1])
+		emit(t.series, ".mean", sum/float64(len(values)))
+		emit(t.series, ".median", percentile(values, 50))
+		for _, p := range a.percentiles {
+			emit(t.series, ".p"+strconv.FormatFloat(p, 'f', -1, 64), percentile(values, p))
+		}
+	}
+
+	for _, st := range a.sets {
+		emit(st.series, ".count", float64(len(st.values)))
+	}
+
+	a.counters = make(map[string]*counter)
+	a.timers = make(map[string]*timer)
+	a.sets = make(map[string]*set)
+
+	return points
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
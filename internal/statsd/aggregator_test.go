@@ -0,0 +1,111 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+func mustParse(t *testing.T, line string) *Metric {
+	t.Helper()
+	m, err := ParseLine(line)
+	if err != nil {
+		t.Fatalf("ParseLine(%q) failed: %v", line, err)
+	}
+	return m
+}
+
+// byMetric indexes flushed points by metric name plus series key
+func byMetric(points []*storage.DataPoint) map[string]float64 {
+	out := make(map[string]float64)
+	for _, p := range points {
+		out[p.SeriesKey()] = p.Value
+	}
+	return out
+}
+
+func TestAggregatorCounter(t *testing.T) {
+	a := NewAggregator(nil)
+	a.Add(mustParse(t, "requests:1|c"))
+	a.Add(mustParse(t, "requests:2|c|@0.5"))
+
+	got := byMetric(a.Flush(time.Unix(100, 0), 10*time.Second))
+
+	if got["requests.count"] != 5 {
+		t.Errorf("expected count=5, got %f", got["requests.count"])
+	}
+	if got["requests.rate"] != 0.5 {
+		t.Errorf("expected rate=0.5, got %f", got["requests.rate"])
+	}
+
+	// Counters reset after a flush
+	if points := a.Flush(time.Unix(110, 0), 10*time.Second); len(points) != 0 {
+		t.Errorf("expected no points after reset, got %d", len(points))
+	}
+}
+
+func TestAggregatorGauge(t *testing.T) {
+	a := NewAggregator(nil)
+	a.Add(mustParse(t, "temp:20|g"))
+	a.Add(mustParse(t, "temp:+5|g"))
+	a.Add(mustParse(t, "temp:-1|g"))
+
+	got := byMetric(a.Flush(time.Unix(100, 0), 10*time.Second))
+	if got["temp"] != 24 {
+		t.Errorf("expected gauge=24, got %f", got["temp"])
+	}
+
+	// Gauges keep their last value across flushes
+	got = byMetric(a.Flush(time.Unix(110, 0), 10*time.Second))
+	if got["temp"] != 24 {
+		t.Errorf("expected gauge to persist, got %v", got)
+	}
+}
+
+func TestAggregatorTimer(t *testing.T) {
+	a := NewAggregator([]float64{95})
+	for i := 1; i <= 100; i++ {
+		a.Add(&Metric{Name: "latency", Type: Timer, Value: float64(i), SampleRate: 1})
+	}
+
+	points := a.Flush(time.Unix(100, 0), 10*time.Second)
+	got := byMetric(points)
+
+	want := map[string]float64{
+		"latency.count":  100,
+		"latency.rate":   10,
+		"latency.sum":    5050,
+		"latency.min":    1,
+		"latency.max":    100,
+		"latency.mean":   50.5,
+		"latency.median": 50,
+		"latency.p95":    95,
+	}
+	for name, v := range want {
+		if got[name] != v {
+			t.Errorf("%s = %f, want %f", name, got[name], v)
+		}
+	}
+
+	if points[0].Timestamp != 100000 {
+		t.Errorf("expected flush timestamp, got %d", points[0].Timestamp)
+	}
+}
+
+func TestAggregatorSetAndTags(t *testing.T) {
+	a := NewAggregator(nil)
+	a.Add(mustParse(t, "users:alice|s|#app:web"))
+	a.Add(mustParse(t, "users:bob|s|#app:web"))
+	a.Add(mustParse(t, "users:alice|s|#app:web"))
+	a.Add(mustParse(t, "users:carol|s|#app:api"))
+
+	got := byMetric(a.Flush(time.Unix(100, 0), 10*time.Second))
+
+	if got["users.count{app=web}"] != 2 {
+		t.Errorf("expected 2 unique web users, got %v", got)
+	}
+	if got["users.count{app=api}"] != 1 {
+		t.Errorf("expected 1 unique api user, got %v", got)
+	}
+}
@@ -0,0 +1,172 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// maxUDPPacket is the largest datagram accepted by the listener
+const maxUDPPacket = 64 * 1024
+
+// BatchWriter persists a batch of data points
+type BatchWriter interface {
+	WriteBatch(points []*storage.DataPoint) error
+}
+
+// Stats holds listener counters
+type Stats struct {
+	MetricsReceived int64
+	ParseErrors     int64
+	PointsWritten   int64
+	PointsRejected  int64 // refused by the series limits
+	WriteErrors     int64 // failed flushes
+}
+
+// Listener receives StatsD packets over UDP and writes aggregated points
+// to storage every flush interval
+type Listener struct {
+	cfg        *config.StatsDConfig
+	aggregator *Aggregator
+	writer     BatchWriter
+	interval   time.Duration
+
+	conn net.PacketConn
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	stats Stats // accessed via atomic
+}
+
+// NewListener creates a listener. The socket is opened by Start.
+func NewListener(cfg *config.StatsDConfig, writer BatchWriter) *Listener {
+	interval := time.Duration(cfg.FlushInterval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &Listener{
+		cfg:        cfg,
+		aggregator: NewAggregator(cfg.Percentiles),
+		writer:     writer,
+		interval:   interval,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start opens the UDP socket and starts the flush loop
+func (l *Listener) Start() error {
+	conn, err := net.ListenPacket("udp", l.cfg.Address)
+	if err != nil {
+		return err
+	}
+	l.conn = conn
+
+	l.wg.Add(2)
+	go l.readLoop()
+	go l.flushLoop()
+
+	return nil
+}
+
+// Addr returns the bound UDP address
+func (l *Listener) Addr() net.Addr {
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.LocalAddr()
+}
+
+// Stats returns a snapshot of the listener counters
+func (l *Listener) Stats() Stats {
+	return Stats{
+		MetricsReceived: atomic.LoadInt64(&l.stats.MetricsReceived),
+		ParseErrors:     atomic.LoadInt64(&l.stats.ParseErrors),
+		PointsWritten:   atomic.LoadInt64(&l.stats.PointsWritten),
+		PointsRejected:  atomic.LoadInt64(&l.stats.PointsRejected),
+		WriteErrors:     atomic.LoadInt64(&l.stats.WriteErrors),
+	}
+}
+
+// Close stops the listener and writes a final flush
+func (l *Listener) Close() error {
+	close(l.done)
+	if l.conn != nil {
+		l.conn.Close()
+	}
+	l.wg.Wait()
+
+	// The reader has stopped, so nothing is added after this flush
+	l.Flush()
+	return nil
+}
+
+// Flush writes the aggregated points accumulated so far
+func (l *Listener) Flush() {
+	points := l.aggregator.Flush(time.Now(), l.interval)
+	if len(points) == 0 {
+		return
+	}
+
+	rejected, err := storage.SplitWriteError(l.writer.WriteBatch(points))
+	if err != nil {
+		atomic.AddInt64(&l.stats.WriteErrors, 1)
+		return
+	}
+	atomic.AddInt64(&l.stats.PointsWritten, int64(len(points)-rejected))
+	atomic.AddInt64(&l.stats.PointsRejected, int64(rejected))
+}
+
+func (l *Listener) readLoop() {
+	defer l.wg.Done()
+
+	buf := make([]byte, maxUDPPacket)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-l.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			m, err := ParseLine(line)
+			if err != nil {
+				atomic.AddInt64(&l.stats.ParseErrors, 1)
+				continue
+			}
+
+			atomic.AddInt64(&l.stats.MetricsReceived, 1)
+			l.aggregator.Add(m)
+		}
+	}
+}
+
+func (l *Listener) flushLoop() {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.Flush()
+		case <-l.done:
+			return
+		}
+	}
+}
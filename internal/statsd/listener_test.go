@@ -0,0 +1,69 @@
+package statsd
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+	"github.com/Pablo997/pulsardb/pkg/storage"
+)
+
+// memWriter records written points
+type memWriter struct {
+	mu     sync.Mutex
+	points []*storage.DataPoint
+}
+
+func (w *memWriter) WriteBatch(points []*storage.DataPoint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.points = append(w.points, points...)
+	return nil
+}
+
+func TestListener(t *testing.T) {
+	writer := &memWriter{}
+	l := NewListener(&config.StatsDConfig{
+		Address:       "127.0.0.1:0",
+		FlushInterval: 3600,
+	}, writer)
+	if err := l.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("requests:1|c\nrequests:1|c\nbad line\n"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for l.Stats().MetricsReceived+l.Stats().ParseErrors < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for packet")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Close performs the final flush
+	l.Close()
+
+	stats := l.Stats()
+	if stats.MetricsReceived != 2 || stats.ParseErrors != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	var count float64
+	for _, p := range writer.points {
+		if p.Metric == "requests.count" {
+			count = p.Value
+		}
+	}
+	if count != 2 {
+		t.Errorf("expected requests.count=2, got %f", count)
+	}
+}
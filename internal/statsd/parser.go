@@ -0,0 +1,107 @@
+// Package statsd implements a StatsD-compatible UDP listener that
+// aggregates metrics in memory and writes summary points on every flush.
+package statsd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MetricType is the StatsD metric type
+type MetricType string
+
+const (
+	Counter MetricType = "c"
+	Gauge   MetricType = "g"
+	Timer   MetricType = "ms"
+	Set     MetricType = "s"
+)
+
+// Metric is one parsed StatsD sample
+type Metric struct {
+	Name       string
+	Type       MetricType
+	Value      float64
+	SetValue   string  // raw value for sets
+	GaugeDelta bool    // gauge value prefixed with + or -
+	SampleRate float64 // 0 < rate <= 1
+	Tags       map[string]string
+}
+
+// ParseLine parses "name:value|type[|@rate][|#tag:value,...]".
+// Histograms (h) and distributions (d) are treated as timers. DogStatsD
+// tags without a value are stored with the value "true".
+func ParseLine(line string) (*Metric, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("missing metric name in %q", line)
+	}
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("missing metric type in %q", line)
+	}
+
+	m := &Metric{Name: name, SampleRate: 1}
+	raw := fields[0]
+
+	switch fields[1] {
+	case "c":
+		m.Type = Counter
+	case "g":
+		m.Type = Gauge
+	case "ms", "h", "d":
+		m.Type = Timer
+	case "s":
+		m.Type = Set
+	default:
+		return nil, fmt.Errorf("unknown metric type %q", fields[1])
+	}
+
+	if m.Type == Set {
+		if raw == "" {
+			return nil, fmt.Errorf("empty set value in %q", line)
+		}
+		m.SetValue = raw
+	} else {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q", raw)
+		}
+		m.Value = v
+		m.GaugeDelta = m.Type == Gauge && (raw[0] == '+' || raw[0] == '-')
+	}
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid sample rate %q", field)
+			}
+			m.SampleRate = rate
+		case strings.HasPrefix(field, "#"):
+			m.Tags = parseTags(field[1:])
+		default:
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+	}
+
+	return m, nil
+}
+
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(tag, ":")
+		if !ok {
+			v = "true"
+		}
+		tags[k] = v
+	}
+	return tags
+}
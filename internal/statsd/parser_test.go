@@ -0,0 +1,66 @@
+package statsd
+
+import (
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Metric
+	}{
+		{"requests:1|c", Metric{Name: "requests", Type: Counter, Value: 1, SampleRate: 1}},
+		{"requests:2|c|@0.5", Metric{Name: "requests", Type: Counter, Value: 2, SampleRate: 0.5}},
+		{"temp:21.5|g", Metric{Name: "temp", Type: Gauge, Value: 21.5, SampleRate: 1}},
+		{"temp:-2|g", Metric{Name: "temp", Type: Gauge, Value: -2, GaugeDelta: true, SampleRate: 1}},
+		{"latency:320|ms", Metric{Name: "latency", Type: Timer, Value: 320, SampleRate: 1}},
+		{"latency:320|h", Metric{Name: "latency", Type: Timer, Value: 320, SampleRate: 1}},
+		{"users:alice|s", Metric{Name: "users", Type: Set, SetValue: "alice", SampleRate: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			m, err := ParseLine(tt.line)
+			if err != nil {
+				t.Fatalf("ParseLine failed: %v", err)
+			}
+
+			if m.Name != tt.want.Name || m.Type != tt.want.Type || m.Value != tt.want.Value ||
+				m.SetValue != tt.want.SetValue || m.GaugeDelta != tt.want.GaugeDelta || m.SampleRate != tt.want.SampleRate {
+				t.Errorf("got %+v, want %+v", *m, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLineTags(t *testing.T) {
+	m, err := ParseLine("requests:1|c|@0.1|#host:a,env:prod,canary")
+	if err != nil {
+		t.Fatalf("ParseLine failed: %v", err)
+	}
+
+	if m.Tags["host"] != "a" || m.Tags["env"] != "prod" || m.Tags["canary"] != "true" {
+		t.Errorf("unexpected tags: %v", m.Tags)
+	}
+
+	if m.SampleRate != 0.1 {
+		t.Errorf("expected sample rate 0.1, got %f", m.SampleRate)
+	}
+}
+
+func TestParseLineInvalid(t *testing.T) {
+	for _, line := range []string{
+		"requests",
+		":1|c",
+		"requests:1",
+		"requests:abc|c",
+		"requests:1|x",
+		"requests:1|c|@2",
+		"requests:1|c|bogus",
+		"users:|s",
+	} {
+		if _, err := ParseLine(line); err == nil {
+			t.Errorf("ParseLine(%q) should fail", line)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrBudgetExceeded is wrapped by the error of a query that read more
+// points or bytes than its QueryBudget allows
+var ErrBudgetExceeded = errors.New("query budget exceeded")
+
+// QueryBudget caps the points and bytes a query may read. One budget may
+// be shared by all Select calls of a query. Zero limits are unlimited.
+type QueryBudget struct {
+	MaxPoints int64
+	MaxBytes  int64
+
+	points   int64 // accessed via atomic
+	bytes    int64 // accessed via atomic
+	exceeded int32 // accessed via atomic
+}
+
+// NewQueryBudget creates a budget with the given limits
+func NewQueryBudget(maxPoints, maxBytes int64) *QueryBudget {
+	return &QueryBudget{MaxPoints: maxPoints, MaxBytes: maxBytes}
+}
+
+// Charge records that point was read. It fails once a limit is passed.
+func (b *QueryBudget) Charge(point *DataPoint) error {
+	points := atomic.AddInt64(&b.points, 1)
+	if b.MaxPoints > 0 && points > b.MaxPoints {
+		return b.exceed(fmt.Errorf("%w: more than %d points", ErrBudgetExceeded, b.MaxPoints))
+	}
+
+	bytes := atomic.AddInt64(&b.bytes, point.ApproximateSize())
+	if b.MaxBytes > 0 && bytes > b.MaxBytes {
+		return b.exceed(fmt.Errorf("%w: more than %d bytes", ErrBudgetExceeded, b.MaxBytes))
+	}
+	return nil
+}
+
+// Check fails if reading points more would pass MaxPoints. Nothing is
+// charged, so a query can be refused before it reads anything.
+func (b *QueryBudget) Check(points int64) error {
+	if b.MaxPoints > 0 && atomic.LoadInt64(&b.points)+points > b.MaxPoints {
+		return b.exceed(fmt.Errorf("%w: more than %d points", ErrBudgetExceeded, b.MaxPoints))
+	}
+	return nil
+}
+
+// Exceeded reports whether Charge or Check has failed
+func (b *QueryBudget) Exceeded() bool {
+	return atomic.LoadInt32(&b.exceeded) != 0
+}
+
+func (b *QueryBudget) exceed(err error) error {
+	atomic.StoreInt32(&b.exceeded, 1)
+	return err
+}
+
+// Used returns the points and bytes charged so far
+func (b *QueryBudget) Used() (points, bytes int64) {
+	return atomic.LoadInt64(&b.points), atomic.LoadInt64(&b.bytes)
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestQueryBudgetCharge(t *testing.T) {
+	point := &DataPoint{Metric: "cpu", Tags: map[string]string{"host": "a"}}
+	size := point.ApproximateSize()
+
+	tests := []struct {
+		name      string
+		budget    *QueryBudget
+		allowed   int
+		exhausted bool
+	}{
+		{"points", NewQueryBudget(3, 0), 3, true},
+		{"bytes", NewQueryBudget(0, size*2), 2, true},
+		{"unlimited", NewQueryBudget(0, 0), 10, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			n := 0
+			for ; n < 10; n++ {
+				if err = tt.budget.Charge(point); err != nil {
+					break
+				}
+			}
+			if n != tt.allowed {
+				t.Errorf("expected %d points allowed, got %d", tt.allowed, n)
+			}
+			if tt.exhausted && !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("expected ErrBudgetExceeded, got %v", err)
+			}
+			if tt.budget.Exceeded() != tt.exhausted {
+				t.Errorf("expected Exceeded() = %v", tt.exhausted)
+			}
+		})
+	}
+}
+
+func TestQueryBudgetCheck(t *testing.T) {
+	budget := NewQueryBudget(3, 0)
+	budget.Charge(&DataPoint{Metric: "cpu"})
+
+	if err := budget.Check(2); err != nil || budget.Exceeded() {
+		t.Fatalf("expected 2 more points to fit, got %v", err)
+	}
+	if points, _ := budget.Used(); points != 1 {
+		t.Errorf("expected Check to charge nothing, got %d points used", points)
+	}
+	if err := budget.Check(3); !errors.Is(err, ErrBudgetExceeded) || !budget.Exceeded() {
+		t.Errorf("expected ErrBudgetExceeded, got %v", err)
+	}
+}
+
+func TestSelectBudget(t *testing.T) {
+	engine := newSelectTestEngine(t)
+	disk, _ := NewMatcher(MatchRegexp, MetricNameLabel, "disk_.*")
+
+	// The budget is shared, so a second Select continues from the first
+	budget := NewQueryBudget(3, 0)
+	spec := QuerySpec{Matchers: []*Matcher{disk}, Start: 0, End: 5000, Budget: budget}
+
+	it, _ := engine.Select(context.Background(), spec)
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 3 || !errors.Is(it.Err(), ErrBudgetExceeded) {
+		t.Errorf("expected 3 points then ErrBudgetExceeded, got %d, %v", n, it.Err())
+	}
+
+	if points, _ := budget.Used(); points != 4 {
+		t.Errorf("expected 4 points charged, got %d", points)
+	}
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// ErrSeriesLimit is wrapped by the error of a write that would create a
+// series beyond StorageConfig.MaxSeries or MaxSeriesPerMetric
+var ErrSeriesLimit = errors.New("series limit exceeded")
+
+// RejectedPoint is a batch point refused by the series limits
+type RejectedPoint struct {
+	Index int // position in the batch
+	Err   error
+}
+
+// PartialWriteError is returned by WriteBatch when the series limits
+// rejected some points. The other points were written.
+type PartialWriteError struct {
+	Rejected []RejectedPoint // in batch order
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("%d points rejected: %v", len(e.Rejected), e.Rejected[0].Err)
+}
+
+// Unwrap returns the error of the first rejected point, so errors.Is
+// matches ErrSeriesLimit
+func (e *PartialWriteError) Unwrap() error {
+	return e.Rejected[0].Err
+}
+
+// SplitWriteError separates the points refused by the series limits from
+// a WriteBatch error. It returns how many points were refused and the
+// error that remains, which is nil when err is a *PartialWriteError:
+// the other points were written and the batch should not be retried.
+func SplitWriteError(err error) (rejected int, failure error) {
+	var partial *PartialWriteError
+	if errors.As(err, &partial) {
+		return len(partial.Rejected), nil
+	}
+	return 0, err
+}
+
+// CardinalityEntry is the series count of a metric, or the number of
+// distinct values of a tag key
+type CardinalityEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// CardinalityStats reports the active series and their top contributors.
+// Active series are those held in the memtable.
+type CardinalityStats struct {
+	Series             int                `json:"series"`
+	MaxSeries          int                `json:"max_series"`
+	MaxSeriesPerMetric int                `json:"max_series_per_metric"`
+	RejectedPoints     int64              `json:"rejected_points"`
+	TopMetrics         []CardinalityEntry `json:"top_metrics"`  // by series
+	TopTagKeys         []CardinalityEntry `json:"top_tag_keys"` // by distinct values
+}
+
+// admit checks that writing point would not create a series beyond the
+// configured limits. The engine lock must be held so that the check and
+// the insert are not interleaved with other writes.
+func (e *Engine) admit(key string, point *DataPoint) error {
+	err := e.index.admit(key, point.Metric, e.config.MaxSeries, e.config.MaxSeriesPerMetric)
+	if err != nil {
+		atomic.AddInt64(&e.seriesRejected, 1)
+	}
+	return err
+}
+
+// Cardinality returns the active series count with the top n metrics and
+// tag keys. With metric set, only that metric's series are counted. n <= 0
+// returns every entry.
+func (e *Engine) Cardinality(metric string, n int) CardinalityStats {
+	stats := e.index.cardinality(metric, n)
+	stats.MaxSeries = e.config.MaxSeries
+	stats.MaxSeriesPerMetric = e.config.MaxSeriesPerMetric
+	stats.RejectedPoints = atomic.LoadInt64(&e.seriesRejected)
+	return stats
+}
+
+func (idx *seriesIndex) admit(key, metric string, maxSeries, maxPerMetric int) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if _, ok := idx.series[key]; ok {
+		return nil
+	}
+	if maxSeries > 0 && len(idx.series) >= maxSeries {
+		return fmt.Errorf("%w: %d active series (max_series)", ErrSeriesLimit, maxSeries)
+	}
+	if n := len(idx.postings[MetricNameLabel][metric]); maxPerMetric > 0 && n >= maxPerMetric {
+		return fmt.Errorf("%w: metric %q has %d series (max_series_per_metric)", ErrSeriesLimit, metric, n)
+	}
+	return nil
+}
+
+func (idx *seriesIndex) cardinality(metric string, n int) CardinalityStats {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var stats CardinalityStats
+	if metric == "" {
+		stats.Series = len(idx.series)
+		for name, set := range idx.postings[MetricNameLabel] {
+			stats.TopMetrics = append(stats.TopMetrics, CardinalityEntry{name, len(set)})
+		}
+		for key, values := range idx.postings {
+			if key != MetricNameLabel {
+				stats.TopTagKeys = append(stats.TopTagKeys, CardinalityEntry{key, len(values)})
+			}
+		}
+	} else {
+		set := idx.postings[MetricNameLabel][metric]
+		stats.Series = len(set)
+		if len(set) > 0 {
+			stats.TopMetrics = []CardinalityEntry{{metric, len(set)}}
+		}
+		values := make(map[string]map[string]struct{})
+		for s := range set {
+			for k, v := range s.Tags {
+				if values[k] == nil {
+					values[k] = make(map[string]struct{})
+				}
+				values[k][v] = struct{}{}
+			}
+		}
+		for key, vs := range values {
+			stats.TopTagKeys = append(stats.TopTagKeys, CardinalityEntry{key, len(vs)})
+		}
+	}
+
+	stats.TopMetrics = topEntries(stats.TopMetrics, n)
+	stats.TopTagKeys = topEntries(stats.TopTagKeys, n)
+	return stats
+}
+
+// topEntries sorts entries by count, largest first, and keeps n of them
+func topEntries(entries []CardinalityEntry, n int) []CardinalityEntry {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	if entries == nil {
+		entries = []CardinalityEntry{}
+	}
+	return entries
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func newLimitedEngine(t *testing.T, maxSeries, maxPerMetric int) *Engine {
+	t.Helper()
+	cfg := &config.StorageConfig{
+		DataDir:            "./test_data_cardinality",
+		MaxMemoryMB:        128,
+		MaxSeries:          maxSeries,
+		MaxSeriesPerMetric: maxPerMetric,
+	}
+	t.Cleanup(func() { os.RemoveAll(cfg.DataDir) })
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	return engine
+}
+
+func hostPoint(metric, host string) *DataPoint {
+	return &DataPoint{Metric: metric, Timestamp: 1000, Value: 1, Tags: map[string]string{"host": host}}
+}
+
+func TestSeriesLimitPerMetric(t *testing.T) {
+	engine := newLimitedEngine(t, 0, 2)
+
+	for _, host := range []string{"a", "b", "a"} {
+		if err := engine.Write(hostPoint("cpu", host)); err != nil {
+			t.Fatalf("write %s failed: %v", host, err)
+		}
+	}
+
+	err := engine.Write(hostPoint("cpu", "c"))
+	if !errors.Is(err, ErrSeriesLimit) {
+		t.Fatalf("expected ErrSeriesLimit, got %v", err)
+	}
+
+	// Other metrics have their own limit
+	if err := engine.Write(hostPoint("mem", "c")); err != nil {
+		t.Errorf("expected mem write to succeed, got %v", err)
+	}
+
+	points, _ := engine.Query("cpu", 0, 2000)
+	if len(points) != 3 {
+		t.Errorf("expected 3 cpu points, got %d", len(points))
+	}
+}
+
+func TestSeriesLimitBatch(t *testing.T) {
+	engine := newLimitedEngine(t, 2, 0)
+
+	err := engine.WriteBatch([]*DataPoint{
+		hostPoint("cpu", "a"),
+		hostPoint("cpu", "b"),
+		hostPoint("cpu", "c"),
+		hostPoint("cpu", "a"),
+		hostPoint("mem", "a"),
+	})
+
+	var partial *PartialWriteError
+	if !errors.As(err, &partial) {
+		t.Fatalf("expected PartialWriteError, got %v", err)
+	}
+	if !errors.Is(err, ErrSeriesLimit) {
+		t.Errorf("expected error to wrap ErrSeriesLimit")
+	}
+
+	var rejected []int
+	for _, rej := range partial.Rejected {
+		rejected = append(rejected, rej.Index)
+	}
+	if fmt.Sprint(rejected) != "[2 4]" {
+		t.Errorf("expected points [2 4] rejected, got %v", rejected)
+	}
+
+	stats := engine.Cardinality("", 0)
+	if stats.Series != 2 || stats.RejectedPoints != 2 {
+		t.Errorf("expected 2 series and 2 rejected points, got %+v", stats)
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	engine := newLimitedEngine(t, 0, 0)
+	engine.WriteBatch([]*DataPoint{
+		{Metric: "http", Timestamp: 1, Tags: map[string]string{"host": "a", "req": "1"}},
+		{Metric: "http", Timestamp: 1, Tags: map[string]string{"host": "a", "req": "2"}},
+		{Metric: "http", Timestamp: 1, Tags: map[string]string{"host": "a", "req": "3"}},
+		{Metric: "cpu", Timestamp: 1, Tags: map[string]string{"host": "a"}},
+		{Metric: "cpu", Timestamp: 1, Tags: map[string]string{"host": "b"}},
+	})
+
+	stats := engine.Cardinality("", 2)
+	if stats.Series != 5 {
+		t.Errorf("expected 5 series, got %d", stats.Series)
+	}
+	if got := fmt.Sprint(stats.TopMetrics); got != "[{http 3} {cpu 2}]" {
+		t.Errorf("unexpected top metrics %s", got)
+	}
+	if got := fmt.Sprint(stats.TopTagKeys); got != "[{req 3} {host 2}]" {
+		t.Errorf("unexpected top tag keys %s", got)
+	}
+
+	stats = engine.Cardinality("http", 0)
+	if got := fmt.Sprint(stats.TopTagKeys); stats.Series != 3 || got != "[{req 3} {host 1}]" {
+		t.Errorf("unexpected http cardinality %+v", stats)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // DataPoint represents a single time-series data point
@@ -21,6 +22,45 @@ func (dp *DataPoint) Key() string {
 	return dp.Metric
 }
 
+// seriesKeyEscaper escapes the separators of a series key in tag keys and
+// values, so that distinct tag sets never share a key
+var seriesKeyEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `=`, `\=`, `}`, `\}`)
+
+// metricKeyEscaper escapes a metric name, which must not look like the
+// start of a tag list
+var metricKeyEscaper = strings.NewReplacer(`\`, `\\`, `{`, `\{`)
+
+// SeriesKey returns a key identifying the series (metric plus sorted tags),
+// e.g. temperature{room=a,sensor=s1}. Backslash, comma, equals and closing
+// braces in tags, and backslash and opening braces in the metric, are
+// escaped with a backslash.
+func (dp *DataPoint) SeriesKey() string {
+	if len(dp.Tags) == 0 {
+		return metricKeyEscaper.Replace(dp.Metric)
+	}
+
+	keys := make([]string, 0, len(dp.Tags))
+	for k := range dp.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	metricKeyEscaper.WriteString(&sb, dp.Metric)
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		seriesKeyEscaper.WriteString(&sb, k)
+		sb.WriteByte('=')
+		seriesKeyEscaper.WriteString(&sb, dp.Tags[k])
+	}
+	sb.WriteByte('}')
+
+	return sb.String()
+}
+
 // ApproximateSize calculates the approximate memory size of this data point
 func (dp *DataPoint) ApproximateSize() int64 {
 	size := int64(len(dp.Metric)) // metric string
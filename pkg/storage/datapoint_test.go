@@ -187,3 +187,58 @@ func TestDataPointApproximateSizeConsistency(t *testing.T) {
 	}
 }
 
+
+func TestDataPointSeriesKey(t *testing.T) {
+	point := &DataPoint{
+		Metric: "temperature",
+		Tags:   map[string]string{"sensor": "s1", "room": "a"},
+	}
+
+	if got := point.SeriesKey(); got != "temperature{room=a,sensor=s1}" {
+		t.Errorf("SeriesKey() = %q", got)
+	}
+
+	bare := &DataPoint{Metric: "temperature"}
+	if got := bare.SeriesKey(); got != "temperature" {
+		t.Errorf("SeriesKey() without tags = %q", got)
+	}
+}
+
+func TestDataPointSeriesKeyCollisions(t *testing.T) {
+	// Each group would share a key if separators were not escaped
+	groups := [][]*DataPoint{
+		{
+			{Metric: "m", Tags: map[string]string{"a": "x,b=y"}},
+			{Metric: "m", Tags: map[string]string{"a": "x", "b": "y"}},
+		},
+		{
+			{Metric: "m", Tags: map[string]string{"a=b": "c"}},
+			{Metric: "m", Tags: map[string]string{"a": "b=c"}},
+		},
+		{
+			{Metric: "m", Tags: map[string]string{"a": "x}"}},
+			{Metric: "m{a=x}", Tags: nil},
+			{Metric: "m", Tags: map[string]string{"a": "x"}},
+		},
+		{
+			{Metric: "m", Tags: map[string]string{"a": `x\`, "b": "y"}},
+			{Metric: "m", Tags: map[string]string{"a": `x\,b=y`}},
+		},
+	}
+
+	for i, group := range groups {
+		seen := make(map[string]bool)
+		for _, p := range group {
+			key := p.SeriesKey()
+			if seen[key] {
+				t.Errorf("group %d: %v shares key %q", i, p, key)
+			}
+			seen[key] = true
+		}
+	}
+
+	escaped := &DataPoint{Metric: "m{", Tags: map[string]string{"a,": `b}\`}}
+	if got := escaped.SeriesKey(); got != `m\{{a\,=b\}\\}` {
+		t.Errorf("SeriesKey() = %q", got)
+	}
+}
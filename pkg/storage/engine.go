@@ -1,8 +1,10 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/Pablo997/pulsardb/internal/config"
@@ -18,6 +20,21 @@ type Engine struct {
 	
 	// Write-Ahead Log for durability (binary encoding)
 	wal *WAL
+
+	// Live subscriptions fed by Write and WriteBatch
+	subs *subscriptionHub
+
+	// Series and label postings of the memtable, for metadata lookups
+	// and the series limits
+	index          *seriesIndex
+	seriesRejected int64 // points refused by the series limits (atomic)
+
+	// Latest point of each series, kept for one flush after the series'
+	// last write and rebuilt from the WAL on recovery
+	last *lastCache
+
+	// Kind, unit and help of each metric, kept in the data directory
+	metadata *metadataRegistry
 	
 	// TODO: Add SSTable management
 	// TODO: Add compaction
@@ -30,9 +47,18 @@ func NewEngine(cfg *config.StorageConfig) (*Engine, error) {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
+	metadata, err := loadMetadataRegistry(filepath.Join(cfg.DataDir, metadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load metadata: %w", err)
+	}
+
 	e := &Engine{
 		config:   cfg,
 		memTable: NewMemTable(cfg.MaxMemoryMB),
+		subs:     newSubscriptionHub(),
+		index:    newSeriesIndex(),
+		last:     newLastCache(),
+		metadata: metadata,
 	}
 
 	// Initialize WAL if enabled
@@ -64,16 +90,25 @@ func (e *Engine) recoverFromWAL() error {
 		if err := e.memTable.Insert(point); err != nil {
 			return fmt.Errorf("failed to insert point during recovery: %w", err)
 		}
+		key := point.SeriesKey()
+		e.index.add(key, point)
+		e.last.update(key, point)
 	}
 
 	return nil
 }
 
-// Write writes a data point to the storage engine
+// Write writes a data point to the storage engine. A point that would
+// create a series beyond the series limits fails with ErrSeriesLimit.
 func (e *Engine) Write(point *DataPoint) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	key := point.SeriesKey()
+	if err := e.admit(key, point); err != nil {
+		return err
+	}
+
 	// Write to WAL first (if enabled) - binary encoding
 	if e.wal != nil {
 		if err := e.wal.Write(point); err != nil {
@@ -85,6 +120,9 @@ func (e *Engine) Write(point *DataPoint) error {
 	if err := e.memTable.Insert(point); err != nil {
 		return err
 	}
+	e.index.add(key, point)
+	e.last.update(key, point)
+	e.subs.publish(point)
 
 	// Flush if memtable is full (Lazy WAL strategy)
 	if e.memTable.IsFull() {
@@ -96,6 +134,47 @@ func (e *Engine) Write(point *DataPoint) error {
 	return nil
 }
 
+// WriteBatch writes multiple data points while holding the engine lock once.
+// Points before a failing point remain written. Points refused by the
+// series limits are skipped and reported in a *PartialWriteError.
+func (e *Engine) WriteBatch(points []*DataPoint) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var rejected []RejectedPoint
+	for i, point := range points {
+		key := point.SeriesKey()
+		if err := e.admit(key, point); err != nil {
+			rejected = append(rejected, RejectedPoint{Index: i, Err: err})
+			continue
+		}
+
+		if e.wal != nil {
+			if err := e.wal.Write(point); err != nil {
+				return fmt.Errorf("WAL write failed: %w", err)
+			}
+		}
+
+		if err := e.memTable.Insert(point); err != nil {
+			return err
+		}
+		e.index.add(key, point)
+		e.last.update(key, point)
+		e.subs.publish(point)
+
+		if e.memTable.IsFull() {
+			if err := e.flush(); err != nil {
+				return fmt.Errorf("flush failed: %w", err)
+			}
+		}
+	}
+
+	if len(rejected) > 0 {
+		return &PartialWriteError{Rejected: rejected}
+	}
+	return nil
+}
+
 // flush persists memtable and truncates WAL
 func (e *Engine) flush() error {
 	// Flush WAL to disk
@@ -107,8 +186,11 @@ func (e *Engine) flush() error {
 
 	// TODO: Write memtable to SSTable
 	
-	// Clear memtable
+	// Clear memtable. The last-value cache keeps the series of the flushed
+	// memtable and evicts those idle since the previous flush.
+	e.last.retain(e.index.contains)
 	e.memTable.Clear()
+	e.index.clear()
 
 	// Truncate WAL (data is now in SSTable)
 	if e.wal != nil {
@@ -120,15 +202,79 @@ func (e *Engine) flush() error {
 	return nil
 }
 
-// Query queries data points within a time range
+// Query queries data points within a time range. It collects the
+// results of Select.
 func (e *Engine) Query(metric string, start, end int64) ([]*DataPoint, error) {
+	it, err := e.Select(context.Background(), QuerySpec{Metric: metric, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var points []*DataPoint
+	for it.Next() {
+		points = append(points, it.At())
+	}
+	return points, it.Err()
+}
+
+// Count returns the number of points of metric in [start, end] without
+// reading them
+func (e *Engine) Count(metric string, start, end int64) int {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	// TODO: Query memtable and SSTables
-	// TODO: Merge and sort results
-	
-	return e.memTable.Query(metric, start, end), nil
+	return e.memTable.Count(metric, start, end)
+}
+
+// Metrics returns the sorted names of all stored metrics
+func (e *Engine) Metrics() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.memTable.Metrics()
+}
+
+// MetricNames returns the sorted names of metrics with points in
+// [start, end], from the series index
+func (e *Engine) MetricNames(start, end int64) []string {
+	return e.index.values(MetricNameLabel, "", start, end)
+}
+
+// TagKeys returns the sorted tag keys of series with points in
+// [start, end]. An empty metric covers all metrics.
+func (e *Engine) TagKeys(metric string, start, end int64) []string {
+	return e.index.keys(metric, start, end)
+}
+
+// TagValues returns the sorted values of tag key in series with points
+// in [start, end]. An empty metric covers all metrics.
+func (e *Engine) TagValues(key, metric string, start, end int64) []string {
+	return e.index.values(key, metric, start, end)
+}
+
+// Series returns the series matching all matchers with points in
+// [start, end], sorted by series key
+func (e *Engine) Series(matchers []*Matcher, start, end int64) []SeriesInfo {
+	return e.index.match(matchers, start, end)
+}
+
+// Last returns the latest point of every series matching all matchers,
+// sorted by series key. It is served from the last-value cache, which
+// covers series written since the flush before last.
+func (e *Engine) Last(matchers []*Matcher) []*DataPoint {
+	return e.last.match(matchers)
+}
+
+// Subscribe returns a subscription to points written from now on.
+// The caller must Close it when done.
+func (e *Engine) Subscribe(opts SubscribeOptions) *Subscription {
+	return e.subs.add(opts)
+}
+
+// Subscriptions returns the number of active subscriptions
+func (e *Engine) Subscriptions() int {
+	return e.subs.len()
 }
 
 // Close closes the storage engine
@@ -136,6 +282,9 @@ func (e *Engine) Close() error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	// End live subscriptions
+	e.subs.closeAll()
+
 	// Flush remaining data
 	if err := e.flush(); err != nil {
 		return fmt.Errorf("final flush failed: %w", err)
@@ -260,3 +260,45 @@ func BenchmarkEngineQuery(b *testing.B) {
 	}
 }
 
+
+func TestEngineWriteBatch(t *testing.T) {
+	cfg := &config.StorageConfig{
+		DataDir:     "./test_data_batch",
+		MaxMemoryMB: 128,
+		WALEnabled:  true,
+		WALPath:     "./test_data_batch/wal.log",
+	}
+	defer os.RemoveAll(cfg.DataDir)
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	points := []*DataPoint{
+		{Metric: "cpu", Timestamp: 1000, Value: 10.0},
+		{Metric: "cpu", Timestamp: 2000, Value: 20.0},
+		{Metric: "memory", Timestamp: 1000, Value: 50.0},
+	}
+
+	if err := engine.WriteBatch(points); err != nil {
+		t.Fatalf("WriteBatch failed: %v", err)
+	}
+
+	results, _ := engine.Query("cpu", 0, 5000)
+	if len(results) != 2 {
+		t.Errorf("expected 2 cpu points, got %d", len(results))
+	}
+
+	// Batched points must be in the WAL too
+	engine.wal.Flush()
+	recovered, err := Recover(cfg.WALPath)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(recovered) != 3 {
+		t.Errorf("expected 3 WAL entries, got %d", len(recovered))
+	}
+
+	engine.Close()
+}
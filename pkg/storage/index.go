@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// SeriesInfo describes an indexed series
+type SeriesInfo struct {
+	Metric  string            `json:"metric"`
+	Tags    map[string]string `json:"tags,omitempty"`
+	MinTime int64             `json:"min_time"` // oldest point, milliseconds
+	MaxTime int64             `json:"max_time"` // newest point, milliseconds
+}
+
+// overlaps reports whether the series has points that may fall in [start, end]
+func (s *SeriesInfo) overlaps(start, end int64) bool {
+	return s.MaxTime >= start && s.MinTime <= end
+}
+
+// seriesIndex maps series keys and label values to the series stored in
+// the memtable, so metadata lookups do not scan points
+type seriesIndex struct {
+	mu     sync.RWMutex
+	series map[string]*SeriesInfo // series key -> series
+	// postings lists the series of each label value; the metric name is
+	// indexed under MetricNameLabel
+	postings map[string]map[string]map[*SeriesInfo]struct{}
+}
+
+func newSeriesIndex() *seriesIndex {
+	return &seriesIndex{
+		series:   make(map[string]*SeriesInfo),
+		postings: make(map[string]map[string]map[*SeriesInfo]struct{}),
+	}
+}
+
+// add indexes point under its series key and reports whether the series
+// is new
+func (idx *seriesIndex) add(key string, point *DataPoint) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if s, ok := idx.series[key]; ok {
+		if point.Timestamp < s.MinTime {
+			s.MinTime = point.Timestamp
+		}
+		if point.Timestamp > s.MaxTime {
+			s.MaxTime = point.Timestamp
+		}
+		return false
+	}
+
+	s := &SeriesInfo{
+		Metric:  point.Metric,
+		Tags:    point.Tags,
+		MinTime: point.Timestamp,
+		MaxTime: point.Timestamp,
+	}
+	idx.series[key] = s
+	idx.post(MetricNameLabel, point.Metric, s)
+	for k, v := range point.Tags {
+		idx.post(k, v, s)
+	}
+	return true
+}
+
+func (idx *seriesIndex) post(name, value string, s *SeriesInfo) {
+	values, ok := idx.postings[name]
+	if !ok {
+		values = make(map[string]map[*SeriesInfo]struct{})
+		idx.postings[name] = values
+	}
+	set, ok := values[value]
+	if !ok {
+		set = make(map[*SeriesInfo]struct{})
+		values[value] = set
+	}
+	set[s] = struct{}{}
+}
+
+// contains reports whether the series key is indexed
+func (idx *seriesIndex) contains(key string) bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	_, ok := idx.series[key]
+	return ok
+}
+
+func (idx *seriesIndex) clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.series = make(map[string]*SeriesInfo)
+	idx.postings = make(map[string]map[string]map[*SeriesInfo]struct{})
+}
+
+// values returns the sorted values of label that have a series in
+// [start, end]. With metric set, only that metric's series count.
+func (idx *seriesIndex) values(label, metric string, start, end int64) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	found := make(map[string]struct{})
+	if metric != "" {
+		for s := range idx.postings[MetricNameLabel][metric] {
+			if v, ok := s.label(label); ok && s.overlaps(start, end) {
+				found[v] = struct{}{}
+			}
+		}
+	} else {
+		for v, set := range idx.postings[label] {
+			for s := range set {
+				if s.overlaps(start, end) {
+					found[v] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+	return sortedSet(found)
+}
+
+// keys returns the sorted tag keys of series in [start, end]. With metric
+// set, only that metric's series count.
+func (idx *seriesIndex) keys(metric string, start, end int64) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	found := make(map[string]struct{})
+	if metric != "" {
+		for s := range idx.postings[MetricNameLabel][metric] {
+			if !s.overlaps(start, end) {
+				continue
+			}
+			for k := range s.Tags {
+				found[k] = struct{}{}
+			}
+		}
+	} else {
+		for k, values := range idx.postings {
+			if k == MetricNameLabel {
+				continue
+			}
+		search:
+			for _, set := range values {
+				for s := range set {
+					if s.overlaps(start, end) {
+						found[k] = struct{}{}
+						break search
+					}
+				}
+			}
+		}
+	}
+	return sortedSet(found)
+}
+
+// match returns the series in [start, end] that satisfy all matchers,
+// sorted by metric and tags
+func (idx *seriesIndex) match(matchers []*Matcher, start, end int64) []SeriesInfo {
+	type keyed struct {
+		key  string
+		info SeriesInfo
+	}
+	var found []keyed
+	idx.each(matchers, start, end, func(s *SeriesInfo) {
+		point := &DataPoint{Metric: s.Metric, Tags: s.Tags}
+		found = append(found, keyed{point.SeriesKey(), *s})
+	})
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].key < found[j].key
+	})
+	result := make([]SeriesInfo, len(found))
+	for i, f := range found {
+		result[i] = f.info
+	}
+	return result
+}
+
+// countByMetric returns the number of matching series of each metric
+func (idx *seriesIndex) countByMetric(matchers []*Matcher, start, end int64) map[string]int {
+	counts := make(map[string]int)
+	idx.each(matchers, start, end, func(s *SeriesInfo) {
+		counts[s.Metric]++
+	})
+	return counts
+}
+
+// each calls fn for the series matching all matchers in [start, end].
+// The candidates are narrowed by the smallest equality posting.
+func (idx *seriesIndex) each(matchers []*Matcher, start, end int64, fn func(*SeriesInfo)) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var candidates map[*SeriesInfo]struct{}
+	narrowed := false
+	for _, m := range matchers {
+		if m.Type != MatchEqual || m.Value == "" {
+			continue
+		}
+		set := idx.postings[m.Name][m.Value]
+		if !narrowed || len(set) < len(candidates) {
+			candidates, narrowed = set, true
+		}
+	}
+
+	check := func(s *SeriesInfo) {
+		if s.overlaps(start, end) && MatchPoint(&DataPoint{Metric: s.Metric, Tags: s.Tags}, matchers) {
+			fn(s)
+		}
+	}
+	if narrowed {
+		for s := range candidates {
+			check(s)
+		}
+	} else {
+		for _, s := range idx.series {
+			check(s)
+		}
+	}
+}
+
+// label returns the value of a tag, or the metric for MetricNameLabel
+func (s *SeriesInfo) label(name string) (string, bool) {
+	if name == MetricNameLabel {
+		return s.Metric, true
+	}
+	v, ok := s.Tags[name]
+	return v, ok
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	out := make([]string, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
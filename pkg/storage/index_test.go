@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func TestEngineMetadataLookups(t *testing.T) {
+	engine := newSelectTestEngine(t)
+	all := []int64{math.MinInt64, math.MaxInt64}
+
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"metrics", engine.MetricNames(all[0], all[1]), []string{"cpu", "disk_read", "disk_write"}},
+		{"metrics in range", engine.MetricNames(2500, 3500), []string{"cpu", "disk_read"}},
+		{"metrics after", engine.MetricNames(3500, 5000), []string{"disk_write"}},
+		{"tag keys", engine.TagKeys("", all[0], all[1]), []string{"host"}},
+		{"tag keys of missing metric", engine.TagKeys("mem", all[0], all[1]), []string{}},
+		{"tag values", engine.TagValues("host", "", all[0], all[1]), []string{"a", "b"}},
+		{"tag values of metric", engine.TagValues("host", "cpu", all[0], all[1]), []string{"a"}},
+		{"tag values in range", engine.TagValues("host", "disk_read", 2000, 2500), []string{"b"}},
+		{"name values", engine.TagValues(MetricNameLabel, "", 0, 1500), []string{"disk_write"}},
+	}
+	for _, tt := range tests {
+		if fmt.Sprint(tt.got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.got)
+		}
+	}
+}
+
+func TestEngineSeries(t *testing.T) {
+	engine := newSelectTestEngine(t)
+	disk, _ := NewMatcher(MatchRegexp, MetricNameLabel, "disk_.*")
+	hostA, _ := NewMatcher(MatchEqual, "host", "a")
+	notB, _ := NewMatcher(MatchNotEqual, "host", "b")
+
+	tests := []struct {
+		name       string
+		matchers   []*Matcher
+		start, end int64
+		want       []string
+	}{
+		{"all", nil, math.MinInt64, math.MaxInt64, []string{"cpu", "disk_read", "disk_read", "disk_write", "disk_write"}},
+		{"regexp and tag", []*Matcher{disk, hostA}, math.MinInt64, math.MaxInt64, []string{"disk_read", "disk_write"}},
+		{"negative", []*Matcher{disk, notB}, 0, 1000, []string{"disk_write"}},
+		{"no match", []*Matcher{hostA}, 5000, 6000, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range engine.Series(tt.matchers, tt.start, tt.end) {
+			got = append(got, s.Metric)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	series := engine.Series([]*Matcher{hostA, disk}, math.MinInt64, math.MaxInt64)
+	if series[0].MinTime != 3000 || series[0].MaxTime != 3000 {
+		t.Errorf("expected disk_read{host=a} to span 3000-3000, got %+v", series[0])
+	}
+}
+
+func TestSeriesIndexRebuiltFromWAL(t *testing.T) {
+	cfg := &config.StorageConfig{
+		DataDir:     "./test_data_index",
+		WALEnabled:  true,
+		WALPath:     "./test_data_index/wal.log",
+		MaxMemoryMB: 128,
+	}
+	defer os.RemoveAll(cfg.DataDir)
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.Write(&DataPoint{Metric: "mem", Timestamp: 1000, Value: 1, Tags: map[string]string{"host": "a"}})
+
+	// Simulate a crash: the WAL is synced but the memtable never flushed
+	if err := engine.wal.Flush(); err != nil {
+		t.Fatalf("WAL flush failed: %v", err)
+	}
+	defer engine.wal.Close()
+
+	engine, err = NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.Close()
+
+	if got := engine.TagValues("host", "mem", math.MinInt64, math.MaxInt64); fmt.Sprint(got) != "[a]" {
+		t.Errorf("expected recovered tag values [a], got %v", got)
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import "context"
+
+// Order is the timestamp order of an iterator
+type Order int
+
+const (
+	// Ascending returns the oldest points first
+	Ascending Order = iota
+	// Descending returns the newest points first
+	Descending
+)
+
+// iteratorBlockSize is the number of points an iterator reads at a time
+const iteratorBlockSize = 1024
+
+// IteratorOptions selects the points of one metric
+type IteratorOptions struct {
+	Start  int64 // inclusive, milliseconds
+	End    int64 // inclusive, milliseconds
+	Order  Order
+	Offset int         // points to skip before the first one returned
+	Stats  *QueryStats // optional; records the blocks and points read
+}
+
+// PointIterator returns the points of one metric in timestamp order. It
+// reads the memtable in blocks and holds no lock between calls, so a long
+// iteration does not block writes. Points written during iteration are
+// returned if they sort after the current position.
+type PointIterator struct {
+	ctx    context.Context
+	engine *Engine
+	metric string
+	opts   IteratorOptions
+
+	block []*DataPoint
+	idx   int
+	cur   *DataPoint
+	done  bool
+	err   error
+
+	// Position: bound is the timestamp of the last point returned (or the
+	// range start) and skip the points already returned at that timestamp
+	bound int64
+	skip  int
+}
+
+// Iterator returns an iterator over metric's points in [opts.Start,
+// opts.End]. It stops before reading a block once ctx is done; see Err.
+func (e *Engine) Iterator(ctx context.Context, metric string, opts IteratorOptions) *PointIterator {
+	if opts.Stats != nil {
+		e.mu.RLock()
+		opts.Stats.addSource(e.memtableSource(metric, opts.Start, opts.End, nil))
+		e.mu.RUnlock()
+	}
+	return e.iterator(ctx, metric, opts)
+}
+
+// iterator returns an iterator that stops before reading a block once
+// ctx is done
+func (e *Engine) iterator(ctx context.Context, metric string, opts IteratorOptions) *PointIterator {
+	bound := opts.Start
+	if opts.Order == Descending {
+		bound = opts.End
+	}
+	return &PointIterator{
+		ctx:    ctx,
+		engine: e,
+		metric: metric,
+		opts:   opts,
+		bound:  bound,
+		skip:   opts.Offset,
+	}
+}
+
+// Next advances to the next point and reports whether there is one
+func (it *PointIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	if it.idx >= len(it.block) {
+		if err := it.ctx.Err(); err != nil {
+			it.done, it.cur, it.err = true, nil, err
+			return false
+		}
+
+		var before int
+		it.block, before = it.fetch()
+		it.idx = 0
+		if len(it.block) == 0 {
+			it.done, it.cur = true, nil
+			return false
+		}
+		// An offset may land inside a run of equal timestamps
+		if ts := it.block[0].Timestamp; ts != it.bound {
+			it.bound, it.skip = ts, before
+		}
+	}
+
+	it.cur = it.block[it.idx]
+	it.idx++
+
+	if it.cur.Timestamp == it.bound {
+		it.skip++
+	} else {
+		it.bound, it.skip = it.cur.Timestamp, 1
+	}
+	return true
+}
+
+// At returns the current point
+func (it *PointIterator) At() *DataPoint {
+	return it.cur
+}
+
+// Err returns the context error if iteration stopped early
+func (it *PointIterator) Err() error {
+	return it.err
+}
+
+// Position returns where the iteration stands: the timestamp of the last
+// point returned and how many points at that timestamp were returned.
+// Passing them as Start (End when descending) and Offset resumes after
+// the last point.
+func (it *PointIterator) Position() (int64, int) {
+	return it.bound, it.skip
+}
+
+// fetch reads the block after the current position
+func (it *PointIterator) fetch() ([]*DataPoint, int) {
+	start, end := it.bound, it.opts.End
+	desc := it.opts.Order == Descending
+	if desc {
+		start, end = it.opts.Start, it.bound
+	}
+
+	it.engine.mu.RLock()
+	block, before := it.engine.memTable.Block(it.metric, start, end, it.skip, iteratorBlockSize, desc)
+	it.engine.mu.RUnlock()
+
+	if len(block) > 0 {
+		it.opts.Stats.addBlock(len(block))
+	}
+	return block, before
+}
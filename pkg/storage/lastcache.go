@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// lastCache holds the latest point of every recent series, so
+// latest-value reads do not scan the memtable. Points survive one flush:
+// a flush keeps the series that had points in the flushed memtable and
+// evicts the rest. Since the series index admits at most the series
+// limits per memtable, the cache holds at most twice the limits.
+type lastCache struct {
+	mu      sync.RWMutex
+	metrics map[string]map[string]*DataPoint // metric -> series key -> point
+}
+
+func newLastCache() *lastCache {
+	return &lastCache{metrics: make(map[string]map[string]*DataPoint)}
+}
+
+// update records point as the latest of its series unless the cache
+// already holds a newer one. A point at the same timestamp replaces the
+// cached one, matching last-write-wins.
+func (c *lastCache) update(key string, point *DataPoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	series := c.metrics[point.Metric]
+	if series == nil {
+		series = make(map[string]*DataPoint)
+		c.metrics[point.Metric] = series
+	}
+	if cur, ok := series[key]; ok && cur.Timestamp > point.Timestamp {
+		return
+	}
+	series[key] = point
+}
+
+// retain evicts the series for which keep returns false
+func (c *lastCache) retain(keep func(key string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for metric, series := range c.metrics {
+		for key := range series {
+			if !keep(key) {
+				delete(series, key)
+			}
+		}
+		if len(series) == 0 {
+			delete(c.metrics, metric)
+		}
+	}
+}
+
+// len returns the number of cached series
+func (c *lastCache) len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	n := 0
+	for _, series := range c.metrics {
+		n += len(series)
+	}
+	return n
+}
+
+// match returns the latest point of every series matching all matchers,
+// sorted by series key. An equality matcher on the metric name limits the
+// scan to that metric.
+func (c *lastCache) match(matchers []*Matcher) []*DataPoint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	type entry struct {
+		key   string
+		point *DataPoint
+	}
+	var found []entry
+	scan := func(series map[string]*DataPoint) {
+		for key, point := range series {
+			if MatchPoint(point, matchers) {
+				found = append(found, entry{key, point})
+			}
+		}
+	}
+
+	if metric, ok := metricEquals(matchers); ok {
+		scan(c.metrics[metric])
+	} else {
+		for _, series := range c.metrics {
+			scan(series)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].key < found[j].key })
+	points := make([]*DataPoint, len(found))
+	for i, e := range found {
+		points[i] = e.point
+	}
+	return points
+}
+
+// metricEquals returns the metric selected by an equality matcher on
+// MetricNameLabel, if there is one
+func metricEquals(matchers []*Matcher) (string, bool) {
+	for _, m := range matchers {
+		if m.Name == MetricNameLabel && m.Type == MatchEqual {
+			return m.Value, true
+		}
+	}
+	return "", false
+}
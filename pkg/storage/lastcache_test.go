@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func lastValues(points []*DataPoint) string {
+	var s string
+	for _, p := range points {
+		s += fmt.Sprintf("%s@%d=%g ", p.SeriesKey(), p.Timestamp, p.Value)
+	}
+	return s
+}
+
+func TestEngineLast(t *testing.T) {
+	engine, err := NewEngine(&config.StorageConfig{DataDir: t.TempDir(), MaxMemoryMB: 128})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.Close()
+
+	a := map[string]string{"host": "a"}
+	b := map[string]string{"host": "b"}
+	engine.WriteBatch([]*DataPoint{
+		{Metric: "cpu", Timestamp: 1000, Value: 1, Tags: a},
+		{Metric: "cpu", Timestamp: 3000, Value: 3, Tags: a},
+		{Metric: "cpu", Timestamp: 2000, Value: 2, Tags: a}, // out of order
+		{Metric: "cpu", Timestamp: 1000, Value: 10, Tags: b},
+		{Metric: "mem", Timestamp: 5000, Value: 50, Tags: a},
+	})
+	engine.Write(&DataPoint{Metric: "cpu", Timestamp: 1000, Value: 11, Tags: b}) // same timestamp wins
+
+	host, _ := NewMatcher(MatchEqual, "host", "a")
+	cpu, _ := NewMatcher(MatchEqual, MetricNameLabel, "cpu")
+	named, _ := NewMatcher(MatchRegexp, MetricNameLabel, ".+")
+
+	tests := []struct {
+		name     string
+		matchers []*Matcher
+		want     string
+	}{
+		{"metric", []*Matcher{cpu}, "cpu{host=a}@3000=3 cpu{host=b}@1000=11 "},
+		{"metric and tag", []*Matcher{cpu, host}, "cpu{host=a}@3000=3 "},
+		{"all metrics", []*Matcher{named, host}, "cpu{host=a}@3000=3 mem{host=a}@5000=50 "},
+	}
+	for _, tt := range tests {
+		if got := lastValues(engine.Last(tt.matchers)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Flushed points stay the latest of their series
+	engine.mu.Lock()
+	engine.flush()
+	engine.mu.Unlock()
+	if got := lastValues(engine.Last([]*Matcher{cpu, host})); got != "cpu{host=a}@3000=3 " {
+		t.Errorf("after flush: got %q", got)
+	}
+}
+
+func TestEngineLastEviction(t *testing.T) {
+	engine, err := NewEngine(&config.StorageConfig{DataDir: t.TempDir(), MaxMemoryMB: 128, MaxSeries: 3})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.Close()
+
+	flush := func() {
+		engine.mu.Lock()
+		defer engine.mu.Unlock()
+		if err := engine.flush(); err != nil {
+			t.Fatalf("flush failed: %v", err)
+		}
+	}
+
+	// A new set of series between every flush, as with an unbounded tag
+	for gen := 0; gen < 10; gen++ {
+		for i := 0; i < 3; i++ {
+			id := fmt.Sprintf("%d-%d", gen, i)
+			if err := engine.Write(&DataPoint{Metric: "req", Timestamp: int64(gen), Value: 1, Tags: map[string]string{"id": id}}); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		if n := engine.last.len(); n > 2*3 {
+			t.Fatalf("generation %d: cache holds %d series, above twice max_series", gen, n)
+		}
+		flush()
+	}
+
+	// Series of the last flushed memtable are kept, older ones evicted
+	id, _ := NewMatcher(MatchRegexp, "id", "[0-9]-.*")
+	if got := len(engine.Last([]*Matcher{id})); got != 3 {
+		t.Errorf("expected the 3 series of the last generation, got %d", got)
+	}
+	flush()
+	if got := len(engine.Last([]*Matcher{id})); got != 0 {
+		t.Errorf("expected idle series to be evicted, got %d", got)
+	}
+}
+
+func TestEngineLastRecovery(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.StorageConfig{
+		DataDir:     dir,
+		MaxMemoryMB: 128,
+		WALEnabled:  true,
+		WALPath:     filepath.Join(dir, "wal.log"),
+	}
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	engine.WriteBatch([]*DataPoint{
+		{Metric: "temp", Timestamp: 2000, Value: 22},
+		{Metric: "temp", Timestamp: 1000, Value: 21},
+	})
+	engine.wal.Flush()
+	engine.wal.Close()
+
+	recovered, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer recovered.Close()
+
+	temp, _ := NewMatcher(MatchEqual, MetricNameLabel, "temp")
+	if got := lastValues(recovered.Last([]*Matcher{temp})); got != "temp@2000=22 " {
+		t.Errorf("got %q, want the newest WAL point", got)
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// MetricNameLabel is the pseudo tag used by matchers to select the metric name
+const MetricNameLabel = "__name__"
+
+// MatchType is the comparison performed by a Matcher
+type MatchType int
+
+const (
+	MatchEqual     MatchType = iota // =
+	MatchNotEqual                   // !=
+	MatchRegexp                     // =~
+	MatchNotRegexp                  // !~
+)
+
+// String returns the operator for the match type
+func (t MatchType) String() string {
+	switch t {
+	case MatchEqual:
+		return "="
+	case MatchNotEqual:
+		return "!="
+	case MatchRegexp:
+		return "=~"
+	case MatchNotRegexp:
+		return "!~"
+	}
+	return "?"
+}
+
+// Matcher selects points by tag value (or metric name via MetricNameLabel)
+type Matcher struct {
+	Type  MatchType
+	Name  string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// NewMatcher creates a matcher. Regular expressions are fully anchored,
+// following Prometheus semantics.
+func NewMatcher(t MatchType, name, value string) (*Matcher, error) {
+	m := &Matcher{Type: t, Name: name, Value: value}
+
+	switch t {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", value, err)
+		}
+		m.re = re
+	default:
+		return nil, fmt.Errorf("unknown match type %d", t)
+	}
+
+	return m, nil
+}
+
+// Matches reports whether a label value satisfies the matcher.
+// A missing tag is treated as the empty string.
+func (m *Matcher) Matches(v string) bool {
+	switch m.Type {
+	case MatchEqual:
+		return v == m.Value
+	case MatchNotEqual:
+		return v != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(v)
+	case MatchNotRegexp:
+		return !m.re.MatchString(v)
+	}
+	return false
+}
+
+// String returns the matcher in selector syntax, e.g. sensor=~"a.*"
+func (m *Matcher) String() string {
+	return fmt.Sprintf("%s%s%q", m.Name, m.Type, m.Value)
+}
+
+// MatchPoint reports whether a data point satisfies all matchers
+func MatchPoint(point *DataPoint, matchers []*Matcher) bool {
+	for _, m := range matchers {
+		v := point.Tags[m.Name]
+		if m.Name == MetricNameLabel {
+			v = point.Metric
+		}
+		if !m.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
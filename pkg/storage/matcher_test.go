@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"testing"
+)
+
+func TestMatcherMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   MatchType
+		value string
+		input string
+		want  bool
+	}{
+		{"equal match", MatchEqual, "a", "a", true},
+		{"equal mismatch", MatchEqual, "a", "b", false},
+		{"not equal", MatchNotEqual, "a", "b", true},
+		{"regex match", MatchRegexp, "sensor[12]", "sensor1", true},
+		{"regex anchored", MatchRegexp, "sensor", "sensor1", false},
+		{"not regex", MatchNotRegexp, "sensor.*", "pump1", true},
+		{"empty matches missing", MatchEqual, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewMatcher(tt.typ, "sensor", tt.value)
+			if err != nil {
+				t.Fatalf("NewMatcher failed: %v", err)
+			}
+			if got := m.Matches(tt.input); got != tt.want {
+				t.Errorf("Matches(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewMatcherInvalidRegex(t *testing.T) {
+	if _, err := NewMatcher(MatchRegexp, "sensor", "("); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+}
+
+func TestMatchPoint(t *testing.T) {
+	point := &DataPoint{
+		Metric: "temperature",
+		Tags:   map[string]string{"sensor": "sensor1"},
+	}
+
+	name, _ := NewMatcher(MatchEqual, MetricNameLabel, "temperature")
+	sensor, _ := NewMatcher(MatchRegexp, "sensor", "sensor.*")
+	missing, _ := NewMatcher(MatchNotEqual, "room", "")
+
+	if !MatchPoint(point, []*Matcher{name, sensor}) {
+		t.Error("expected point to match")
+	}
+
+	if MatchPoint(point, []*Matcher{name, missing}) {
+		t.Error("expected missing tag to fail != \"\" matcher")
+	}
+}
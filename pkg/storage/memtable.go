@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sort"
 	"sync"
 )
 
@@ -10,6 +11,11 @@ type MemTable struct {
 	mu        sync.RWMutex
 	data      map[string][]*DataPoint // metric -> []DataPoint
 	size      int64                   // approximate size in bytes
+
+	// late holds points older than the newest point of their metric, in
+	// arrival order. They are merged into data by the next read, so a
+	// backfill costs one merge instead of shifting data for every point.
+	late map[string][]*DataPoint
 }
 
 // NewMemTable creates a new memtable
@@ -17,6 +23,7 @@ func NewMemTable(maxSizeMB int) *MemTable {
 	return &MemTable{
 		maxSizeMB: maxSizeMB,
 		data:      make(map[string][]*DataPoint),
+		late:      make(map[string][]*DataPoint),
 	}
 }
 
@@ -25,8 +32,15 @@ func (mt *MemTable) Insert(point *DataPoint) error {
 	mt.mu.Lock()
 	defer mt.mu.Unlock()
 
+	// Keep each metric sorted by timestamp. In-order writes append;
+	// late points wait in the late buffer until the next read.
 	metric := point.Metric
-	mt.data[metric] = append(mt.data[metric], point)
+	points := mt.data[metric]
+	if n := len(points); n == 0 || points[n-1].Timestamp <= point.Timestamp {
+		mt.data[metric] = append(points, point)
+	} else {
+		mt.late[metric] = append(mt.late[metric], point)
+	}
 	
 	// Track memory usage accurately
 	mt.size += point.ApproximateSize()
@@ -34,32 +48,145 @@ func (mt *MemTable) Insert(point *DataPoint) error {
 	return nil
 }
 
-// Query queries the memtable for data points
+// Query returns the points of metric in [start, end], sorted by timestamp
 func (mt *MemTable) Query(metric string, start, end int64) []*DataPoint {
+	mt.settle(metric)
 	mt.mu.RLock()
 	defer mt.mu.RUnlock()
 
-	points, exists := mt.data[metric]
-	if !exists {
+	lo, hi := mt.bounds(metric, start, end)
+	if lo >= hi {
 		return nil
 	}
 
-	// Pre-allocate result slice with estimated capacity
-	// This reduces allocations when we have many matching points
-	estimatedSize := len(points) / 2 // heuristic: usually query half the data
-	if estimatedSize < 10 {
-		estimatedSize = 10
+	result := make([]*DataPoint, hi-lo)
+	copy(result, mt.data[metric][lo:hi])
+	return result
+}
+
+// Count returns the number of points of metric in [start, end]
+func (mt *MemTable) Count(metric string, start, end int64) int {
+	mt.settle(metric)
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	lo, hi := mt.bounds(metric, start, end)
+	if lo >= hi {
+		return 0
 	}
-	result := make([]*DataPoint, 0, estimatedSize)
-	
-	// Filter by time range
-	for _, point := range points {
-		if point.Timestamp >= start && point.Timestamp <= end {
-			result = append(result, point)
+	return hi - lo
+}
+
+// Block returns up to n points of metric in [start, end], skipping the
+// first skip points. With desc set, points are returned newest first and
+// skip counts from end. before is the number of points sharing the first
+// returned point's timestamp that precede it in that order.
+func (mt *MemTable) Block(metric string, start, end int64, skip, n int, desc bool) (block []*DataPoint, before int) {
+	mt.settle(metric)
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	lo, hi := mt.bounds(metric, start, end)
+	if hi-lo <= skip || n <= 0 {
+		return nil, 0
+	}
+	if hi-lo-skip < n {
+		n = hi - lo - skip
+	}
+
+	points := mt.data[metric]
+	block = make([]*DataPoint, n)
+	if desc {
+		first := hi - 1 - skip
+		for i := range block {
+			block[i] = points[first-i]
 		}
+		ts := points[first].Timestamp
+		last := sort.Search(len(points), func(i int) bool { return points[i].Timestamp > ts })
+		before = last - 1 - first
+	} else {
+		first := lo + skip
+		copy(block, points[first:])
+		ts := points[first].Timestamp
+		before = first - sort.Search(len(points), func(i int) bool { return points[i].Timestamp >= ts })
 	}
+	return block, before
+}
 
-	return result
+// settle merges the late points of metric into its sorted points. Late
+// points go after existing points with the same timestamp, in arrival
+// order. The write lock is only taken when there are late points.
+func (mt *MemTable) settle(metric string) {
+	mt.mu.RLock()
+	pending := len(mt.late[metric]) > 0
+	mt.mu.RUnlock()
+	if !pending {
+		return
+	}
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	late := mt.late[metric]
+	if len(late) == 0 {
+		return
+	}
+	sort.SliceStable(late, func(i, j int) bool { return late[i].Timestamp < late[j].Timestamp })
+
+	points := mt.data[metric]
+	merged := make([]*DataPoint, 0, len(points)+len(late))
+	i, j := 0, 0
+	for i < len(points) && j < len(late) {
+		if points[i].Timestamp <= late[j].Timestamp {
+			merged = append(merged, points[i])
+			i++
+		} else {
+			merged = append(merged, late[j])
+			j++
+		}
+	}
+	merged = append(merged, points[i:]...)
+	merged = append(merged, late[j:]...)
+
+	mt.data[metric] = merged
+	delete(mt.late, metric)
+}
+
+// bounds returns the index range of metric's points in [start, end].
+// Callers must hold mt.mu.
+func (mt *MemTable) bounds(metric string, start, end int64) (int, int) {
+	points := mt.data[metric]
+	lo := sort.Search(len(points), func(i int) bool { return points[i].Timestamp >= start })
+	hi := sort.Search(len(points), func(i int) bool { return points[i].Timestamp > end })
+	return lo, hi
+}
+
+// Extent returns the oldest and newest timestamps of metric and its
+// number of points. n is 0 if the memtable has no points of metric.
+func (mt *MemTable) Extent(metric string) (minTime, maxTime int64, n int) {
+	mt.settle(metric)
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	points := mt.data[metric]
+	if len(points) == 0 {
+		return 0, 0, 0
+	}
+	return points[0].Timestamp, points[len(points)-1].Timestamp, len(points)
+}
+
+// Metrics returns the sorted names of all metrics in the memtable
+func (mt *MemTable) Metrics() []string {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	names := make([]string, 0, len(mt.data))
+	for name := range mt.data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
 }
 
 // IsFull returns true if the memtable should be flushed
@@ -76,6 +203,7 @@ func (mt *MemTable) Clear() {
 	defer mt.mu.Unlock()
 	
 	mt.data = make(map[string][]*DataPoint)
+	mt.late = make(map[string][]*DataPoint)
 	mt.size = 0
 }
 
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -245,3 +246,88 @@ func BenchmarkMemTableQuery(b *testing.B) {
 	}
 }
 
+func BenchmarkMemTableBackfill(b *testing.B) {
+	// Each iteration writes 10000 points newest first, as a backfill
+	// behind live data would
+	for i := 0; i < b.N; i++ {
+		mt := NewMemTable(512)
+		mt.Insert(&DataPoint{Metric: "benchmark", Timestamp: 1 << 40})
+		for ts := int64(10000); ts > 0; ts-- {
+			mt.Insert(&DataPoint{Metric: "benchmark", Timestamp: ts * 1000, Value: float64(ts)})
+		}
+		mt.Count("benchmark", 0, 1<<41)
+	}
+}
+
+func TestMemTableMetrics(t *testing.T) {
+	mt := NewMemTable(128)
+
+	mt.Insert(&DataPoint{Metric: "pressure", Timestamp: 1000, Value: 1.0})
+	mt.Insert(&DataPoint{Metric: "humidity", Timestamp: 1000, Value: 2.0})
+	mt.Insert(&DataPoint{Metric: "pressure", Timestamp: 2000, Value: 3.0})
+
+	metrics := mt.Metrics()
+	if len(metrics) != 2 || metrics[0] != "humidity" || metrics[1] != "pressure" {
+		t.Errorf("unexpected metrics: %v", metrics)
+	}
+}
+
+func TestMemTableOutOfOrderInsert(t *testing.T) {
+	mt := NewMemTable(128)
+
+	for _, ts := range []int64{3000, 1000, 2000, 1000, 4000} {
+		mt.Insert(&DataPoint{Metric: "late", Timestamp: ts, Value: float64(ts)})
+	}
+
+	results := mt.Query("late", 0, 5000)
+	want := []int64{1000, 1000, 2000, 3000, 4000}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+	for i, ts := range want {
+		if results[i].Timestamp != ts {
+			t.Errorf("result %d: expected timestamp=%d, got %d", i, ts, results[i].Timestamp)
+		}
+	}
+
+	// Late points merged by a read go after earlier points with the same
+	// timestamp, in arrival order
+	mt.Insert(&DataPoint{Metric: "late", Timestamp: 2000, Value: 1})
+	mt.Insert(&DataPoint{Metric: "late", Timestamp: 2000, Value: 2})
+	if n := mt.Count("late", 2000, 2000); n != 3 {
+		t.Fatalf("expected 3 points at 2000, got %d", n)
+	}
+	mt.Insert(&DataPoint{Metric: "late", Timestamp: 500, Value: 500})
+	results = mt.Query("late", 0, 5000)
+	var got []float64
+	for _, p := range results {
+		got = append(got, p.Value)
+	}
+	if fmt.Sprint(got) != "[500 1000 1000 2000 1 2 3000 4000]" {
+		t.Errorf("unexpected order: %v", got)
+	}
+	if minTime, _, n := mt.Extent("late"); minTime != 500 || n != 8 {
+		t.Errorf("unexpected extent: min=%d n=%d", minTime, n)
+	}
+}
+
+func TestMemTableBlock(t *testing.T) {
+	mt := NewMemTable(128)
+	for _, ts := range []int64{1000, 2000, 2000, 2000, 3000} {
+		mt.Insert(&DataPoint{Metric: "m", Timestamp: ts})
+	}
+
+	block, before := mt.Block("m", 0, 5000, 2, 2, false)
+	if len(block) != 2 || block[0].Timestamp != 2000 || before != 1 {
+		t.Errorf("ascending: unexpected block %v, before=%d", block, before)
+	}
+
+	block, before = mt.Block("m", 0, 5000, 1, 10, true)
+	if len(block) != 4 || block[0].Timestamp != 2000 || block[3].Timestamp != 1000 || before != 0 {
+		t.Errorf("descending: unexpected block %v, before=%d", block, before)
+	}
+
+	if block, _ := mt.Block("m", 0, 5000, 5, 10, false); len(block) != 0 {
+		t.Errorf("expected empty block past the end, got %d points", len(block))
+	}
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MetricKind is the type of a metric, which tells which functions make
+// sense on it
+type MetricKind string
+
+const (
+	KindGauge     MetricKind = "gauge"
+	KindCounter   MetricKind = "counter"
+	KindHistogram MetricKind = "histogram"
+	KindSummary   MetricKind = "summary"
+)
+
+// ParseMetricKind validates a kind name. An empty name is allowed and
+// means the kind is unknown.
+func ParseMetricKind(s string) (MetricKind, error) {
+	switch k := MetricKind(s); k {
+	case "", KindGauge, KindCounter, KindHistogram, KindSummary:
+		return k, nil
+	}
+	return "", fmt.Errorf("invalid kind %q: must be gauge, counter, histogram or summary", s)
+}
+
+// MetricMetadata describes a metric
+type MetricMetadata struct {
+	Kind MetricKind `json:"kind,omitempty"`
+	Unit string     `json:"unit,omitempty"`
+	Help string     `json:"help,omitempty"`
+}
+
+// metadataFile is the registry file in the data directory
+const metadataFile = "metadata.json"
+
+// metadataRegistry holds the metadata of each metric. Every change is
+// written to its file, so the registry survives restarts and flushes.
+type metadataRegistry struct {
+	mu      sync.RWMutex
+	path    string
+	entries map[string]MetricMetadata
+}
+
+// loadMetadataRegistry reads the registry at path. A missing file is an
+// empty registry.
+func loadMetadataRegistry(path string) (*metadataRegistry, error) {
+	r := &metadataRegistry{path: path, entries: make(map[string]MetricMetadata)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &r.entries); err != nil {
+		return nil, fmt.Errorf("invalid metadata file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *metadataRegistry) get(metric string) (MetricMetadata, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	md, ok := r.entries[metric]
+	return md, ok
+}
+
+func (r *metadataRegistry) all() map[string]MetricMetadata {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]MetricMetadata, len(r.entries))
+	for k, v := range r.entries {
+		out[k] = v
+	}
+	return out
+}
+
+func (r *metadataRegistry) set(metric string, md MetricMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, existed := r.entries[metric]
+	r.entries[metric] = md
+	if err := r.save(); err != nil {
+		if existed {
+			r.entries[metric] = prev
+		} else {
+			delete(r.entries, metric)
+		}
+		return err
+	}
+	return nil
+}
+
+// setDefaults adds the metrics of mds that have no metadata yet
+func (r *metadataRegistry) setDefaults(mds map[string]MetricMetadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var added []string
+	for metric, md := range mds {
+		if _, ok := r.entries[metric]; !ok {
+			r.entries[metric] = md
+			added = append(added, metric)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if err := r.save(); err != nil {
+		for _, metric := range added {
+			delete(r.entries, metric)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *metadataRegistry) delete(metric string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	md, ok := r.entries[metric]
+	if !ok {
+		return false, nil
+	}
+	delete(r.entries, metric)
+	if err := r.save(); err != nil {
+		r.entries[metric] = md
+		return false, err
+	}
+	return true, nil
+}
+
+// save writes the registry to a temporary file and renames it over the
+// old one, so a crash never leaves a partial file
+func (r *metadataRegistry) save() error {
+	data, err := json.MarshalIndent(r.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), metadataFile+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// Metadata returns the metadata registered for metric
+func (e *Engine) Metadata(metric string) (MetricMetadata, bool) {
+	return e.metadata.get(metric)
+}
+
+// AllMetadata returns the metadata of every registered metric
+func (e *Engine) AllMetadata() map[string]MetricMetadata {
+	return e.metadata.all()
+}
+
+// SetMetadata registers the metadata of metric, replacing any previous
+// metadata, and persists it
+func (e *Engine) SetMetadata(metric string, md MetricMetadata) error {
+	if _, err := ParseMetricKind(string(md.Kind)); err != nil {
+		return err
+	}
+	return e.metadata.set(metric, md)
+}
+
+// InferMetadata registers metadata reported by an ingestion protocol.
+// Metrics that already have metadata keep it.
+func (e *Engine) InferMetadata(mds map[string]MetricMetadata) error {
+	return e.metadata.setDefaults(mds)
+}
+
+// DeleteMetadata removes the metadata of metric and reports whether it
+// existed
+func (e *Engine) DeleteMetadata(metric string) (bool, error) {
+	return e.metadata.delete(metric)
+}
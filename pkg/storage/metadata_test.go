@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func TestMetadataPersisted(t *testing.T) {
+	cfg := &config.StorageConfig{
+		DataDir:     "./test_data_metadata",
+		MaxMemoryMB: 128,
+	}
+	defer os.RemoveAll(cfg.DataDir)
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	cpu := MetricMetadata{Kind: KindGauge, Unit: "percent", Help: "CPU usage"}
+	if err := engine.SetMetadata("cpu", cpu); err != nil {
+		t.Fatalf("SetMetadata failed: %v", err)
+	}
+	if err := engine.SetMetadata("bad", MetricMetadata{Kind: "timer"}); err == nil {
+		t.Error("expected error for invalid kind")
+	}
+	engine.SetMetadata("tmp", MetricMetadata{Kind: KindCounter})
+	if found, err := engine.DeleteMetadata("tmp"); !found || err != nil {
+		t.Errorf("expected tmp to be deleted, got %v %v", found, err)
+	}
+	engine.Close()
+
+	engine, err = NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	defer engine.Close()
+
+	if md, ok := engine.Metadata("cpu"); !ok || md != cpu {
+		t.Errorf("expected %+v after restart, got %+v", cpu, md)
+	}
+	if all := engine.AllMetadata(); len(all) != 1 {
+		t.Errorf("expected 1 registered metric, got %v", all)
+	}
+}
+
+func TestInferMetadataKeepsRegistered(t *testing.T) {
+	engine := newSelectTestEngine(t)
+
+	engine.SetMetadata("cpu", MetricMetadata{Kind: KindGauge, Unit: "percent"})
+	err := engine.InferMetadata(map[string]MetricMetadata{
+		"cpu":      {Kind: KindCounter},
+		"requests": {Kind: KindCounter, Unit: "1"},
+	})
+	if err != nil {
+		t.Fatalf("InferMetadata failed: %v", err)
+	}
+
+	if md, _ := engine.Metadata("cpu"); md.Kind != KindGauge || md.Unit != "percent" {
+		t.Errorf("expected registered cpu metadata to be kept, got %+v", md)
+	}
+	if md, ok := engine.Metadata("requests"); !ok || md.Kind != KindCounter {
+		t.Errorf("expected inferred requests metadata, got %+v", md)
+	}
+}
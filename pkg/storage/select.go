@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"container/heap"
+	"context"
+)
+
+// QuerySpec selects points for Engine.Select
+type QuerySpec struct {
+	Metric   string     // optional when Matchers select __name__
+	Matchers []*Matcher // all must match; see MatchPoint
+	Start    int64      // inclusive, milliseconds
+	End      int64      // inclusive, milliseconds
+	Order    Order
+	Budget   *QueryBudget // optional; charged for every point returned
+	Stats    *QueryStats  // optional; records sources and points read
+}
+
+// SeriesIterator returns the points of the selected series merged in
+// timestamp order. Points with equal timestamps come in metric order.
+type SeriesIterator interface {
+	// Next advances to the next point. It returns false when the points
+	// are exhausted, the query was cancelled or the budget ran out; see Err.
+	Next() bool
+	// At returns the current point
+	At() *DataPoint
+	// Err returns the context or budget error that stopped the iteration
+	Err() error
+	// Close releases the iterator. Callers may stop early by closing it.
+	Close()
+}
+
+// Select returns a lazy iterator over the points matching spec. Sources
+// are read in blocks and ctx is checked before each block, so a
+// cancelled query stops without scanning the rest of the range.
+func (e *Engine) Select(ctx context.Context, spec QuerySpec) (SeriesIterator, error) {
+	matchers := spec.Matchers
+	if spec.Metric != "" {
+		m, err := NewMatcher(MatchEqual, MetricNameLabel, spec.Metric)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append([]*Matcher{m}, matchers...)
+	}
+
+	opts := IteratorOptions{Start: spec.Start, End: spec.End, Order: spec.Order, Stats: spec.Stats}
+	it := &mergeIterator{ctx: ctx, matchers: matchers, budget: spec.Budget, stats: spec.Stats, desc: spec.Order == Descending}
+
+	// One source per metric. TODO: add SSTable sources once they exist.
+	for _, metric := range e.selectSources(matchers, spec) {
+		src := e.iterator(ctx, metric, opts)
+		if src.Next() {
+			it.heap = append(it.heap, src)
+		} else if err := src.Err(); err != nil {
+			return nil, err
+		}
+	}
+	heap.Init(it)
+
+	return it, nil
+}
+
+// selectSources returns the metrics whose memtable source may hold
+// points for spec. Sources without points in the time range are pruned,
+// and so are those without matching series when there are tag matchers.
+func (e *Engine) selectSources(matchers []*Matcher, spec QuerySpec) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var seriesCounts map[string]int
+	if hasTagMatchers(matchers) {
+		seriesCounts = e.index.countByMetric(matchers, spec.Start, spec.End)
+		if spec.Stats != nil {
+			lookup := IndexLookup{}
+			for _, m := range matchers {
+				lookup.Matchers = append(lookup.Matchers, m.String())
+			}
+			for _, n := range seriesCounts {
+				lookup.Series += n
+			}
+			spec.Stats.addLookup(lookup)
+		}
+	}
+
+	var selected []string
+	for _, metric := range e.selectMetrics(matchers) {
+		src := e.memtableSource(metric, spec.Start, spec.End, seriesCounts)
+		spec.Stats.addSource(src)
+		if src.Selected {
+			selected = append(selected, metric)
+		}
+	}
+	return selected
+}
+
+// hasTagMatchers reports whether any matcher applies to a tag
+func hasTagMatchers(matchers []*Matcher) bool {
+	for _, m := range matchers {
+		if m.Name != MetricNameLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// selectMetrics resolves the metric names selected by the __name__
+// matchers. An equality matcher avoids listing every metric. Callers
+// must hold e.mu.
+func (e *Engine) selectMetrics(matchers []*Matcher) []string {
+	var nameMatchers []*Matcher
+	for _, m := range matchers {
+		if m.Name != MetricNameLabel {
+			continue
+		}
+		if m.Type == MatchEqual {
+			return []string{m.Value}
+		}
+		nameMatchers = append(nameMatchers, m)
+	}
+
+	var names []string
+	for _, name := range e.memTable.Metrics() {
+		matched := true
+		for _, m := range nameMatchers {
+			if !m.Matches(name) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// mergeIterator merges sources positioned on their next point. It is a
+// heap ordered by the sources' current timestamps.
+type mergeIterator struct {
+	ctx      context.Context
+	matchers []*Matcher
+	budget   *QueryBudget
+	stats    *QueryStats
+	desc     bool
+
+	heap []*PointIterator
+	cur  *DataPoint
+	err  error
+	// started is set once the first point has been taken from the heap
+	started bool
+}
+
+func (it *mergeIterator) Next() bool {
+	for it.advance() {
+		if !MatchPoint(it.cur, it.matchers) {
+			continue
+		}
+		if it.budget != nil {
+			if err := it.budget.Charge(it.cur); err != nil {
+				it.err, it.cur = err, nil
+				return false
+			}
+		}
+		it.stats.AddReturned(1)
+		return true
+	}
+	return false
+}
+
+// advance moves to the next point of any source
+func (it *mergeIterator) advance() bool {
+	if it.err != nil {
+		return false
+	}
+
+	// Move the source of the previous point past it
+	if it.started && len(it.heap) > 0 {
+		src := it.heap[0]
+		if src.Next() {
+			heap.Fix(it, 0)
+		} else {
+			if err := src.Err(); err != nil {
+				it.err, it.cur = err, nil
+				return false
+			}
+			heap.Pop(it)
+		}
+	}
+	it.started = true
+
+	if len(it.heap) == 0 {
+		it.cur = nil
+		return false
+	}
+	it.cur = it.heap[0].At()
+	return true
+}
+
+func (it *mergeIterator) At() *DataPoint {
+	return it.cur
+}
+
+func (it *mergeIterator) Err() error {
+	return it.err
+}
+
+func (it *mergeIterator) Close() {
+	it.heap, it.cur = nil, nil
+}
+
+// heap.Interface
+
+func (it *mergeIterator) Len() int { return len(it.heap) }
+
+func (it *mergeIterator) Less(i, j int) bool {
+	a, b := it.heap[i].At(), it.heap[j].At()
+	if a.Timestamp != b.Timestamp {
+		if it.desc {
+			return a.Timestamp > b.Timestamp
+		}
+		return a.Timestamp < b.Timestamp
+	}
+	return a.Metric < b.Metric
+}
+
+func (it *mergeIterator) Swap(i, j int) { it.heap[i], it.heap[j] = it.heap[j], it.heap[i] }
+
+func (it *mergeIterator) Push(x interface{}) { it.heap = append(it.heap, x.(*PointIterator)) }
+
+func (it *mergeIterator) Pop() interface{} {
+	n := len(it.heap)
+	src := it.heap[n-1]
+	it.heap = it.heap[:n-1]
+	return src
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func newSelectTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	cfg := &config.StorageConfig{
+		DataDir:     "./test_data_select",
+		MaxMemoryMB: 128,
+	}
+	t.Cleanup(func() { os.RemoveAll(cfg.DataDir) })
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	t.Cleanup(func() { engine.Close() })
+
+	engine.WriteBatch([]*DataPoint{
+		{Metric: "disk_read", Timestamp: 3000, Value: 3, Tags: map[string]string{"host": "a"}},
+		{Metric: "disk_write", Timestamp: 1000, Value: 1, Tags: map[string]string{"host": "a"}},
+		{Metric: "disk_read", Timestamp: 2000, Value: 2, Tags: map[string]string{"host": "b"}},
+		{Metric: "disk_write", Timestamp: 4000, Value: 4, Tags: map[string]string{"host": "b"}},
+		{Metric: "cpu", Timestamp: 2500, Value: 99, Tags: map[string]string{"host": "a"}},
+	})
+	return engine
+}
+
+func selectValues(t *testing.T, engine *Engine, spec QuerySpec) []float64 {
+	t.Helper()
+	it, err := engine.Select(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	defer it.Close()
+
+	var values []float64
+	for it.Next() {
+		values = append(values, it.At().Value)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	return values
+}
+
+func TestSelectMergesMetrics(t *testing.T) {
+	engine := newSelectTestEngine(t)
+	disk, _ := NewMatcher(MatchRegexp, MetricNameLabel, "disk_.*")
+	hostA, _ := NewMatcher(MatchEqual, "host", "a")
+
+	tests := []struct {
+		name string
+		spec QuerySpec
+		want string
+	}{
+		{"merged ascending", QuerySpec{Matchers: []*Matcher{disk}, Start: 0, End: 5000}, "[1 2 3 4]"},
+		{"merged descending", QuerySpec{Matchers: []*Matcher{disk}, Start: 0, End: 5000, Order: Descending}, "[4 3 2 1]"},
+		{"tag filter", QuerySpec{Matchers: []*Matcher{disk, hostA}, Start: 0, End: 5000}, "[1 3]"},
+		{"metric and range", QuerySpec{Metric: "disk_read", Start: 2500, End: 5000}, "[3]"},
+		{"unknown metric", QuerySpec{Metric: "missing", Start: 0, End: 5000}, "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fmt.Sprint(selectValues(t, engine, tt.spec)); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectCancellation(t *testing.T) {
+	engine := newSelectTestEngine(t)
+	for i := 0; i < iteratorBlockSize*3; i++ {
+		engine.Write(&DataPoint{Metric: "big", Timestamp: int64(i)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	it, err := engine.Select(ctx, QuerySpec{Metric: "big", Start: 0, End: int64(iteratorBlockSize * 3)})
+	if err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	defer it.Close()
+
+	// The first block is already read; cancellation stops before the next
+	n := 0
+	for it.Next() {
+		n++
+		if n == 10 {
+			cancel()
+		}
+	}
+
+	if n != iteratorBlockSize {
+		t.Errorf("expected iteration to stop after %d points, got %d", iteratorBlockSize, n)
+	}
+	if it.Err() != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", it.Err())
+	}
+}
+
+func TestSelectCancelledBeforeStart(t *testing.T) {
+	engine := newSelectTestEngine(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := engine.Select(ctx, QuerySpec{Metric: "cpu", Start: 0, End: 5000}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import "sync"
+
+// SourceKindMemtable is the kind of a memtable source. Each metric's
+// points in the memtable form one source.
+const SourceKindMemtable = "memtable"
+
+// QueryStats records how queries read the engine, to explain them. Pass
+// it in QuerySpec.Stats or IteratorOptions.Stats; the selects of one
+// query may share it. It is safe for concurrent use.
+type QueryStats struct {
+	mu sync.Mutex
+
+	Sources        []SourceStats `json:"sources"`
+	IndexLookups   []IndexLookup `json:"index_lookups"`
+	BlocksRead     int64         `json:"blocks_read"`
+	PointsScanned  int64         `json:"points_scanned"`  // read from sources
+	PointsReturned int64         `json:"points_returned"` // after matchers
+}
+
+// SourceStats describes a source a query considered
+type SourceStats struct {
+	Kind     string `json:"kind"`
+	Metric   string `json:"metric"`
+	MinTime  int64  `json:"min_time"`
+	MaxTime  int64  `json:"max_time"`
+	Points   int    `json:"points"`
+	Selected bool   `json:"selected"`
+	Reason   string `json:"reason,omitempty"` // why a source was pruned
+}
+
+// IndexLookup is a series index lookup made to prune sources
+type IndexLookup struct {
+	Matchers []string `json:"matchers"`
+	Series   int      `json:"series"` // matching series in the time range
+}
+
+func (s *QueryStats) addSource(src SourceStats) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Sources = append(s.Sources, src)
+}
+
+func (s *QueryStats) addLookup(lookup IndexLookup) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.IndexLookups = append(s.IndexLookups, lookup)
+}
+
+func (s *QueryStats) addBlock(points int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.BlocksRead++
+	s.PointsScanned += int64(points)
+}
+
+// AddReturned counts n points returned to the caller. Select counts its
+// points itself; callers of Iterator count theirs.
+func (s *QueryStats) AddReturned(n int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.PointsReturned += int64(n)
+}
+
+// Snapshot returns a copy of the stats that is safe to read
+func (s *QueryStats) Snapshot() *QueryStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &QueryStats{
+		Sources:        append([]SourceStats{}, s.Sources...),
+		IndexLookups:   append([]IndexLookup{}, s.IndexLookups...),
+		BlocksRead:     s.BlocksRead,
+		PointsScanned:  s.PointsScanned,
+		PointsReturned: s.PointsReturned,
+	}
+}
+
+// memtableSource describes the memtable source of metric for a query
+// over [start, end]. seriesCounts holds the matching series per metric
+// from an index lookup, or is nil if none was made. Callers must hold
+// e.mu.
+func (e *Engine) memtableSource(metric string, start, end int64, seriesCounts map[string]int) SourceStats {
+	src := SourceStats{Kind: SourceKindMemtable, Metric: metric}
+	src.MinTime, src.MaxTime, src.Points = e.memTable.Extent(metric)
+
+	switch {
+	case src.Points == 0:
+		src.Reason = "no points"
+	case src.MaxTime < start || src.MinTime > end:
+		src.Reason = "outside time range"
+	case seriesCounts != nil && seriesCounts[metric] == 0:
+		src.Reason = "no matching series"
+	default:
+		src.Selected = true
+	}
+	return src
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSelectStats(t *testing.T) {
+	engine := newSelectTestEngine(t)
+
+	nameRe, _ := NewMatcher(MatchRegexp, MetricNameLabel, "disk_.*")
+	hostB, _ := NewMatcher(MatchEqual, "host", "b")
+	stats := &QueryStats{}
+	values := selectValues(t, engine, QuerySpec{
+		Matchers: []*Matcher{nameRe, hostB},
+		Start:    1500,
+		End:      3500,
+		Stats:    stats,
+	})
+	if len(values) != 1 || values[0] != 2 {
+		t.Fatalf("expected [2], got %v", values)
+	}
+
+	// disk_write's only host b point is outside the range
+	if len(stats.IndexLookups) != 1 || stats.IndexLookups[0].Series != 1 {
+		t.Errorf("unexpected index lookups %+v", stats.IndexLookups)
+	}
+	want := map[string]string{"disk_read": "", "disk_write": "no matching series"}
+	if len(stats.Sources) != len(want) {
+		t.Fatalf("expected %d sources, got %+v", len(want), stats.Sources)
+	}
+	for _, src := range stats.Sources {
+		reason, ok := want[src.Metric]
+		if !ok || src.Reason != reason || src.Selected != (reason == "") || src.Kind != SourceKindMemtable {
+			t.Errorf("unexpected source %+v", src)
+		}
+	}
+
+	// disk_read has two points in range; host a's is dropped by the matchers
+	if stats.BlocksRead != 1 || stats.PointsScanned != 2 || stats.PointsReturned != 1 {
+		t.Errorf("unexpected counters %+v", stats)
+	}
+}
+
+func TestSelectStatsTimePruning(t *testing.T) {
+	engine := newSelectTestEngine(t)
+
+	stats := &QueryStats{}
+	selectValues(t, engine, QuerySpec{Metric: "cpu", Start: 3000, End: 5000, Stats: stats})
+	selectValues(t, engine, QuerySpec{Metric: "mem", Start: 0, End: 5000, Stats: stats})
+
+	if len(stats.Sources) != 2 || stats.Sources[0].Reason != "outside time range" || stats.Sources[1].Reason != "no points" {
+		t.Errorf("unexpected sources %+v", stats.Sources)
+	}
+	if len(stats.IndexLookups) != 0 || stats.BlocksRead != 0 {
+		t.Errorf("expected no lookups or blocks, got %+v", stats)
+	}
+}
+
+func TestIteratorStats(t *testing.T) {
+	engine := newSelectTestEngine(t)
+
+	stats := &QueryStats{}
+	it := engine.Iterator(context.Background(), "disk_write", IteratorOptions{Start: 0, End: 5000, Stats: stats})
+	for it.Next() {
+	}
+
+	snap := stats.Snapshot()
+	if len(snap.Sources) != 1 || !snap.Sources[0].Selected || snap.Sources[0].MinTime != 1000 || snap.Sources[0].MaxTime != 4000 {
+		t.Errorf("unexpected sources %+v", snap.Sources)
+	}
+	if snap.BlocksRead != 1 || snap.PointsScanned != 2 || snap.PointsReturned != 0 {
+		t.Errorf("unexpected counters %+v", snap)
+	}
+}
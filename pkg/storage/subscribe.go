@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSlowConsumer ends a subscription that fell behind under the
+// Disconnect policy
+var ErrSlowConsumer = errors.New("subscriber too slow, disconnected")
+
+// ErrSubscriptionClosed ends subscriptions when the engine is closed
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
+// SlowConsumerPolicy decides what happens when a subscriber's buffer is full
+type SlowConsumerPolicy int
+
+const (
+	// DropPoints skips points the subscriber has no room for
+	DropPoints SlowConsumerPolicy = iota
+	// Disconnect ends the subscription with ErrSlowConsumer
+	Disconnect
+)
+
+// DefaultSubscriptionBuffer is used when SubscribeOptions.BufferSize is 0
+const DefaultSubscriptionBuffer = 1000
+
+// SubscribeOptions selects the points a subscription receives
+type SubscribeOptions struct {
+	Matchers   []*Matcher // all must match; see MatchPoint
+	BufferSize int
+	Policy     SlowConsumerPolicy
+}
+
+// Subscription receives points as they are written. Delivery never
+// blocks a writer: when C is full, the point is dropped or the
+// subscription is ended, depending on the policy.
+type Subscription struct {
+	C <-chan *DataPoint
+
+	ch       chan *DataPoint
+	matchers []*Matcher
+	policy   SlowConsumerPolicy
+	hub      *subscriptionHub
+
+	dropped int64 // accessed via atomic
+	done    chan struct{}
+	err     error // set before done is closed
+}
+
+// Dropped returns the number of points skipped because C was full
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Done is closed when the subscription ends. C is closed at the same time.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns why the subscription ended, or nil if it is active or was
+// closed by the subscriber
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.remove(s, nil)
+}
+
+// subscriptionHub fans written points out to subscriptions
+type subscriptionHub struct {
+	mu    sync.Mutex
+	subs  map[*Subscription]struct{}
+	count int32 // len(subs), read without the lock on the write path
+}
+
+func newSubscriptionHub() *subscriptionHub {
+	return &subscriptionHub{subs: make(map[*Subscription]struct{})}
+}
+
+func (h *subscriptionHub) add(opts SubscribeOptions) *Subscription {
+	size := opts.BufferSize
+	if size <= 0 {
+		size = DefaultSubscriptionBuffer
+	}
+
+	ch := make(chan *DataPoint, size)
+	s := &Subscription{
+		C:        ch,
+		ch:       ch,
+		matchers: opts.Matchers,
+		policy:   opts.Policy,
+		hub:      h,
+		done:     make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	atomic.StoreInt32(&h.count, int32(len(h.subs)))
+	h.mu.Unlock()
+
+	return s
+}
+
+// remove ends s with err. It is a no-op if s has already ended.
+func (h *subscriptionHub) remove(s *Subscription, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(s, err)
+}
+
+func (h *subscriptionHub) removeLocked(s *Subscription, err error) {
+	if _, ok := h.subs[s]; !ok {
+		return
+	}
+	delete(h.subs, s)
+	atomic.StoreInt32(&h.count, int32(len(h.subs)))
+
+	s.err = err
+	close(s.ch)
+	close(s.done)
+}
+
+// publish delivers point to every matching subscription without blocking
+func (h *subscriptionHub) publish(point *DataPoint) {
+	if atomic.LoadInt32(&h.count) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		if !MatchPoint(point, s.matchers) {
+			continue
+		}
+
+		select {
+		case s.ch <- point:
+		default:
+			if s.policy == Disconnect {
+				h.removeLocked(s, ErrSlowConsumer)
+			} else {
+				atomic.AddInt64(&s.dropped, 1)
+			}
+		}
+	}
+}
+
+func (h *subscriptionHub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		h.removeLocked(s, ErrSubscriptionClosed)
+	}
+}
+
+// len returns the number of active subscriptions
+func (h *subscriptionHub) len() int {
+	return int(atomic.LoadInt32(&h.count))
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Pablo997/pulsardb/internal/config"
+)
+
+func newSubscribeTestEngine(t *testing.T) *Engine {
+	t.Helper()
+
+	cfg := &config.StorageConfig{
+		DataDir:     "./test_data_subscribe",
+		MaxMemoryMB: 128,
+	}
+	t.Cleanup(func() { os.RemoveAll(cfg.DataDir) })
+
+	engine, err := NewEngine(cfg)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	return engine
+}
+
+func TestSubscribeMatchingPoints(t *testing.T) {
+	engine := newSubscribeTestEngine(t)
+	defer engine.Close()
+
+	nameMatcher, _ := NewMatcher(MatchEqual, MetricNameLabel, "cpu")
+	hostMatcher, _ := NewMatcher(MatchEqual, "host", "a")
+	sub := engine.Subscribe(SubscribeOptions{Matchers: []*Matcher{nameMatcher, hostMatcher}})
+	defer sub.Close()
+
+	engine.Write(&DataPoint{Metric: "cpu", Timestamp: 1, Value: 1, Tags: map[string]string{"host": "a"}})
+	engine.Write(&DataPoint{Metric: "cpu", Timestamp: 2, Value: 2, Tags: map[string]string{"host": "b"}})
+	engine.WriteBatch([]*DataPoint{
+		{Metric: "memory", Timestamp: 3, Value: 3, Tags: map[string]string{"host": "a"}},
+		{Metric: "cpu", Timestamp: 4, Value: 4, Tags: map[string]string{"host": "a"}},
+	})
+
+	if len(sub.C) != 2 {
+		t.Fatalf("expected 2 buffered points, got %d", len(sub.C))
+	}
+	if p := <-sub.C; p.Timestamp != 1 {
+		t.Errorf("expected first point ts=1, got %d", p.Timestamp)
+	}
+	if p := <-sub.C; p.Timestamp != 4 {
+		t.Errorf("expected second point ts=4, got %d", p.Timestamp)
+	}
+}
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	engine := newSubscribeTestEngine(t)
+	defer engine.Close()
+
+	dropper := engine.Subscribe(SubscribeOptions{BufferSize: 2, Policy: DropPoints})
+	defer dropper.Close()
+	disconnected := engine.Subscribe(SubscribeOptions{BufferSize: 2, Policy: Disconnect})
+
+	for i := 0; i < 5; i++ {
+		if err := engine.Write(&DataPoint{Metric: "cpu", Timestamp: int64(i), Value: 1}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if dropper.Dropped() != 3 || len(dropper.C) != 2 {
+		t.Errorf("expected 3 dropped and 2 buffered, got %d and %d", dropper.Dropped(), len(dropper.C))
+	}
+
+	select {
+	case <-disconnected.Done():
+	default:
+		t.Fatal("expected slow subscriber to be disconnected")
+	}
+	if disconnected.Err() != ErrSlowConsumer {
+		t.Errorf("expected ErrSlowConsumer, got %v", disconnected.Err())
+	}
+
+	// Buffered points can still be drained before the closed channel
+	n := 0
+	for range disconnected.C {
+		n++
+	}
+	if n != 2 {
+		t.Errorf("expected 2 buffered points, got %d", n)
+	}
+
+	if engine.Subscriptions() != 1 {
+		t.Errorf("expected 1 active subscription, got %d", engine.Subscriptions())
+	}
+}
+
+func TestSubscribeClose(t *testing.T) {
+	engine := newSubscribeTestEngine(t)
+
+	sub := engine.Subscribe(SubscribeOptions{})
+	other := engine.Subscribe(SubscribeOptions{})
+
+	sub.Close()
+	sub.Close() // closing twice is a no-op
+	if sub.Err() != nil {
+		t.Errorf("expected nil error after Close, got %v", sub.Err())
+	}
+
+	engine.Close()
+	if other.Err() != ErrSubscriptionClosed {
+		t.Errorf("expected ErrSubscriptionClosed, got %v", other.Err())
+	}
+	if engine.Subscriptions() != 0 {
+		t.Errorf("expected no subscriptions, got %d", engine.Subscriptions())
+	}
+}